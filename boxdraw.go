@@ -0,0 +1,169 @@
+package headlessterm
+
+import "math"
+
+// glyphRect is a filled rectangle in pixels relative to the top-left of a cell.
+// Alpha is the coverage (1 for solid, lower for shade characters).
+type glyphRect struct {
+	X, Y, W, H float64
+	Alpha      float64
+}
+
+// boxLineWeights lists the line weights of U+2500..U+257F as up, right, down, left
+// (0 none, 1 light, 2 heavy, 3 double). "-" marks characters without a line description.
+// Dashed variants are drawn solid and arcs as square corners.
+var boxLineWeights = [...]string{
+	"0101", "0202", "1010", "2020", "0101", "0202", "1010", "2020", // 2500
+	"0101", "0202", "1010", "2020", "0110", "0210", "0120", "0220", // 2508
+	"0011", "0012", "0021", "0022", "1100", "1200", "2100", "2200", // 2510
+	"1001", "1002", "2001", "2002", "1110", "1210", "2110", "1120", // 2518
+	"2120", "2210", "1220", "2220", "1011", "1012", "2011", "1021", // 2520
+	"2021", "2012", "1022", "2022", "0111", "0112", "0211", "0212", // 2528
+	"0121", "0122", "0221", "0222", "1101", "1102", "1201", "1202", // 2530
+	"2101", "2102", "2201", "2202", "1111", "1112", "1211", "1212", // 2538
+	"2111", "1121", "2121", "2112", "2211", "1122", "1221", "2212", // 2540
+	"1222", "2122", "2221", "2222", "0101", "0202", "1010", "2020", // 2548
+	"0303", "3030", "0310", "0130", "0330", "0013", "0031", "0033", // 2550
+	"1300", "3100", "3300", "1003", "3001", "3003", "1310", "3130", // 2558
+	"3330", "1013", "3031", "3033", "0313", "0131", "0333", "1303", // 2560
+	"3101", "3303", "1313", "3131", "3333", "0110", "0011", "1001", // 2568
+	"1100", "-", "-", "-", "0001", "1000", "0100", "0010", // 2570
+	"0002", "2000", "0200", "0020", "0201", "1020", "0102", "2010", // 2578
+}
+
+// isProceduralGlyph reports whether r is drawn as geometry instead of a font glyph.
+func isProceduralGlyph(r rune) bool {
+	switch {
+	case r >= 0x2500 && r <= 0x257F:
+		return boxLineWeights[r-0x2500] != "-"
+	case r >= 0x2580 && r <= 0x259F:
+		return true
+	}
+	return false
+}
+
+// boxGlyphRects returns the rectangles that draw a box-drawing or block element
+// character in a cell of w x h pixels. Returns nil for other characters.
+func boxGlyphRects(r rune, w, h float64) []glyphRect {
+	switch {
+	case r >= 0x2500 && r <= 0x257F:
+		return boxLineRects(boxLineWeights[r-0x2500], w, h)
+	case r >= 0x2580 && r <= 0x259F:
+		return blockRects(r, w, h)
+	}
+	return nil
+}
+
+// boxLineRects draws the four arms of a box-drawing character.
+func boxLineRects(weights string, w, h float64) []glyphRect {
+	if len(weights) != 4 {
+		return nil
+	}
+
+	light := math.Max(1, math.Round(w/8))
+	cx, cy := math.Round(w/2), math.Round(h/2)
+	var rects []glyphRect
+
+	// Arms are extended past the center so perpendicular arms join cleanly
+	arm := func(dir int, weight byte) {
+		var thick float64
+		switch weight {
+		case '1':
+			thick = light
+		case '2':
+			thick = light * 2
+		case '3':
+			thick = light * 3
+		default:
+			return
+		}
+		var offsets []float64
+		if weight == '3' {
+			offsets = []float64{-thick / 2, thick/2 - light}
+			thick = light
+		} else {
+			offsets = []float64{-thick / 2}
+		}
+		reach := light * 1.5
+		for _, off := range offsets {
+			switch dir {
+			case 0: // up
+				rects = append(rects, glyphRect{X: cx + off, Y: 0, W: thick, H: cy + reach, Alpha: 1})
+			case 1: // right
+				rects = append(rects, glyphRect{X: cx - reach, Y: cy + off, W: w - cx + reach, H: thick, Alpha: 1})
+			case 2: // down
+				rects = append(rects, glyphRect{X: cx + off, Y: cy - reach, W: thick, H: h - cy + reach, Alpha: 1})
+			case 3: // left
+				rects = append(rects, glyphRect{X: 0, Y: cy + off, W: cx + reach, H: thick, Alpha: 1})
+			}
+		}
+	}
+	for dir := 0; dir < 4; dir++ {
+		arm(dir, weights[dir])
+	}
+	return rects
+}
+
+// blockRects draws block elements (U+2580..U+259F) as fractions of the cell.
+func blockRects(r rune, w, h float64) []glyphRect {
+	frac := func(x0, y0, x1, y1, alpha float64) glyphRect {
+		return glyphRect{X: x0 * w, Y: y0 * h, W: (x1 - x0) * w, H: (y1 - y0) * h, Alpha: alpha}
+	}
+	// Quadrants: upper left, upper right, lower left, lower right
+	quads := func(ul, ur, ll, lr bool) []glyphRect {
+		var rects []glyphRect
+		if ul {
+			rects = append(rects, frac(0, 0, 0.5, 0.5, 1))
+		}
+		if ur {
+			rects = append(rects, frac(0.5, 0, 1, 0.5, 1))
+		}
+		if ll {
+			rects = append(rects, frac(0, 0.5, 0.5, 1, 1))
+		}
+		if lr {
+			rects = append(rects, frac(0.5, 0.5, 1, 1, 1))
+		}
+		return rects
+	}
+
+	switch {
+	case r == 0x2580:
+		return []glyphRect{frac(0, 0, 1, 0.5, 1)}
+	case r >= 0x2581 && r <= 0x2588:
+		n := float64(r-0x2580) / 8
+		return []glyphRect{frac(0, 1-n, 1, 1, 1)}
+	case r >= 0x2589 && r <= 0x258F:
+		n := float64(8-(r-0x2588)) / 8
+		return []glyphRect{frac(0, 0, n, 1, 1)}
+	case r == 0x2590:
+		return []glyphRect{frac(0.5, 0, 1, 1, 1)}
+	case r >= 0x2591 && r <= 0x2593:
+		return []glyphRect{frac(0, 0, 1, 1, float64(r-0x2590)/4)}
+	case r == 0x2594:
+		return []glyphRect{frac(0, 0, 1, 0.125, 1)}
+	case r == 0x2595:
+		return []glyphRect{frac(0.875, 0, 1, 1, 1)}
+	case r == 0x2596:
+		return quads(false, false, true, false)
+	case r == 0x2597:
+		return quads(false, false, false, true)
+	case r == 0x2598:
+		return quads(true, false, false, false)
+	case r == 0x2599:
+		return quads(true, false, true, true)
+	case r == 0x259A:
+		return quads(true, false, false, true)
+	case r == 0x259B:
+		return quads(true, true, true, false)
+	case r == 0x259C:
+		return quads(true, true, false, true)
+	case r == 0x259D:
+		return quads(false, true, false, false)
+	case r == 0x259E:
+		return quads(false, true, true, false)
+	case r == 0x259F:
+		return quads(false, true, true, true)
+	}
+	return nil
+}
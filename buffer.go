@@ -10,6 +10,22 @@ type Buffer struct {
 	tabStop    []bool
 	scrollback ScrollbackProvider
 	hasDirty   bool
+	pushed     uint64 // Total lines pushed to scrollback
+	evicted    uint64 // Total lines dropped from the front of scrollback, or scrolled off without it
+
+	// Wrap flags of the newest scrollback lines, aligned with the end of
+	// scrollback (lines pushed by other code have no entry and count as unwrapped)
+	scrollbackWrapped []bool
+
+	// Change tracking: generation at which each row last changed
+	clock   *generationClock
+	lineGen []Generation
+
+	// Damage history: changed column spans per row, moved along with rows,
+	// and recent scrolls. Queries older than damageFloor get full damage.
+	spans       [][]damageSpan
+	scrolls     []scrollRecord
+	damageFloor Generation
 }
 
 // NewBuffer creates a buffer with the given dimensions and no scrollback.
@@ -27,6 +43,8 @@ func NewBufferWithStorage(rows, cols int, storage ScrollbackProvider) *Buffer {
 		wrapped:    make([]bool, rows),
 		tabStop:    make([]bool, cols),
 		scrollback: storage,
+		lineGen:    make([]Generation, rows),
+		spans:      make([][]damageSpan, rows),
 	}
 
 	for i := range b.cells {
@@ -72,6 +90,7 @@ func (b *Buffer) SetCell(row, col int, cell Cell) {
 	cell.MarkDirty()
 	b.cells[row][col] = cell
 	b.hasDirty = true
+	b.touchCols(row, col, col+1)
 }
 
 // MarkDirty marks the cell at (row, col) as modified.
@@ -82,6 +101,7 @@ func (b *Buffer) MarkDirty(row, col int) {
 	}
 	b.cells[row][col].MarkDirty()
 	b.hasDirty = true
+	b.touchCols(row, col, col+1)
 }
 
 // HasDirty returns true if any cell has been modified since the last ClearAllDirty call.
@@ -122,6 +142,7 @@ func (b *Buffer) ClearRow(row int) {
 		b.cells[row][col].MarkDirty()
 	}
 	b.hasDirty = true
+	b.touch(row)
 }
 
 // ClearRowRange resets cells in the row from startCol (inclusive) to endCol (exclusive).
@@ -140,6 +161,7 @@ func (b *Buffer) ClearRowRange(row, startCol, endCol int) {
 		b.cells[row][col].MarkDirty()
 	}
 	b.hasDirty = true
+	b.touchCols(row, startCol, endCol)
 }
 
 // ClearAll resets all cells in the buffer to default state.
@@ -169,15 +191,31 @@ func (b *Buffer) ScrollUp(top, bottom, n int) {
 
 	// Save lines to scrollback if enabled and scrolling from top
 	if b.scrollback != nil && b.scrollback.MaxLines() > 0 && top == 0 {
+		indexed, _ := b.scrollback.(*IndexedScrollback)
+		before := b.scrollback.Len()
 		for i := 0; i < n; i++ {
-			b.scrollback.Push(b.cells[i])
+			if indexed != nil {
+				indexed.pushWrapped(b.cells[i], b.wrapped[i])
+			} else {
+				b.scrollback.Push(b.cells[i])
+			}
+			b.scrollbackWrapped = append(b.scrollbackWrapped, b.wrapped[i])
 		}
+		b.pushed += uint64(n)
+		b.evicted += uint64(max(before+n-b.scrollback.Len(), 0))
+		if extra := len(b.scrollbackWrapped) - b.scrollback.Len(); extra > 0 {
+			b.scrollbackWrapped = b.scrollbackWrapped[extra:]
+		}
+	} else if top == 0 {
+		// Without scrollback the lines are dropped right away
+		b.evicted += uint64(n)
 	}
 
 	// Move lines up (including wrapped flags)
 	for row := top; row < bottom-n; row++ {
 		b.cells[row] = b.cells[row+n]
 		b.wrapped[row] = b.wrapped[row+n]
+		b.spans[row] = b.spans[row+n]
 		for col := range b.cells[row] {
 			b.cells[row][col].MarkDirty()
 		}
@@ -191,8 +229,10 @@ func (b *Buffer) ScrollUp(top, bottom, n int) {
 			b.cells[row][col] = NewCell()
 			b.cells[row][col].MarkDirty()
 		}
+		b.spans[row] = nil
 	}
 	b.hasDirty = true
+	b.recordScroll(top, bottom, n)
 }
 
 // ScrollDown shifts lines down by n positions within [top, bottom).
@@ -216,6 +256,7 @@ func (b *Buffer) ScrollDown(top, bottom, n int) {
 	for row := bottom - 1; row >= top+n; row-- {
 		b.cells[row] = b.cells[row-n]
 		b.wrapped[row] = b.wrapped[row-n]
+		b.spans[row] = b.spans[row-n]
 		for col := 0; col < b.cols; col++ {
 			b.cells[row][col].MarkDirty()
 		}
@@ -229,8 +270,10 @@ func (b *Buffer) ScrollDown(top, bottom, n int) {
 			b.cells[row][col] = NewCell()
 			b.cells[row][col].MarkDirty()
 		}
+		b.spans[row] = nil
 	}
 	b.hasDirty = true
+	b.recordScroll(top, bottom, -n)
 }
 
 // InsertLines inserts n blank lines at row, shifting existing lines down.
@@ -269,6 +312,7 @@ func (b *Buffer) InsertBlanks(row, col, n int) {
 		b.cells[row][c].MarkDirty()
 	}
 	b.hasDirty = true
+	b.touchCols(row, col, b.cols)
 }
 
 // DeleteChars removes n characters at (row, col), shifting remaining characters left.
@@ -291,6 +335,7 @@ func (b *Buffer) DeleteChars(row, col, n int) {
 		}
 	}
 	b.hasDirty = true
+	b.touchCols(row, col, b.cols)
 }
 
 // Resize changes buffer dimensions, preserving existing cells where possible.
@@ -321,9 +366,11 @@ func (b *Buffer) Resize(rows, cols int) {
 
 	b.cells = newCells
 	b.wrapped = newWrapped
+	b.lineGen = make([]Generation, rows)
 	b.rows = rows
 	b.cols = cols
 	b.hasDirty = true
+	b.resetDamage()
 
 	// Resize tab stops
 	newTabStop := make([]bool, cols)
@@ -387,6 +434,7 @@ func (b *Buffer) FillWithE() {
 		}
 	}
 	b.hasDirty = true
+	b.touchRange(0, b.rows)
 }
 
 // ScrollbackLen returns the number of lines stored in scrollback.
@@ -406,17 +454,45 @@ func (b *Buffer) ScrollbackLine(index int) []Cell {
 	return b.scrollback.Line(index)
 }
 
+// ScrollbackWrapped returns true if the scrollback line at index (0 is the
+// oldest) was wrapped into the next line.
+func (b *Buffer) ScrollbackWrapped(index int) bool {
+	i := index - (b.ScrollbackLen() - len(b.scrollbackWrapped))
+	if i < 0 || i >= len(b.scrollbackWrapped) {
+		return false
+	}
+	return b.scrollbackWrapped[i]
+}
+
+// popScrollbackWrapped removes and returns the wrap flag of the newest
+// scrollback line, after the line itself was popped.
+func (b *Buffer) popScrollbackWrapped() bool {
+	n := len(b.scrollbackWrapped)
+	if n == 0 {
+		return false
+	}
+	wrapped := b.scrollbackWrapped[n-1]
+	b.scrollbackWrapped = b.scrollbackWrapped[:n-1]
+	return wrapped
+}
+
 // ClearScrollback removes all stored scrollback lines.
 func (b *Buffer) ClearScrollback() {
 	if b.scrollback != nil {
+		b.evicted += uint64(b.scrollback.Len())
 		b.scrollback.Clear()
 	}
+	b.scrollbackWrapped = nil
 }
 
 // SetMaxScrollback sets the maximum number of scrollback lines to retain.
 func (b *Buffer) SetMaxScrollback(max int) {
 	if b.scrollback != nil {
+		before := b.scrollback.Len()
 		b.scrollback.SetMaxLines(max)
+		if after := b.scrollback.Len(); after < before {
+			b.evicted += uint64(before - after)
+		}
 	}
 }
 
@@ -430,7 +506,11 @@ func (b *Buffer) MaxScrollback() int {
 
 // SetScrollbackProvider replaces the scrollback storage implementation.
 func (b *Buffer) SetScrollbackProvider(storage ScrollbackProvider) {
+	if b.scrollback != nil {
+		b.evicted += uint64(b.scrollback.Len())
+	}
 	b.scrollback = storage
+	b.scrollbackWrapped = nil
 }
 
 // ScrollbackProvider returns the current scrollback storage implementation.
@@ -503,8 +583,11 @@ func (b *Buffer) GrowRows(n int) {
 
 	b.cells = newCells
 	b.wrapped = newWrapped
+	b.lineGen = append(b.lineGen, make([]Generation, n)...)
+	b.spans = append(b.spans, make([][]damageSpan, n)...)
 	b.rows = newRows
 	b.hasDirty = true
+	b.touchRange(newRows-n, newRows)
 }
 
 // GrowCols expands a single row to at least minCols columns.
@@ -539,6 +622,50 @@ func (b *Buffer) GrowCols(row, minCols int) {
 	}
 
 	b.hasDirty = true
+	b.touch(row)
+}
+
+// --- Change Tracking ---
+
+// setClock attaches the generation clock used for change tracking.
+func (b *Buffer) setClock(c *generationClock) {
+	b.clock = c
+}
+
+// touch records that a whole row changed at a new generation.
+func (b *Buffer) touch(row int) {
+	b.touchCols(row, 0, b.cols)
+}
+
+// touchRange records that rows in [top, bottom) changed at a single new generation.
+func (b *Buffer) touchRange(top, bottom int) {
+	if b.clock == nil {
+		return
+	}
+	gen := b.clock.next()
+	for row := max(top, 0); row < bottom && row < len(b.lineGen); row++ {
+		b.lineGen[row] = gen
+		b.spans[row] = append(b.spans[row][:0], damageSpan{gen: gen, start: 0, end: b.cols})
+	}
+}
+
+// lineGeneration returns the generation at which a row last changed (0 if never or untracked).
+func (b *Buffer) lineGeneration(row int) Generation {
+	if row < 0 || row >= len(b.lineGen) {
+		return 0
+	}
+	return b.lineGen[row]
+}
+
+// pushedLines returns the total number of lines pushed to scrollback.
+func (b *Buffer) pushedLines() uint64 {
+	return b.pushed
+}
+
+// scrollbackLineID returns a number identifying the scrollback line at index
+// that stays the same while lines are pushed and evicted.
+func (b *Buffer) scrollbackLineID(index int) uint64 {
+	return b.evicted + uint64(index)
 }
 
 // --- Wrapped Line Tracking ---
@@ -563,6 +690,7 @@ func (b *Buffer) SetWrapped(row int, wrapped bool) {
 // Row semantics depend on the API:
 //   - Search(), SetSelection(), GetSelectedText(): viewport-relative (0 to Rows()-1)
 //   - SearchScrollback(): negative for scrollback (-1 = most recent scrollback line)
+//   - SearchRegexp(): absolute (scrollback index, then screen rows)
 //   - Shell integration (PromptMark.Row): absolute (includes scrollback offset)
 //
 // Use ViewportRowToAbsolute/AbsoluteRowToViewport to convert between systems.
@@ -83,6 +83,26 @@ func TestBufferScrollUp(t *testing.T) {
 	}
 }
 
+func TestBufferScrollbackWrapped(t *testing.T) {
+	b := NewBufferWithStorage(3, 10, NewMemoryScrollback(2))
+	b.SetWrapped(0, true)
+	b.SetWrapped(2, true)
+
+	b.ScrollUp(0, 3, 3)
+	if b.ScrollbackLen() != 2 {
+		t.Fatalf("expected 2 scrollback lines, got %d", b.ScrollbackLen())
+	}
+	// The oldest line was evicted; its flag must not shift onto the others
+	if b.ScrollbackWrapped(0) || !b.ScrollbackWrapped(1) {
+		t.Errorf("expected wrap flags [false true], got [%v %v]", b.ScrollbackWrapped(0), b.ScrollbackWrapped(1))
+	}
+
+	b.ClearScrollback()
+	if b.ScrollbackWrapped(0) {
+		t.Error("expected no wrap flags after clearing scrollback")
+	}
+}
+
 func TestBufferScrollDown(t *testing.T) {
 	b := NewBuffer(5, 10)
 
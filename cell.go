@@ -24,6 +24,10 @@ const (
 	CellFlagDirty
 )
 
+// cellUnderlineFlags covers all underline styles.
+const cellUnderlineFlags = CellFlagUnderline | CellFlagDoubleUnderline | CellFlagCurlyUnderline |
+	CellFlagDottedUnderline | CellFlagDashedUnderline
+
 // Cell stores the character, colors, and formatting attributes for one grid position.
 // Wide characters (2 columns) use a spacer cell in the second position.
 type Cell struct {
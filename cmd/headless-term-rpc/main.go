@@ -0,0 +1,61 @@
+// Command headless-term-rpc drives headless terminals over JSON-RPC 2.0 on
+// stdin/stdout, so editors and scripts in any language can embed them by
+// spawning a child process.
+//
+// Messages are framed like the Language Server Protocol: a
+// "Content-Length: N" header, a blank line, then N bytes of JSON. Batches
+// are supported. Calls run in arrival order, except WaitForText which runs
+// concurrently so other calls can feed the terminal meanwhile.
+//
+// Method names mirror the headlessterm.Terminal API. Every method except
+// New, ListTerminals and Unsubscribe takes a "terminal" ID:
+//
+//	New            {"rows", "cols", "scrollback", "record"} -> {"terminal", "rows", "cols"}
+//	Close          {} -> {}
+//	ListTerminals  {} -> {"terminals": [id, ...]}
+//	Spawn          {"command": ["bash"], "env": {"K": "V"}, "dir"} -> {"pid"}
+//	Write          {"data"} -> {"written"}       feed output to the terminal
+//	SendInput      {"data"} -> {}                send input to the process
+//	EncodeKey      {"key": "Ctrl+C"} -> {"data"}
+//	EncodePaste    {"text"} -> {"data"}
+//	EncodeMouse    {"row", "col", "button", "action", "mods"} -> {"data"}
+//	SendKey, SendPaste, SendMouse                encode and send as input
+//	Resize         {"rows", "cols"} -> {}
+//	Snapshot       {"detail": "text|styled|full"} -> headlessterm.Snapshot
+//	String         {} -> {"text"}
+//	LineContent    {"row"} -> {"text"}
+//	CursorPos      {} -> {"row", "col"}
+//	Title          {} -> {"title"}
+//	Search         {"pattern", "scrollback"} -> {"matches": [{"row", "col"}]}
+//	WaitForText    {"text" | "regexp", "scrollback", "timeout_ms"} -> {"found"}
+//	Subscribe      {"types": ["title_changed", ...]} -> {"subscription"}
+//	Unsubscribe    {"subscription"} -> {}
+//
+// Input without a spawned process goes to the terminal's PTYWriter, which
+// discards it unless the terminal was configured with one.
+//
+// The server sends two notifications:
+//
+//	Event          {"terminal", "subscription", "type", "event"}
+//	ProcessExited  {"terminal", "exit_code"}
+//
+// WaitForText fails with code -32001 when its timeout (default 5s) expires.
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("headless-term-rpc: ")
+
+	c := newConn(os.Stdin, os.Stdout)
+	svc := newService(c)
+	err := c.serve(svc.methods())
+	svc.shutdown()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+	"github.com/danielgatis/go-headless-term/pty"
+)
+
+// defaultWaitTimeout applies to WaitForText calls without a timeout.
+const defaultWaitTimeout = 5 * time.Second
+
+// instance is a terminal created by New, with its optional process.
+type instance struct {
+	term *headlessterm.Terminal
+	proc *pty.Session
+}
+
+// service implements the RPC methods.
+type service struct {
+	conn *conn
+
+	mu      sync.Mutex
+	nextID  int
+	terms   map[int]*instance
+	subs    map[int]*headlessterm.Subscription
+	nextSub int
+}
+
+func newService(c *conn) *service {
+	return &service{conn: c, terms: make(map[int]*instance), subs: make(map[int]*headlessterm.Subscription)}
+}
+
+// methods returns the method table. Names mirror the Terminal API.
+func (s *service) methods() map[string]method {
+	return map[string]method{
+		"New":           {handle: s.newTerminal},
+		"Close":         {handle: s.close},
+		"Spawn":         {handle: s.spawn},
+		"Write":         {handle: s.write},
+		"SendInput":     {handle: s.sendInput},
+		"EncodeKey":     {handle: s.encodeKey},
+		"EncodePaste":   {handle: s.encodePaste},
+		"EncodeMouse":   {handle: s.encodeMouse},
+		"SendKey":       {handle: s.sendKey},
+		"SendPaste":     {handle: s.sendPaste},
+		"SendMouse":     {handle: s.sendMouse},
+		"Resize":        {handle: s.resize},
+		"Snapshot":      {handle: s.snapshot},
+		"String":        {handle: s.string},
+		"LineContent":   {handle: s.lineContent},
+		"CursorPos":     {handle: s.cursorPos},
+		"Title":         {handle: s.title},
+		"Search":        {handle: s.search},
+		"WaitForText":   {handle: s.waitForText, async: true},
+		"Subscribe":     {handle: s.subscribe},
+		"Unsubscribe":   {handle: s.unsubscribe},
+		"ListTerminals": {handle: s.listTerminals},
+	}
+}
+
+// shutdown closes all subscriptions and processes.
+func (s *service) shutdown() {
+	s.mu.Lock()
+	terms, subs := s.terms, s.subs
+	s.terms, s.subs = make(map[int]*instance), make(map[int]*headlessterm.Subscription)
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+	for _, inst := range terms {
+		if inst.proc != nil {
+			inst.proc.Close()
+		}
+	}
+}
+
+// terminalParams identifies the target terminal.
+type terminalParams struct {
+	Terminal int `json:"terminal"`
+}
+
+// lookup decodes params into v (which embeds terminalParams) and returns the instance.
+func (s *service) lookup(params json.RawMessage, v any, id *int) (*instance, error) {
+	if err := decodeParams(params, v); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst := s.terms[*id]
+	if inst == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown terminal %d", *id)}
+	}
+	return inst, nil
+}
+
+type newParams struct {
+	Rows       int  `json:"rows"`
+	Cols       int  `json:"cols"`
+	Scrollback int  `json:"scrollback"`
+	Record     bool `json:"record"`
+}
+
+func (s *service) newTerminal(params json.RawMessage) (any, error) {
+	p := newParams{Scrollback: 1000}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	opts := []headlessterm.Option{headlessterm.WithSize(p.Rows, p.Cols)}
+	if p.Scrollback > 0 {
+		opts = append(opts, headlessterm.WithScrollback(headlessterm.NewMemoryScrollback(p.Scrollback)))
+	}
+	if p.Record {
+		opts = append(opts, headlessterm.WithRecording(headlessterm.NewMemoryRecording()))
+	}
+	term := headlessterm.New(opts...)
+
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.terms[id] = &instance{term: term}
+	s.mu.Unlock()
+
+	return map[string]any{"terminal": id, "rows": term.Rows(), "cols": term.Cols()}, nil
+}
+
+func (s *service) close(params json.RawMessage) (any, error) {
+	var p terminalParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	delete(s.terms, p.Terminal)
+	s.mu.Unlock()
+
+	if inst.proc != nil {
+		inst.proc.Close()
+	}
+	return nil, nil
+}
+
+type spawnParams struct {
+	terminalParams
+	Command []string          `json:"command"`
+	Env     map[string]string `json:"env"`
+	Dir     string            `json:"dir"`
+}
+
+func (s *service) spawn(params json.RawMessage) (any, error) {
+	var p spawnParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Command) == 0 {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "missing command"}
+	}
+
+	s.mu.Lock()
+	running := inst.proc != nil
+	s.mu.Unlock()
+	if running {
+		return nil, fmt.Errorf("terminal %d already has a process", p.Terminal)
+	}
+
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Dir = p.Dir
+	if len(p.Env) > 0 {
+		cmd.Env = os.Environ()
+		keys := make([]string, 0, len(p.Env))
+		for k := range p.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+p.Env[k])
+		}
+	}
+	proc, err := pty.Spawn(cmd, inst.term, pty.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	inst.proc = proc
+	s.mu.Unlock()
+
+	go func() {
+		<-proc.Done()
+		s.conn.notify("ProcessExited", map[string]int{"terminal": p.Terminal, "exit_code": proc.ExitCode()})
+	}()
+	return map[string]int{"pid": proc.Pid()}, nil
+}
+
+type dataParams struct {
+	terminalParams
+	Data string `json:"data"`
+}
+
+func (s *service) write(params json.RawMessage) (any, error) {
+	var p dataParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	n, err := inst.term.Write([]byte(p.Data))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"written": n}, nil
+}
+
+// send delivers input to the terminal's process, or to its PTYWriter if
+// no process was spawned.
+func (s *service) send(inst *instance, data []byte) error {
+	s.mu.Lock()
+	proc := inst.proc
+	s.mu.Unlock()
+
+	var err error
+	if proc != nil {
+		_, err = proc.Write(data)
+	} else {
+		_, err = inst.term.PTYWriter().Write(data)
+	}
+	return err
+}
+
+func (s *service) sendInput(params json.RawMessage) (any, error) {
+	var p dataParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.send(inst, []byte(p.Data))
+}
+
+type keyParams struct {
+	terminalParams
+	Key string `json:"key"`
+}
+
+func (s *service) encodeKey(params json.RawMessage) (any, error) {
+	var p keyParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := inst.term.EncodeKey(p.Key)
+	if err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return map[string]string{"data": string(seq)}, nil
+}
+
+func (s *service) sendKey(params json.RawMessage) (any, error) {
+	var p keyParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := inst.term.EncodeKey(p.Key)
+	if err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil, s.send(inst, seq)
+}
+
+type pasteParams struct {
+	terminalParams
+	Text string `json:"text"`
+}
+
+func (s *service) encodePaste(params json.RawMessage) (any, error) {
+	var p pasteParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"data": string(inst.term.EncodePaste(p.Text))}, nil
+}
+
+func (s *service) sendPaste(params json.RawMessage) (any, error) {
+	var p pasteParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.send(inst, inst.term.EncodePaste(p.Text))
+}
+
+type mouseParams struct {
+	terminalParams
+	Row    int      `json:"row"`
+	Col    int      `json:"col"`
+	Button string   `json:"button"`
+	Action string   `json:"action"`
+	Mods   []string `json:"mods"`
+}
+
+var (
+	mouseButtons = map[string]headlessterm.MouseButton{
+		"left": headlessterm.MouseLeft, "middle": headlessterm.MouseMiddle, "right": headlessterm.MouseRight,
+		"none": headlessterm.MouseNone, "": headlessterm.MouseNone,
+		"wheelup": headlessterm.MouseWheelUp, "wheeldown": headlessterm.MouseWheelDown,
+		"wheelleft": headlessterm.MouseWheelLeft, "wheelright": headlessterm.MouseWheelRight,
+	}
+	mouseActions = map[string]headlessterm.MouseAction{
+		"press": headlessterm.MousePress, "": headlessterm.MousePress,
+		"release": headlessterm.MouseRelease, "motion": headlessterm.MouseMotion,
+	}
+	mouseMods = map[string]headlessterm.KeyModifiers{
+		"shift": headlessterm.ModShift, "alt": headlessterm.ModAlt,
+		"ctrl": headlessterm.ModCtrl, "meta": headlessterm.ModMeta,
+	}
+)
+
+// event converts the parameters into a MouseEvent.
+func (p *mouseParams) event() (headlessterm.MouseEvent, error) {
+	button, ok := mouseButtons[strings.ToLower(p.Button)]
+	if !ok {
+		return headlessterm.MouseEvent{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown mouse button %q", p.Button)}
+	}
+	action, ok := mouseActions[strings.ToLower(p.Action)]
+	if !ok {
+		return headlessterm.MouseEvent{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown mouse action %q", p.Action)}
+	}
+	ev := headlessterm.MouseEvent{Row: p.Row, Col: p.Col, Button: button, Action: action}
+	for _, name := range p.Mods {
+		mod, ok := mouseMods[strings.ToLower(name)]
+		if !ok {
+			return headlessterm.MouseEvent{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown modifier %q", name)}
+		}
+		ev.Mods |= mod
+	}
+	return ev, nil
+}
+
+func (s *service) encodeMouse(params json.RawMessage) (any, error) {
+	var p mouseParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := p.event()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"data": string(inst.term.EncodeMouse(ev))}, nil
+}
+
+func (s *service) sendMouse(params json.RawMessage) (any, error) {
+	var p mouseParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := p.event()
+	if err != nil {
+		return nil, err
+	}
+	if data := inst.term.EncodeMouse(ev); len(data) > 0 {
+		return map[string]bool{"sent": true}, s.send(inst, data)
+	}
+	return map[string]bool{"sent": false}, nil
+}
+
+type resizeParams struct {
+	terminalParams
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+func (s *service) resize(params json.RawMessage) (any, error) {
+	var p resizeParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	if p.Rows <= 0 || p.Cols <= 0 {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid size %dx%d", p.Rows, p.Cols)}
+	}
+
+	s.mu.Lock()
+	proc := inst.proc
+	s.mu.Unlock()
+	if proc != nil {
+		err := proc.Resize(p.Rows, p.Cols)
+		if !errors.Is(err, pty.ErrClosed) {
+			return nil, err
+		}
+	}
+	// No process, or it exited: resize the terminal alone.
+	inst.term.Resize(p.Rows, p.Cols)
+	return nil, nil
+}
+
+type snapshotParams struct {
+	terminalParams
+	Detail headlessterm.SnapshotDetail `json:"detail"`
+}
+
+func (s *service) snapshot(params json.RawMessage) (any, error) {
+	var p snapshotParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Detail {
+	case "":
+		p.Detail = headlessterm.SnapshotDetailStyled
+	case headlessterm.SnapshotDetailText, headlessterm.SnapshotDetailStyled, headlessterm.SnapshotDetailFull:
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown detail %q", p.Detail)}
+	}
+	return inst.term.Snapshot(p.Detail), nil
+}
+
+func (s *service) string(params json.RawMessage) (any, error) {
+	var p terminalParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"text": inst.term.String()}, nil
+}
+
+type rowParams struct {
+	terminalParams
+	Row int `json:"row"`
+}
+
+func (s *service) lineContent(params json.RawMessage) (any, error) {
+	var p rowParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"text": inst.term.LineContent(p.Row)}, nil
+}
+
+func (s *service) cursorPos(params json.RawMessage) (any, error) {
+	var p terminalParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	row, col := inst.term.CursorPos()
+	return map[string]int{"row": row, "col": col}, nil
+}
+
+func (s *service) title(params json.RawMessage) (any, error) {
+	var p terminalParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"title": inst.term.Title()}, nil
+}
+
+type searchParams struct {
+	terminalParams
+	Pattern    string `json:"pattern"`
+	Scrollback bool   `json:"scrollback"`
+}
+
+func (s *service) search(params json.RawMessage) (any, error) {
+	var p searchParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	var positions []headlessterm.Position
+	if p.Scrollback {
+		positions = append(positions, inst.term.SearchScrollback(p.Pattern)...)
+	}
+	positions = append(positions, inst.term.Search(p.Pattern)...)
+
+	matches := make([]map[string]int, len(positions))
+	for i, pos := range positions {
+		matches[i] = map[string]int{"row": pos.Row, "col": pos.Col}
+	}
+	return map[string]any{"matches": matches}, nil
+}
+
+type waitParams struct {
+	terminalParams
+	Text       string `json:"text"`
+	Regexp     string `json:"regexp"`
+	Scrollback bool   `json:"scrollback"`
+	TimeoutMS  int    `json:"timeout_ms"`
+}
+
+func (s *service) waitForText(params json.RawMessage) (any, error) {
+	var p waitParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+
+	var cond headlessterm.WaitCondition
+	switch {
+	case p.Regexp != "":
+		re, err := regexp.Compile(p.Regexp)
+		if err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		if p.Scrollback {
+			cond = headlessterm.WaitScrollbackRegexp(re)
+		} else {
+			cond = headlessterm.WaitRegexp(re)
+		}
+	case p.Text != "":
+		if p.Scrollback {
+			cond = headlessterm.WaitScrollbackText(p.Text)
+		} else {
+			cond = headlessterm.WaitText(p.Text)
+		}
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: "missing text or regexp"}
+	}
+
+	timeout := defaultWaitTimeout
+	if p.TimeoutMS > 0 {
+		timeout = time.Duration(p.TimeoutMS) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := inst.term.WaitFor(ctx, cond); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &rpcError{Code: codeTimeout, Message: err.Error()}
+		}
+		return nil, err
+	}
+	return map[string]bool{"found": true}, nil
+}
+
+type subscribeParams struct {
+	terminalParams
+	Types []string `json:"types"`
+}
+
+// eventNotification is the params of an "Event" notification.
+type eventNotification struct {
+	Terminal     int                `json:"terminal"`
+	Subscription int                `json:"subscription"`
+	Type         string             `json:"type"`
+	Event        headlessterm.Event `json:"event"`
+}
+
+func (s *service) subscribe(params json.RawMessage) (any, error) {
+	var p subscribeParams
+	inst, err := s.lookup(params, &p, &p.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	types := headlessterm.EventAll
+	if len(p.Types) > 0 {
+		types = 0
+		for _, name := range p.Types {
+			typ, ok := headlessterm.ParseEventType(name)
+			if !ok {
+				return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown event type %q", name)}
+			}
+			types |= typ
+		}
+	}
+
+	sub := inst.term.Subscribe(types)
+	s.mu.Lock()
+	s.nextSub++
+	id := s.nextSub
+	s.subs[id] = sub
+	s.mu.Unlock()
+
+	go func() {
+		for ev := range sub.Events() {
+			s.conn.notify("Event", eventNotification{
+				Terminal:     p.Terminal,
+				Subscription: id,
+				Type:         ev.Type().String(),
+				Event:        ev,
+			})
+		}
+	}()
+	return map[string]int{"subscription": id}, nil
+}
+
+type unsubscribeParams struct {
+	Subscription int `json:"subscription"`
+}
+
+func (s *service) unsubscribe(params json.RawMessage) (any, error) {
+	var p unsubscribeParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	sub := s.subs[p.Subscription]
+	delete(s.subs, p.Subscription)
+	s.mu.Unlock()
+
+	if sub == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown subscription %d", p.Subscription)}
+	}
+	sub.Close()
+	return nil, nil
+}
+
+func (s *service) listTerminals(params json.RawMessage) (any, error) {
+	s.mu.Lock()
+	ids := make([]int, 0, len(s.terms))
+	for id := range s.terms {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+	sort.Ints(ids)
+	return map[string][]int{"terminals": ids}, nil
+}
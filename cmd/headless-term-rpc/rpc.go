@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// codeServerError is used for failures reported by a method.
+	codeServerError = -32000
+	// codeTimeout is returned when a wait times out.
+	codeTimeout = -32001
+)
+
+// maxMessageSize bounds the Content-Length of incoming messages.
+const maxMessageSize = 64 << 20
+
+// rpcError is a JSON-RPC error object; methods return it to pick the code.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// request is an incoming call or notification (no ID).
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is the reply to a call.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// notification is a server-to-client message without ID.
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// method handles a call. Async methods may block and run on their own
+// goroutine; all others run in arrival order.
+type method struct {
+	handle func(params json.RawMessage) (any, error)
+	async  bool
+}
+
+// conn reads framed messages from r and writes framed messages to w.
+type conn struct {
+	r *bufio.Reader
+
+	writeMu sync.Mutex
+	w       io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+// readMessage reads one message framed with a Content-Length header.
+func (c *conn) readMessage() ([]byte, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return body, nil
+}
+
+// writeMessage marshals v and writes it with a Content-Length header.
+func (c *conn) writeMessage(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+// notify sends a notification to the client.
+func (c *conn) notify(method string, params any) error {
+	return c.writeMessage(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// serve reads requests until EOF and dispatches them to methods.
+// Batches are processed in order and answered with one array.
+func (c *conn) serve(methods map[string]method) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		body, err := c.readMessage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+				c.writeMessage(errorResponse(nil, codeInvalidRequest, "invalid batch"))
+				continue
+			}
+			var replies []*response
+			for _, raw := range batch {
+				if resp := c.call(methods, raw); resp != nil {
+					replies = append(replies, resp)
+				}
+			}
+			if len(replies) > 0 {
+				c.writeMessage(replies)
+			}
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.writeMessage(errorResponse(nil, codeParseError, err.Error()))
+			continue
+		}
+		if m, ok := methods[req.Method]; ok && m.async {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := c.call(methods, body); resp != nil {
+					c.writeMessage(resp)
+				}
+			}()
+			continue
+		}
+		if resp := c.call(methods, body); resp != nil {
+			c.writeMessage(resp)
+		}
+	}
+}
+
+// call runs one request and returns its response, or nil for notifications.
+func (c *conn) call(methods map[string]method, raw json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, codeInvalidRequest, err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request")
+	}
+
+	m, ok := methods[req.Method]
+	var result any
+	var err error
+	if !ok {
+		err = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	} else {
+		result, err = m.handle(req.Params)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{Code: codeServerError, Message: err.Error()}
+		}
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: rerr}
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// errorResponse builds an error reply; a nil ID is sent as null.
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: msg}}
+}
+
+// decodeParams unmarshals params into v, reporting invalid params.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + strings.TrimPrefix(err.Error(), "json: ")}
+	}
+	return nil
+}
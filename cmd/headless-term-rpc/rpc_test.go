@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client talks to a server running on in-memory pipes.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	conn   *conn
+	msgs   chan json.RawMessage
+	nextID int
+	// notes holds notifications received while waiting for responses.
+	notes []notificationMessage
+}
+
+type notificationMessage struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type responseMessage struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	srv := newConn(inR, outW)
+	svc := newService(srv)
+	done := make(chan struct{})
+	go func() {
+		srv.serve(svc.methods())
+		svc.shutdown()
+		outW.Close()
+		close(done)
+	}()
+
+	c := &client{t: t, w: inW, conn: newConn(outR, nil), msgs: make(chan json.RawMessage, 64)}
+	go func() {
+		defer close(c.msgs)
+		for {
+			msg, err := c.conn.readMessage()
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		<-done
+	})
+	return c
+}
+
+// send writes a raw framed message.
+func (c *client) send(body string) {
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// next returns the next message from the server.
+func (c *client) next() json.RawMessage {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+	}
+	return nil
+}
+
+// start sends a call and returns its ID without waiting.
+func (c *client) start(method string, params any) int {
+	c.nextID++
+	data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	c.send(string(data))
+	return c.nextID
+}
+
+// wait returns the response to id, queueing notifications and skipping
+// responses to other calls.
+func (c *client) wait(id int) responseMessage {
+	c.t.Helper()
+	for {
+		msg := c.next()
+		var probe struct {
+			ID     *int   `json:"id"`
+			Method string `json:"method"`
+		}
+		json.Unmarshal(msg, &probe)
+		if probe.Method != "" {
+			var n notificationMessage
+			json.Unmarshal(msg, &n)
+			c.notes = append(c.notes, n)
+			continue
+		}
+		if probe.ID != nil && *probe.ID == id {
+			var resp responseMessage
+			json.Unmarshal(msg, &resp)
+			return resp
+		}
+	}
+}
+
+// call invokes method and decodes its result into out (if non-nil).
+func (c *client) call(method string, params, out any) {
+	c.t.Helper()
+	resp := c.wait(c.start(method, params))
+	if resp.Error != nil {
+		c.t.Fatalf("%s: error %d: %s", method, resp.Error.Code, resp.Error.Message)
+	}
+	if out != nil {
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			c.t.Fatalf("%s: decode %s: %v", method, resp.Result, err)
+		}
+	}
+}
+
+// callError invokes method and returns its error code.
+func (c *client) callError(method string, params any) int {
+	c.t.Helper()
+	resp := c.wait(c.start(method, params))
+	if resp.Error == nil {
+		c.t.Fatalf("%s: expected an error, got %s", method, resp.Result)
+	}
+	return resp.Error.Code
+}
+
+// notification returns the next notification with the given method.
+func (c *client) notification(method string) json.RawMessage {
+	c.t.Helper()
+	for {
+		for i, n := range c.notes {
+			if n.Method == method {
+				c.notes = append(c.notes[:i], c.notes[i+1:]...)
+				return n.Params
+			}
+		}
+		var n notificationMessage
+		json.Unmarshal(c.next(), &n)
+		if n.Method != "" {
+			c.notes = append(c.notes, n)
+		}
+	}
+}
+
+func (c *client) newTerminal(rows, cols int) int {
+	c.t.Helper()
+	var res struct {
+		Terminal int `json:"terminal"`
+	}
+	c.call("New", map[string]any{"rows": rows, "cols": cols}, &res)
+	return res.Terminal
+}
+
+func TestWriteSnapshotAndQueries(t *testing.T) {
+	c := newClient(t)
+	id := c.newTerminal(5, 20)
+
+	var written struct {
+		Written int `json:"written"`
+	}
+	c.call("Write", map[string]any{"terminal": id, "data": "\x1b]2;demo\x07hello\r\nworld"}, &written)
+	if written.Written != 21 {
+		t.Errorf("written = %d", written.Written)
+	}
+
+	var snap struct {
+		Size struct {
+			Rows int `json:"rows"`
+		} `json:"size"`
+		Lines []struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+	}
+	c.call("Snapshot", map[string]any{"terminal": id, "detail": "text"}, &snap)
+	if snap.Size.Rows != 5 || len(snap.Lines) != 5 || snap.Lines[0].Text != "hello" || snap.Lines[1].Text != "world" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	var title struct {
+		Title string `json:"title"`
+	}
+	c.call("Title", map[string]any{"terminal": id}, &title)
+	if title.Title != "demo" {
+		t.Errorf("title = %q", title.Title)
+	}
+
+	var pos struct {
+		Row int `json:"row"`
+		Col int `json:"col"`
+	}
+	c.call("CursorPos", map[string]any{"terminal": id}, &pos)
+	if pos.Row != 1 || pos.Col != 5 {
+		t.Errorf("cursor = %+v", pos)
+	}
+
+	var found struct {
+		Matches []struct {
+			Row int `json:"row"`
+			Col int `json:"col"`
+		} `json:"matches"`
+	}
+	c.call("Search", map[string]any{"terminal": id, "pattern": "orl"}, &found)
+	if len(found.Matches) != 1 || found.Matches[0].Row != 1 || found.Matches[0].Col != 1 {
+		t.Errorf("search = %+v", found.Matches)
+	}
+
+	c.call("Resize", map[string]any{"terminal": id, "rows": 3, "cols": 10}, nil)
+	c.call("Snapshot", map[string]any{"terminal": id, "detail": "text"}, &snap)
+	if snap.Size.Rows != 3 {
+		t.Errorf("rows after resize = %d", snap.Size.Rows)
+	}
+
+	c.call("Close", map[string]any{"terminal": id}, nil)
+	if code := c.callError("String", map[string]any{"terminal": id}); code != codeInvalidParams {
+		t.Errorf("closed terminal code = %d", code)
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	c := newClient(t)
+	id := c.newTerminal(5, 20)
+
+	var res struct {
+		Data string `json:"data"`
+	}
+	c.call("EncodeKey", map[string]any{"terminal": id, "key": "Ctrl+Up"}, &res)
+	if res.Data != "\x1b[1;5A" {
+		t.Errorf("Ctrl+Up = %q", res.Data)
+	}
+
+	c.call("Write", map[string]any{"terminal": id, "data": "\x1b[?2004h\x1b[?1000h\x1b[?1006h"}, nil)
+	c.call("EncodePaste", map[string]any{"terminal": id, "text": "a\nb"}, &res)
+	if res.Data != "\x1b[200~a\rb\x1b[201~" {
+		t.Errorf("paste = %q", res.Data)
+	}
+	c.call("EncodeMouse", map[string]any{"terminal": id, "row": 1, "col": 2, "button": "left", "mods": []string{"ctrl"}}, &res)
+	if res.Data != "\x1b[<16;3;2M" {
+		t.Errorf("mouse = %q", res.Data)
+	}
+
+	if code := c.callError("EncodeKey", map[string]any{"terminal": id, "key": "Hyper"}); code != codeInvalidParams {
+		t.Errorf("bad key code = %d", code)
+	}
+	if code := c.callError("EncodeMouse", map[string]any{"terminal": id, "button": "thumb"}); code != codeInvalidParams {
+		t.Errorf("bad button code = %d", code)
+	}
+}
+
+func TestWaitForText(t *testing.T) {
+	c := newClient(t)
+	id := c.newTerminal(5, 20)
+
+	waitID := c.start("WaitForText", map[string]any{"terminal": id, "regexp": `ready \d+`, "timeout_ms": 5000})
+	c.call("Write", map[string]any{"terminal": id, "data": "ready 42"}, nil)
+	if resp := c.wait(waitID); resp.Error != nil {
+		t.Errorf("wait = %+v", resp.Error)
+	}
+
+	if code := c.callError("WaitForText", map[string]any{"terminal": id, "text": "never", "timeout_ms": 20}); code != codeTimeout {
+		t.Errorf("timeout code = %d", code)
+	}
+	if code := c.callError("WaitForText", map[string]any{"terminal": id}); code != codeInvalidParams {
+		t.Errorf("missing text code = %d", code)
+	}
+}
+
+func TestSubscribeNotifications(t *testing.T) {
+	c := newClient(t)
+	id := c.newTerminal(5, 20)
+
+	var sub struct {
+		Subscription int `json:"subscription"`
+	}
+	c.call("Subscribe", map[string]any{"terminal": id, "types": []string{"title_changed"}}, &sub)
+	c.call("Write", map[string]any{"terminal": id, "data": "\x1b]2;hello\x07"}, nil)
+
+	var note struct {
+		Terminal     int    `json:"terminal"`
+		Subscription int    `json:"subscription"`
+		Type         string `json:"type"`
+		Event        struct {
+			Title string
+		} `json:"event"`
+	}
+	json.Unmarshal(c.notification("Event"), &note)
+	if note.Terminal != id || note.Subscription != sub.Subscription || note.Type != "title_changed" || note.Event.Title != "hello" {
+		t.Errorf("notification = %+v", note)
+	}
+
+	c.call("Unsubscribe", map[string]any{"subscription": sub.Subscription}, nil)
+	if code := c.callError("Subscribe", map[string]any{"terminal": id, "types": []string{"bogus"}}); code != codeInvalidParams {
+		t.Errorf("bad type code = %d", code)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := newClient(t)
+
+	if code := c.callError("Frobnicate", nil); code != codeMethodNotFound {
+		t.Errorf("unknown method code = %d", code)
+	}
+
+	c.send(`{"jsonrpc": "2.0", "id": 1, `)
+	var resp response
+	json.Unmarshal(c.next(), &resp)
+	if resp.Error == nil || resp.Error.Code != codeParseError || string(resp.ID) != "null" {
+		t.Errorf("parse error = %+v", resp)
+	}
+
+	// A batch is answered with one array; notifications get no reply.
+	c.send(`[{"jsonrpc": "2.0", "id": 10, "method": "New", "params": {"rows": 2, "cols": 4}},
+		{"jsonrpc": "2.0", "method": "New"},
+		{"jsonrpc": "2.0", "id": 11, "method": "ListTerminals"}]`)
+	var batch []responseMessage
+	if err := json.Unmarshal(c.next(), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].ID != 10 || batch[1].ID != 11 {
+		t.Fatalf("batch = %+v", batch)
+	}
+	var list struct {
+		Terminals []int `json:"terminals"`
+	}
+	json.Unmarshal(batch[1].Result, &list)
+	if len(list.Terminals) != 2 {
+		t.Errorf("terminals = %v", list.Terminals)
+	}
+}
+
+func TestReadMessageFraming(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 2\r\n\r\n{}Content-Length: x\r\n\r\n"))
+	c := &conn{r: r}
+	if msg, err := c.readMessage(); err != nil || string(msg) != "{}" {
+		t.Errorf("readMessage = %q, %v", msg, err)
+	}
+	if _, err := c.readMessage(); err == nil {
+		t.Error("expected an error for an invalid Content-Length")
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("pty spawning is only tested on linux")
+	}
+	c := newClient(t)
+	id := c.newTerminal(5, 40)
+
+	var res struct {
+		Pid int `json:"pid"`
+	}
+	c.call("Spawn", map[string]any{"terminal": id, "command": []string{"sh", "-c", `read line; echo "got:$line:$NAME"; exit 3`},
+		"env": map[string]string{"NAME": "rpc"}}, &res)
+	if res.Pid == 0 {
+		t.Error("pid = 0")
+	}
+
+	c.call("SendInput", map[string]any{"terminal": id, "data": "hi"}, nil)
+	c.call("SendKey", map[string]any{"terminal": id, "key": "Enter"}, nil)
+	c.call("WaitForText", map[string]any{"terminal": id, "text": "got:hi:rpc"}, nil)
+
+	var exited struct {
+		Terminal int `json:"terminal"`
+		ExitCode int `json:"exit_code"`
+	}
+	json.Unmarshal(c.notification("ProcessExited"), &exited)
+	if exited.Terminal != id || exited.ExitCode != 3 {
+		t.Errorf("exit = %+v", exited)
+	}
+}
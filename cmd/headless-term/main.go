@@ -0,0 +1,62 @@
+// Command headless-term runs programs on a headless terminal from the shell.
+//
+// Usage:
+//
+//	headless-term run [flags] -- command [args...]
+//
+// run starts the command on a PTY attached to a Terminal, optionally feeds it
+// scripted input, and writes the final screen once the command exits (or, with
+// -wait-for, as soon as the screen matches a regular expression):
+//
+//	-rows, -cols     terminal size (default 24x80)
+//	-timeout         give up after this long (default 10s)
+//	-wait-for REGEX  capture as soon as the screen matches REGEX
+//	-format          text, json, html, svg or png (default text)
+//	-o FILE          write the capture to FILE instead of stdout
+//	-input STEP      scripted input, repeatable and applied in order:
+//	                 "text:ls -l", "key:Enter", "paste:...", "sleep:500ms" or
+//	                 "wait:REGEX" (a value without prefix is sent as text)
+//
+// Without -wait-for, run exits with the command's exit status, or 128 plus the
+// signal number if a signal killed the command. It exits with
+// 124 on timeout (still writing the screen captured at that point), 2 on
+// usage errors and 1 on other failures.
+//
+// Examples:
+//
+//	headless-term run -format png -o top.png -wait-for 'load average' -- top
+//	headless-term run -input key:Down -input key:Enter -wait-for Done -- ./menu
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: headless-term run [flags] -- command [args...]
+
+Run "headless-term run -h" for the list of flags.
+`
+
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// dispatch runs the subcommand named by args[0] and returns the exit status.
+func dispatch(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "run":
+		return run(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "headless-term: unknown command %q\n%s", args[0], usage)
+		return exitUsage
+	}
+}
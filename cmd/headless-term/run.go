@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+	"github.com/danielgatis/go-headless-term/pty"
+)
+
+// Exit statuses besides the command's own.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	exitTimeout = 124
+)
+
+// formats maps -format values to screen encoders.
+var formats = map[string]func(w io.Writer, term *headlessterm.Terminal) error{
+	"text": func(w io.Writer, term *headlessterm.Terminal) error {
+		_, err := fmt.Fprintln(w, term.String())
+		return err
+	},
+	"json": func(w io.Writer, term *headlessterm.Terminal) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(term.Snapshot(headlessterm.SnapshotDetailStyled))
+	},
+	"html": func(w io.Writer, term *headlessterm.Terminal) error {
+		return term.ExportHTML(w, headlessterm.HTMLOptions{})
+	},
+	"svg": func(w io.Writer, term *headlessterm.Terminal) error {
+		return term.ExportSVG(w, headlessterm.SVGOptions{})
+	},
+	"png": func(w io.Writer, term *headlessterm.Terminal) error {
+		return png.Encode(w, term.RenderImage(headlessterm.RenderOptions{}))
+	},
+}
+
+// inputStep is one -input value.
+type inputStep struct {
+	kind string // text, key, paste, sleep or wait
+	arg  string
+	dur  time.Duration
+	re   *regexp.Regexp
+}
+
+// inputSteps collects repeated -input flags in order.
+type inputSteps []inputStep
+
+func (s *inputSteps) String() string {
+	return fmt.Sprint(len(*s), " steps")
+}
+
+func (s *inputSteps) Set(value string) error {
+	step := inputStep{kind: "text", arg: value}
+	if kind, arg, ok := strings.Cut(value, ":"); ok {
+		switch kind {
+		case "text", "key", "paste":
+			step = inputStep{kind: kind, arg: arg}
+		case "sleep":
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return err
+			}
+			step = inputStep{kind: kind, dur: d}
+		case "wait":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return err
+			}
+			step = inputStep{kind: kind, re: re}
+		}
+	}
+	*s = append(*s, step)
+	return nil
+}
+
+// runConfig holds the parsed flags of the run command.
+type runConfig struct {
+	rows, cols int
+	timeout    time.Duration
+	waitFor    *regexp.Regexp
+	format     string
+	output     string
+	steps      inputSteps
+	command    []string
+}
+
+// parseRunFlags parses the run command line; usage errors are printed to stderr.
+func parseRunFlags(args []string, stderr io.Writer) (*runConfig, error) {
+	cfg := &runConfig{}
+	fs := flag.NewFlagSet("headless-term run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&cfg.rows, "rows", 24, "terminal rows")
+	fs.IntVar(&cfg.cols, "cols", 80, "terminal columns")
+	fs.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "maximum run time")
+	waitFor := fs.String("wait-for", "", "capture as soon as the screen matches this regular expression")
+	fs.StringVar(&cfg.format, "format", "text", "output format: text, json, html, svg or png")
+	fs.StringVar(&cfg.output, "o", "", "write the capture to this file instead of stdout")
+	fs.Var(&cfg.steps, "input", "scripted input step (text:, key:, paste:, sleep: or wait: prefix), repeatable")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: headless-term run [flags] -- command [args...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.command = fs.Args()
+	if len(cfg.command) == 0 {
+		return nil, errors.New("missing command")
+	}
+	if cfg.rows <= 0 || cfg.cols <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", cfg.rows, cfg.cols)
+	}
+	if _, ok := formats[cfg.format]; !ok {
+		return nil, fmt.Errorf("unknown format %q", cfg.format)
+	}
+	if *waitFor != "" {
+		re, err := regexp.Compile(*waitFor)
+		if err != nil {
+			return nil, fmt.Errorf("-wait-for: %w", err)
+		}
+		cfg.waitFor = re
+	}
+	return cfg, nil
+}
+
+// run implements "headless-term run" and returns the exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseRunFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "headless-term run: %v\n", err)
+		return exitUsage
+	}
+
+	term := headlessterm.New(headlessterm.WithSize(cfg.rows, cfg.cols))
+	proc, err := pty.Spawn(exec.Command(cfg.command[0], cfg.command[1:]...), term, pty.Options{})
+	if err != nil {
+		fmt.Fprintf(stderr, "headless-term run: %v\n", err)
+		return exitFailure
+	}
+	defer proc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+
+	status, runErr := capture(ctx, cfg, term, proc)
+
+	// Encode before reporting so the capture reflects the moment the run
+	// ended, even if the process keeps drawing.
+	var out bytes.Buffer
+	if err := formats[cfg.format](&out, term); err != nil {
+		fmt.Fprintf(stderr, "headless-term run: encode %s: %v\n", cfg.format, err)
+		return exitFailure
+	}
+	if err := writeOutput(cfg.output, stdout, out.Bytes()); err != nil {
+		fmt.Fprintf(stderr, "headless-term run: %v\n", err)
+		return exitFailure
+	}
+	if runErr != nil {
+		fmt.Fprintf(stderr, "headless-term run: %v\n", runErr)
+	}
+	return status
+}
+
+// capture feeds the scripted input and waits until the capture point:
+// a -wait-for match, or the process exit. It returns the exit status.
+func capture(ctx context.Context, cfg *runConfig, term *headlessterm.Terminal, proc *pty.Session) (int, error) {
+	// Waits end early when the process exits.
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-proc.Done():
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	for i, step := range cfg.steps {
+		if err := feed(waitCtx, term, proc, step); err != nil {
+			return failure(ctx, fmt.Errorf("input step %d (%s): %w", i+1, step.kind, err))
+		}
+	}
+
+	if cfg.waitFor != nil {
+		if err := term.WaitFor(waitCtx, headlessterm.WaitRegexp(cfg.waitFor)); err != nil {
+			if ctx.Err() == nil {
+				err = fmt.Errorf("process exited before the screen matched %q", cfg.waitFor)
+			}
+			return failure(ctx, err)
+		}
+		return 0, nil
+	}
+
+	select {
+	case <-proc.Done():
+		return exitStatus(proc), nil
+	case <-ctx.Done():
+		return failure(ctx, ctx.Err())
+	}
+}
+
+// exitStatus returns the exit code of the exited process, or 128 plus the
+// signal number if a signal killed it, like a shell reports it.
+func exitStatus(proc *pty.Session) int {
+	if ws, ok := proc.ProcessState().Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return proc.ExitCode()
+}
+
+// failure maps err to an exit status, reporting timeouts as exitTimeout.
+func failure(ctx context.Context, err error) (int, error) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return exitTimeout, errors.New("timed out")
+	}
+	return exitFailure, err
+}
+
+// feed applies one input step.
+func feed(ctx context.Context, term *headlessterm.Terminal, proc *pty.Session, step inputStep) error {
+	var data []byte
+	switch step.kind {
+	case "text":
+		data = []byte(step.arg)
+	case "paste":
+		data = term.EncodePaste(step.arg)
+	case "key":
+		seq, err := term.EncodeKey(step.arg)
+		if err != nil {
+			return err
+		}
+		data = seq
+	case "sleep":
+		select {
+		case <-time.After(step.dur):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case "wait":
+		return term.WaitFor(ctx, headlessterm.WaitRegexp(step.re))
+	}
+	_, err := proc.Write(data)
+	return err
+}
+
+// writeOutput writes data to path, or to stdout if path is empty.
+func writeOutput(path string, stdout io.Writer, data []byte) error {
+	if path == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// runCmd runs the CLI and returns its exit status and output.
+func runCmd(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := dispatch(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunText(t *testing.T) {
+	code, out, errOut := runCmd("run", "-rows", "5", "-cols", "30", "--", "sh", "-c", `printf 'hello\n\033[31mred\033[0m\n'; exit 3`)
+	if code != 3 {
+		t.Errorf("exit = %d, stderr %q", code, errOut)
+	}
+	if out != "hello\nred\n" {
+		t.Errorf("output = %q", out)
+	}
+
+	code, _, errOut = runCmd("run", "--", "sh", "-c", "kill -TERM $$")
+	if code != 128+int(syscall.SIGTERM) {
+		t.Errorf("exit after SIGTERM = %d, stderr %q", code, errOut)
+	}
+}
+
+func TestRunScriptedInputAndWaitFor(t *testing.T) {
+	script := `printf 'name? '; read name; echo "hi $name"; sleep 10`
+	code, out, errOut := runCmd("run", "-timeout", "5s", "-wait-for", `hi \w+`,
+		"-input", "wait:name\\?", "-input", "bob", "-input", "key:Backspace", "-input", "key:Enter",
+		"--", "sh", "-c", script)
+	if code != 0 {
+		t.Fatalf("exit = %d, stderr %q", code, errOut)
+	}
+	if !strings.Contains(out, "hi bo\n") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	code, out, errOut := runCmd("run", "-timeout", "200ms", "-wait-for", "never", "--", "sh", "-c", "echo partial; sleep 10")
+	if code != exitTimeout || !strings.Contains(errOut, "timed out") {
+		t.Errorf("exit = %d, stderr %q", code, errOut)
+	}
+	if !strings.Contains(out, "partial") {
+		t.Errorf("timeout capture = %q", out)
+	}
+
+	code, _, errOut = runCmd("run", "-wait-for", "never", "--", "true")
+	if code != exitFailure || !strings.Contains(errOut, "exited before") {
+		t.Errorf("early exit = %d, stderr %q", code, errOut)
+	}
+}
+
+func TestRunFormats(t *testing.T) {
+	code, out, _ := runCmd("run", "-rows", "3", "-cols", "10", "-format", "json", "--", "printf", "\033[1mbold")
+	if code != 0 {
+		t.Fatalf("exit = %d", code)
+	}
+	var snap headlessterm.Snapshot
+	if err := json.Unmarshal([]byte(out), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Size.Rows != 3 || snap.Lines[0].Text != "bold" || len(snap.Lines[0].Segments) == 0 || !snap.Lines[0].Segments[0].Attributes.Bold {
+		t.Errorf("snapshot = %+v", snap.Lines[0])
+	}
+
+	_, out, _ = runCmd("run", "-format", "html", "--", "echo", "<tag>")
+	if !strings.Contains(out, "&lt;tag&gt;") {
+		t.Errorf("html = %q", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "screen.png")
+	if code, _, errOut := runCmd("run", "-rows", "4", "-cols", "8", "-format", "png", "-o", path, "--", "echo", "x"); code != 0 {
+		t.Fatalf("png exit = %d, stderr %q", code, errOut)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 80 || b.Dy() != 80 {
+		t.Errorf("png size = %v", b)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"bogus"},
+		{"run"},
+		{"run", "-format", "gif", "--", "true"},
+		{"run", "-input", "sleep:soon", "--", "true"},
+		{"run", "-wait-for", "(", "--", "true"},
+	} {
+		if code, _, _ := runCmd(args...); code != exitUsage {
+			t.Errorf("%q: exit = %d, want %d", args, code, exitUsage)
+		}
+	}
+}
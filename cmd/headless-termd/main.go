@@ -0,0 +1,91 @@
+// Command headless-termd hosts headless terminal sessions behind a local
+// HTTP and WebSocket API, so programs written in any language can drive
+// terminals without linking Go code.
+//
+// Usage:
+//
+//	headless-termd [-addr 127.0.0.1:7681] [-scrollback 10000] [-token TOKEN] [-keep-exited 5m]
+//
+// Every request must carry the token as "Authorization: Bearer TOKEN". Unless
+// a token is set with -token or $HEADLESS_TERMD_TOKEN, a random one is
+// generated and printed to stderr at startup; a given token is never printed.
+// WebSocket clients that cannot set headers may pass it
+// as ?token=TOKEN instead. Requests for another host name than an IP
+// address, localhost or the listen host are rejected, as are cross-origin
+// requests and request bodies that are not application/json.
+//
+// Sessions whose process has exited are kept for -keep-exited, so clients can
+// still read the final screen and exit code, and are then removed.
+//
+// REST API (JSON bodies and responses):
+//
+//	POST   /sessions                 create: {"command": ["bash"], "rows": 24, "cols": 80,
+//	                                 "env": {"K": "V"}, "dir": "/tmp", "record": true}
+//	GET    /sessions                 list sessions
+//	GET    /sessions/{id}            session info
+//	DELETE /sessions/{id}            hang up and remove the session
+//	POST   /sessions/{id}/input      {"events": [{"text": "ls"}, {"key": "Enter"},
+//	                                 {"paste": "..."}, {"mouse": {"row": 0, "col": 0,
+//	                                 "button": "left", "action": "press", "mods": ["ctrl"]}}]}
+//	POST   /sessions/{id}/resize     {"rows": 40, "cols": 120}
+//	GET    /sessions/{id}/snapshot   ?detail=text|styled|full (default styled)
+//	GET    /sessions/{id}/recording  raw output bytes (sessions created with "record")
+//	GET    /sessions/{id}/search     ?q=text[&scrollback=true]
+//	GET    /sessions/{id}/ws         WebSocket, ?detail=text|styled|full
+//
+// The WebSocket sends a {"type": "snapshot"} message first, then
+// {"type": "delta"} messages with headlessterm.SnapshotDelta payloads as the
+// screen changes, plus "title", "bell" and a final "exit" message. Clients
+// send {"type": "input", "events": [...]} and {"type": "resize", "rows": r,
+// "cols": c} messages.
+//
+// Errors are returned as {"error": "message"} with a matching status code.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:7681", "listen address")
+	scrollback := flag.Int("scrollback", 10000, "scrollback lines per session")
+	token := flag.String("token", os.Getenv("HEADLESS_TERMD_TOKEN"), "bearer token required by every request (default random)")
+	keepExited := flag.Duration("keep-exited", defaultKeepExited, "how long sessions are kept after their process exits")
+	flag.Parse()
+
+	if *token == "" {
+		*token = newToken()
+		fmt.Fprintf(os.Stderr, "token: %s\n", *token)
+	}
+	srv, err := newServer(*scrollback, *addr, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv.keepExited = *keepExited
+	httpServer := &http.Server{Addr: *addr, Handler: srv.handler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("headless-termd listening on %s", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	srv.closeAll()
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+	"github.com/danielgatis/go-headless-term/pty"
+)
+
+// maxBodySize bounds JSON request bodies.
+const maxBodySize = 1 << 20
+
+// defaultKeepExited is how long sessions are kept after their process exits.
+const defaultKeepExited = 5 * time.Minute
+
+// server owns the sessions and serves the HTTP API.
+type server struct {
+	scrollback int
+	listenHost string // Host part of the listen address, empty for all interfaces
+	listenPort string
+	token      string        // Bearer token every request must carry
+	keepExited time.Duration // How long sessions are kept after their process exits
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session is a terminal with the process running on it.
+type session struct {
+	id      string
+	command []string
+	created time.Time
+	term    *headlessterm.Terminal
+	proc    *pty.Session
+}
+
+// newServer returns a server for the given listen address. Requests must
+// carry token as a bearer token.
+func newServer(scrollback int, addr, token string) (*server, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = ""
+	}
+	return &server{
+		scrollback: scrollback,
+		listenHost: host,
+		listenPort: port,
+		token:      token,
+		keepExited: defaultKeepExited,
+		sessions:   make(map[string]*session),
+	}, nil
+}
+
+// newToken returns a random bearer token.
+func newToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// handler returns the HTTP routes behind the request checks.
+func (s *server) handler() http.Handler {
+	return s.guard(s.routes())
+}
+
+// guard rejects requests that may come from a web page rather than a
+// local client: requests for another host name, which a DNS rebinding
+// attack would send, cross-origin requests, and requests without the
+// bearer token. Browsers cannot set headers on WebSocket connections, so
+// upgrades may pass the token as the token query parameter instead.
+func (s *server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			writeError(w, http.StatusForbidden, "host not allowed")
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !s.allowedOrigin(origin, r.Host) {
+			writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = ""
+			if headerContains(r.Header, "Upgrade", "websocket") {
+				token = r.URL.Query().Get("token")
+			}
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether a Host header names this server: its port
+// with an IP address, localhost or the host it listens on. Other names
+// could resolve to this server through DNS rebinding.
+func (s *server) allowedHost(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil || port != s.listenPort {
+		return false
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return net.ParseIP(host) != nil || host == "localhost" || (s.listenHost != "" && host == strings.ToLower(s.listenHost))
+}
+
+// allowedOrigin reports whether an Origin header is the server's own
+// origin, as for a page it served.
+func (s *server) allowedOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, host)
+}
+
+// routes returns the HTTP routes.
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", s.handleCreate)
+	mux.HandleFunc("GET /sessions", s.handleList)
+	mux.HandleFunc("GET /sessions/{id}", s.withSession(s.handleInfo))
+	mux.HandleFunc("DELETE /sessions/{id}", s.handleDelete)
+	mux.HandleFunc("POST /sessions/{id}/input", s.withSession(s.handleInput))
+	mux.HandleFunc("POST /sessions/{id}/resize", s.withSession(s.handleResize))
+	mux.HandleFunc("GET /sessions/{id}/snapshot", s.withSession(s.handleSnapshot))
+	mux.HandleFunc("GET /sessions/{id}/recording", s.withSession(s.handleRecording))
+	mux.HandleFunc("GET /sessions/{id}/search", s.withSession(s.handleSearch))
+	mux.HandleFunc("GET /sessions/{id}/ws", s.withSession(s.handleWebSocket))
+	return mux
+}
+
+// closeAll hangs up every session.
+func (s *server) closeAll() {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.proc.Close()
+	}
+}
+
+// lookup returns the session with the given ID, or nil.
+func (s *server) lookup(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// withSession resolves the {id} path value or responds with 404.
+func (s *server) withSession(h func(http.ResponseWriter, *http.Request, *session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess := s.lookup(r.PathValue("id"))
+		if sess == nil {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		h(w, r, sess)
+	}
+}
+
+// writeJSON writes v with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an {"error": msg} response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// readJSON decodes a request body into v. The body must be sent as
+// application/json, which browsers do not allow in cross-origin requests
+// without a preflight.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return false
+	}
+	return true
+}
+
+// createRequest is the body of POST /sessions.
+type createRequest struct {
+	Command []string          `json:"command"`
+	Rows    int               `json:"rows"`
+	Cols    int               `json:"cols"`
+	Env     map[string]string `json:"env"`
+	Dir     string            `json:"dir"`
+	Record  bool              `json:"record"`
+}
+
+// sessionInfo describes a session in API responses.
+type sessionInfo struct {
+	ID       string    `json:"id"`
+	Command  []string  `json:"command"`
+	Rows     int       `json:"rows"`
+	Cols     int       `json:"cols"`
+	Pid      int       `json:"pid"`
+	Title    string    `json:"title"`
+	Running  bool      `json:"running"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (sess *session) info() sessionInfo {
+	info := sessionInfo{
+		ID:      sess.id,
+		Command: sess.command,
+		Rows:    sess.term.Rows(),
+		Cols:    sess.term.Cols(),
+		Pid:     sess.proc.Pid(),
+		Title:   sess.term.Title(),
+		Running: true,
+		Created: sess.created,
+	}
+	select {
+	case <-sess.proc.Done():
+		code := sess.proc.ExitCode()
+		info.Running = false
+		info.ExitCode = &code
+	default:
+	}
+	return info
+}
+
+func (s *server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(req.Command) == 0 {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		req.Command = []string{shell}
+	}
+
+	opts := []headlessterm.Option{
+		headlessterm.WithSize(req.Rows, req.Cols),
+		headlessterm.WithScrollback(headlessterm.NewMemoryScrollback(s.scrollback)),
+	}
+	if req.Record {
+		opts = append(opts, headlessterm.WithRecording(headlessterm.NewMemoryRecording()))
+	}
+	term := headlessterm.New(opts...)
+
+	cmd := exec.Command(req.Command[0], req.Command[1:]...)
+	cmd.Dir = req.Dir
+	if len(req.Env) > 0 {
+		cmd.Env = os.Environ()
+		keys := make([]string, 0, len(req.Env))
+		for k := range req.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+req.Env[k])
+		}
+	}
+
+	proc, err := pty.Spawn(cmd, term, pty.Options{})
+	if errors.Is(err, pty.ErrUnsupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sess := &session{id: newID(), command: req.Command, created: time.Now(), term: term, proc: proc}
+	s.mu.Lock()
+	s.sessions[sess.id] = sess
+	s.mu.Unlock()
+	go s.reap(sess, s.keepExited)
+
+	writeJSON(w, http.StatusCreated, sess.info())
+}
+
+// reap removes the session once its process has been gone for keep.
+func (s *server) reap(sess *session, keep time.Duration) {
+	<-sess.proc.Done()
+	time.Sleep(keep)
+
+	s.mu.Lock()
+	if s.sessions[sess.id] != sess {
+		// Deleted or closed already
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+	sess.proc.Close()
+}
+
+// newID returns a random session identifier.
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	infos := make([]sessionInfo, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		infos = append(infos, sess.info())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Created.Before(infos[j].Created) })
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *server) handleInfo(w http.ResponseWriter, r *http.Request, sess *session) {
+	writeJSON(w, http.StatusOK, sess.info())
+}
+
+func (s *server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	sess := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if sess == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	sess.proc.Close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// inputEvent is one input item; exactly one field is set.
+type inputEvent struct {
+	Text  *string     `json:"text,omitempty"`
+	Key   *string     `json:"key,omitempty"`
+	Paste *string     `json:"paste,omitempty"`
+	Mouse *mouseInput `json:"mouse,omitempty"`
+}
+
+// mouseInput is the JSON form of a headlessterm.MouseEvent.
+type mouseInput struct {
+	Row    int      `json:"row"`
+	Col    int      `json:"col"`
+	Button string   `json:"button"`
+	Action string   `json:"action"`
+	Mods   []string `json:"mods"`
+}
+
+var (
+	mouseButtons = map[string]headlessterm.MouseButton{
+		"left": headlessterm.MouseLeft, "middle": headlessterm.MouseMiddle, "right": headlessterm.MouseRight,
+		"none": headlessterm.MouseNone, "": headlessterm.MouseNone,
+		"wheelup": headlessterm.MouseWheelUp, "wheeldown": headlessterm.MouseWheelDown,
+		"wheelleft": headlessterm.MouseWheelLeft, "wheelright": headlessterm.MouseWheelRight,
+	}
+	mouseActions = map[string]headlessterm.MouseAction{
+		"press": headlessterm.MousePress, "": headlessterm.MousePress,
+		"release": headlessterm.MouseRelease, "motion": headlessterm.MouseMotion,
+	}
+	mouseMods = map[string]headlessterm.KeyModifiers{
+		"shift": headlessterm.ModShift, "alt": headlessterm.ModAlt,
+		"ctrl": headlessterm.ModCtrl, "meta": headlessterm.ModMeta,
+	}
+)
+
+// encodeInput converts input events into the bytes to send to the process.
+// Keys, pastes and mouse events are encoded for the terminal's current modes.
+func encodeInput(term *headlessterm.Terminal, events []inputEvent) ([]byte, error) {
+	var out []byte
+	for i, ev := range events {
+		switch {
+		case ev.Text != nil:
+			out = append(out, *ev.Text...)
+		case ev.Key != nil:
+			seq, err := term.EncodeKey(*ev.Key)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			out = append(out, seq...)
+		case ev.Paste != nil:
+			out = append(out, term.EncodePaste(*ev.Paste)...)
+		case ev.Mouse != nil:
+			mev, err := ev.Mouse.event()
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			out = append(out, term.EncodeMouse(mev)...)
+		default:
+			return nil, fmt.Errorf("event %d: no text, key, paste or mouse", i)
+		}
+	}
+	return out, nil
+}
+
+// event converts the JSON form into a MouseEvent.
+func (m *mouseInput) event() (headlessterm.MouseEvent, error) {
+	button, ok := mouseButtons[strings.ToLower(m.Button)]
+	if !ok {
+		return headlessterm.MouseEvent{}, fmt.Errorf("unknown mouse button %q", m.Button)
+	}
+	action, ok := mouseActions[strings.ToLower(m.Action)]
+	if !ok {
+		return headlessterm.MouseEvent{}, fmt.Errorf("unknown mouse action %q", m.Action)
+	}
+	ev := headlessterm.MouseEvent{Row: m.Row, Col: m.Col, Button: button, Action: action}
+	for _, name := range m.Mods {
+		mod, ok := mouseMods[strings.ToLower(name)]
+		if !ok {
+			return headlessterm.MouseEvent{}, fmt.Errorf("unknown modifier %q", name)
+		}
+		ev.Mods |= mod
+	}
+	return ev, nil
+}
+
+// inputRequest is the body of POST /sessions/{id}/input.
+type inputRequest struct {
+	Events []inputEvent `json:"events"`
+}
+
+func (s *server) handleInput(w http.ResponseWriter, r *http.Request, sess *session) {
+	var req inputRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	data, err := encodeInput(sess.term, req.Events)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := sess.proc.Write(data); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resizeRequest is the body of POST /sessions/{id}/resize.
+type resizeRequest struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+func (s *server) handleResize(w http.ResponseWriter, r *http.Request, sess *session) {
+	var req resizeRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := sess.proc.Resize(req.Rows, req.Cols); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, pty.ErrClosed) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, sess.info())
+}
+
+// parseDetail reads the detail query parameter.
+func parseDetail(r *http.Request) (headlessterm.SnapshotDetail, error) {
+	switch detail := headlessterm.SnapshotDetail(r.URL.Query().Get("detail")); detail {
+	case "":
+		return headlessterm.SnapshotDetailStyled, nil
+	case headlessterm.SnapshotDetailText, headlessterm.SnapshotDetailStyled, headlessterm.SnapshotDetailFull:
+		return detail, nil
+	default:
+		return "", fmt.Errorf("unknown detail %q", detail)
+	}
+}
+
+func (s *server) handleSnapshot(w http.ResponseWriter, r *http.Request, sess *session) {
+	detail, err := parseDetail(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, sess.term.Snapshot(detail))
+}
+
+func (s *server) handleRecording(w http.ResponseWriter, r *http.Request, sess *session) {
+	if _, ok := sess.term.RecordingProvider().(headlessterm.NoopRecording); ok {
+		writeError(w, http.StatusNotFound, "session is not recorded")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(sess.term.RecordedData())
+}
+
+// searchMatch is a match position; scrollback rows are negative, -1 being
+// the most recent scrollback line.
+type searchMatch struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request, sess *session) {
+	q := r.URL.Query()
+	pattern := q.Get("q")
+	if pattern == "" {
+		writeError(w, http.StatusBadRequest, "missing q")
+		return
+	}
+
+	var positions []headlessterm.Position
+	if q.Get("scrollback") == "true" {
+		positions = append(positions, sess.term.SearchScrollback(pattern)...)
+	}
+	positions = append(positions, sess.term.Search(pattern)...)
+
+	matches := make([]searchMatch, len(positions))
+	for i, p := range positions {
+		matches[i] = searchMatch{Row: p.Row, Col: p.Col}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"matches": matches})
+}
@@ -0,0 +1,437 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// testToken is the bearer token of test servers.
+const testToken = "test-token"
+
+// newTestServer starts the API on 127.0.0.1 and closes all sessions at the end.
+func newTestServer(t *testing.T) (*server, *httptest.Server) {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil)
+	srv, err := newServer(1000, ts.Listener.Addr().String(), testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Config.Handler = srv.handler()
+	ts.Start()
+	t.Cleanup(func() {
+		ts.Close()
+		srv.closeAll()
+	})
+	return srv, ts
+}
+
+// call sends a JSON request and decodes the JSON response into out (if non-nil).
+func call(t *testing.T, method, url string, body, out any) int {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		r = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, url, r)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// createSession creates a session running script with sh.
+func createSession(t *testing.T, ts *httptest.Server, script string, extra map[string]any) sessionInfo {
+	t.Helper()
+	body := map[string]any{"command": []string{"sh", "-c", script}, "rows": 10, "cols": 40}
+	for k, v := range extra {
+		body[k] = v
+	}
+	var info sessionInfo
+	if code := call(t, "POST", ts.URL+"/sessions", body, &info); code != http.StatusCreated {
+		t.Fatalf("create = %d", code)
+	}
+	return info
+}
+
+// waitScreen polls the text snapshot until it contains want.
+func waitScreen(t *testing.T, ts *httptest.Server, id, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	var text string
+	for time.Now().Before(deadline) {
+		var snap headlessterm.Snapshot
+		call(t, "GET", ts.URL+"/sessions/"+id+"/snapshot?detail=text", nil, &snap)
+		var lines []string
+		for _, l := range snap.Lines {
+			lines = append(lines, l.Text)
+		}
+		text = strings.Join(lines, "\n")
+		if strings.Contains(text, want) {
+			return text
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("screen never contained %q:\n%s", want, text)
+	return ""
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	srv, ts := newTestServer(t)
+	info := createSession(t, ts, `printf 'ready\n'; read line; echo "got:$line"; echo "$GREETING"; sleep 10`,
+		map[string]any{"env": map[string]string{"GREETING": "hello-env"}, "record": true})
+	if info.Rows != 10 || info.Cols != 40 || !info.Running || info.Pid == 0 {
+		t.Errorf("info = %+v", info)
+	}
+
+	waitScreen(t, ts, info.ID, "ready")
+	input := map[string]any{"events": []map[string]any{{"text": "abc"}, {"key": "Backspace"}, {"key": "Enter"}}}
+	if code := call(t, "POST", ts.URL+"/sessions/"+info.ID+"/input", input, nil); code != http.StatusNoContent {
+		t.Fatalf("input = %d", code)
+	}
+	waitScreen(t, ts, info.ID, "got:ab")
+	waitScreen(t, ts, info.ID, "hello-env")
+
+	var result struct {
+		Matches []searchMatch `json:"matches"`
+	}
+	call(t, "GET", ts.URL+"/sessions/"+info.ID+"/search?q=got:", nil, &result)
+	if len(result.Matches) != 1 || result.Matches[0].Col != 0 {
+		t.Errorf("search = %+v", result.Matches)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/sessions/"+info.ID+"/recording", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Contains(recording, []byte("got:ab")) {
+		t.Errorf("recording = %q", recording)
+	}
+
+	var list []sessionInfo
+	call(t, "GET", ts.URL+"/sessions", nil, &list)
+	if len(list) != 1 || list[0].ID != info.ID {
+		t.Errorf("list = %+v", list)
+	}
+
+	if code := call(t, "DELETE", ts.URL+"/sessions/"+info.ID, nil, nil); code != http.StatusNoContent {
+		t.Errorf("delete = %d", code)
+	}
+	if srv.lookup(info.ID) != nil {
+		t.Error("session still registered")
+	}
+	if code := call(t, "GET", ts.URL+"/sessions/"+info.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("info after delete = %d", code)
+	}
+}
+
+func TestExitedSessionsAreRemoved(t *testing.T) {
+	srv, ts := newTestServer(t)
+	srv.keepExited = 500 * time.Millisecond
+	info := createSession(t, ts, `exit 7`, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got sessionInfo
+		code := call(t, "GET", ts.URL+"/sessions/"+info.ID, nil, &got)
+		if code != http.StatusOK {
+			t.Fatalf("info before removal = %d", code)
+		}
+		if !got.Running {
+			if got.ExitCode == nil || *got.ExitCode != 7 {
+				t.Errorf("exited info = %+v", got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("process never exited")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for srv.lookup(info.ID) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("exited session never removed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if code := call(t, "GET", ts.URL+"/sessions/"+info.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("info after removal = %d", code)
+	}
+}
+
+func TestResizeAndErrors(t *testing.T) {
+	_, ts := newTestServer(t)
+	info := createSession(t, ts, `stty size; read x; stty size; sleep 10`, nil)
+	waitScreen(t, ts, info.ID, "10 40")
+
+	var resized sessionInfo
+	if code := call(t, "POST", ts.URL+"/sessions/"+info.ID+"/resize", map[string]int{"rows": 20, "cols": 100}, &resized); code != http.StatusOK {
+		t.Fatalf("resize = %d", code)
+	}
+	if resized.Rows != 20 || resized.Cols != 100 {
+		t.Errorf("resized = %+v", resized)
+	}
+	call(t, "POST", ts.URL+"/sessions/"+info.ID+"/input", map[string]any{"events": []map[string]any{{"key": "Enter"}}}, nil)
+	waitScreen(t, ts, info.ID, "20 100")
+
+	var errResp map[string]string
+	if code := call(t, "POST", ts.URL+"/sessions/"+info.ID+"/input", map[string]any{"events": []map[string]any{{"key": "Hyper"}}}, &errResp); code != http.StatusBadRequest || errResp["error"] == "" {
+		t.Errorf("bad key = %d %v", code, errResp)
+	}
+	if code := call(t, "GET", ts.URL+"/sessions/"+info.ID+"/snapshot?detail=bogus", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("bad detail = %d", code)
+	}
+	if code := call(t, "GET", ts.URL+"/sessions/"+info.ID+"/recording", nil, nil); code != http.StatusNotFound {
+		t.Errorf("recording without record = %d", code)
+	}
+	if code := call(t, "POST", ts.URL+"/sessions", map[string]any{"command": []string{"/nonexistent"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("bad command = %d", code)
+	}
+}
+
+// wsClient is a minimal WebSocket client for tests.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, ts *httptest.Server, path string) *wsClient {
+	t.Helper()
+	conn, resp := handshakeWS(t, ts, path, "")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: %s", resp.Status)
+	}
+	return conn
+}
+
+// handshakeWS sends a WebSocket opening handshake with an optional Origin
+// header and returns the client and the server's response.
+func handshakeWS(t *testing.T, ts *httptest.Server, path, origin string) (*wsClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var raw [16]byte
+	rand.Read(raw[:])
+	key := base64.StdEncoding.EncodeToString(raw[:])
+	var extra string
+	if origin != "" {
+		extra = "Origin: " + origin + "\r\n"
+	}
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n%s\r\n",
+		path, conn.RemoteAddr(), key, extra)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols && resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		t.Fatalf("handshake: %s %v", resp.Status, resp.Header)
+	}
+	return &wsClient{conn: conn, br: br}, resp
+}
+
+// send writes a masked frame, split in two fragments to exercise reassembly.
+func (c *wsClient) send(t *testing.T, v any) {
+	t.Helper()
+	data, _ := json.Marshal(v)
+	half := len(data) / 2
+	c.writeFrame(opText, false, data[:half])
+	c.writeFrame(opPing, true, []byte("p"))
+	c.writeFrame(opContinuation, true, data[half:])
+}
+
+func (c *wsClient) writeFrame(op byte, fin bool, payload []byte) {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.conn.Write(frame)
+}
+
+// next returns the next data message, skipping pongs.
+func (c *wsClient) next(t *testing.T) wsMessage {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		n := int(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint64(ext[:]))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			t.Fatalf("read payload: %v", err)
+		}
+		switch hdr[0] & 0x0f {
+		case opPong:
+			continue
+		case opClose:
+			return wsMessage{Type: "close"}
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatalf("decode %q: %v", payload, err)
+		}
+		return msg
+	}
+}
+
+func TestWebSocketStreamsDeltas(t *testing.T) {
+	_, ts := newTestServer(t)
+	info := createSession(t, ts, `printf '\033]2;demo\007ready\n'; read line; echo "got:$line"; exit 7`, nil)
+	waitScreen(t, ts, info.ID, "ready")
+
+	ws := dialWS(t, ts, "/sessions/"+info.ID+"/ws?detail=text&token="+testToken)
+	first := ws.next(t)
+	if first.Type != "snapshot" || first.Snapshot == nil {
+		t.Fatalf("first message = %+v", first)
+	}
+	snap := first.Snapshot
+
+	ws.send(t, map[string]any{"type": "input", "events": []map[string]any{{"text": "ws"}, {"key": "Enter"}}})
+
+	var exitCode *int
+	for exitCode == nil {
+		msg := ws.next(t)
+		switch msg.Type {
+		case "delta":
+			snap.ApplyDelta(msg.Delta)
+		case "exit":
+			exitCode = msg.ExitCode
+		case "error", "close":
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	if *exitCode != 7 {
+		t.Errorf("exit code = %d, want 7", *exitCode)
+	}
+	var text []string
+	for _, l := range snap.Lines {
+		text = append(text, l.Text)
+	}
+	if !strings.Contains(strings.Join(text, "\n"), "got:ws") {
+		t.Errorf("snapshot after deltas:\n%s", strings.Join(text, "\n"))
+	}
+}
+
+func TestWebSocketRejectsPlainRequests(t *testing.T) {
+	_, ts := newTestServer(t)
+	info := createSession(t, ts, `sleep 10`, nil)
+	if code := call(t, "GET", ts.URL+"/sessions/"+info.ID+"/ws", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("plain GET = %d, want 400", code)
+	}
+}
+
+func TestRejectsRequestsFromWebPages(t *testing.T) {
+	_, ts := newTestServer(t)
+	info := createSession(t, ts, `sleep 10`, nil)
+
+	send := func(method, path, body string, header map[string]string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		for k, v := range header {
+			if k == "Host" {
+				req.Host = v
+			} else {
+				req.Header.Set(k, v)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	auth := "Bearer " + testToken
+	create := `{"command":["sh","-c","touch /tmp/pwned"]}`
+
+	if code := send("POST", "/sessions", create, map[string]string{"Content-Type": "application/json"}); code != http.StatusUnauthorized {
+		t.Errorf("without token = %d, want 401", code)
+	}
+	if code := send("GET", "/sessions", "", map[string]string{"Authorization": "Bearer wrong"}); code != http.StatusUnauthorized {
+		t.Errorf("wrong token = %d, want 401", code)
+	}
+	if code := send("POST", "/sessions", create, map[string]string{"Authorization": auth, "Content-Type": "text/plain"}); code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain body = %d, want 415", code)
+	}
+	if code := send("POST", "/sessions", create, map[string]string{"Authorization": auth, "Content-Type": "application/json", "Origin": "https://evil.example"}); code != http.StatusForbidden {
+		t.Errorf("cross-origin = %d, want 403", code)
+	}
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	if code := send("GET", "/sessions", "", map[string]string{"Authorization": auth, "Host": "evil.example:" + port}); code != http.StatusForbidden {
+		t.Errorf("rebound host = %d, want 403", code)
+	}
+	if code := send("GET", "/sessions", "", map[string]string{"Authorization": auth, "Host": "localhost:" + port}); code != http.StatusOK {
+		t.Errorf("localhost = %d, want 200", code)
+	}
+
+	path := "/sessions/" + info.ID + "/ws?token=" + testToken
+	if _, resp := handshakeWS(t, ts, path, "https://evil.example"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin websocket = %s, want 403", resp.Status)
+	}
+	if _, resp := handshakeWS(t, ts, "/sessions/"+info.ID+"/ws", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("websocket without token = %s, want 401", resp.Status)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// Minimal server side of RFC 6455: text and binary messages with
+// fragmentation, ping/pong and close. Extensions are not negotiated.
+
+const (
+	wsGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessageSize = 1 << 20
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+var errWSClosed = errors.New("websocket: closed")
+
+// wsConn is an upgraded WebSocket connection. Writes are serialized;
+// ReadMessage must be called from a single goroutine.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// wsAccept computes the Sec-WebSocket-Accept value for a key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains reports whether a comma-separated header has token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket performs the opening handshake. On failure it writes an
+// HTTP error and returns nil.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) *wsConn {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		writeError(w, http.StatusBadRequest, "websocket upgrade required")
+		return nil
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "unsupported websocket version")
+		return nil
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "connection cannot be upgraded")
+		return nil
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil
+	}
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil
+	}
+	return &wsConn{conn: conn, br: brw.Reader}
+}
+
+// ReadMessage returns the next data message, answering pings on the way.
+// It returns errWSClosed after a close frame.
+func (c *wsConn) ReadMessage() (opcode byte, payload []byte, err error) {
+	for {
+		fin, op, data, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, data)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, data)
+			c.conn.Close()
+			return 0, nil, errWSClosed
+		case opText, opBinary:
+		default:
+			return 0, nil, fmt.Errorf("websocket: unexpected opcode %#x", op)
+		}
+
+		payload = data
+		for !fin {
+			var cont byte
+			fin, cont, data, err = c.readFrame()
+			if err != nil {
+				return 0, nil, err
+			}
+			switch cont {
+			case opPing:
+				c.writeFrame(opPong, data)
+				fin = false
+				continue
+			case opPong:
+				fin = false
+				continue
+			case opContinuation:
+			default:
+				return 0, nil, fmt.Errorf("websocket: unexpected opcode %#x in fragmented message", cont)
+			}
+			if len(payload)+len(data) > wsMaxMessageSize {
+				return 0, nil, errors.New("websocket: message too large")
+			}
+			payload = append(payload, data...)
+		}
+		return op, payload, nil
+	}
+}
+
+// readFrame reads one frame and unmasks its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0f
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket: reserved bits set")
+	}
+	if hdr[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket: client frame not masked")
+	}
+
+	length := uint64(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame writes a single unmasked frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWSClosed
+	}
+
+	hdr := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(hdr, payload...)); err != nil {
+		return err
+	}
+	if op == opClose {
+		c.closed = true
+	}
+	return nil
+}
+
+// WriteJSON sends v as a text message.
+func (c *wsConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// Close sends a normal closure frame and closes the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(opClose, []byte{0x03, 0xe8})
+	return c.conn.Close()
+}
+
+// wsMessage is a message exchanged over the session WebSocket.
+type wsMessage struct {
+	Type     string                      `json:"type"`
+	Snapshot *headlessterm.Snapshot      `json:"snapshot,omitempty"`
+	Delta    *headlessterm.SnapshotDelta `json:"delta,omitempty"`
+	Title    *string                     `json:"title,omitempty"`
+	ExitCode *int                        `json:"exit_code,omitempty"`
+	Error    string                      `json:"error,omitempty"`
+
+	// Client messages
+	Events []inputEvent `json:"events,omitempty"`
+	Rows   int          `json:"rows,omitempty"`
+	Cols   int          `json:"cols,omitempty"`
+}
+
+func (s *server) handleWebSocket(w http.ResponseWriter, r *http.Request, sess *session) {
+	detail, err := parseDetail(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ws := upgradeWebSocket(w, r)
+	if ws == nil {
+		return
+	}
+	defer ws.Close()
+
+	sub := sess.term.Subscribe(headlessterm.EventAll)
+	defer sub.Close()
+
+	readErr := make(chan error, 1)
+	go func() { readErr <- s.readClientMessages(ws, sess) }()
+
+	snap := sess.term.Snapshot(detail)
+	if ws.WriteJSON(wsMessage{Type: "snapshot", Snapshot: snap}) != nil {
+		return
+	}
+	gen, cursor := snap.Generation, snap.Cursor
+
+	for {
+		select {
+		case <-readErr:
+			return
+		case <-sess.proc.Done():
+			if d := sess.term.SnapshotDelta(gen, detail); !d.IsEmpty() || d.Cursor != cursor {
+				ws.WriteJSON(wsMessage{Type: "delta", Delta: d})
+			}
+			code := sess.proc.ExitCode()
+			ws.WriteJSON(wsMessage{Type: "exit", ExitCode: &code})
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			switch ev := ev.(type) {
+			case headlessterm.TitleChangedEvent:
+				if ws.WriteJSON(wsMessage{Type: "title", Title: &ev.Title}) != nil {
+					return
+				}
+			case headlessterm.BellEvent:
+				if ws.WriteJSON(wsMessage{Type: "bell"}) != nil {
+					return
+				}
+			}
+			d := sess.term.SnapshotDelta(gen, detail)
+			if d.IsEmpty() && d.Cursor == cursor {
+				continue
+			}
+			if ws.WriteJSON(wsMessage{Type: "delta", Delta: d}) != nil {
+				return
+			}
+			gen, cursor = d.Generation, d.Cursor
+		}
+	}
+}
+
+// readClientMessages applies input and resize messages until the client
+// disconnects. Invalid messages are answered with an error message.
+func (s *server) readClientMessages(ws *wsConn, sess *session) error {
+	for {
+		op, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if op != opText {
+			continue
+		}
+
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			ws.WriteJSON(wsMessage{Type: "error", Error: fmt.Sprintf("invalid message: %v", err)})
+			continue
+		}
+		switch msg.Type {
+		case "input":
+			input, err := encodeInput(sess.term, msg.Events)
+			if err == nil {
+				_, err = sess.proc.Write(input)
+			}
+			if err != nil {
+				ws.WriteJSON(wsMessage{Type: "error", Error: err.Error()})
+			}
+		case "resize":
+			if err := sess.proc.Resize(msg.Rows, msg.Cols); err != nil {
+				ws.WriteJSON(wsMessage{Type: "error", Error: err.Error()})
+			}
+		default:
+			ws.WriteJSON(wsMessage{Type: "error", Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+		}
+	}
+}
@@ -163,3 +163,61 @@ func resolveNamedColor(name int, fg bool) color.RGBA {
 		return DefaultBackground
 	}
 }
+
+// resolveColorLocked converts a cell color to RGBA, honoring palette overrides
+// set via OSC 4/10/11 before falling back to the defaults (caller must hold lock).
+func (t *Terminal) resolveColorLocked(c color.Color, fg bool) color.RGBA {
+	index := -1
+	switch v := c.(type) {
+	case nil:
+		index = NamedColorBackground
+		if fg {
+			index = NamedColorForeground
+		}
+	case *IndexedColor:
+		index = v.Index
+	case *NamedColor:
+		index = v.Name
+	}
+	if override, ok := t.colors[index]; ok && override != nil {
+		return resolveDefaultColor(override, fg)
+	}
+	return resolveDefaultColor(c, fg)
+}
+
+// cellColors holds the final colors of a cell after applying reverse video, dim and hidden.
+type cellColors struct {
+	Fg, Bg    color.RGBA
+	Underline color.RGBA
+	DefaultBg bool // Background is the terminal background (no fill needed)
+}
+
+// resolveCellColorsLocked computes the colors a renderer should use for a cell (caller must hold lock).
+func (t *Terminal) resolveCellColorsLocked(c *Cell) cellColors {
+	fg := t.resolveColorLocked(c.Fg, true)
+	bg := t.resolveColorLocked(c.Bg, false)
+	defaultBg := isDefaultBackground(c.Bg)
+
+	if c.HasFlag(CellFlagReverse) {
+		fg, bg = bg, fg
+		defaultBg = false
+	}
+	if c.HasFlag(CellFlagDim) {
+		fg = color.RGBA{
+			R: uint8(float64(fg.R) * 0.66),
+			G: uint8(float64(fg.G) * 0.66),
+			B: uint8(float64(fg.B) * 0.66),
+			A: fg.A,
+		}
+	}
+	if c.HasFlag(CellFlagHidden) {
+		fg = bg
+	}
+
+	ul := fg
+	if c.UnderlineColor != nil {
+		ul = t.resolveColorLocked(c.UnderlineColor, true)
+	}
+
+	return cellColors{Fg: fg, Bg: bg, Underline: ul, DefaultBg: defaultBg}
+}
@@ -0,0 +1,258 @@
+package headlessterm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// Command is a shell command recorded from OSC 133 prompt marks. The
+// prompt start (A), command start (B), command executed (C) and command
+// finished (D) marks delimit its prompt, command line and output.
+type Command struct {
+	// Index is the position of the command in Commands when it was
+	// returned. It shifts down as older commands are dropped.
+	Index int
+	// Prompt is the prompt text, from the A mark to the B mark.
+	Prompt string
+	// CommandLine is the command as typed, from the B mark to the C mark.
+	// Multi-line commands are joined with '\n'.
+	CommandLine string
+	// PromptStart is the absolute position of the A mark, or of the first
+	// mark received for the command if the shell sent no A mark. Like the
+	// other positions, it is current when the command is returned.
+	PromptStart Position
+	// OutputStart is the absolute position of the C mark.
+	OutputStart Position
+	// OutputEnd is the absolute position of the D mark, just past the last
+	// output cell. It is zero while the command runs.
+	OutputEnd Position
+	// ExitCode is the exit status from the D mark, or -1 while the command
+	// runs or if the shell did not report one.
+	ExitCode int
+	// WorkingDir is the OSC 7 working directory URI when the command started.
+	WorkingDir string
+	// StartedAt and FinishedAt are when the C and D marks were received.
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Finished reports whether the D mark was received.
+	Finished bool
+	// Evicted reports whether the prompt line of a running command was
+	// evicted from the scrollback. Positions on evicted lines are reported
+	// as (0, 0), and Output only returns the lines that are left. Finished
+	// commands are dropped from the history once their prompt line is
+	// evicted.
+	Evicted bool
+
+	t *Terminal
+	// Positions anchored to line IDs, from which the exported positions
+	// are computed when the command is returned
+	promptStart, outputStart, outputEnd linePos
+}
+
+// Duration returns how long the command ran, or 0 while it runs.
+func (c *Command) Duration() time.Duration {
+	if !c.Finished {
+		return 0
+	}
+	return c.FinishedAt.Sub(c.StartedAt)
+}
+
+// Output returns the output of the command rendered in format. The output
+// of a running command ends at the cursor. Rows evicted from the scrollback
+// are left out.
+//
+// Example:
+//
+//	for _, cmd := range term.Commands() {
+//	    if cmd.ExitCode != 0 {
+//	        report(cmd.CommandLine, cmd.Output(headlessterm.SelectionText))
+//	    }
+//	}
+func (c *Command) Output(format SelectionFormat) string {
+	if c == nil || c.t == nil {
+		return ""
+	}
+	t := c.t
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	start, _ := t.absolutePosLocked(c.outputStart)
+	end, _ := t.absolutePosLocked(c.outputEnd)
+	if !c.Finished {
+		end = Position{Row: t.cursor.Row + t.primaryBuffer.ScrollbackLen(), Col: t.cursor.Col}
+	}
+	last, ok := t.beforeLocked(end)
+	if !ok || last.Before(start) {
+		return t.renderLinesLocked(nil, format)
+	}
+	return t.renderLinesLocked(t.rangeLinesLocked(start, last, false), format)
+}
+
+// Commands returns the recorded commands, oldest first. A command is
+// recorded when its C mark is received, and dropped once it has finished
+// and its prompt line is evicted from the scrollback, so the history is
+// bounded by the scrollback.
+func (t *Terminal) Commands() []Command {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.commandsLocked()
+}
+
+// commandsLocked returns copies of the commands with their positions
+// updated (caller must hold lock).
+func (t *Terminal) commandsLocked() []Command {
+	live := t.commands[t.evictedCommandsLocked():]
+	cmds := make([]Command, len(live))
+	for i := range live {
+		cmds[i] = t.commandLocked(&live[i], i)
+	}
+	return cmds
+}
+
+// Command returns a copy of the i-th recorded command, or nil if there is
+// none. Negative indexes count from the end, so Command(-1) is the latest.
+func (t *Terminal) Command(i int) *Command {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	live := t.commands[t.evictedCommandsLocked():]
+	if i < 0 {
+		i += len(live)
+	}
+	if i < 0 || i >= len(live) {
+		return nil
+	}
+	c := t.commandLocked(&live[i], i)
+	return &c
+}
+
+// commandLocked returns a copy of a command at index i with its positions
+// updated (caller must hold lock).
+func (t *Terminal) commandLocked(cmd *Command, i int) Command {
+	c := *cmd
+	c.Index = i
+	var ok bool
+	c.PromptStart, ok = t.absolutePosLocked(c.promptStart)
+	c.Evicted = !ok
+	c.OutputStart, _ = t.absolutePosLocked(c.outputStart)
+	if c.Finished {
+		c.OutputEnd, _ = t.absolutePosLocked(c.outputEnd)
+	}
+	return c
+}
+
+// evictedCommandsLocked returns the number of commands at the start of the
+// history that have finished and whose prompt line was evicted (caller must
+// hold lock).
+func (t *Terminal) evictedCommandsLocked() int {
+	n := 0
+	for n < len(t.commands) && t.commands[n].Finished && t.lineRowLocked(t.commands[n].promptStart.line) < 0 {
+		n++
+	}
+	return n
+}
+
+// dropEvictedCommandsLocked removes the commands that evictedCommandsLocked
+// counts (caller must hold lock).
+func (t *Terminal) dropEvictedCommandsLocked() {
+	t.commands = t.commands[t.evictedCommandsLocked():]
+}
+
+// restoreCommandsLocked anchors commands restored from state to their
+// lines, given the number of lines evicted before the saved scrollback
+// (caller must hold lock).
+func (t *Terminal) restoreCommandsLocked(cmds []Command, evicted uint64) {
+	anchor := func(pos Position) linePos {
+		return linePos{line: evicted + uint64(max(pos.Row, 0)), col: pos.Col}
+	}
+	t.commands = cmds
+	t.pendingCommand = nil
+	for i := range t.commands {
+		c := &t.commands[i]
+		c.t = t
+		c.promptStart = anchor(c.PromptStart)
+		if c.Evicted {
+			// Line 0 is evicted whenever a prompt line was
+			c.promptStart = linePos{}
+		}
+		c.outputStart = anchor(c.OutputStart)
+		c.outputEnd = anchor(c.OutputEnd)
+	}
+}
+
+// ClearCommands removes all recorded commands.
+func (t *Terminal) ClearCommands() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commands = nil
+	t.pendingCommand = nil
+}
+
+// recordCommandMarkLocked updates the command history with a prompt mark
+// received at an absolute position (caller must hold lock).
+func (t *Terminal) recordCommandMarkLocked(mark ansicode.ShellIntegrationMark, exitCode int, pos Position) {
+	t.dropEvictedCommandsLocked()
+	at := t.linePosLocked(pos)
+	switch mark {
+	case ansicode.PromptStart:
+		t.pendingCommand = &Command{ExitCode: -1, t: t, promptStart: at}
+
+	case ansicode.CommandStart:
+		if t.pendingCommand == nil {
+			t.pendingCommand = &Command{ExitCode: -1, t: t, promptStart: at}
+		}
+		c := t.pendingCommand
+		c.Prompt = t.markedTextLocked(c.promptStart, pos)
+		c.outputStart = at // Start of the command line until the C mark
+
+	case ansicode.CommandExecuted:
+		c := t.pendingCommand
+		if c == nil {
+			c = &Command{ExitCode: -1, t: t, promptStart: at, outputStart: at}
+		}
+		t.pendingCommand = nil
+		c.CommandLine = t.markedTextLocked(c.outputStart, pos)
+		c.outputStart = at
+		c.WorkingDir = t.workingDir
+		c.StartedAt = time.Now()
+		t.commands = append(t.commands, *c)
+		i := len(t.commands) - 1
+		t.queueEventLocked(CommandStartedEvent{Command: t.commandLocked(&t.commands[i], i)})
+
+	case ansicode.CommandFinished:
+		if len(t.commands) == 0 || t.commands[len(t.commands)-1].Finished {
+			return
+		}
+		c := &t.commands[len(t.commands)-1]
+		c.outputEnd = at
+		c.ExitCode = exitCode
+		c.FinishedAt = time.Now()
+		c.Finished = true
+		t.queueEventLocked(CommandFinishedEvent{Command: t.commandLocked(c, len(t.commands)-1)})
+	}
+}
+
+// markedTextLocked returns the text from an anchored position up to, but
+// not including, an absolute one, without trailing blanks (caller must hold lock).
+func (t *Terminal) markedTextLocked(from linePos, to Position) string {
+	start, _ := t.absolutePosLocked(from)
+	last, ok := t.beforeLocked(to)
+	if !ok || last.Before(start) {
+		return ""
+	}
+	return strings.TrimRight(selectionText(t.rangeLinesLocked(start, last, false)), " ")
+}
+
+// beforeLocked returns the absolute position of the cell before pos, which
+// is the last column of the previous row at column 0 (caller must hold lock).
+func (t *Terminal) beforeLocked(pos Position) (Position, bool) {
+	if pos.Col > 0 {
+		return Position{Row: pos.Row, Col: pos.Col - 1}, true
+	}
+	if pos.Row == 0 {
+		return Position{}, false
+	}
+	return Position{Row: pos.Row - 1, Col: t.cols - 1}, true
+}
@@ -0,0 +1,148 @@
+package headlessterm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// runCommand writes a prompt, a command line and its output with OSC 133
+// marks, as a shell with shell integration would.
+func runCommand(term *Terminal, cmd, output, exitCode string) {
+	term.WriteString("\x1b]133;A\x07~ $ \x1b]133;B\x07" + cmd + "\r\n\x1b]133;C\x07" + output + "\x1b]133;D;" + exitCode + "\x07")
+}
+
+func TestCommands(t *testing.T) {
+	term := New(WithSize(10, 40))
+	term.WriteString("\x1b]7;file://host/home/me\x07")
+	runCommand(term, "ls", "a.txt\r\nb.txt\r\n", "0")
+	runCommand(term, "false", "", "1")
+	term.WriteString("\x1b]133;A\x07~ $ \x1b]133;B\x07sleep 1\r\n\x1b]133;C\x07zz")
+
+	cmds := term.Commands()
+	if len(cmds) != 3 {
+		t.Fatalf("commands = %+v", cmds)
+	}
+	ls := cmds[0]
+	if ls.Index != 0 || ls.Prompt != "~ $" || ls.CommandLine != "ls" || ls.ExitCode != 0 || ls.WorkingDir != "file://host/home/me" {
+		t.Errorf("ls = %+v", ls)
+	}
+	if ls.PromptStart != (Position{0, 0}) || ls.OutputStart != (Position{1, 0}) || ls.OutputEnd != (Position{3, 0}) {
+		t.Errorf("ls range = %v %v %v", ls.PromptStart, ls.OutputStart, ls.OutputEnd)
+	}
+	if !ls.Finished || ls.StartedAt.IsZero() || ls.FinishedAt.Before(ls.StartedAt) || ls.Duration() < 0 {
+		t.Errorf("ls timing = %v %v", ls.StartedAt, ls.FinishedAt)
+	}
+	if got := ls.Output(SelectionText); got != "a.txt\nb.txt" {
+		t.Errorf("ls output = %q", got)
+	}
+	if got := ls.Output(SelectionMarkdown); got != "```\na.txt\nb.txt\n```\n" {
+		t.Errorf("ls markdown output = %q", got)
+	}
+
+	if f := term.Command(1); f == nil || f.CommandLine != "false" || f.ExitCode != 1 || f.Output(SelectionText) != "" {
+		t.Errorf("false = %+v", f)
+	}
+
+	running := term.Command(-1)
+	if running.Finished || running.ExitCode != -1 || running.Duration() != 0 || running.CommandLine != "sleep 1" {
+		t.Errorf("running = %+v", running)
+	}
+	if got := running.Output(SelectionText); got != "zz" {
+		t.Errorf("running output = %q", got)
+	}
+	if term.Command(3) != nil || term.Command(-4) != nil {
+		t.Error("Command out of range returned a command")
+	}
+
+	term.ClearCommands()
+	if len(term.Commands()) != 0 {
+		t.Error("ClearCommands kept commands")
+	}
+}
+
+func TestCommands_MissingMarks(t *testing.T) {
+	term := New(WithSize(5, 20))
+	// A D mark without a running command is ignored
+	term.WriteString("\x1b]133;D;0\x07")
+	// A C mark without A and B records a command with no prompt
+	term.WriteString("\x1b]133;C\x07out\r\n\x1b]133;D\x07")
+
+	cmds := term.Commands()
+	if len(cmds) != 1 || cmds[0].Prompt != "" || cmds[0].CommandLine != "" || cmds[0].ExitCode != -1 || !cmds[0].Finished {
+		t.Fatalf("commands = %+v", cmds)
+	}
+	if got := cmds[0].Output(SelectionText); got != "out" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestCommands_Events(t *testing.T) {
+	term := New(WithSize(5, 20))
+	sub := term.Subscribe(EventCommandStarted, EventCommandFinished)
+	defer sub.Close()
+
+	runCommand(term, "make", "ok\r\n", "2")
+	started := waitEvent(t, sub, EventCommandStarted).(CommandStartedEvent)
+	if started.Command.CommandLine != "make" || started.Command.Finished {
+		t.Errorf("started = %+v", started.Command)
+	}
+	finished := waitEvent(t, sub, EventCommandFinished).(CommandFinishedEvent)
+	if finished.Command.ExitCode != 2 || finished.Command.Output(SelectionText) != "ok" {
+		t.Errorf("finished = %+v", finished.Command)
+	}
+}
+
+func TestCommands_State(t *testing.T) {
+	term := New(WithSize(5, 20))
+	runCommand(term, "echo hi", "hi\r\n", "0")
+
+	var buf bytes.Buffer
+	if err := term.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cmd := restored.Command(0)
+	if cmd == nil || cmd.CommandLine != "echo hi" || cmd.Output(SelectionText) != "hi" {
+		t.Errorf("restored command = %+v", cmd)
+	}
+}
+
+func TestCommands_Eviction(t *testing.T) {
+	term := New(WithSize(3, 20), WithScrollback(NewMemoryScrollback(2)))
+	term.WriteString("\x1b]133;A\x07~ $ \x1b]133;B\x07seq 4\r\n\x1b]133;C\x071\r\n2\r\n3\r\n4\r\n")
+
+	// Lines 0 to 4 are the prompt and four output lines, and the prompt
+	// line of the running command is evicted
+	cmd := term.Command(0)
+	if !cmd.Evicted || cmd.PromptStart != (Position{}) || cmd.OutputStart != (Position{0, 0}) {
+		t.Errorf("command = %+v", cmd)
+	}
+	if got := cmd.Output(SelectionText); got != "1\n2\n3\n4" {
+		t.Errorf("output = %q", got)
+	}
+
+	// A finished command stays until its prompt line is evicted
+	term.WriteString("\x1b]133;D;0\x07")
+	if cmd := term.Command(0); cmd != nil {
+		t.Errorf("finished command with an evicted prompt = %+v", cmd)
+	}
+
+	runCommand(term, "true", "", "0")
+	runCommand(term, "false", "", "1")
+	cmds := term.Commands()
+	if len(cmds) != 2 || cmds[0].CommandLine != "true" || cmds[1].Index != 1 {
+		t.Fatalf("commands = %+v", cmds)
+	}
+	term.WriteString("\r\n\r\n\r\n")
+	if cmds := term.Commands(); len(cmds) != 1 || cmds[0].CommandLine != "false" || cmds[0].Index != 0 {
+		t.Errorf("commands after evicting a prompt = %+v", cmds)
+	}
+	// The next mark drops evicted commands from the history
+	term.WriteString("\x1b]133;A\x07")
+	if n := len(term.commands); n != 1 {
+		t.Errorf("history holds %d commands, want 1", n)
+	}
+}
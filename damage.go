@@ -0,0 +1,210 @@
+package headlessterm
+
+const (
+	// maxRowSpans bounds the damage history kept per row; older spans are merged.
+	maxRowSpans = 4
+	// maxScrollRecords bounds the scroll history kept per buffer.
+	maxScrollRecords = 64
+)
+
+// Damage describes how the active screen changed since a generation.
+// Apply Scrolls in order, then redraw Spans (given in current coordinates).
+type Damage struct {
+	Since      Generation // Generation the damage is relative to
+	Generation Generation // Current generation; pass it to the next DamageSince call
+	Rows, Cols int        // Current dimensions
+
+	// Full is set when everything must be redrawn: the screen was resized,
+	// switched or restored, or the history for Since is no longer available.
+	Full bool
+
+	Scrolls       []ScrollDamage
+	Spans         []DamageSpan
+	ImagesChanged bool // Image placements changed
+}
+
+// IsEmpty reports whether nothing changed.
+func (d Damage) IsEmpty() bool {
+	return !d.Full && len(d.Scrolls) == 0 && len(d.Spans) == 0 && !d.ImagesChanged
+}
+
+// ScrollDamage is a scroll of the rows [Top, Bottom) by Lines.
+// Positive Lines scroll content up, negative down; rows scrolled in are
+// reported as damaged spans.
+type ScrollDamage struct {
+	Top, Bottom int
+	Lines       int
+}
+
+// DamageSpan is a changed column range [StartCol, EndCol) of a row.
+type DamageSpan struct {
+	Row              int
+	StartCol, EndCol int
+}
+
+// damageSpan is a column range of a row changed at gen.
+type damageSpan struct {
+	gen        Generation
+	start, end int
+}
+
+// scrollRecord is a scroll applied at gen.
+type scrollRecord struct {
+	gen Generation
+	ScrollDamage
+}
+
+// DamageSince reports what changed in the active screen after generation since,
+// without clearing any state, so any number of renderers can track the screen
+// independently. Pass 0 to get full damage.
+//
+// Unlike DirtyCells, the result is proportional to the number of changed rows,
+// and scrolls are reported as ScrollDamage instead of damaging every moved row.
+//
+// Example:
+//
+//	var gen headlessterm.Generation
+//	for range ticker.C {
+//	    d := term.DamageSince(gen)
+//	    gen = d.Generation
+//	    render(d)
+//	}
+func (t *Terminal) DamageSince(since Generation) Damage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.damageSinceLocked(since)
+}
+
+// damageSinceLocked computes DamageSince (caller must hold lock).
+func (t *Terminal) damageSinceLocked(since Generation) Damage {
+	now := t.clock.now()
+	d := Damage{
+		Since:         since,
+		Generation:    now,
+		Rows:          t.rows,
+		Cols:          t.cols,
+		ImagesChanged: t.images.changedAt() > since,
+	}
+	if since == 0 || since > now || t.screenGen > since {
+		d.Full = true
+		return d
+	}
+	d.Scrolls, d.Spans, d.Full = t.activeBuffer.damageSince(since)
+	return d
+}
+
+// touchCols records that columns [start, end) of a row changed at a new generation.
+func (b *Buffer) touchCols(row, start, end int) {
+	if b.clock == nil || row < 0 || row >= len(b.lineGen) {
+		return
+	}
+	gen := b.clock.next()
+	b.lineGen[row] = gen
+	b.addSpan(row, damageSpan{gen: gen, start: max(start, 0), end: min(end, b.cols)})
+}
+
+// addSpan appends a span to a row's damage history, merging where possible.
+func (b *Buffer) addSpan(row int, s damageSpan) {
+	if s.start >= s.end {
+		return
+	}
+	spans := b.spans[row]
+
+	// A full-row span supersedes the whole history
+	if s.start == 0 && s.end >= b.cols {
+		b.spans[row] = append(spans[:0], s)
+		return
+	}
+
+	// Extend the latest span when touching it, as when typing along a line
+	if n := len(spans); n > 0 {
+		last := &spans[n-1]
+		if s.start <= last.end && s.end >= last.start {
+			last.start, last.end, last.gen = min(last.start, s.start), max(last.end, s.end), s.gen
+			return
+		}
+	}
+
+	// Merge the two oldest spans; the result keeps the newer generation so
+	// queries may over-report but never miss a change
+	if len(spans) == maxRowSpans {
+		spans[1] = damageSpan{gen: spans[1].gen, start: min(spans[0].start, spans[1].start), end: max(spans[0].end, spans[1].end)}
+		copy(spans, spans[1:])
+		spans = spans[:len(spans)-1]
+	}
+	b.spans[row] = append(spans, s)
+}
+
+// resetDamage drops the damage history after the buffer was reallocated.
+func (b *Buffer) resetDamage() {
+	b.spans = make([][]damageSpan, b.rows)
+	b.scrolls = nil
+	if b.clock != nil {
+		b.damageFloor = b.clock.next()
+	}
+	b.touchRange(0, b.rows)
+}
+
+// recordScroll records a scroll of [top, bottom) by lines (positive up,
+// negative down). Span histories must already have moved with their rows.
+func (b *Buffer) recordScroll(top, bottom, lines int) {
+	if b.clock == nil {
+		return
+	}
+	gen := b.clock.next()
+	for row := top; row < bottom; row++ {
+		b.lineGen[row] = gen
+	}
+
+	// Rows scrolled in are blank and fully damaged
+	first, last := bottom-lines, bottom
+	if lines < 0 {
+		first, last = top, top-lines
+	}
+	for row := first; row < last; row++ {
+		b.spans[row] = []damageSpan{{gen: gen, start: 0, end: b.cols}}
+	}
+
+	b.scrolls = append(b.scrolls, scrollRecord{gen: gen, ScrollDamage: ScrollDamage{Top: top, Bottom: bottom, Lines: lines}})
+	if len(b.scrolls) > maxScrollRecords {
+		b.damageFloor = b.scrolls[0].gen
+		n := copy(b.scrolls, b.scrolls[1:])
+		b.scrolls = b.scrolls[:n]
+	}
+}
+
+// damageSince returns the scrolls and spans recorded after generation since.
+// full is set if the history does not reach back to since.
+func (b *Buffer) damageSince(since Generation) (scrolls []ScrollDamage, spans []DamageSpan, full bool) {
+	if b.clock == nil || since < b.damageFloor {
+		return nil, nil, true
+	}
+
+	for _, rec := range b.scrolls {
+		if rec.gen <= since {
+			continue
+		}
+		// Join consecutive scrolls of the same region and direction
+		if n := len(scrolls); n > 0 {
+			prev := &scrolls[n-1]
+			if prev.Top == rec.Top && prev.Bottom == rec.Bottom && (prev.Lines > 0) == (rec.Lines > 0) {
+				prev.Lines += rec.Lines
+				continue
+			}
+		}
+		scrolls = append(scrolls, rec.ScrollDamage)
+	}
+
+	for row, history := range b.spans {
+		start, end := b.cols, 0
+		for _, s := range history {
+			if s.gen > since {
+				start, end = min(start, s.start), max(end, s.end)
+			}
+		}
+		if start < end {
+			spans = append(spans, DamageSpan{Row: row, StartCol: start, EndCol: end})
+		}
+	}
+	return scrolls, spans, false
+}
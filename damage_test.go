@@ -0,0 +1,199 @@
+package headlessterm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// damageModel is a renderer that only updates what DamageSince reports.
+type damageModel struct {
+	gen   Generation
+	lines [][]rune
+}
+
+// screenRunes returns the characters of the active screen.
+func screenRunes(term *Terminal) [][]rune {
+	rows, cols := term.Rows(), term.Cols()
+	lines := make([][]rune, rows)
+	for row := range lines {
+		lines[row] = make([]rune, cols)
+		for col := range lines[row] {
+			lines[row][col] = term.Cell(row, col).Char
+		}
+	}
+	return lines
+}
+
+// update applies damage since the last update and returns it.
+func (m *damageModel) update(term *Terminal) Damage {
+	d := term.DamageSince(m.gen)
+	m.gen = d.Generation
+	if d.Full {
+		m.lines = screenRunes(term)
+		return d
+	}
+
+	for _, s := range d.Scrolls {
+		n := s.Lines
+		if n > 0 {
+			n = min(n, s.Bottom-s.Top)
+			copy(m.lines[s.Top:s.Bottom-n], m.lines[s.Top+n:s.Bottom])
+		} else {
+			n = min(-n, s.Bottom-s.Top)
+			copy(m.lines[s.Top+n:s.Bottom], m.lines[s.Top:s.Bottom-n])
+		}
+		// Scrolled rows now alias their neighbours; copy them apart
+		for row := s.Top; row < s.Bottom; row++ {
+			m.lines[row] = append([]rune(nil), m.lines[row]...)
+		}
+	}
+	for _, span := range d.Spans {
+		for col := span.StartCol; col < span.EndCol; col++ {
+			m.lines[span.Row][col] = term.Cell(span.Row, col).Char
+		}
+	}
+	return d
+}
+
+func TestDamageSince_Spans(t *testing.T) {
+	term := New(WithSize(5, 20))
+	gen := term.Generation()
+
+	term.WriteString("\x1b[2;3Habc")
+	d := term.DamageSince(gen)
+	if d.Full || len(d.Scrolls) != 0 {
+		t.Fatalf("unexpected damage %+v", d)
+	}
+	if want := []DamageSpan{{Row: 1, StartCol: 2, EndCol: 5}}; !reflect.DeepEqual(d.Spans, want) {
+		t.Errorf("spans = %+v, want %+v", d.Spans, want)
+	}
+
+	if d := term.DamageSince(d.Generation); !d.IsEmpty() {
+		t.Errorf("expected no damage, got %+v", d)
+	}
+	if d := term.DamageSince(0); !d.Full {
+		t.Error("generation 0 should report full damage")
+	}
+}
+
+func TestDamageSince_ScrollIsNotRowDamage(t *testing.T) {
+	term := New(WithSize(60, 200))
+	for i := 0; i < 60; i++ {
+		term.WriteString(fmt.Sprintf("\x1b[%d;1Hline %d", i+1, i))
+	}
+	gen := term.Generation()
+
+	term.WriteString("\r\nnext")
+	d := term.DamageSince(gen)
+	if want := []ScrollDamage{{Top: 0, Bottom: 60, Lines: 1}}; !reflect.DeepEqual(d.Scrolls, want) {
+		t.Errorf("scrolls = %+v, want %+v", d.Scrolls, want)
+	}
+	if want := []DamageSpan{{Row: 59, StartCol: 0, EndCol: 200}}; !reflect.DeepEqual(d.Spans, want) {
+		t.Errorf("spans = %+v, want %+v", d.Spans, want)
+	}
+}
+
+func TestDamageSince_IndependentConsumers(t *testing.T) {
+	term := New(WithSize(5, 20))
+	first := term.Generation()
+	term.WriteString("a")
+	second := term.Generation()
+	term.WriteString("\x1b[3;1Hb")
+
+	if d := term.DamageSince(first); len(d.Spans) != 2 {
+		t.Errorf("first consumer spans = %+v, want rows 0 and 2", d.Spans)
+	}
+	if d := term.DamageSince(second); len(d.Spans) != 1 || d.Spans[0].Row != 2 {
+		t.Errorf("second consumer spans = %+v, want row 2", d.Spans)
+	}
+	if !term.HasDirty() {
+		t.Error("DamageSince must not clear dirty state")
+	}
+}
+
+func TestDamageSince_Full(t *testing.T) {
+	term := New(WithSize(5, 20))
+	gen := term.Generation()
+	term.WriteString("\x1b[?1049h")
+	if d := term.DamageSince(gen); !d.Full {
+		t.Error("buffer switch should report full damage")
+	}
+
+	gen = term.Generation()
+	term.Resize(6, 20)
+	if d := term.DamageSince(gen); !d.Full || d.Rows != 6 {
+		t.Errorf("resize should report full damage, got %+v", d)
+	}
+
+	// History older than the retained scroll records is lost
+	term.WriteString("\x1b[?1049l")
+	gen = term.Generation()
+	for i := 0; i < maxScrollRecords+1; i++ {
+		term.WriteString("\x1b[6;1H\n")
+	}
+	if d := term.DamageSince(gen); !d.Full {
+		t.Error("truncated scroll history should report full damage")
+	}
+}
+
+func TestDamageSince_SpanHistoryMerges(t *testing.T) {
+	term := New(WithSize(3, 40))
+	gen := term.Generation()
+	for col := 1; col <= 31; col += 5 {
+		term.WriteString(fmt.Sprintf("\x1b[1;%dHx", col))
+	}
+	d := term.DamageSince(gen)
+	if want := []DamageSpan{{Row: 0, StartCol: 0, EndCol: 31}}; !reflect.DeepEqual(d.Spans, want) {
+		t.Errorf("spans = %+v, want %+v", d.Spans, want)
+	}
+}
+
+func TestDamageSince_Replay(t *testing.T) {
+	term := New(WithSize(8, 20))
+	m := &damageModel{}
+	m.update(term)
+
+	steps := []string{
+		"hello\r\nworld",
+		"\x1b[8;1H\n\n\nbottom",
+		"\x1b[3;6r\x1b[6;1H\n\nregion\x1b[r",
+		"\x1b[2;1H\x1b[2L",
+		"\x1b[4;1H\x1b[3M",
+		"\x1b[1;1H\x1bM\x1bMtop",
+		"\x1b[5;3H\x1b[2@ins\x1b[5;1H\x1b[3P",
+		"\x1b[2;4r\x1b[2;1H\x1b[1T\x1b[2S\x1b[r",
+		"\x1b[6;1H\x1b[K\x1b[1;10H\x1b[1J",
+	}
+	for i, step := range steps {
+		term.WriteString(step)
+		d := m.update(term)
+		if d.Full {
+			t.Errorf("step %d: unexpected full damage", i)
+		}
+		if want := screenRunes(term); !reflect.DeepEqual(m.lines, want) {
+			t.Fatalf("step %d: model diverged\n got: %q\nwant: %q", i, m.lines, want)
+		}
+	}
+}
+
+func TestSubscribe_ScrolledEvent(t *testing.T) {
+	term := New(WithSize(3, 10))
+	sub := term.Subscribe(EventScrolled, EventDamage)
+	defer sub.Close()
+
+	term.WriteString("\x1b[3;1H\n")
+	ev := waitEvent(t, sub, EventScrolled).(ScrolledEvent)
+	if ev.ScrollDamage != (ScrollDamage{Top: 0, Bottom: 3, Lines: 1}) {
+		t.Errorf("scroll = %+v", ev.ScrollDamage)
+	}
+	select {
+	case ev := <-sub.Events():
+		if d, ok := ev.(DamageEvent); !ok || !reflect.DeepEqual(d.Rows, []RowRange{{2, 3}}) {
+			t.Errorf("expected damage of the scrolled-in row, got %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no damage event")
+	}
+}
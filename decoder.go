@@ -0,0 +1,49 @@
+package headlessterm
+
+import (
+	"github.com/danielgatis/go-ansicode"
+	"github.com/danielgatis/go-vte"
+)
+
+// decoder parses terminal output and dispatches it to the Terminal. It is
+// ansicode's decoder with a performer that sees sequences before ansicode
+// applies parameter defaults, where those defaults lose information.
+type decoder struct {
+	parser *vte.Parser
+}
+
+func newDecoder(t *Terminal) *decoder {
+	return &decoder{parser: vte.NewParser(&performer{Performer: ansicode.NewPerformer(t), t: t})}
+}
+
+// Write parses b. It never fails.
+func (d *decoder) Write(b []byte) (int, error) {
+	for _, c := range b {
+		d.parser.Advance(c)
+	}
+	return len(b), nil
+}
+
+// performer forwards parsed sequences to ansicode, except the ones it
+// handles itself.
+type performer struct {
+	*ansicode.Performer
+	t *Terminal
+}
+
+// CsiDispatch handles DECSTBM (CSI top ; bottom r), for which ansicode
+// reports a missing parameter as 1, so CSI r and CSI 1;1 r look the same.
+// Missing parameters are passed to SetScrollingRegion as 0 instead.
+func (p *performer) CsiDispatch(params [][]uint16, intermediates []byte, ignore bool, action rune) {
+	if action != 'r' || len(intermediates) > 0 || ignore {
+		p.Performer.CsiDispatch(params, intermediates, ignore, action)
+		return
+	}
+	param := func(i int) int {
+		if i < len(params) && len(params[i]) > 0 {
+			return int(params[i][0])
+		}
+		return 0
+	}
+	p.t.SetScrollingRegion(param(0), param(1))
+}
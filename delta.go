@@ -0,0 +1,133 @@
+package headlessterm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SnapshotDelta holds the changes of a terminal screen between two generations.
+// Lines reuse the SnapshotLine JSON layout, so clients that understand snapshots
+// can apply deltas with the same decoding code. A changed line is always sent
+// whole; deltas do not split lines into changed segments.
+//
+// Images holds the placements added or changed since From, and RemovedImages
+// the IDs of the placements removed since. If ImagesReset is set, Images holds
+// every placement instead and replaces the client's list.
+type SnapshotDelta struct {
+	From          Generation          `json:"from"`                     // Generation the delta starts at
+	Generation    Generation          `json:"generation"`               // Generation the delta brings the snapshot to
+	Size          *SnapshotSize       `json:"size,omitempty"`           // Set if the dimensions changed
+	Cursor        SnapshotCursor      `json:"cursor"`                   // Current cursor state
+	Lines         []SnapshotLineDelta `json:"lines,omitempty"`          // Changed lines
+	Images        []SnapshotImage     `json:"images,omitempty"`         // Added or changed placements
+	RemovedImages []uint32            `json:"removed_images,omitempty"` // IDs of removed placements
+	ImagesReset   bool                `json:"images_reset,omitempty"`   // Images holds every placement
+	ImagesChanged bool                `json:"images_changed,omitempty"` // Placements were added, moved or removed
+}
+
+// SnapshotLineDelta is a replacement for one line of the screen.
+type SnapshotLineDelta struct {
+	Row int `json:"row"`
+	SnapshotLine
+}
+
+// IsEmpty returns true if the delta carries no screen changes besides the cursor.
+func (d *SnapshotDelta) IsEmpty() bool {
+	return d.Size == nil && len(d.Lines) == 0 && !d.ImagesChanged
+}
+
+// SnapshotDelta returns what changed since the given generation, with lines
+// rendered at the given detail level. Use Snapshot.Generation or a previous
+// delta's Generation as since. A since of 0, or one the terminal never issued,
+// produces a delta with every line.
+//
+// Example:
+//
+//	snap := term.Snapshot(headlessterm.SnapshotDetailStyled)
+//	// ... later
+//	delta := term.SnapshotDelta(snap.Generation, headlessterm.SnapshotDetailStyled)
+//	snap.ApplyDelta(delta)
+func (t *Terminal) SnapshotDelta(since Generation, detail SnapshotDetail) *SnapshotDelta {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.clock.now()
+	d := &SnapshotDelta{
+		From:       since,
+		Generation: now,
+		Cursor:     t.snapshotCursor(),
+	}
+
+	full := since == 0 || since > now || t.screenGen > since
+	if full || t.sizeGen > since {
+		d.Size = &SnapshotSize{Rows: t.rows, Cols: t.cols}
+	}
+
+	for row := 0; row < t.rows; row++ {
+		if full || t.activeBuffer.lineGeneration(row) > since {
+			d.Lines = append(d.Lines, SnapshotLineDelta{
+				Row:          row,
+				SnapshotLine: t.snapshotLine(row, detail),
+			})
+		}
+	}
+
+	if full || t.images.changedAt() > since {
+		d.ImagesChanged = true
+		changed, removed, reset := t.images.changesSince(since)
+		if full || reset {
+			d.ImagesReset = true
+			d.Images = t.snapshotImages()
+		} else {
+			d.Images = t.snapshotPlacements(changed)
+			d.RemovedImages = removed
+		}
+	}
+
+	return d
+}
+
+// ApplyDelta updates the snapshot in place with the changes from d.
+// The snapshot must be at least as recent as the delta's starting generation.
+func (s *Snapshot) ApplyDelta(d *SnapshotDelta) error {
+	if s.Generation < d.From {
+		return fmt.Errorf("delta starts at generation %d, snapshot is at %d", d.From, s.Generation)
+	}
+
+	if d.Size != nil {
+		if d.Size.Rows < 0 || d.Size.Cols < 0 {
+			return fmt.Errorf("invalid delta size: %dx%d", d.Size.Rows, d.Size.Cols)
+		}
+		lines := make([]SnapshotLine, d.Size.Rows)
+		copy(lines, s.Lines)
+		s.Lines = lines
+		s.Size = *d.Size
+	}
+
+	for _, l := range d.Lines {
+		if l.Row < 0 || l.Row >= len(s.Lines) {
+			return fmt.Errorf("delta line %d out of range (%d rows)", l.Row, len(s.Lines))
+		}
+		s.Lines[l.Row] = l.SnapshotLine
+	}
+
+	if d.ImagesReset {
+		s.Images = d.Images
+	} else if d.ImagesChanged {
+		s.Images = slices.DeleteFunc(s.Images, func(img SnapshotImage) bool {
+			return slices.Contains(d.RemovedImages, img.PlacementID)
+		})
+		for _, img := range d.Images {
+			i := slices.IndexFunc(s.Images, func(cur SnapshotImage) bool { return cur.PlacementID == img.PlacementID })
+			if i >= 0 {
+				s.Images[i] = img
+			} else {
+				s.Images = append(s.Images, img)
+			}
+		}
+	}
+
+	s.Cursor = d.Cursor
+	s.Generation = d.Generation
+	return nil
+}
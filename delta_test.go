@@ -0,0 +1,145 @@
+package headlessterm
+
+import (
+	"cmp"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// applyJSONDelta round-trips a delta through JSON before applying it, like a remote client would.
+func applyJSONDelta(t *testing.T, snap *Snapshot, d *SnapshotDelta) {
+	t.Helper()
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal delta: %v", err)
+	}
+	var decoded SnapshotDelta
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	if err := snap.ApplyDelta(&decoded); err != nil {
+		t.Fatalf("ApplyDelta: %v", err)
+	}
+}
+
+func TestSnapshotDelta_ChangedLinesOnly(t *testing.T) {
+	term := New(WithSize(5, 20))
+	term.WriteString("one\r\ntwo\r\nthree")
+	snap := term.Snapshot(SnapshotDetailStyled)
+
+	delta := term.SnapshotDelta(snap.Generation, SnapshotDetailStyled)
+	if !delta.IsEmpty() {
+		t.Errorf("expected empty delta, got %+v", delta)
+	}
+
+	term.WriteString("\x1b[2;1H\x1b[1;31mTWO\x1b[0m")
+	delta = term.SnapshotDelta(snap.Generation, SnapshotDetailStyled)
+	if len(delta.Lines) != 1 || delta.Lines[0].Row != 1 {
+		t.Fatalf("expected only row 1 to change, got %+v", delta.Lines)
+	}
+	if delta.Size != nil {
+		t.Error("size should not be included")
+	}
+
+	applyJSONDelta(t, snap, delta)
+	if want := term.Snapshot(SnapshotDetailStyled); !reflect.DeepEqual(snap, want) {
+		t.Errorf("applied snapshot = %+v, want %+v", snap, want)
+	}
+}
+
+func TestSnapshotDelta_ScrollResizeAndAlternate(t *testing.T) {
+	term := New(WithSize(3, 10))
+	snap := term.Snapshot(SnapshotDetailFull)
+
+	steps := []func(){
+		func() { term.WriteString("a\r\nb\r\nc\r\nd") },
+		func() { term.Resize(4, 12) },
+		func() { term.WriteString("\x1b[?1049h\x1b[Halt") },
+		func() { term.WriteString("\x1b[?1049l") },
+		func() { term.Resize(2, 8) },
+	}
+	for i, step := range steps {
+		step()
+		applyJSONDelta(t, snap, term.SnapshotDelta(snap.Generation, SnapshotDetailFull))
+		if want := term.Snapshot(SnapshotDetailFull); !reflect.DeepEqual(snap, want) {
+			t.Fatalf("step %d: applied snapshot = %+v, want %+v", i, snap, want)
+		}
+	}
+}
+
+func TestSnapshotDelta_Images(t *testing.T) {
+	term := New(WithSize(5, 10))
+	snap := term.Snapshot(SnapshotDetailText)
+
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;AAAA/wAAAP8AAAD/AAAA/w==\x1b\\")
+	delta := term.SnapshotDelta(snap.Generation, SnapshotDetailText)
+	if !delta.ImagesChanged || len(delta.Images) != 1 {
+		t.Fatalf("expected one placement, got %+v", delta.Images)
+	}
+	applyJSONDelta(t, snap, delta)
+
+	term.WriteString("\x1b_Ga=d,d=a\x1b\\")
+	delta = term.SnapshotDelta(snap.Generation, SnapshotDetailText)
+	if !delta.ImagesChanged || len(delta.Images) != 0 {
+		t.Fatalf("expected placement removal, got %+v", delta)
+	}
+	applyJSONDelta(t, snap, delta)
+	if len(snap.Images) != 0 {
+		t.Errorf("images = %+v, want none", snap.Images)
+	}
+}
+
+func TestSnapshotDelta_ImageChanges(t *testing.T) {
+	const img = "f=32,s=2,v=2;AAAA/wAAAP8AAAD/AAAA/w==\x1b\\"
+	term := New(WithSize(10, 20))
+	term.WriteString("\x1b_Ga=T,i=1,p=1," + img)
+	term.WriteString("\x1b_Ga=T,i=2,p=2," + img)
+	snap := term.Snapshot(SnapshotDetailText)
+	if len(snap.Images) != 2 {
+		t.Fatalf("expected two placements, got %+v", snap.Images)
+	}
+
+	term.WriteString("\x1b_Ga=T,i=3,p=3," + img)
+	delta := term.SnapshotDelta(snap.Generation, SnapshotDetailText)
+	if delta.ImagesReset || len(delta.Images) != 1 || delta.Images[0].ID != 3 || len(delta.RemovedImages) != 0 {
+		t.Fatalf("expected only the added placement, got %+v", delta)
+	}
+	applyJSONDelta(t, snap, delta)
+
+	term.WriteString("\x1b_Ga=d,d=i,i=1\x1b\\")
+	delta = term.SnapshotDelta(snap.Generation, SnapshotDetailText)
+	if delta.ImagesReset || len(delta.Images) != 0 || len(delta.RemovedImages) != 1 {
+		t.Fatalf("expected one removed placement, got %+v", delta)
+	}
+	applyJSONDelta(t, snap, delta)
+
+	slices.SortFunc(snap.Images, func(a, b SnapshotImage) int { return cmp.Compare(a.PlacementID, b.PlacementID) })
+	if want := term.Snapshot(SnapshotDetailText).Images; !reflect.DeepEqual(snap.Images, want) {
+		t.Errorf("images = %+v, want %+v", snap.Images, want)
+	}
+}
+
+func TestSnapshotDelta_Full(t *testing.T) {
+	term := New(WithSize(3, 10))
+	term.WriteString("hello")
+
+	delta := term.SnapshotDelta(0, SnapshotDetailText)
+	if delta.Size == nil || len(delta.Lines) != 3 {
+		t.Fatalf("expected a full delta, got %+v", delta)
+	}
+
+	var snap Snapshot
+	if err := snap.ApplyDelta(delta); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Lines[0].Text != "hello" {
+		t.Errorf("line 0 = %q", snap.Lines[0].Text)
+	}
+
+	stale := &Snapshot{Generation: 0}
+	if err := stale.ApplyDelta(&SnapshotDelta{From: 5}); err == nil {
+		t.Error("expected error for snapshot older than delta")
+	}
+}
@@ -0,0 +1,736 @@
+package headlessterm
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Frame is an immutable copy of the visible screen.
+// It is the baseline against which DiffEncoder computes incremental updates.
+type Frame struct {
+	Rows    int
+	Cols    int
+	Lines   [][]Cell
+	Wrapped []bool
+	Cursor  Cursor
+
+	// Alternate is true if the frame was taken from the alternate screen.
+	Alternate bool
+
+	// dirty marks rows that contained dirty cells when the frame was captured (nil if unknown).
+	dirty []bool
+}
+
+// Frame returns a copy of the visible screen for incremental encoding.
+func (t *Terminal) Frame() *Frame {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.frameLocked()
+}
+
+// frameLocked copies the visible screen (caller must hold lock).
+func (t *Terminal) frameLocked() *Frame {
+	b := t.activeBuffer
+	f := &Frame{
+		Rows:      t.rows,
+		Cols:      t.cols,
+		Lines:     make([][]Cell, t.rows),
+		Wrapped:   make([]bool, t.rows),
+		Cursor:    *t.cursor,
+		Alternate: b == t.alternateBuffer,
+		dirty:     make([]bool, t.rows),
+	}
+	for row := 0; row < t.rows; row++ {
+		line := make([]Cell, t.cols)
+		copy(line, b.cells[row])
+		for col := range line {
+			if line[col].IsDirty() {
+				f.dirty[row] = true
+			}
+		}
+		f.Lines[row] = line
+		f.Wrapped[row] = b.IsWrapped(row)
+	}
+	return f
+}
+
+// FrameFromSnapshot builds a Frame from a snapshot of any detail level.
+// Full snapshots keep all attributes; styled snapshots keep segment styles;
+// text snapshots produce default-styled cells. Colors equal to the default
+// foreground/background are mapped back to the terminal defaults.
+func FrameFromSnapshot(s *Snapshot) *Frame {
+	f := &Frame{
+		Rows:    s.Size.Rows,
+		Cols:    s.Size.Cols,
+		Lines:   make([][]Cell, s.Size.Rows),
+		Wrapped: make([]bool, s.Size.Rows),
+		Cursor: Cursor{
+			Row:     s.Cursor.Row,
+			Col:     s.Cursor.Col,
+			Visible: s.Cursor.Visible,
+			Style:   cursorStyleFromString(s.Cursor.Style),
+		},
+	}
+
+	for row := 0; row < f.Rows; row++ {
+		line := make([]Cell, f.Cols)
+		for col := range line {
+			line[col] = NewCell()
+		}
+		if row < len(s.Lines) {
+			fillLineFromSnapshot(line, &s.Lines[row])
+		}
+		f.Lines[row] = line
+	}
+	return f
+}
+
+// fillLineFromSnapshot decodes a snapshot line into cells.
+func fillLineFromSnapshot(line []Cell, sl *SnapshotLine) {
+	switch {
+	case len(sl.Cells) > 0:
+		for col, sc := range sl.Cells {
+			if col >= len(line) {
+				break
+			}
+			c := snapshotStyleToCell(sc.Fg, sc.Bg, sc.UnderlineColor, sc.Attributes, sc.Hyperlink)
+			if r := []rune(sc.Char); len(r) > 0 {
+				c.Char = r[0]
+			}
+			if sc.Wide {
+				c.SetFlag(CellFlagWideChar)
+			}
+			if sc.WideSpacer {
+				c.SetFlag(CellFlagWideCharSpacer)
+			}
+			line[col] = c
+		}
+
+	case len(sl.Segments) > 0:
+		col := 0
+		for _, seg := range sl.Segments {
+			tmpl := snapshotStyleToCell(seg.Fg, seg.Bg, seg.UnderlineColor, seg.Attributes, seg.Hyperlink)
+			col = fillRunes(line, col, seg.Text, tmpl)
+		}
+
+	default:
+		fillRunes(line, 0, sl.Text, NewCell())
+	}
+}
+
+// fillRunes writes text into line starting at col using tmpl's style, adding spacers
+// after wide characters. Returns the next column.
+func fillRunes(line []Cell, col int, text string, tmpl Cell) int {
+	for _, r := range text {
+		if col >= len(line) {
+			break
+		}
+		c := tmpl
+		c.Char = r
+		if isWideRune(r) && col+1 < len(line) {
+			c.SetFlag(CellFlagWideChar)
+			line[col] = c
+			spacer := tmpl
+			spacer.Char = ' '
+			spacer.SetFlag(CellFlagWideCharSpacer)
+			line[col+1] = spacer
+			col += 2
+			continue
+		}
+		line[col] = c
+		col++
+	}
+	return col
+}
+
+// snapshotStyleToCell converts snapshot style fields back to a cell.
+func snapshotStyleToCell(fg, bg, ul string, attrs SnapshotAttrs, link *SnapshotLink) Cell {
+	c := NewCell()
+	if v, ok := parseHexColor(fg); ok && v != DefaultForeground {
+		c.Fg = v
+	}
+	if v, ok := parseHexColor(bg); ok && v != DefaultBackground {
+		c.Bg = v
+	}
+	if v, ok := parseHexColor(ul); ok {
+		c.UnderlineColor = v
+	}
+	c.Flags = snapshotAttrsToFlags(attrs)
+	if link != nil {
+		c.Hyperlink = &Hyperlink{ID: link.ID, URI: link.URI}
+	}
+	return c
+}
+
+// snapshotAttrsToFlags is the inverse of cellAttrsToSnapshot.
+func snapshotAttrsToFlags(a SnapshotAttrs) CellFlags {
+	var flags CellFlags
+	if a.Bold {
+		flags |= CellFlagBold
+	}
+	if a.Dim {
+		flags |= CellFlagDim
+	}
+	if a.Italic {
+		flags |= CellFlagItalic
+	}
+	switch a.Underline {
+	case "single":
+		flags |= CellFlagUnderline
+	case "double":
+		flags |= CellFlagDoubleUnderline
+	case "curly":
+		flags |= CellFlagCurlyUnderline
+	case "dotted":
+		flags |= CellFlagDottedUnderline
+	case "dashed":
+		flags |= CellFlagDashedUnderline
+	}
+	switch a.Blink {
+	case "slow":
+		flags |= CellFlagBlinkSlow
+	case "fast":
+		flags |= CellFlagBlinkFast
+	}
+	if a.Reverse {
+		flags |= CellFlagReverse
+	}
+	if a.Hidden {
+		flags |= CellFlagHidden
+	}
+	if a.Strikethrough {
+		flags |= CellFlagStrike
+	}
+	return flags
+}
+
+// parseHexColor parses "#rrggbb". Returns false for empty or malformed input.
+func parseHexColor(s string) (color.RGBA, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, true
+}
+
+// cursorStyleFromString is the inverse of cursorStyleToString (steady variants are not distinguished).
+func cursorStyleFromString(s string) CursorStyle {
+	switch s {
+	case "underline":
+		return CursorStyleBlinkingUnderline
+	case "bar":
+		return CursorStyleBlinkingBar
+	default:
+		return CursorStyleBlinkingBlock
+	}
+}
+
+// DiffOptions configures incremental encoding.
+type DiffOptions struct {
+	// ColorDepth is the color capability of the target terminal (default: truecolor).
+	ColorDepth ColorDepth
+	// TrackDirty makes DiffEncoder.Encode compare only rows containing dirty cells
+	// and clear the terminal's dirty state afterwards. Only enable this when the
+	// encoder is the sole consumer of dirty tracking.
+	TrackDirty bool
+}
+
+// DiffEncoder emits the escape sequences that update a real terminal from the
+// previously encoded frame to the current one. It detects scrolled regions,
+// optimizes cursor motion, erases line tails with EL and emits SGR deltas.
+//
+// The target terminal is assumed to be the same size and to receive only this
+// encoder's output. The first Encode call (and the first after Reset or a resize)
+// repaints the whole screen.
+//
+// Example:
+//
+//	enc := headlessterm.NewDiffEncoder(headlessterm.DiffOptions{})
+//	for range ticker.C {
+//	    enc.Encode(conn, term)
+//	}
+type DiffEncoder struct {
+	opts DiffOptions
+	prev *Frame
+}
+
+// NewDiffEncoder creates an encoder with no baseline frame.
+func NewDiffEncoder(opts DiffOptions) *DiffEncoder {
+	return &DiffEncoder{opts: opts}
+}
+
+// Reset discards the baseline so the next Encode repaints the whole screen.
+func (d *DiffEncoder) Reset() {
+	d.prev = nil
+}
+
+// Encode writes the update from the previous frame to the terminal's current screen
+// and makes the current screen the new baseline.
+func (d *DiffEncoder) Encode(w io.Writer, t *Terminal) error {
+	t.mu.Lock()
+	cur := t.frameLocked()
+	if d.opts.TrackDirty {
+		t.activeBuffer.ClearAllDirty()
+	}
+	t.mu.Unlock()
+
+	if !d.opts.TrackDirty {
+		cur.dirty = nil
+	}
+	if err := EncodeFrameDiff(w, d.prev, cur, d.opts); err != nil {
+		return err
+	}
+	d.prev = cur
+	return nil
+}
+
+// EncodeSnapshotDiff writes the escape sequences that update a terminal showing prev
+// so that it shows cur. A nil prev repaints the whole screen.
+func EncodeSnapshotDiff(w io.Writer, prev, cur *Snapshot, opts DiffOptions) error {
+	var pf *Frame
+	if prev != nil {
+		pf = FrameFromSnapshot(prev)
+	}
+	cf := FrameFromSnapshot(cur)
+	cf.dirty = nil
+	return EncodeFrameDiff(w, pf, cf, opts)
+}
+
+// EncodeFrameDiff writes the escape sequences that update a terminal showing prev
+// so that it shows cur. A nil prev, or one of a different size, repaints the whole screen.
+func EncodeFrameDiff(w io.Writer, prev, cur *Frame, opts DiffOptions) error {
+	d := newFrameDiff(cur, opts.ColorDepth)
+
+	if prev == nil || prev.Rows != cur.Rows || prev.Cols != cur.Cols {
+		d.WriteString("\x1b[r\x1b[0m\x1b]8;;\x1b\\\x1b[H\x1b[2J")
+		d.resetPen()
+		d.row, d.col, d.posKnown = 0, 0, true
+		d.model = blankLines(cur.Rows, cur.Cols)
+		d.cursorShown = !cur.Cursor.Visible // force emission
+		d.cursorStyle = -1
+	} else {
+		d.model = make([][]Cell, prev.Rows)
+		copy(d.model, prev.Lines)
+		d.row, d.col, d.posKnown = prev.Cursor.Row, min(prev.Cursor.Col, prev.Cols), true
+		d.cursorShown = prev.Cursor.Visible
+		d.cursorStyle = prev.Cursor.Style
+		if prev.Alternate == cur.Alternate {
+			d.dirty = cur.dirty
+		}
+	}
+
+	// Hide the cursor while painting to avoid flicker
+	if d.paint() && d.cursorShown {
+		painted := append([]byte(nil), d.buf.Bytes()...)
+		d.buf.Reset()
+		d.WriteString("\x1b[?25l")
+		d.buf.Write(painted)
+		d.cursorShown = false
+	}
+
+	d.finish()
+
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+// blankLines creates a grid of default cells.
+func blankLines(rows, cols int) [][]Cell {
+	lines := make([][]Cell, rows)
+	for row := range lines {
+		lines[row] = make([]Cell, cols)
+		for col := range lines[row] {
+			lines[row][col] = NewCell()
+		}
+	}
+	return lines
+}
+
+// frameDiff holds the state of a single diff computation.
+type frameDiff struct {
+	*ansiEncoder
+
+	cur   *Frame
+	model [][]Cell // What the target terminal currently shows
+	dirty []bool   // Rows that may have changed (nil means all)
+
+	// Tracked target cursor position; col == cols means a pending wrap
+	row, col int
+	posKnown bool
+
+	cursorShown bool
+	cursorStyle CursorStyle
+}
+
+func newFrameDiff(cur *Frame, depth ColorDepth) *frameDiff {
+	return &frameDiff{
+		ansiEncoder: newANSIEncoder(depth),
+		cur:         cur,
+	}
+}
+
+// paint emits all content changes. Returns true if anything was written.
+func (d *frameDiff) paint() bool {
+	start := d.buf.Len()
+	d.detectScroll()
+	for row := 0; row < d.cur.Rows; row++ {
+		if d.dirty != nil && !d.dirty[row] {
+			continue
+		}
+		d.paintRow(row)
+	}
+	d.setHyperlink(nil)
+	return d.buf.Len() > start
+}
+
+// finish positions the cursor and updates its style and visibility.
+func (d *frameDiff) finish() {
+	c := d.cur.Cursor
+	cols := d.cur.Cols
+	if c.Col >= cols {
+		// Recreate a pending wrap by rewriting the last cell of the row
+		if !d.posKnown || d.row != c.Row || d.col < cols {
+			d.cursorTo(c.Row, cols-1, false)
+			last := &d.cur.Lines[c.Row][cols-1]
+			if last.IsWideSpacer() && cols > 1 {
+				d.cursorTo(c.Row, cols-2, false)
+				last = &d.cur.Lines[c.Row][cols-2]
+			}
+			d.putCell(last)
+			d.setHyperlink(nil)
+		}
+	} else if !d.posKnown || d.row != c.Row || d.col != c.Col {
+		d.cursorTo(c.Row, c.Col, false)
+	}
+	if c.Style != d.cursorStyle {
+		fmt.Fprintf(&d.buf, "\x1b[%d q", cursorStyleToDECSCUSR(c.Style))
+	}
+	if c.Visible != d.cursorShown {
+		if c.Visible {
+			d.WriteString("\x1b[?25h")
+		} else {
+			d.WriteString("\x1b[?25l")
+		}
+	}
+}
+
+// detectScroll finds the largest block of rows that moved vertically and replays
+// the move with a scroll region and SU/SD, updating the model accordingly.
+func (d *frameDiff) detectScroll() {
+	rows := d.cur.Rows
+	bestShift, bestStart, bestLen, bestGain := 0, 0, 0, 0
+
+	for shift := -(rows - 1); shift < rows; shift++ {
+		if shift == 0 {
+			continue
+		}
+		runStart, runLen, gain := 0, 0, 0
+		for row := 0; row < rows; row++ {
+			src := row + shift
+			if src >= 0 && src < rows && linesEqual(d.cur.Lines[row], d.model[src]) {
+				if runLen == 0 {
+					runStart, gain = row, 0
+				}
+				runLen++
+				if !isBlankLine(d.cur.Lines[row]) && !linesEqual(d.cur.Lines[row], d.model[row]) {
+					gain++
+				}
+				if gain > bestGain {
+					bestShift, bestStart, bestLen, bestGain = shift, runStart, runLen, gain
+				}
+				continue
+			}
+			runLen = 0
+		}
+	}
+
+	// Scrolling only pays off when it saves repainting several lines
+	if bestGain < 2 {
+		return
+	}
+
+	var top, bottom, n int
+	if bestShift > 0 {
+		top, bottom, n = bestStart, bestStart+bestLen+bestShift, bestShift
+	} else {
+		top, bottom, n = bestStart+bestShift, bestStart+bestLen, -bestShift
+	}
+
+	// Scrolled-in lines take the current background, so reset it first
+	d.setHyperlink(nil)
+	if d.pen.bg != "" || !d.penValid {
+		blank := NewCell()
+		d.setPen(&blank)
+	}
+	if top != 0 || bottom != rows {
+		fmt.Fprintf(&d.buf, "\x1b[%d;%dr", top+1, bottom)
+		d.posKnown = false
+	}
+	if bestShift > 0 {
+		d.WriteString(csiMove(n, 'S'))
+	} else {
+		d.WriteString(csiMove(n, 'T'))
+	}
+	if top != 0 || bottom != rows {
+		d.WriteString("\x1b[r")
+	}
+
+	// Apply the same move to the model and mark the uncovered rows for repainting
+	moved := make([][]Cell, bottom-top)
+	for i := range moved {
+		src := top + i + bestShift
+		if src >= top && src < bottom {
+			moved[i] = d.model[src]
+		} else {
+			moved[i] = blankLines(1, d.cur.Cols)[0]
+		}
+	}
+	copy(d.model[top:bottom], moved)
+	if d.dirty != nil {
+		// Rows shifted into place may not be dirty themselves, but the ones uncovered are
+		d.dirty = append([]bool(nil), d.dirty...)
+		for row := top; row < bottom; row++ {
+			d.dirty[row] = true
+		}
+	}
+}
+
+// paintRow emits the changes needed to turn the model row into the current row.
+func (d *frameDiff) paintRow(row int) {
+	cur, old := d.cur.Lines[row], d.model[row]
+	cols := d.cur.Cols
+
+	first, last := -1, -1
+	for col := 0; col < cols; col++ {
+		if !cellsEqual(&cur[col], &old[col]) {
+			if first < 0 {
+				first = col
+			}
+			last = col
+		}
+	}
+	if first < 0 {
+		return
+	}
+
+	// Never start in the middle of a wide character
+	if first > 0 && (cur[first].IsWideSpacer() || old[first].IsWideSpacer()) {
+		first--
+	}
+
+	// Find the blank tail that can be erased with EL instead of written
+	tail := cols
+	for tail > 0 && isBlankCell(&cur[tail-1]) && d.penFor(&cur[tail-1]).bg == d.penFor(&cur[cols-1]).bg {
+		tail--
+	}
+	useErase := tail <= last && cols-tail > 3
+	end := last
+	if useErase {
+		end = tail - 1
+	}
+
+	// Write changed cells, skipping long runs of unchanged cells with cursor motion
+	gapStart := -1
+	for col := first; col <= end; col++ {
+		c := &cur[col]
+		if c.IsWideSpacer() {
+			continue
+		}
+		unchanged := cellsEqual(c, &old[col]) && (!c.IsWide() || col+1 >= cols || cellsEqual(&cur[col+1], &old[col+1]))
+		if unchanged {
+			if gapStart < 0 {
+				gapStart = col
+			}
+			continue
+		}
+		if gapStart >= 0 && d.posKnown && d.row == row && d.col == gapStart && col-gapStart <= 4 && d.sameLinkRun(cur, gapStart, col) {
+			// Rewriting a short gap is cheaper than moving over it
+			for g := gapStart; g < col; g++ {
+				if !cur[g].IsWideSpacer() {
+					d.putCell(&cur[g])
+				}
+			}
+		}
+		gapStart = -1
+		d.cursorTo(row, col, true)
+		d.putCell(c)
+	}
+
+	if useErase {
+		d.cursorTo(row, tail, false)
+		d.setHyperlink(nil)
+		d.setPen(&cur[cols-1])
+		d.WriteString("\x1b[K")
+	}
+
+	d.model[row] = cur
+}
+
+// sameLinkRun reports whether cells in [from, to) keep the currently active hyperlink,
+// so rewriting them does not need extra OSC 8 sequences.
+func (d *frameDiff) sameLinkRun(line []Cell, from, to int) bool {
+	for col := from; col < to; col++ {
+		if !sameHyperlink(line[col].Hyperlink, d.link) {
+			return false
+		}
+	}
+	return true
+}
+
+// putCell writes a cell at the tracked cursor position and advances it.
+func (d *frameDiff) putCell(c *Cell) {
+	d.writeCell(c)
+	width := 1
+	if c.IsWide() {
+		width = 2
+	}
+	d.col += width
+	if d.col > d.cur.Cols {
+		d.col = d.cur.Cols
+	}
+}
+
+// cursorTo moves the target cursor to (row, col) using the shortest sequence.
+// forWrite indicates that a character is written next, which allows a pending
+// wrap at the end of a soft-wrapped row to carry the cursor to the next row.
+func (d *frameDiff) cursorTo(row, col int, forWrite bool) {
+	if d.posKnown && d.row == row && d.col == col {
+		return
+	}
+	defer func() {
+		d.row, d.col, d.posKnown = row, col, true
+	}()
+
+	if forWrite && d.posKnown && d.col >= d.cur.Cols && d.row+1 == row && col == 0 &&
+		d.row < len(d.cur.Wrapped) && d.cur.Wrapped[d.row] && row < d.cur.Rows {
+		return
+	}
+
+	best := fmt.Sprintf("\x1b[%d;%dH", row+1, col+1)
+	if d.posKnown {
+		for _, cand := range d.relativeMoves(row, col) {
+			if len(cand) < len(best) {
+				best = cand
+			}
+		}
+	}
+	d.WriteString(best)
+}
+
+// relativeMoves returns candidate sequences moving from the tracked position to (row, col).
+func (d *frameDiff) relativeMoves(row, col int) []string {
+	var cands []string
+	pending := d.col >= d.cur.Cols
+
+	vertical := ""
+	switch dr := row - d.row; {
+	case dr > 0:
+		vertical = csiMove(dr, 'B')
+	case dr < 0:
+		vertical = csiMove(-dr, 'A')
+	}
+
+	// Via carriage return
+	cr := "\r" + vertical
+	if col > 0 {
+		cr += csiMove(col, 'C')
+	}
+	cands = append(cands, cr)
+	if row == d.row+1 && col == 0 && row < d.cur.Rows {
+		cands = append(cands, "\r\n")
+	}
+
+	// Pure relative motion is unreliable from a pending wrap
+	if !pending {
+		horizontal := ""
+		switch dc := col - d.col; {
+		case dc > 0:
+			horizontal = csiMove(dc, 'C')
+		case dc == -1:
+			horizontal = "\b"
+		case dc < 0:
+			horizontal = csiMove(-dc, 'D')
+		}
+		cands = append(cands, vertical+horizontal)
+		if row == d.row {
+			cands = append(cands, fmt.Sprintf("\x1b[%dG", col+1))
+		}
+	}
+	return cands
+}
+
+// csiMove returns a relative cursor movement sequence.
+func csiMove(n int, final byte) string {
+	if n == 1 {
+		return "\x1b[" + string(final)
+	}
+	return "\x1b[" + strconv.Itoa(n) + string(final)
+}
+
+// linesEqual reports whether two rows render identically.
+func linesEqual(a, b []Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !cellsEqual(&a[i], &b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBlankLine reports whether a row contains only blank default-background cells.
+func isBlankLine(line []Cell) bool {
+	for i := range line {
+		if !isBlankCell(&line[i]) || !isDefaultBackground(line[i].Bg) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDefaultBackground reports whether c is the default background color.
+func isDefaultBackground(c color.Color) bool {
+	if c == nil {
+		return true
+	}
+	n, ok := c.(*NamedColor)
+	return ok && n.Name == NamedColorBackground
+}
+
+// cellsEqual reports whether two cells render identically (dirty state is ignored).
+func cellsEqual(a, b *Cell) bool {
+	const visible = penFlags | CellFlagWideChar | CellFlagWideCharSpacer
+	return printableRune(a) == printableRune(b) &&
+		a.Flags&visible == b.Flags&visible &&
+		colorsEqual(a.Fg, b.Fg) &&
+		colorsEqual(a.Bg, b.Bg) &&
+		colorsEqual(a.UnderlineColor, b.UnderlineColor) &&
+		sameHyperlink(a.Hyperlink, b.Hyperlink)
+}
+
+// colorsEqual compares colors by kind and value.
+func colorsEqual(a, b color.Color) bool {
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case *NamedColor:
+		bv, ok := b.(*NamedColor)
+		return ok && av.Name == bv.Name
+	case *IndexedColor:
+		bv, ok := b.(*IndexedColor)
+		return ok && av.Index == bv.Index
+	case color.RGBA:
+		bv, ok := b.(color.RGBA)
+		return ok && av == bv
+	default:
+		return b != nil && strings.EqualFold(encodeStateColor(a), encodeStateColor(b))
+	}
+}
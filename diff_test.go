@@ -0,0 +1,162 @@
+package headlessterm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// diffStep encodes the change since the last step and feeds it to dst.
+func diffStep(t *testing.T, enc *DiffEncoder, src, dst *Terminal) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, src); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	dst.Write(buf.Bytes())
+	assertSameScreen(t, dst, src)
+	return buf.String()
+}
+
+func TestDiffEncoder_Incremental(t *testing.T) {
+	src := New(WithSize(5, 20))
+	dst := New(WithSize(5, 20))
+	enc := NewDiffEncoder(DiffOptions{})
+
+	src.WriteString("\x1b[1;31mhello\x1b[0m world\r\n\x1b[44mbg\x1b[K\x1b[0m\r\n中文 wide")
+	diffStep(t, enc, src, dst)
+
+	out := diffStep(t, enc, src, dst)
+	if out != "" {
+		t.Errorf("unchanged screen produced %q", out)
+	}
+
+	src.WriteString("\x1b[1;7Hthere")
+	out = diffStep(t, enc, src, dst)
+	if strings.Contains(out, "hello") || !strings.Contains(out, "there") {
+		t.Errorf("diff should only contain the changed cells, got %q", out)
+	}
+
+	src.WriteString("\x1b[3;1Habc")
+	diffStep(t, enc, src, dst)
+
+	src.WriteString("\x1b[2;1H\x1b[2K\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\x1b[4:3mcurly\x1b[0m")
+	diffStep(t, enc, src, dst)
+
+	src.WriteString("\x1b[?25l\x1b[4 q")
+	out = diffStep(t, enc, src, dst)
+	if !strings.Contains(out, "\x1b[?25l") || !strings.Contains(out, "\x1b[4 q") {
+		t.Errorf("cursor changes not encoded: %q", out)
+	}
+}
+
+func TestDiffEncoder_Scroll(t *testing.T) {
+	src := New(WithSize(6, 30))
+	dst := New(WithSize(6, 30))
+	enc := NewDiffEncoder(DiffOptions{})
+
+	for i := 0; i < 6; i++ {
+		src.WriteString("line number " + string(rune('A'+i)) + " with some text")
+		if i < 5 {
+			src.WriteString("\r\n")
+		}
+	}
+	diffStep(t, enc, src, dst)
+
+	src.WriteString("\r\nnew line")
+	out := diffStep(t, enc, src, dst)
+	if !strings.Contains(out, "\x1b[S") {
+		t.Errorf("expected scroll up, got %q", out)
+	}
+	if strings.Contains(out, "line number C") {
+		t.Errorf("scrolled lines should not be repainted: %q", out)
+	}
+
+	// Reverse scroll inside a region
+	src.WriteString("\x1b[2;5r\x1b[2;1H\x1bM\x1bM\x1b[r")
+	out = diffStep(t, enc, src, dst)
+	if !strings.Contains(out, "\x1b[2T") {
+		t.Errorf("expected scroll down, got %q", out)
+	}
+}
+
+func TestDiffEncoder_ResizeAndAlternate(t *testing.T) {
+	src := New(WithSize(4, 10))
+	enc := NewDiffEncoder(DiffOptions{ColorDepth: ColorDepth256})
+
+	src.WriteString("primary")
+	diffStep(t, enc, src, New(WithSize(4, 10)))
+
+	src.Resize(5, 12)
+	dst := New(WithSize(5, 12))
+	out := diffStep(t, enc, src, dst)
+	if !strings.Contains(out, "\x1b[2J") {
+		t.Errorf("resize should repaint everything: %q", out)
+	}
+
+	src.WriteString("\x1b[?1049h\x1b[Hfull screen")
+	diffStep(t, enc, src, dst)
+	src.WriteString("\x1b[?1049l")
+	diffStep(t, enc, src, dst)
+}
+
+func TestDiffEncoder_PendingWrap(t *testing.T) {
+	src := New(WithSize(3, 10))
+	dst := New(WithSize(3, 10))
+	enc := NewDiffEncoder(DiffOptions{})
+
+	src.WriteString("0123456789")
+	diffStep(t, enc, src, dst)
+	src.WriteString("\x1b[3;1Hxyz\x1b[1;10H9")
+	diffStep(t, enc, src, dst)
+
+	src.WriteString("abc")
+	dst.WriteString("abc")
+	assertSameScreen(t, dst, src)
+}
+
+func TestDiffEncoder_TrackDirty(t *testing.T) {
+	src := New(WithSize(4, 20))
+	dst := New(WithSize(4, 20))
+	enc := NewDiffEncoder(DiffOptions{TrackDirty: true})
+
+	src.WriteString("one\r\ntwo\r\nthree")
+	diffStep(t, enc, src, dst)
+	if src.HasDirty() {
+		t.Error("dirty state should be cleared")
+	}
+
+	src.WriteString("\x1b[1;1HONE")
+	out := diffStep(t, enc, src, dst)
+	if !strings.Contains(out, "ONE") {
+		t.Errorf("dirty row not encoded: %q", out)
+	}
+}
+
+func TestEncodeSnapshotDiff(t *testing.T) {
+	src := New(WithSize(3, 15))
+	src.WriteString("\x1b[32mgreen\x1b[0m text")
+	prev := src.Snapshot(SnapshotDetailFull)
+	src.WriteString("\r\n\x1b[1mbold\x1b[0m 中")
+	cur := src.Snapshot(SnapshotDetailFull)
+
+	dst := New(WithSize(3, 15))
+	var buf bytes.Buffer
+	if err := EncodeSnapshotDiff(&buf, nil, prev, DiffOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeSnapshotDiff(&buf, prev, cur, DiffOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dst.Write(buf.Bytes())
+
+	got := dst.Snapshot(SnapshotDetailFull)
+	for row := range cur.Lines {
+		if got.Lines[row].Text != cur.Lines[row].Text {
+			t.Errorf("row %d = %q, want %q", row, got.Lines[row].Text, cur.Lines[row].Text)
+		}
+	}
+	if got.Lines[1].Cells[0].Attributes.Bold != true {
+		t.Error("bold attribute not applied")
+	}
+}
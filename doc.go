@@ -32,7 +32,7 @@
 //	term := headlessterm.New(
 //	    headlessterm.WithSize(24, 80),           // 24 rows, 80 columns
 //	    headlessterm.WithScrollback(storage),    // Enable scrollback
-//	    headlessterm.WithResponse(ptyWriter),    // Handle terminal responses
+//	    headlessterm.WithPTYWriter(ptyWriter),   // Handle terminal responses
 //	)
 //
 //	// Process output from a command
@@ -173,6 +173,10 @@
 //	text := term.GetSelectedText()
 //	term.ClearSelection()
 //
+// StartSelection and ExtendSelection take absolute rows and a SelectionMode
+// (character, word, line, block or semantic), so selections survive
+// scrolling and resizing.
+//
 // # Search
 //
 // Find text in the visible screen or scrollback:
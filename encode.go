@@ -0,0 +1,593 @@
+package headlessterm
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColorDepth selects the color capability of the terminal receiving encoded output.
+type ColorDepth int
+
+const (
+	// ColorDepthTrueColor emits 24-bit colors as-is.
+	ColorDepthTrueColor ColorDepth = iota
+	// ColorDepth256 maps 24-bit colors to the nearest 256-color palette entry.
+	ColorDepth256
+	// ColorDepth16 maps all colors to the nearest of the 16 standard ANSI colors.
+	ColorDepth16
+)
+
+// EncodeOptions configures EncodeScreen.
+type EncodeOptions struct {
+	// ColorDepth is the color capability of the target terminal (default: truecolor).
+	ColorDepth ColorDepth
+	// IncludeScrollback emits scrollback lines before the screen so they end up
+	// in the target terminal's own scrollback.
+	IncludeScrollback bool
+}
+
+// EncodeScreen writes an escape-sequence stream that repaints the current terminal
+// state on a real terminal of the same size. It is intended for detach/reattach:
+// the output restores cell contents with SGR attributes and OSC 8 hyperlinks, the
+// primary and alternate screens, palette overrides, title, scroll region, input
+// and mouse modes, and the cursor position, style and visibility. The current
+// pen (SGR attributes and hyperlink) and charsets are restored last, so text
+// written afterwards by the application renders as it would in this terminal.
+//
+// Images are not transmitted; cells covered by images are painted as blanks.
+//
+// Example:
+//
+//	var buf bytes.Buffer
+//	term.EncodeScreen(&buf, headlessterm.EncodeOptions{ColorDepth: headlessterm.ColorDepth256})
+//	os.Stdout.Write(buf.Bytes())
+func (t *Terminal) EncodeScreen(w io.Writer, opts EncodeOptions) error {
+	t.mu.RLock()
+	e := newANSIEncoder(opts.ColorDepth)
+	t.encodeScreenLocked(e, opts)
+	t.mu.RUnlock()
+
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// encodeScreenLocked writes the repaint stream into e (caller must hold lock).
+func (t *Terminal) encodeScreenLocked(e *ansiEncoder, opts EncodeOptions) {
+	// Start from a known state: primary screen, no margins, default pen, autowrap on
+	e.WriteString("\x1b[?1049l\x1b[r\x1b[?6l\x1b[?7h\x1b[0m\x1b(B\x0f")
+	e.resetPen()
+
+	for _, index := range sortedColorIndexes(t.colors) {
+		rgba := resolveDefaultColor(t.colors[index], true)
+		fmt.Fprintf(&e.buf, "\x1b]4;%d;rgb:%02x/%02x/%02x\x1b\\", index, rgba.R, rgba.G, rgba.B)
+	}
+	if t.title != "" {
+		fmt.Fprintf(&e.buf, "\x1b]2;%s\x1b\\", oscText(t.title, ""))
+	}
+
+	e.WriteString("\x1b[H\x1b[2J")
+
+	var scrollback [][]Cell
+	if opts.IncludeScrollback {
+		for i := 0; i < t.primaryBuffer.ScrollbackLen(); i++ {
+			scrollback = append(scrollback, t.primaryBuffer.ScrollbackLine(i))
+		}
+	}
+	e.encodeLines(scrollback, t.primaryBuffer)
+
+	if t.activeBuffer == t.alternateBuffer {
+		// Park the cursor where the primary screen expects it when the application
+		// leaves the alternate screen, then let the target save it on switch.
+		if t.savedCursor != nil {
+			e.placeCursor(t.primaryBuffer, t.savedCursor.Row, t.savedCursor.Col, 0)
+		}
+		e.WriteString("\x1b[?1049h\x1b[H\x1b[2J")
+		e.encodeLines(nil, t.alternateBuffer)
+	}
+
+	t.encodeModesLocked(e)
+}
+
+// encodeModesLocked emits the scroll region, modes, cursor and pen state (caller must hold lock).
+func (t *Terminal) encodeModesLocked(e *ansiEncoder) {
+	e.setHyperlink(nil)
+
+	if t.scrollTop != 0 || t.scrollBottom != t.rows {
+		fmt.Fprintf(&e.buf, "\x1b[%d;%dr", t.scrollTop+1, t.scrollBottom)
+	}
+
+	decModes := []struct {
+		mode TerminalMode
+		code int
+	}{
+		{ModeCursorKeys, 1},
+		{ModeOrigin, 6},
+		{ModeBlinkingCursor, 12},
+		{ModeReportMouseClicks, 1000},
+		{ModeReportCellMouseMotion, 1002},
+		{ModeReportAllMouseMotion, 1003},
+		{ModeReportFocusInOut, 1004},
+		{ModeUTF8Mouse, 1005},
+		{ModeSGRMouse, 1006},
+		{ModeAlternateScroll, 1007},
+		{ModeBracketedPaste, 2004},
+	}
+	for _, m := range decModes {
+		if t.modes&m.mode != 0 {
+			fmt.Fprintf(&e.buf, "\x1b[?%dh", m.code)
+		}
+	}
+	if t.modes&ModeInsert != 0 {
+		e.WriteString("\x1b[4h")
+	}
+	if t.modes&ModeLineFeedNewLine != 0 {
+		e.WriteString("\x1b[20h")
+	}
+	if t.modes&ModeKeypadApplication != 0 {
+		e.WriteString("\x1b=")
+	}
+
+	// Cursor positioning is relative to the scroll region in origin mode
+	rowOffset := 0
+	if t.modes&ModeOrigin != 0 {
+		rowOffset = t.scrollTop
+	}
+	e.placeCursor(t.activeBuffer, t.cursor.Row, t.cursor.Col, rowOffset)
+
+	if t.modes&ModeLineWrap == 0 {
+		e.WriteString("\x1b[?7l")
+	}
+
+	fmt.Fprintf(&e.buf, "\x1b[%d q", cursorStyleToDECSCUSR(t.cursor.Style))
+	if t.cursor.Visible {
+		e.WriteString("\x1b[?25h")
+	} else {
+		e.WriteString("\x1b[?25l")
+	}
+
+	// Current pen, hyperlink and charsets for subsequent application output
+	e.setPen(&t.template.Cell)
+	e.setHyperlink(t.currentHyperlink)
+	for i, cs := range t.charsets {
+		if cs == CharsetLineDrawing {
+			e.buf.WriteByte(0x1b)
+			e.buf.WriteByte("()*+"[i])
+			e.buf.WriteByte('0')
+		}
+	}
+	if t.activeCharset == 1 {
+		e.buf.WriteByte(0x0e)
+	}
+}
+
+// cursorStyleToDECSCUSR converts a cursor style to its DECSCUSR parameter.
+func cursorStyleToDECSCUSR(style CursorStyle) int {
+	switch style {
+	case CursorStyleSteadyBlock:
+		return 2
+	case CursorStyleBlinkingUnderline:
+		return 3
+	case CursorStyleSteadyUnderline:
+		return 4
+	case CursorStyleBlinkingBar:
+		return 5
+	case CursorStyleSteadyBar:
+		return 6
+	default:
+		return 1
+	}
+}
+
+// sortedColorIndexes returns the palette override indexes in ascending order.
+func sortedColorIndexes(colors map[int]color.Color) []int {
+	indexes := make([]int, 0, len(colors))
+	for i := 0; i < 256; i++ {
+		if _, ok := colors[i]; ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// ansiEncoder accumulates escape sequences and tracks the pen state of the target terminal.
+type ansiEncoder struct {
+	buf   bytes.Buffer
+	depth ColorDepth
+
+	pen      cellPen
+	penValid bool
+	link     *Hyperlink
+}
+
+// cellPen is the SGR-relevant subset of a cell, with colors pre-encoded for the target depth.
+type cellPen struct {
+	fg, bg, ul string
+	flags      CellFlags
+}
+
+// penFlags are the cell flags that are expressed through SGR.
+const penFlags = CellFlagBold | CellFlagDim | CellFlagItalic | CellFlagUnderline |
+	CellFlagDoubleUnderline | CellFlagCurlyUnderline | CellFlagDottedUnderline |
+	CellFlagDashedUnderline | CellFlagBlinkSlow | CellFlagBlinkFast | CellFlagReverse |
+	CellFlagHidden | CellFlagStrike
+
+func newANSIEncoder(depth ColorDepth) *ansiEncoder {
+	return &ansiEncoder{depth: depth}
+}
+
+// WriteString appends raw bytes to the output.
+func (e *ansiEncoder) WriteString(s string) {
+	e.buf.WriteString(s)
+}
+
+// resetPen records that the target has default attributes.
+func (e *ansiEncoder) resetPen() {
+	e.pen = cellPen{}
+	e.penValid = true
+}
+
+// moveTo emits an absolute cursor position (0-based).
+func (e *ansiEncoder) moveTo(row, col int) {
+	fmt.Fprintf(&e.buf, "\x1b[%d;%dH", row+1, col+1)
+}
+
+// placeCursor moves the cursor to (row, col) of b, where row is emitted relative to
+// rowOffset. A column past the right margin denotes a pending wrap, which is
+// re-created by repainting the last column.
+func (e *ansiEncoder) placeCursor(b *Buffer, row, col, rowOffset int) {
+	if col < b.Cols() {
+		e.moveTo(row-rowOffset, col)
+		return
+	}
+	e.moveTo(row-rowOffset, b.Cols()-1)
+	if cell := b.Cell(row, b.Cols()-1); cell != nil && !cell.IsWideSpacer() {
+		e.writeCell(cell)
+	}
+}
+
+// penFor computes the pen for a cell at the encoder's color depth.
+func (e *ansiEncoder) penFor(c *Cell) cellPen {
+	p := cellPen{
+		fg:    e.colorParams(c.Fg, 30),
+		bg:    e.colorParams(c.Bg, 40),
+		flags: c.Flags & penFlags,
+	}
+	if e.depth != ColorDepth16 {
+		p.ul = e.colorParams(c.UnderlineColor, 50)
+	}
+	return p
+}
+
+// setPen emits SGR so the target's attributes match c.
+// When the current pen is known, only the differences are emitted if that is shorter
+// than a full reset.
+func (e *ansiEncoder) setPen(c *Cell) {
+	p := e.penFor(c)
+	if e.penValid && p == e.pen {
+		return
+	}
+
+	params := []string{"0"}
+	params = append(params, flagParams(p.flags)...)
+	for _, s := range []string{p.fg, p.bg, p.ul} {
+		if s != "" {
+			params = append(params, s)
+		}
+	}
+	if e.penValid {
+		if delta := penDelta(e.pen, p); sgrLen(delta) < sgrLen(params) {
+			params = delta
+		}
+	}
+	e.writeSGR(params)
+	e.pen = p
+	e.penValid = true
+}
+
+// penDelta returns the SGR parameters that change from into to without a reset.
+func penDelta(from, to cellPen) []string {
+	var params []string
+	removed := from.flags &^ to.flags
+	added := to.flags &^ from.flags
+
+	// Bold and dim share a single reset (22), as do both blink speeds (25)
+	if removed&(CellFlagBold|CellFlagDim) != 0 {
+		params = append(params, "22")
+		added |= to.flags & (CellFlagBold | CellFlagDim)
+	}
+	if removed&CellFlagItalic != 0 {
+		params = append(params, "23")
+	}
+	if removed&cellUnderlineFlags != 0 && to.flags&cellUnderlineFlags == 0 {
+		params = append(params, "24")
+	}
+	if removed&(CellFlagBlinkSlow|CellFlagBlinkFast) != 0 {
+		params = append(params, "25")
+		added |= to.flags & (CellFlagBlinkSlow | CellFlagBlinkFast)
+	}
+	if removed&CellFlagReverse != 0 {
+		params = append(params, "27")
+	}
+	if removed&CellFlagHidden != 0 {
+		params = append(params, "28")
+	}
+	if removed&CellFlagStrike != 0 {
+		params = append(params, "29")
+	}
+
+	// A changed underline style is expressed by setting the new one
+	if from.flags&cellUnderlineFlags != to.flags&cellUnderlineFlags {
+		added |= to.flags & cellUnderlineFlags
+	}
+	params = append(params, flagParams(added)...)
+
+	colors := []struct {
+		from, to, reset string
+	}{
+		{from.fg, to.fg, "39"},
+		{from.bg, to.bg, "49"},
+		{from.ul, to.ul, "59"},
+	}
+	for _, c := range colors {
+		switch {
+		case c.from == c.to:
+		case c.to == "":
+			params = append(params, c.reset)
+		default:
+			params = append(params, c.to)
+		}
+	}
+	return params
+}
+
+// sgrLen returns the encoded length of SGR parameters, excluding the CSI and final byte.
+func sgrLen(params []string) int {
+	n := len(params)
+	for _, p := range params {
+		n += len(p)
+	}
+	return n
+}
+
+// writeSGR emits a single SGR sequence with the given parameters.
+func (e *ansiEncoder) writeSGR(params []string) {
+	e.buf.WriteString("\x1b[")
+	for i, p := range params {
+		if i > 0 {
+			e.buf.WriteByte(';')
+		}
+		e.buf.WriteString(p)
+	}
+	e.buf.WriteByte('m')
+}
+
+// setHyperlink emits OSC 8 if the active hyperlink differs from link.
+func (e *ansiEncoder) setHyperlink(link *Hyperlink) {
+	if sameHyperlink(e.link, link) {
+		return
+	}
+	if link == nil {
+		e.buf.WriteString("\x1b]8;;\x1b\\")
+	} else if id := oscText(link.ID, ";:"); id != "" {
+		fmt.Fprintf(&e.buf, "\x1b]8;id=%s;%s\x1b\\", id, oscText(link.URI, ""))
+	} else {
+		fmt.Fprintf(&e.buf, "\x1b]8;;%s\x1b\\", oscText(link.URI, ""))
+	}
+	e.link = link
+}
+
+// oscText returns s without C0 and C1 controls, invalid UTF-8 and any
+// character in drop, so it cannot end or extend the OSC string it is
+// written into.
+func oscText(s, drop string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if !(r == utf8.RuneError && size == 1 || r < 0x20 || r >= 0x7f && r <= 0x9f || strings.ContainsRune(drop, r)) {
+			b.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	return b.String()
+}
+
+// sameHyperlink reports whether two hyperlinks are equivalent.
+func sameHyperlink(a, b *Hyperlink) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.URI == b.URI
+}
+
+// writeCell emits a cell's pen, hyperlink and character. Spacers must be skipped by the caller.
+func (e *ansiEncoder) writeCell(c *Cell) {
+	e.setPen(c)
+	e.setHyperlink(c.Hyperlink)
+	e.buf.WriteRune(printableRune(c))
+}
+
+// printableRune returns the character to emit for a cell.
+func printableRune(c *Cell) rune {
+	if c.Char == 0 || c.Char == ImagePlaceholderChar || c.Char < 0x20 {
+		return ' '
+	}
+	return c.Char
+}
+
+// isBlankCell returns true if the cell renders as empty space with the given background.
+func isBlankCell(c *Cell) bool {
+	return printableRune(c) == ' ' && c.Hyperlink == nil &&
+		c.Flags&(CellFlagReverse|CellFlagUnderline|CellFlagDoubleUnderline|CellFlagCurlyUnderline|
+			CellFlagDottedUnderline|CellFlagDashedUnderline|CellFlagStrike|CellFlagWideChar) == 0
+}
+
+// encodeLines paints scrollback lines followed by the buffer rows, top to bottom,
+// starting at the cursor's current position. Soft-wrapped rows are written without
+// a line break so the target terminal also records them as wrapped.
+func (e *ansiEncoder) encodeLines(scrollback [][]Cell, b *Buffer) {
+	total := len(scrollback) + b.Rows()
+	for i := 0; i < total; i++ {
+		var line []Cell
+		wrapped := false
+		if i < len(scrollback) {
+			line = scrollback[i]
+		} else {
+			row := i - len(scrollback)
+			line = b.cells[row]
+			wrapped = b.IsWrapped(row) && row < b.Rows()-1
+		}
+
+		e.encodeLine(line, wrapped)
+		if !wrapped && i < total-1 {
+			e.setHyperlink(nil)
+			e.buf.WriteString("\r\n")
+		}
+	}
+	e.setHyperlink(nil)
+}
+
+// encodeLine writes a single line. Trailing blanks are replaced by an erase-to-EOL
+// when they have a non-default background, and omitted otherwise.
+func (e *ansiEncoder) encodeLine(line []Cell, full bool) {
+	end := len(line)
+	if !full {
+		for end > 0 && isBlankCell(&line[end-1]) {
+			end--
+		}
+	}
+
+	for col := 0; col < end; col++ {
+		if line[col].IsWideSpacer() {
+			continue
+		}
+		e.writeCell(&line[col])
+	}
+
+	if end < len(line) {
+		tail := &line[end]
+		if e.penFor(tail).bg != "" {
+			e.setHyperlink(nil)
+			e.setPen(tail)
+			e.buf.WriteString("\x1b[K")
+		}
+	}
+}
+
+// flagParams returns SGR parameters for the given flags.
+func flagParams(flags CellFlags) []string {
+	var params []string
+	if flags&CellFlagBold != 0 {
+		params = append(params, "1")
+	}
+	if flags&CellFlagDim != 0 {
+		params = append(params, "2")
+	}
+	if flags&CellFlagItalic != 0 {
+		params = append(params, "3")
+	}
+	if ul := underlineParam(flags); ul != "" {
+		params = append(params, ul)
+	}
+	if flags&CellFlagBlinkSlow != 0 {
+		params = append(params, "5")
+	}
+	if flags&CellFlagBlinkFast != 0 {
+		params = append(params, "6")
+	}
+	if flags&CellFlagReverse != 0 {
+		params = append(params, "7")
+	}
+	if flags&CellFlagHidden != 0 {
+		params = append(params, "8")
+	}
+	if flags&CellFlagStrike != 0 {
+		params = append(params, "9")
+	}
+	return params
+}
+
+// underlineParam returns the SGR parameter for the underline style in flags, or "".
+func underlineParam(flags CellFlags) string {
+	switch {
+	case flags&CellFlagCurlyUnderline != 0:
+		return "4:3"
+	case flags&CellFlagDoubleUnderline != 0:
+		return "4:2"
+	case flags&CellFlagDottedUnderline != 0:
+		return "4:4"
+	case flags&CellFlagDashedUnderline != 0:
+		return "4:5"
+	case flags&CellFlagUnderline != 0:
+		return "4"
+	}
+	return ""
+}
+
+// colorParams returns the SGR parameters selecting c as a foreground (base 30),
+// background (base 40) or underline (base 50) color. Returns "" for the default color.
+func (e *ansiEncoder) colorParams(c color.Color, base int) string {
+	switch v := c.(type) {
+	case nil:
+		return ""
+	case *NamedColor:
+		switch {
+		case v.Name == NamedColorForeground && base == 30,
+			v.Name == NamedColorBackground && base == 40:
+			return ""
+		case v.Name >= 0 && v.Name < 16:
+			return e.indexParams(v.Name, base)
+		}
+	case *IndexedColor:
+		if v.Index >= 0 && v.Index < 256 {
+			return e.indexParams(v.Index, base)
+		}
+	}
+
+	rgba := resolveDefaultColor(c, base != 40)
+	switch e.depth {
+	case ColorDepth256:
+		return e.indexParams(nearestPaletteIndex(rgba, 16, 256), base)
+	case ColorDepth16:
+		return e.indexParams(nearestPaletteIndex(rgba, 0, 16), base)
+	default:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, rgba.R, rgba.G, rgba.B)
+	}
+}
+
+// indexParams returns the SGR parameters selecting a palette index.
+func (e *ansiEncoder) indexParams(index, base int) string {
+	if index >= 16 && e.depth == ColorDepth16 {
+		index = nearestPaletteIndex(DefaultPalette[index], 0, 16)
+	}
+	switch {
+	case base == 50:
+		return "58;5;" + strconv.Itoa(index)
+	case index < 8:
+		return strconv.Itoa(base + index)
+	case index < 16:
+		return strconv.Itoa(base + 60 + index - 8)
+	default:
+		return fmt.Sprintf("%d;5;%d", base+8, index)
+	}
+}
+
+// nearestPaletteIndex returns the DefaultPalette index in [from, to) closest to c.
+func nearestPaletteIndex(c color.RGBA, from, to int) int {
+	best, bestDist := from, -1
+	for i := from; i < to; i++ {
+		p := DefaultPalette[i]
+		dr := int(c.R) - int(p.R)
+		dg := int(c.G) - int(p.G)
+		db := int(c.B) - int(p.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,185 @@
+package headlessterm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// replay feeds encoded output into a fresh terminal of the same size.
+func replay(t *testing.T, src *Terminal, opts EncodeOptions, termOpts ...Option) *Terminal {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := src.EncodeScreen(&buf, opts); err != nil {
+		t.Fatalf("EncodeScreen: %v", err)
+	}
+	dst := New(append([]Option{WithSize(src.Rows(), src.Cols())}, termOpts...)...)
+	dst.Write(buf.Bytes())
+	return dst
+}
+
+// assertSameScreen compares text, attributes and hyperlinks cell by cell.
+func assertSameScreen(t *testing.T, got, want *Terminal) {
+	t.Helper()
+	gs, ws := got.Snapshot(SnapshotDetailFull), want.Snapshot(SnapshotDetailFull)
+	for row := range ws.Lines {
+		for col := range ws.Lines[row].Cells {
+			g, w := gs.Lines[row].Cells[col], ws.Lines[row].Cells[col]
+			if g.Char != w.Char || g.Fg != w.Fg || g.Bg != w.Bg || g.Attributes != w.Attributes ||
+				g.UnderlineColor != w.UnderlineColor || g.Wide != w.Wide || g.WideSpacer != w.WideSpacer {
+				t.Fatalf("cell (%d,%d) = %+v, want %+v", row, col, g, w)
+			}
+			if (g.Hyperlink == nil) != (w.Hyperlink == nil) || (g.Hyperlink != nil && *g.Hyperlink != *w.Hyperlink) {
+				t.Fatalf("cell (%d,%d) hyperlink = %+v, want %+v", row, col, g.Hyperlink, w.Hyperlink)
+			}
+		}
+	}
+	if gs.Cursor != ws.Cursor {
+		t.Errorf("cursor = %+v, want %+v", gs.Cursor, ws.Cursor)
+	}
+}
+
+func TestEncodeScreen_RoundTrip(t *testing.T) {
+	term := New(WithSize(6, 20))
+	term.WriteString("\x1b[1;31mred bold\x1b[0m plain\r\n")
+	term.WriteString("\x1b[4:3;58;5;2;38;2;10;20;30mcurly\x1b[0m \x1b[7;9mrev\x1b[0m\r\n")
+	term.WriteString("\x1b]8;id=a;https://example.com\x1b\\link\x1b]8;;\x1b\\ 中文\r\n")
+	term.WriteString("\x1b[44mblue bg\x1b[K\x1b[0m\r\n")
+	term.WriteString("0123456789abcdefghijKLM")
+	term.WriteString("\x1b[?25l\x1b[6 q")
+
+	got := replay(t, term, EncodeOptions{})
+	assertSameScreen(t, got, term)
+
+	if !got.IsWrapped(4) {
+		t.Error("soft wrap not preserved")
+	}
+	if got.CursorVisible() {
+		t.Error("cursor should be hidden")
+	}
+	if got.CursorStyle() != CursorStyleSteadyBar {
+		t.Errorf("CursorStyle = %v, want steady bar", got.CursorStyle())
+	}
+}
+
+func TestEncodeScreen_ModesAndRegion(t *testing.T) {
+	term := New(WithSize(10, 20))
+	term.WriteString("\x1b[?1h\x1b[?2004h\x1b[?1006h\x1b[?1000h\x1b=\x1b[3;8r\x1b[5;3H\x1b[1;32m")
+	term.WriteString("\x1b]8;;https://x.test\x1b\\")
+
+	got := replay(t, term, EncodeOptions{})
+
+	for _, m := range []TerminalMode{ModeCursorKeys, ModeBracketedPaste, ModeSGRMouse, ModeReportMouseClicks, ModeKeypadApplication} {
+		if !got.HasMode(m) {
+			t.Errorf("mode %b not restored", m)
+		}
+	}
+	top, bottom := got.ScrollRegion()
+	if top != 2 || bottom != 8 {
+		t.Errorf("ScrollRegion = (%d,%d), want (2,8)", top, bottom)
+	}
+	row, col := got.CursorPos()
+	if row != 4 || col != 2 {
+		t.Errorf("cursor = (%d,%d), want (4,2)", row, col)
+	}
+
+	// The pen must carry over to subsequent output
+	got.WriteString("X")
+	cell := got.Cell(4, 2)
+	if !cell.HasFlag(CellFlagBold) || colorToHex(cell.Fg) != colorToHex(&NamedColor{Name: 2}) {
+		t.Errorf("pen not restored: %+v", cell)
+	}
+	if cell.Hyperlink == nil || cell.Hyperlink.URI != "https://x.test" {
+		t.Errorf("hyperlink not restored: %+v", cell.Hyperlink)
+	}
+}
+
+func TestEncodeScreen_AlternateScreen(t *testing.T) {
+	term := New(WithSize(4, 10))
+	term.WriteString("shell$ vim\x1b[?1049h\x1b[H~\r\n~\x1b[2;2H")
+
+	got := replay(t, term, EncodeOptions{})
+	if !got.IsAlternateScreen() {
+		t.Fatal("alternate screen not entered")
+	}
+	assertSameScreen(t, got, term)
+
+	got.WriteString("\x1b[?1049l")
+	term.WriteString("\x1b[?1049l")
+	assertSameScreen(t, got, term)
+}
+
+func TestEncodeScreen_Scrollback(t *testing.T) {
+	term := New(WithSize(3, 10), WithScrollback(NewMemoryScrollback(100)))
+	for _, s := range []string{"one", "two", "three", "four", "five"} {
+		term.WriteString(s + "\r\n")
+	}
+
+	got := replay(t, term, EncodeOptions{IncludeScrollback: true}, WithScrollback(NewMemoryScrollback(100)))
+	assertSameScreen(t, got, term)
+	if got.ScrollbackLen() != term.ScrollbackLen() {
+		t.Fatalf("ScrollbackLen = %d, want %d", got.ScrollbackLen(), term.ScrollbackLen())
+	}
+
+	without := replay(t, term, EncodeOptions{}, WithScrollback(NewMemoryScrollback(100)))
+	if without.ScrollbackLen() != 0 {
+		t.Errorf("ScrollbackLen = %d, want 0", without.ScrollbackLen())
+	}
+}
+
+func TestEncodeScreen_PendingWrap(t *testing.T) {
+	term := New(WithSize(3, 5))
+	term.WriteString("abcde")
+
+	got := replay(t, term, EncodeOptions{})
+	got.WriteString("f")
+	term.WriteString("f")
+	assertSameScreen(t, got, term)
+}
+
+func TestEncodeScreen_ColorDepth(t *testing.T) {
+	term := New(WithSize(1, 10))
+	term.WriteString("\x1b[38;2;255;0;0mA\x1b[38;5;200mB")
+
+	var buf bytes.Buffer
+	term.EncodeScreen(&buf, EncodeOptions{ColorDepth: ColorDepthTrueColor})
+	if !strings.Contains(buf.String(), "38;2;255;0;0") {
+		t.Errorf("truecolor output missing RGB: %q", buf.String())
+	}
+
+	buf.Reset()
+	term.EncodeScreen(&buf, EncodeOptions{ColorDepth: ColorDepth256})
+	if strings.Contains(buf.String(), "38;2;") || !strings.Contains(buf.String(), "38;5;196") {
+		t.Errorf("256-color output = %q", buf.String())
+	}
+
+	buf.Reset()
+	term.EncodeScreen(&buf, EncodeOptions{ColorDepth: ColorDepth16})
+	if strings.Contains(buf.String(), "38;2;") || strings.Contains(buf.String(), "38;5;") {
+		t.Errorf("16-color output = %q", buf.String())
+	}
+}
+
+func TestEncodeScreen_ControlsInOSCStrings(t *testing.T) {
+	term := New(WithSize(3, 20))
+	term.SetTitle("ti\x07tle\x1b]0;x\x9c")
+	term.SetHyperlink(&ansicode.Hyperlink{ID: "a;b:c\x1b", URI: "https://example.com/\x07\x1b[2J"})
+	term.WriteString("link")
+
+	var buf bytes.Buffer
+	if err := term.EncodeScreen(&buf, EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "\x1b]2;title]0;x\x1b\\") {
+		t.Errorf("title not sanitized: %q", out)
+	}
+	if !strings.Contains(out, "\x1b]8;id=abc;https://example.com/[2J\x1b\\") {
+		t.Errorf("hyperlink not sanitized: %q", out)
+	}
+	if strings.Contains(out, "\x07") {
+		t.Errorf("control leaked into output: %q", out)
+	}
+}
@@ -0,0 +1,609 @@
+package headlessterm
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType identifies the kind of an Event. Types are bit flags so several
+// can be combined when subscribing.
+type EventType uint32
+
+const (
+	// EventDamage reports rows whose content changed.
+	EventDamage EventType = 1 << iota
+	// EventCursorMoved reports a new cursor position.
+	EventCursorMoved
+	// EventTitleChanged reports a new window title.
+	EventTitleChanged
+	// EventModeChanged reports a terminal mode being set or reset.
+	EventModeChanged
+	// EventBufferSwitched reports a switch between primary and alternate screen.
+	EventBufferSwitched
+	// EventResized reports new terminal dimensions.
+	EventResized
+	// EventBell reports BEL characters.
+	EventBell
+	// EventScrollbackPushed reports lines moved into scrollback.
+	EventScrollbackPushed
+	// EventPromptMark reports a semantic prompt mark (OSC 133).
+	EventPromptMark
+	// EventImagePlaced reports a new image placement.
+	EventImagePlaced
+	// EventImageRemoved reports a removed image placement.
+	EventImageRemoved
+	// EventWorkingDirectory reports a working directory change (OSC 7).
+	EventWorkingDirectory
+	// EventUserVar reports a user variable change (OSC 1337 SetUserVar).
+	EventUserVar
+	// EventDropped reports that events were discarded because the subscriber fell behind.
+	EventDropped
+	// EventScrolled reports a scroll of a screen region.
+	EventScrolled
+	// EventCommandStarted reports a shell command starting to run (OSC 133 C).
+	EventCommandStarted
+	// EventCommandFinished reports a finished shell command (OSC 133 D).
+	EventCommandFinished
+
+	// EventAll matches every event type.
+	EventAll EventType = 1<<iota - 1
+)
+
+// eventTypeNames holds the names returned by EventType.String, in bit order.
+var eventTypeNames = []string{
+	"damage", "cursor_moved", "title_changed", "mode_changed", "buffer_switched",
+	"resized", "bell", "scrollback_pushed", "prompt_mark", "image_placed",
+	"image_removed", "working_directory", "user_var", "dropped", "scrolled",
+	"command_started", "command_finished",
+}
+
+// String returns the snake_case name of the type, such as "title_changed".
+// Combined types are joined with '|'.
+func (t EventType) String() string {
+	var names []string
+	for i, name := range eventTypeNames {
+		if t&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseEventType returns the event type with the given String name.
+func ParseEventType(name string) (EventType, bool) {
+	for i, n := range eventTypeNames {
+		if n == name {
+			return 1 << i, true
+		}
+	}
+	return 0, false
+}
+
+// Event is a change notification delivered to subscribers.
+type Event interface {
+	Type() EventType
+}
+
+// RowRange is a range of viewport rows [Start, End).
+type RowRange struct {
+	Start, End int
+}
+
+// DamageEvent lists the rows whose content changed.
+type DamageEvent struct {
+	Rows []RowRange
+}
+
+// CursorMovedEvent reports the new cursor position (0-based).
+type CursorMovedEvent struct {
+	Row, Col int
+}
+
+// TitleChangedEvent reports the new window title.
+type TitleChangedEvent struct {
+	Title string
+}
+
+// ModeChangedEvent reports a mode being set (Enabled) or reset.
+type ModeChangedEvent struct {
+	Mode    TerminalMode
+	Enabled bool
+}
+
+// BufferSwitchedEvent reports the now active buffer.
+type BufferSwitchedEvent struct {
+	Alternate bool
+}
+
+// ResizedEvent reports the new dimensions.
+type ResizedEvent struct {
+	Rows, Cols int
+}
+
+// BellEvent reports Count bells since the previous BellEvent.
+type BellEvent struct {
+	Count int
+}
+
+// ScrollbackPushedEvent reports Lines pushed into scrollback.
+type ScrollbackPushedEvent struct {
+	Lines int
+}
+
+// PromptMarkEvent reports a recorded prompt mark.
+type PromptMarkEvent struct {
+	Mark PromptMark
+}
+
+// ImagePlacedEvent reports a new image placement.
+type ImagePlacedEvent struct {
+	PlacementID, ImageID uint32
+}
+
+// ImageRemovedEvent reports a removed image placement.
+type ImageRemovedEvent struct {
+	PlacementID, ImageID uint32
+}
+
+// WorkingDirectoryEvent reports the new working directory URI.
+type WorkingDirectoryEvent struct {
+	URI string
+}
+
+// UserVarEvent reports a user variable change.
+type UserVarEvent struct {
+	Name, Value string
+}
+
+// ScrolledEvent reports a region scroll. Damage events that follow it use
+// the scrolled coordinates.
+type ScrolledEvent struct {
+	ScrollDamage
+}
+
+// CommandStartedEvent reports a command from the command history that
+// started running.
+type CommandStartedEvent struct {
+	Command Command
+}
+
+// CommandFinishedEvent reports a command from the command history that
+// finished, with its exit code and timestamps.
+type CommandFinishedEvent struct {
+	Command Command
+}
+
+// DroppedEvent reports Count discarded events. Subscribers should resync
+// from the terminal state when they receive it.
+type DroppedEvent struct {
+	Count int
+}
+
+func (DamageEvent) Type() EventType           { return EventDamage }
+func (CursorMovedEvent) Type() EventType      { return EventCursorMoved }
+func (TitleChangedEvent) Type() EventType     { return EventTitleChanged }
+func (ModeChangedEvent) Type() EventType      { return EventModeChanged }
+func (BufferSwitchedEvent) Type() EventType   { return EventBufferSwitched }
+func (ResizedEvent) Type() EventType          { return EventResized }
+func (BellEvent) Type() EventType             { return EventBell }
+func (ScrollbackPushedEvent) Type() EventType { return EventScrollbackPushed }
+func (PromptMarkEvent) Type() EventType       { return EventPromptMark }
+func (ImagePlacedEvent) Type() EventType      { return EventImagePlaced }
+func (ImageRemovedEvent) Type() EventType     { return EventImageRemoved }
+func (WorkingDirectoryEvent) Type() EventType { return EventWorkingDirectory }
+func (UserVarEvent) Type() EventType          { return EventUserVar }
+func (DroppedEvent) Type() EventType          { return EventDropped }
+func (ScrolledEvent) Type() EventType         { return EventScrolled }
+func (CommandStartedEvent) Type() EventType   { return EventCommandStarted }
+func (CommandFinishedEvent) Type() EventType  { return EventCommandFinished }
+
+// maxPendingEvents bounds the queue of a subscriber that is not reading.
+// Events that cannot be coalesced are dropped beyond this limit.
+const maxPendingEvents = 256
+
+// Subscription receives events from a Terminal until closed.
+//
+// Events are queued per subscriber, so a slow subscriber never blocks Write.
+// While events wait in the queue they are coalesced: damage ranges are merged
+// up to the previous scroll, consecutive scrolls of a region are joined,
+// state events (cursor, title, size, buffer, working directory, per-mode and
+// per-variable changes) keep only the latest value, and bells and scrollback
+// pushes are summed. Scrolls, prompt marks, command and image events are kept individually up
+// to a limit, after which a DroppedEvent is queued instead.
+type Subscription struct {
+	t      *Terminal
+	filter EventType
+	out    chan Event
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu    sync.Mutex
+	queue []Event
+}
+
+// Events returns the channel events are delivered on.
+// It is closed after Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.out
+}
+
+// Close stops delivery and unregisters the subscription.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.t.events.remove(s)
+		close(s.done)
+	})
+}
+
+// push queues an event, coalescing it with pending events where possible.
+func (s *Subscription) push(ev Event) {
+	if ev.Type()&s.filter == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	for i := len(s.queue) - 1; i >= 0; i-- {
+		pending := s.queue[i]
+		if merged, ok := coalesceEvents(pending, ev); ok {
+			// Move the merged event to the end so it follows the events it raced with
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			ev = merged
+			break
+		}
+		// Damage is relative to the scrolls before it, and scrolls only
+		// join directly adjacent scrolls
+		if ev.Type() == EventScrolled || (ev.Type() == EventDamage && pending.Type() == EventScrolled) {
+			break
+		}
+	}
+	switch ev.Type() {
+	case EventPromptMark, EventImagePlaced, EventImageRemoved, EventScrolled, EventCommandStarted, EventCommandFinished:
+		if len(s.queue) >= maxPendingEvents {
+			ev = DroppedEvent{Count: 1}
+			for i, pending := range s.queue {
+				if d, ok := pending.(DroppedEvent); ok {
+					s.queue = append(s.queue[:i], s.queue[i+1:]...)
+					ev = DroppedEvent{Count: d.Count + 1}
+					break
+				}
+			}
+		}
+	}
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// coalesceEvents merges next into a pending event of the same kind.
+func coalesceEvents(pending, next Event) (Event, bool) {
+	switch n := next.(type) {
+	case DamageEvent:
+		if p, ok := pending.(DamageEvent); ok {
+			return DamageEvent{Rows: mergeRowRanges(append(append([]RowRange(nil), p.Rows...), n.Rows...))}, true
+		}
+	case CursorMovedEvent, TitleChangedEvent, BufferSwitchedEvent, ResizedEvent, WorkingDirectoryEvent:
+		if pending.Type() == next.Type() {
+			return next, true
+		}
+	case ModeChangedEvent:
+		if p, ok := pending.(ModeChangedEvent); ok && p.Mode == n.Mode {
+			return next, true
+		}
+	case UserVarEvent:
+		if p, ok := pending.(UserVarEvent); ok && p.Name == n.Name {
+			return next, true
+		}
+	case BellEvent:
+		if p, ok := pending.(BellEvent); ok {
+			return BellEvent{Count: p.Count + n.Count}, true
+		}
+	case ScrollbackPushedEvent:
+		if p, ok := pending.(ScrollbackPushedEvent); ok {
+			return ScrollbackPushedEvent{Lines: p.Lines + n.Lines}, true
+		}
+	case ScrolledEvent:
+		if p, ok := pending.(ScrolledEvent); ok && p.Top == n.Top && p.Bottom == n.Bottom && (p.Lines > 0) == (n.Lines > 0) {
+			p.Lines += n.Lines
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// mergeRowRanges sorts ranges and joins overlapping or adjacent ones.
+func mergeRowRanges(ranges []RowRange) []RowRange {
+	for i := 1; i < len(ranges); i++ {
+		for j := i; j > 0 && ranges[j].Start < ranges[j-1].Start; j-- {
+			ranges[j], ranges[j-1] = ranges[j-1], ranges[j]
+		}
+	}
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// next pops the oldest queued event.
+func (s *Subscription) next() (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+	ev := s.queue[0]
+	s.queue = s.queue[1:]
+	return ev, true
+}
+
+// run delivers queued events until the subscription is closed.
+func (s *Subscription) run() {
+	defer close(s.out)
+	for {
+		ev, ok := s.next()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		select {
+		case s.out <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// eventHub tracks subscribers and the state last reported to them.
+type eventHub struct {
+	mu     sync.Mutex // Serializes delivery; acquired before Terminal.mu
+	subs   []*Subscription
+	active atomic.Int32
+
+	// Discrete events recorded by handlers (guarded by Terminal.mu)
+	pending []Event
+
+	// State at the last delivery (guarded by Terminal.mu)
+	last eventState
+}
+
+// eventState is the terminal state events are computed against.
+type eventState struct {
+	gen        Generation
+	rows, cols int
+	cursorRow  int
+	cursorCol  int
+	title      string
+	modes      TerminalMode
+	alternate  bool
+	pushed     uint64
+	workingDir string
+	placements map[uint32]uint32 // Placement ID -> image ID
+}
+
+// remove unregisters a subscription.
+func (h *eventHub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, sub := range h.subs {
+		if sub == s {
+			h.subs = append(h.subs[:i], h.subs[i+1:]...)
+			h.active.Add(-1)
+			return
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the given event types (all types if none given).
+// Events are computed after each Write and Resize and delivered on a separate
+// goroutine, never while the terminal lock is held. Call Close when done.
+//
+// Example:
+//
+//	sub := term.Subscribe(headlessterm.EventDamage, headlessterm.EventTitleChanged)
+//	defer sub.Close()
+//	for ev := range sub.Events() {
+//	    switch ev := ev.(type) {
+//	    case headlessterm.DamageEvent:
+//	        redraw(ev.Rows)
+//	    case headlessterm.TitleChangedEvent:
+//	        setTitle(ev.Title)
+//	    }
+//	}
+func (t *Terminal) Subscribe(types ...EventType) *Subscription {
+	filter := EventType(0)
+	for _, typ := range types {
+		filter |= typ
+	}
+	if filter == 0 {
+		filter = EventAll
+	}
+
+	s := &Subscription{
+		t:      t,
+		filter: filter,
+		out:    make(chan Event),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	h := &t.events
+	h.mu.Lock()
+	if len(h.subs) == 0 {
+		// Start tracking from the current state
+		t.mu.Lock()
+		h.pending = nil
+		h.last = t.eventStateLocked()
+		t.mu.Unlock()
+	}
+	h.subs = append(h.subs, s)
+	h.active.Add(1)
+	h.mu.Unlock()
+
+	go s.run()
+	return s
+}
+
+// queueEventLocked records a discrete event for the next delivery (caller must hold lock).
+func (t *Terminal) queueEventLocked(ev Event) {
+	if t.events.active.Load() == 0 {
+		return
+	}
+	t.events.pending = append(t.events.pending, ev)
+}
+
+// flushEvents computes the events since the last delivery and hands them to subscribers.
+func (t *Terminal) flushEvents() {
+	h := &t.events
+	if h.active.Load() == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	events := t.collectEventsLocked()
+	t.mu.Unlock()
+
+	for _, s := range h.subs {
+		for _, ev := range events {
+			s.push(ev)
+		}
+	}
+}
+
+// eventStateLocked captures the state events are computed against (caller must hold lock).
+func (t *Terminal) eventStateLocked() eventState {
+	st := eventState{
+		gen:        t.clock.now(),
+		rows:       t.rows,
+		cols:       t.cols,
+		cursorRow:  t.cursor.Row,
+		cursorCol:  t.cursor.Col,
+		title:      t.title,
+		modes:      t.modes,
+		alternate:  t.activeBuffer == t.alternateBuffer,
+		pushed:     t.primaryBuffer.pushedLines(),
+		workingDir: t.workingDir,
+		placements: t.placementImagesLocked(),
+	}
+	return st
+}
+
+// placementImagesLocked maps current placement IDs to their image IDs (caller must hold lock).
+func (t *Terminal) placementImagesLocked() map[uint32]uint32 {
+	placements := make(map[uint32]uint32)
+	for _, p := range t.images.Placements() {
+		placements[p.ID] = p.ImageID
+	}
+	return placements
+}
+
+// collectEventsLocked diffs the current state against the last delivered one
+// and drains queued discrete events (caller must hold lock).
+func (t *Terminal) collectEventsLocked() []Event {
+	h := &t.events
+	last := h.last
+	var events []Event
+
+	cur := eventState{
+		gen:        t.clock.now(),
+		rows:       t.rows,
+		cols:       t.cols,
+		cursorRow:  t.cursor.Row,
+		cursorCol:  t.cursor.Col,
+		title:      t.title,
+		modes:      t.modes,
+		alternate:  t.activeBuffer == t.alternateBuffer,
+		pushed:     t.primaryBuffer.pushedLines(),
+		workingDir: t.workingDir,
+		placements: last.placements, // Recomputed below only if images changed
+	}
+
+	if cur.rows != last.rows || cur.cols != last.cols {
+		events = append(events, ResizedEvent{Rows: cur.rows, Cols: cur.cols})
+	}
+	if cur.alternate != last.alternate {
+		events = append(events, BufferSwitchedEvent{Alternate: cur.alternate})
+	}
+	for changed := cur.modes ^ last.modes; changed != 0; changed &= changed - 1 {
+		mode := changed & -changed
+		events = append(events, ModeChangedEvent{Mode: mode, Enabled: cur.modes&mode != 0})
+	}
+	if cur.title != last.title {
+		events = append(events, TitleChangedEvent{Title: cur.title})
+	}
+	if cur.workingDir != last.workingDir {
+		events = append(events, WorkingDirectoryEvent{URI: cur.workingDir})
+	}
+	if cur.pushed != last.pushed {
+		events = append(events, ScrollbackPushedEvent{Lines: int(cur.pushed - last.pushed)})
+	}
+	events = append(events, h.pending...)
+	h.pending = nil
+
+	if t.images.changedAt() > last.gen {
+		cur.placements = t.placementImagesLocked()
+		for id, imageID := range cur.placements {
+			if _, ok := last.placements[id]; !ok {
+				events = append(events, ImagePlacedEvent{PlacementID: id, ImageID: imageID})
+			}
+		}
+		for id, imageID := range last.placements {
+			if _, ok := cur.placements[id]; !ok {
+				events = append(events, ImageRemovedEvent{PlacementID: id, ImageID: imageID})
+			}
+		}
+	}
+
+	events = append(events, damageEvents(t.damageSinceLocked(last.gen))...)
+	if cur.cursorRow != last.cursorRow || cur.cursorCol != last.cursorCol {
+		events = append(events, CursorMovedEvent{Row: cur.cursorRow, Col: cur.cursorCol})
+	}
+
+	h.last = cur
+	return events
+}
+
+// damageEvents converts damage into scroll events followed by a damage event.
+func damageEvents(d Damage) []Event {
+	if d.Full {
+		return []Event{DamageEvent{Rows: []RowRange{{Start: 0, End: d.Rows}}}}
+	}
+	var events []Event
+	for _, s := range d.Scrolls {
+		events = append(events, ScrolledEvent{ScrollDamage: s})
+	}
+	var rows []RowRange
+	for _, span := range d.Spans {
+		if n := len(rows); n > 0 && rows[n-1].End == span.Row {
+			rows[n-1].End = span.Row + 1
+		} else {
+			rows = append(rows, RowRange{Start: span.Row, End: span.Row + 1})
+		}
+	}
+	if len(rows) > 0 {
+		events = append(events, DamageEvent{Rows: rows})
+	}
+	return events
+}
@@ -0,0 +1,189 @@
+package headlessterm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// waitEvent reads events until one of type typ arrives.
+func waitEvent(t *testing.T, sub *Subscription, typ EventType) Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("subscription closed waiting for event %d", typ)
+			}
+			if ev.Type() == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for event %d", typ)
+		}
+	}
+}
+
+func TestSubscribe_Events(t *testing.T) {
+	term := New(WithSize(5, 20), WithScrollback(NewMemoryScrollback(100)))
+	sub := term.Subscribe()
+	defer sub.Close()
+
+	term.WriteString("\x1b[3;1Hhello")
+	if ev := waitEvent(t, sub, EventDamage).(DamageEvent); !reflect.DeepEqual(ev.Rows, []RowRange{{2, 3}}) {
+		t.Errorf("damage = %v, want row 2", ev.Rows)
+	}
+	if ev := waitEvent(t, sub, EventCursorMoved).(CursorMovedEvent); ev.Row != 2 || ev.Col != 5 {
+		t.Errorf("cursor = %d,%d, want 2,5", ev.Row, ev.Col)
+	}
+
+	term.WriteString("\x1b]2;title\x07")
+	if ev := waitEvent(t, sub, EventTitleChanged).(TitleChangedEvent); ev.Title != "title" {
+		t.Errorf("title = %q", ev.Title)
+	}
+
+	term.WriteString("\x1b[?2004h")
+	if ev := waitEvent(t, sub, EventModeChanged).(ModeChangedEvent); ev.Mode != ModeBracketedPaste || !ev.Enabled {
+		t.Errorf("mode event = %+v", ev)
+	}
+
+	term.WriteString("\x1b[?1049h")
+	if ev := waitEvent(t, sub, EventBufferSwitched).(BufferSwitchedEvent); !ev.Alternate {
+		t.Error("expected switch to alternate screen")
+	}
+	term.WriteString("\x1b[?1049l")
+	waitEvent(t, sub, EventBufferSwitched)
+
+	term.Resize(6, 30)
+	if ev := waitEvent(t, sub, EventResized).(ResizedEvent); ev.Rows != 6 || ev.Cols != 30 {
+		t.Errorf("resized = %+v", ev)
+	}
+
+	term.WriteString("\a")
+	waitEvent(t, sub, EventBell)
+
+	term.WriteString("\x1b[6;1H\n\n")
+	if ev := waitEvent(t, sub, EventScrollbackPushed).(ScrollbackPushedEvent); ev.Lines != 2 {
+		t.Errorf("pushed = %d, want 2", ev.Lines)
+	}
+
+	term.WriteString("\x1b]133;D;1\x07")
+	if ev := waitEvent(t, sub, EventPromptMark).(PromptMarkEvent); ev.Mark.Type != ansicode.CommandFinished || ev.Mark.ExitCode != 1 {
+		t.Errorf("prompt mark = %+v", ev.Mark)
+	}
+
+	term.WriteString("\x1b]7;file://host/tmp\x07")
+	if ev := waitEvent(t, sub, EventWorkingDirectory).(WorkingDirectoryEvent); ev.URI != "file://host/tmp" {
+		t.Errorf("cwd = %q", ev.URI)
+	}
+
+	term.WriteString("\x1b]1337;SetUserVar=foo=YmFy\x07")
+	if ev := waitEvent(t, sub, EventUserVar).(UserVarEvent); ev.Name != "foo" || ev.Value != "bar" {
+		t.Errorf("user var = %+v", ev)
+	}
+
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;AAAA/wAAAP8AAAD/AAAA/w==\x1b\\")
+	placed := waitEvent(t, sub, EventImagePlaced).(ImagePlacedEvent)
+	term.WriteString("\x1b_Ga=d,d=a\x1b\\")
+	if ev := waitEvent(t, sub, EventImageRemoved).(ImageRemovedEvent); ev.PlacementID != placed.PlacementID {
+		t.Errorf("removed placement %d, want %d", ev.PlacementID, placed.PlacementID)
+	}
+}
+
+func TestSubscribe_CoalescesForSlowSubscriber(t *testing.T) {
+	term := New(WithSize(5, 20))
+	sub := term.Subscribe(EventDamage, EventBell, EventCursorMoved)
+	defer sub.Close()
+
+	// Nothing is read while writing, so events pile up and coalesce
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			term.WriteString("\a\x1b[1;1Hx\x1b[4;1Hy")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked on a slow subscriber")
+	}
+
+	// The delivery goroutine may hold one event in flight; the rest is coalesced
+	sub.mu.Lock()
+	queued := len(sub.queue)
+	sub.mu.Unlock()
+	if queued > 3 {
+		t.Errorf("%d events queued, want coalesced", queued)
+	}
+
+	bells := 0
+	var damage []RowRange
+	timeout := time.After(2 * time.Second)
+	for bells < 1000 || len(damage) == 0 {
+		select {
+		case ev := <-sub.Events():
+			switch ev := ev.(type) {
+			case BellEvent:
+				bells += ev.Count
+			case DamageEvent:
+				damage = mergeRowRanges(append(damage, ev.Rows...))
+			case TitleChangedEvent:
+				t.Error("filtered event delivered")
+			}
+		case <-timeout:
+			t.Fatalf("got %d bells, want 1000", bells)
+		}
+	}
+	if !reflect.DeepEqual(damage, []RowRange{{0, 1}, {3, 4}}) {
+		t.Errorf("damage = %v, want rows 0 and 3", damage)
+	}
+}
+
+func TestSubscribe_Close(t *testing.T) {
+	term := New(WithSize(5, 20))
+	sub := term.Subscribe()
+	sub.Close()
+	sub.Close()
+
+	term.WriteString("after close")
+	select {
+	case _, ok := <-sub.Events():
+		if ok {
+			t.Error("event delivered after Close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("channel not closed")
+	}
+	if term.events.active.Load() != 0 {
+		t.Error("subscription still registered")
+	}
+}
+
+func TestMergeRowRanges(t *testing.T) {
+	got := mergeRowRanges([]RowRange{{5, 6}, {0, 2}, {2, 3}, {4, 5}, {10, 12}, {11, 13}})
+	want := []RowRange{{0, 3}, {4, 6}, {10, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	if got := EventTitleChanged.String(); got != "title_changed" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (EventDamage | EventBell).String(); got != "damage|bell" {
+		t.Errorf("combined String() = %q", got)
+	}
+	for typ := EventDamage; typ < EventAll; typ <<= 1 {
+		if parsed, ok := ParseEventType(typ.String()); !ok || parsed != typ {
+			t.Errorf("ParseEventType(%q) = %v, %v", typ.String(), parsed, ok)
+		}
+	}
+	if _, ok := ParseEventType("nope"); ok {
+		t.Error("ParseEventType accepted an unknown name")
+	}
+}
@@ -0,0 +1,334 @@
+package headlessterm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed fonts/fixed-7x13.psf
+var defaultFontData []byte
+
+// BitmapFont is a fixed-cell bitmap font used by RenderImage.
+// Glyphs may be twice the cell width for wide characters.
+type BitmapFont struct {
+	Width  int // Cell width in pixels
+	Height int // Cell height in pixels
+
+	glyphs map[rune]*bitmapGlyph
+}
+
+// bitmapGlyph is a 1-bit glyph bitmap, rows packed MSB first.
+type bitmapGlyph struct {
+	width  int
+	stride int
+	bits   []byte
+}
+
+// set reports whether the pixel at (x, y) is on.
+func (g *bitmapGlyph) set(x, y int) bool {
+	return g.bits[y*g.stride+x/8]&(0x80>>(x%8)) != 0
+}
+
+// HasGlyph reports whether the font has a glyph for r.
+func (f *BitmapFont) HasGlyph(r rune) bool {
+	_, ok := f.glyphs[r]
+	return ok
+}
+
+// lookup returns the glyph for r, falling back to U+FFFD and '?'.
+func (f *BitmapFont) lookup(r rune) *bitmapGlyph {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	if g, ok := f.glyphs[utf8.RuneError]; ok {
+		return g
+	}
+	return f.glyphs['?']
+}
+
+var defaultFont = sync.OnceValue(func() *BitmapFont {
+	f, err := LoadPSF(bytes.NewReader(defaultFontData))
+	if err != nil {
+		panic("headlessterm: embedded font: " + err.Error())
+	}
+	return f
+})
+
+// DefaultFont returns the embedded 7x13 font (X11 misc-fixed, public domain).
+func DefaultFont() *BitmapFont {
+	return defaultFont()
+}
+
+// LoadPSF loads a PC Screen Font (PSF1 or PSF2, optionally gzip-compressed).
+// Fonts without a Unicode table map glyph i to code point i.
+func LoadPSF(r io.Reader) (*BitmapFont, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read psf: %w", err)
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gunzip psf: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("gunzip psf: %w", err)
+		}
+	}
+
+	switch {
+	case len(data) >= 4 && data[0] == 0x36 && data[1] == 0x04:
+		return loadPSF1(data)
+	case len(data) >= 32 && bytes.Equal(data[:4], []byte{0x72, 0xb5, 0x4a, 0x86}):
+		return loadPSF2(data)
+	}
+	return nil, fmt.Errorf("not a psf font")
+}
+
+// loadPSF1 parses a PSF version 1 font (8 pixels wide).
+func loadPSF1(data []byte) (*BitmapFont, error) {
+	mode, height := data[2], int(data[3])
+	count := 256
+	if mode&0x01 != 0 {
+		count = 512
+	}
+	glyphData := data[4:]
+	if height == 0 || len(glyphData) < count*height {
+		return nil, fmt.Errorf("psf1: truncated glyph data")
+	}
+
+	f := &BitmapFont{Width: 8, Height: height, glyphs: make(map[rune]*bitmapGlyph)}
+	glyphAt := func(i int) *bitmapGlyph {
+		return &bitmapGlyph{width: 8, stride: 1, bits: glyphData[i*height : (i+1)*height]}
+	}
+
+	table := glyphData[count*height:]
+	if mode&0x06 == 0 || len(table) == 0 {
+		for i := 0; i < count; i++ {
+			f.glyphs[rune(i)] = glyphAt(i)
+		}
+		return f, nil
+	}
+
+	// Unicode table: little-endian uint16 entries per glyph, 0xFFFE starts
+	// combining sequences (ignored) and 0xFFFF ends the glyph's entries
+	for i := 0; i < count && len(table) >= 2; i++ {
+		inSeq := false
+		for len(table) >= 2 {
+			v := binary.LittleEndian.Uint16(table)
+			table = table[2:]
+			if v == 0xFFFF {
+				break
+			}
+			if v == 0xFFFE {
+				inSeq = true
+				continue
+			}
+			if !inSeq {
+				if _, exists := f.glyphs[rune(v)]; !exists {
+					f.glyphs[rune(v)] = glyphAt(i)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// loadPSF2 parses a PSF version 2 font.
+func loadPSF2(data []byte) (*BitmapFont, error) {
+	le := binary.LittleEndian
+	headerSize := int(le.Uint32(data[8:]))
+	flags := le.Uint32(data[12:])
+	count := int(le.Uint32(data[16:]))
+	charSize := int(le.Uint32(data[20:]))
+	height := int(le.Uint32(data[24:]))
+	width := int(le.Uint32(data[28:]))
+
+	stride := (width + 7) / 8
+	if width <= 0 || height <= 0 || charSize != stride*height {
+		return nil, fmt.Errorf("psf2: invalid glyph size %dx%d (%d bytes)", width, height, charSize)
+	}
+	if headerSize < 32 || count < 0 || len(data) < headerSize+count*charSize {
+		return nil, fmt.Errorf("psf2: truncated glyph data")
+	}
+
+	f := &BitmapFont{Width: width, Height: height, glyphs: make(map[rune]*bitmapGlyph)}
+	glyphData := data[headerSize:]
+	glyphAt := func(i int) *bitmapGlyph {
+		return &bitmapGlyph{width: width, stride: stride, bits: glyphData[i*charSize : (i+1)*charSize]}
+	}
+
+	table := glyphData[count*charSize:]
+	if flags&0x01 == 0 || len(table) == 0 {
+		for i := 0; i < count; i++ {
+			f.glyphs[rune(i)] = glyphAt(i)
+		}
+		return f, nil
+	}
+
+	// Unicode table: UTF-8 entries per glyph, 0xFE starts combining
+	// sequences (ignored) and 0xFF ends the glyph's entries
+	for i := 0; i < count && len(table) > 0; i++ {
+		end := bytes.IndexByte(table, 0xFF)
+		if end < 0 {
+			end = len(table)
+		}
+		entry := table[:end]
+		if seq := bytes.IndexByte(entry, 0xFE); seq >= 0 {
+			entry = entry[:seq]
+		}
+		for len(entry) > 0 {
+			r, size := utf8.DecodeRune(entry)
+			entry = entry[size:]
+			if r == utf8.RuneError && size <= 1 {
+				continue
+			}
+			if _, exists := f.glyphs[r]; !exists {
+				f.glyphs[r] = glyphAt(i)
+			}
+		}
+		table = table[min(end+1, len(table)):]
+	}
+	return f, nil
+}
+
+// bdfIntFields lists the BDF keywords with leading integer values used by LoadBDF.
+var bdfIntFields = map[string]int{"FONTBOUNDINGBOX": 4, "BBX": 4, "ENCODING": 1, "DWIDTH": 1, "FONT_ASCENT": 1}
+
+// LoadBDF loads a font in the X11 Bitmap Distribution Format.
+// Glyphs are positioned on the font bounding box; glyphs whose advance is
+// wider than the bounding box are kept as wide glyphs.
+func LoadBDF(r io.Reader) (*BitmapFont, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var f *BitmapFont
+	var fontX, fontY, ascent int
+	haveAscent := false
+
+	// Current glyph
+	var (
+		encoding   = -1
+		advance    int
+		bbW, bbH   int
+		bbX, bbY   int
+		inBitmap   bool
+		bitmapRows []string
+		lineNo     int
+	)
+
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if inBitmap {
+			if line == "ENDCHAR" {
+				inBitmap = false
+				if f == nil {
+					return nil, fmt.Errorf("bdf line %d: glyph before FONTBOUNDINGBOX", lineNo)
+				}
+				if encoding >= 0 {
+					g, err := bdfGlyph(f, bitmapRows, advance, bbW, bbH, bbX-fontX, ascent-(bbY+bbH))
+					if err != nil {
+						return nil, fmt.Errorf("bdf line %d: %w", lineNo, err)
+					}
+					f.glyphs[rune(encoding)] = g
+				}
+				continue
+			}
+			bitmapRows = append(bitmapRows, line)
+			continue
+		}
+
+		fields := strings.Fields(line)
+		keyword := fields[0]
+		args := make([]int, 0, len(fields)-1)
+		if n, ok := bdfIntFields[keyword]; ok {
+			if len(fields)-1 < n {
+				return nil, fmt.Errorf("bdf line %d: %s needs %d values", lineNo, keyword, n)
+			}
+			for _, s := range fields[1 : n+1] {
+				v, err := strconv.Atoi(s)
+				if err != nil {
+					return nil, fmt.Errorf("bdf line %d: %s: %w", lineNo, keyword, err)
+				}
+				args = append(args, v)
+			}
+		}
+
+		switch keyword {
+		case "FONTBOUNDINGBOX":
+			if args[0] <= 0 || args[1] <= 0 {
+				return nil, fmt.Errorf("bdf line %d: invalid bounding box", lineNo)
+			}
+			f = &BitmapFont{Width: args[0], Height: args[1], glyphs: make(map[rune]*bitmapGlyph)}
+			fontX, fontY = args[2], args[3]
+			if !haveAscent {
+				ascent = args[1] + fontY
+			}
+		case "FONT_ASCENT":
+			ascent, haveAscent = args[0], true
+		case "STARTCHAR":
+			encoding, advance, bbW, bbH, bbX, bbY = -1, 0, 0, 0, 0, 0
+			bitmapRows = bitmapRows[:0]
+		case "ENCODING":
+			encoding = args[0]
+		case "DWIDTH":
+			advance = args[0]
+		case "BBX":
+			bbW, bbH, bbX, bbY = args[0], args[1], args[2], args[3]
+		case "BITMAP":
+			inBitmap = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read bdf: %w", err)
+	}
+	if f == nil {
+		return nil, fmt.Errorf("bdf: missing FONTBOUNDINGBOX")
+	}
+	return f, nil
+}
+
+// bdfGlyph places a BDF bitmap at (x, y) on a glyph the size of the font cell.
+func bdfGlyph(f *BitmapFont, rows []string, advance, w, h, x, y int) (*bitmapGlyph, error) {
+	width := f.Width
+	if advance > f.Width {
+		width = f.Width * 2
+	}
+	g := &bitmapGlyph{width: width, stride: (width + 7) / 8}
+	g.bits = make([]byte, g.stride*f.Height)
+
+	for row := 0; row < h && row < len(rows); row++ {
+		src, err := hex.DecodeString(rows[row])
+		if err != nil {
+			return nil, fmt.Errorf("bitmap row %q: %w", rows[row], err)
+		}
+		dy := y + row
+		if dy < 0 || dy >= f.Height {
+			continue
+		}
+		for col := 0; col < w && col/8 < len(src); col++ {
+			if src[col/8]&(0x80>>(col%8)) == 0 {
+				continue
+			}
+			dx := x + col
+			if dx >= 0 && dx < width {
+				g.bits[dy*g.stride+dx/8] |= 0x80 >> (dx % 8)
+			}
+		}
+	}
+	return g, nil
+}
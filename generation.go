@@ -0,0 +1,42 @@
+package headlessterm
+
+import "sync/atomic"
+
+// Generation is a monotonically increasing change counter.
+// Every modification of the screen advances it, so two generations can be
+// compared to find out what changed in between.
+type Generation uint64
+
+// generationClock issues generations shared by a terminal's buffers and image manager.
+type generationClock struct {
+	n atomic.Uint64
+}
+
+// now returns the latest issued generation.
+func (c *generationClock) now() Generation {
+	return Generation(c.n.Load())
+}
+
+// next advances the clock and returns the new generation.
+func (c *generationClock) next() Generation {
+	return Generation(c.n.Add(1))
+}
+
+// Generation returns the current change counter.
+// Pass it to SnapshotDelta later to get only what changed since this point.
+func (t *Terminal) Generation() Generation {
+	return t.clock.now()
+}
+
+// markScreenChangedLocked records a change that invalidates every line, such as
+// a resize or buffer switch (caller must hold lock).
+func (t *Terminal) markScreenChangedLocked() {
+	t.screenGen = t.clock.next()
+	t.sizeGen = t.screenGen
+}
+
+// markSizeChangedLocked records a change of the screen dimensions that keeps
+// existing lines in place (caller must hold lock).
+func (t *Terminal) markSizeChangedLocked() {
+	t.sizeGen = t.clock.next()
+}
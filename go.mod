@@ -4,11 +4,11 @@ go 1.25.1
 
 require (
 	github.com/danielgatis/go-ansicode v1.0.14
+	github.com/danielgatis/go-vte v1.0.11
 	github.com/unilibs/uniwidth v0.1.0
 )
 
 require (
 	github.com/danielgatis/go-iterator v0.0.1 // indirect
 	github.com/danielgatis/go-utf8 v1.0.1 // indirect
-	github.com/danielgatis/go-vte v1.0.11 // indirect
 )
@@ -295,6 +295,10 @@ func (t *Terminal) Bell() {
 }
 
 func (t *Terminal) bellInternal() {
+	t.mu.Lock()
+	t.queueEventLocked(BellEvent{Count: 1})
+	t.mu.Unlock()
+
 	if t.bellProvider != nil {
 		t.bellProvider.Ring()
 	}
@@ -557,6 +561,7 @@ func (t *Terminal) eraseCharsInternal(n int) {
 		cell := t.activeBuffer.Cell(t.cursor.Row, t.cursor.Col+i)
 		if cell != nil {
 			cell.Reset()
+			t.activeBuffer.MarkDirty(t.cursor.Row, t.cursor.Col+i)
 		}
 	}
 }
@@ -1503,11 +1508,13 @@ func (t *Terminal) setModeLocked(mode ansicode.TerminalMode, set bool) {
 			t.saveCursorPositionLocked()
 			t.activeBuffer = t.alternateBuffer
 			t.activeBuffer.ClearAll()
+			t.markScreenChangedLocked()
 			// Clear image placements when switching to alternate screen
 			t.images.ClearPlacements()
 		} else {
 			t.activeBuffer = t.primaryBuffer
 			t.restoreCursorPositionLocked()
+			t.markScreenChangedLocked()
 			// Clear image placements when switching back to primary screen
 			t.images.ClearPlacements()
 		}
@@ -1541,6 +1548,8 @@ func (t *Terminal) setModifyOtherKeysInternal(modify ansicode.ModifyOtherKeys) {
 }
 
 // SetScrollingRegion sets the scroll boundaries (1-based, converted to 0-based internally).
+// A top of 0 selects the first row and a bottom of 0 the last row, as when
+// the parameters are missing. Regions of fewer than two rows are ignored.
 // Moves cursor to home position (top-left of region if origin mode, else absolute top-left).
 func (t *Terminal) SetScrollingRegion(top, bottom int) {
 	if t.middleware != nil && t.middleware.SetScrollingRegion != nil {
@@ -1554,17 +1563,18 @@ func (t *Terminal) setScrollingRegionInternal(top, bottom int) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Convert from 1-based to 0-based
+	if bottom == 0 || bottom > t.rows {
+		bottom = t.rows
+	}
+
+	// Convert from 1-based inclusive to 0-based with exclusive bottom
 	top--
-	bottom--
 
 	if top < 0 {
 		top = 0
 	}
-	if bottom <= 0 || bottom > t.rows {
-		bottom = t.rows
-	}
-	if top >= bottom {
+	// A region needs at least two lines
+	if bottom-top < 2 {
 		return
 	}
 
@@ -1783,6 +1793,7 @@ func (t *Terminal) substituteInternal() {
 	cell := t.activeBuffer.Cell(t.cursor.Row, t.cursor.Col)
 	if cell != nil {
 		cell.Char = '?'
+		t.activeBuffer.MarkDirty(t.cursor.Row, t.cursor.Col)
 	}
 }
 
@@ -1926,6 +1937,7 @@ func (t *Terminal) setUserVarInternal(name, value string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.userVars[name] = value
+	t.queueEventLocked(UserVarEvent{Name: name, Value: value})
 }
 
 // GetUserVar returns the value of a user variable, or empty string if not set.
@@ -2115,6 +2127,11 @@ func (t *Terminal) assignImageToCells(imageID, placementID uint32, p *ImagePlace
 		if p.Row < t.scrollTop {
 			p.Row = t.scrollTop
 		}
+		t.images.markChanged(placementID)
+	}
+	p.Anchored = t.activeBuffer == t.primaryBuffer
+	if p.Anchored {
+		p.LineID = t.lineIDLocked(t.primaryBuffer.ScrollbackLen() + p.Row)
 	}
 
 	// Calculate scale factors
@@ -2175,7 +2192,7 @@ func (t *Terminal) assignImageToCells(imageID, placementID uint32, p *ImagePlace
 					ScaleY:      scaleY,
 					ZIndex:      p.ZIndex,
 				}
-				cell.MarkDirty()
+				t.activeBuffer.MarkDirty(cellRow, cellCol)
 			}
 		}
 	}
@@ -0,0 +1,106 @@
+package headlesstermtest
+
+import (
+	"strconv"
+	"strings"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// diff returns a line diff of two golden renderings. Removed lines start
+// with '-', added lines with '+'. A '>' after the marker flags the text row
+// holding the cursor in got, and a caret line under an added text row points
+// at the columns that differ from the removed row it replaces.
+func diff(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	cursorLine := cursorTextLine(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("--- golden\n+++ got\n")
+	mark := func(j int) string {
+		if j == cursorLine {
+			return ">"
+		}
+		return " "
+	}
+	var removed []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString(" " + mark(j) + a[i] + "\n")
+			removed = nil
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			removed = append(removed, a[i])
+			i++
+		default:
+			out.WriteString("+" + mark(j) + b[j] + "\n")
+			if len(removed) > 0 {
+				if carets := caretLine(removed[0], b[j]); carets != "" {
+					out.WriteString("  " + carets + "\n")
+				}
+				removed = removed[1:]
+			}
+			j++
+		}
+	}
+	return out.String()
+}
+
+// cursorTextLine returns the index of the text row holding the cursor in a
+// rendering split into lines, or -1.
+func cursorTextLine(lines []string) int {
+	text := -1
+	row := -1
+	for i, line := range lines {
+		if pos, ok := strings.CutPrefix(line, "cursor "); ok {
+			rc, _, _ := strings.Cut(pos, " ")
+			r, _, _ := strings.Cut(rc, ",")
+			row, _ = strconv.Atoi(r)
+		}
+		if line == "text" {
+			text = i
+			break
+		}
+	}
+	if text < 0 || row < 0 || text+1+row >= len(lines) {
+		return -1
+	}
+	return text + 1 + row
+}
+
+// caretLine marks the runes of got that differ from want when both are text
+// rows; it returns "" otherwise.
+func caretLine(want, got string) string {
+	if !strings.HasPrefix(want, "|") || !strings.HasPrefix(got, "|") {
+		return ""
+	}
+	w, g := []rune(want), []rune(got)
+	var b strings.Builder
+	for k, r := range g {
+		mark := " "
+		if k >= len(w) || w[k] != r {
+			mark = "^"
+		}
+		b.WriteString(strings.Repeat(mark, max(headlessterm.StringWidth(string(r)), 1)))
+	}
+	return strings.TrimRight(b.String(), " ")
+}
@@ -0,0 +1,210 @@
+// Package headlesstermtest provides golden-file assertions for programs
+// rendered by a headlessterm.Terminal.
+//
+// AssertScreen compares the screen with a golden file in a human-readable
+// format derived from SnapshotDetailStyled: the size, the cursor, the text
+// grid and the runs of non-default style. Run the tests with
+// -headlesstermtest.update, or with -update if the test binary defines that
+// flag, to (re)write the golden files:
+//
+//	func TestMenu(t *testing.T) {
+//	    term := headlessterm.New(headlessterm.WithSize(10, 40))
+//	    runMenu(term)
+//	    headlesstermtest.AssertScreen(t, term, "testdata/menu.golden",
+//	        headlesstermtest.WithMasks(headlesstermtest.MaskRegexp(regexp.MustCompile(`\d\d:\d\d`))))
+//	}
+//
+//	go test ./... -headlesstermtest.update
+//
+// A golden file looks like this:
+//
+//	size 3x12
+//	cursor 1,2 block
+//	text
+//	|ok ░░░░░    |
+//	|$           |
+//	|            |
+//	styles
+//	0:0-1 "ok" fg=#0dbc79 bold
+//
+// Text rows are padded to the terminal width between '|' delimiters, masked
+// cells are drawn as MaskRune, and style runs list the row, the inclusive
+// column range, the text and the attributes that differ from the defaults.
+package headlesstermtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// update has a package-specific name so it cannot clash with an -update
+// flag that the test binary defines itself.
+var update = flag.Bool("headlesstermtest.update", false, "rewrite headlesstermtest golden files with the current screens")
+
+// updating reports whether golden files should be rewritten, by
+// -headlesstermtest.update or by a boolean -update flag of the test binary.
+// The latter is looked up when needed, after the flags have been parsed.
+func updating() bool {
+	if *update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			b, _ := g.Get().(bool)
+			return b
+		}
+	}
+	return false
+}
+
+// MaskRune replaces masked cells in golden files.
+const MaskRune = '░'
+
+// Mask hides a volatile region of the screen (clocks, PIDs, timings) from
+// comparisons. Masked cells are written as MaskRune without styles.
+type Mask interface {
+	apply(g *grid)
+}
+
+type rectMask struct {
+	row, col, rows, cols int
+}
+
+// MaskRect masks the rectangle of rows x cols cells at (row, col).
+// The rectangle is clipped to the screen.
+func MaskRect(row, col, rows, cols int) Mask {
+	return rectMask{row: row, col: col, rows: rows, cols: cols}
+}
+
+func (m rectMask) apply(g *grid) {
+	for row := max(m.row, 0); row < min(m.row+m.rows, len(g.cells)); row++ {
+		for col := max(m.col, 0); col < min(m.col+m.cols, g.cols); col++ {
+			g.mask(row, col)
+		}
+	}
+}
+
+type regexpMask struct {
+	re *regexp.Regexp
+}
+
+// MaskRegexp masks every match of re within a screen row.
+func MaskRegexp(re *regexp.Regexp) Mask {
+	return regexpMask{re: re}
+}
+
+func (m regexpMask) apply(g *grid) {
+	for row := range g.cells {
+		text, cols := g.rowText(row)
+		for _, loc := range m.re.FindAllStringIndex(text, -1) {
+			for i := loc[0]; i < loc[1]; i++ {
+				g.mask(row, cols[i])
+			}
+		}
+	}
+}
+
+// Option configures AssertScreen and Render.
+type Option func(*config)
+
+type config struct {
+	masks    []Mask
+	noStyles bool
+	noCursor bool
+}
+
+// WithMasks hides volatile regions from the comparison.
+func WithMasks(masks ...Mask) Option {
+	return func(c *config) {
+		c.masks = append(c.masks, masks...)
+	}
+}
+
+// WithoutStyles compares text only; the golden file has no styles section.
+func WithoutStyles() Option {
+	return func(c *config) {
+		c.noStyles = true
+	}
+}
+
+// WithoutCursor leaves the cursor out of the comparison.
+func WithoutCursor() Option {
+	return func(c *config) {
+		c.noCursor = true
+	}
+}
+
+// Render returns the golden representation of the current screen.
+func Render(term *headlessterm.Terminal, opts ...Option) string {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	snap := term.Snapshot(headlessterm.SnapshotDetailStyled)
+	g := newGrid(snap)
+	for _, m := range cfg.masks {
+		m.apply(g)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "size %dx%d\n", snap.Size.Rows, snap.Size.Cols)
+	if !cfg.noCursor {
+		visibility := ""
+		if !snap.Cursor.Visible {
+			visibility = " hidden"
+		}
+		fmt.Fprintf(&b, "cursor %d,%d %s%s\n", snap.Cursor.Row, snap.Cursor.Col, snap.Cursor.Style, visibility)
+	}
+	b.WriteString("text\n")
+	for row := range g.cells {
+		text, _ := g.rowText(row)
+		b.WriteString("|" + text + "|\n")
+	}
+	if !cfg.noStyles {
+		b.WriteString("styles\n")
+		g.writeStyles(&b)
+	}
+	return b.String()
+}
+
+// AssertScreen compares the screen of term with the golden file at path and
+// reports a readable diff through t.Errorf on mismatch. With
+// -headlesstermtest.update (or -update, see the package documentation) the
+// file is written instead (creating its directory). It returns whether the screen
+// matched.
+func AssertScreen(t testing.TB, term *headlessterm.Terminal, golden string, opts ...Option) bool {
+	t.Helper()
+	got := Render(term, opts...)
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Errorf("headlesstermtest: %v", err)
+			return false
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Errorf("headlesstermtest: %v", err)
+			return false
+		}
+		t.Logf("headlesstermtest: updated %s", golden)
+		return true
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Errorf("headlesstermtest: %v (run the test with -headlesstermtest.update to create it)", err)
+		return false
+	}
+	if string(want) == got {
+		return true
+	}
+	t.Errorf("headlesstermtest: screen does not match %s (run the test with -headlesstermtest.update to accept it):\n%s",
+		golden, diff(string(want), got))
+	return false
+}
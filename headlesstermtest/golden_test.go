@@ -0,0 +1,132 @@
+package headlesstermtest
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// testUpdate is the -update flag many golden-file suites define. Declaring
+// it must not clash with the package's own flag.
+var testUpdate = flag.Bool("update", false, "rewrite golden files")
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Logf(string, ...any) {}
+
+func newTerm(rows, cols int, output string) *headlessterm.Terminal {
+	term := headlessterm.New(headlessterm.WithSize(rows, cols))
+	term.WriteString(output)
+	return term
+}
+
+func TestAssertScreen(t *testing.T) {
+	term := newTerm(4, 16, "\x1b[1;32mok\x1b[0m plain\r\n\x1b[4m\x1b[44mblue\x1b[0m 世界\r\n$ ")
+	AssertScreen(t, term, "testdata/styled.golden")
+}
+
+func TestRender(t *testing.T) {
+	term := newTerm(3, 12, "\x1b[32;1mok\x1b[0m 12:34\r\n$ \x1b]8;;https://example.com\x07link\x1b]8;;\x07\x1b[?25l")
+	got := Render(term, WithMasks(MaskRegexp(regexp.MustCompile(`\d\d:\d\d`))))
+	want := `size 3x12
+cursor 1,6 block hidden
+text
+|ok ░░░░░    |
+|$ link      |
+|            |
+styles
+0:0-1 "ok" fg=#0dbc79 bold
+1:2-5 "link" link=https://example.com
+`
+	if got != want {
+		t.Errorf("Render =\n%s\nwant\n%s", got, want)
+	}
+
+	got = Render(term, WithoutStyles(), WithoutCursor())
+	if strings.Contains(got, "cursor") || strings.Contains(got, "styles") {
+		t.Errorf("Render without styles and cursor =\n%s", got)
+	}
+}
+
+func TestMasks(t *testing.T) {
+	term := newTerm(3, 10, "pid 4242\r\n\x1b[31m世界\x1b[0mabc\r\nxyz")
+	got := Render(term, WithMasks(
+		MaskRegexp(regexp.MustCompile(`\d+`)),
+		MaskRect(1, 1, 1, 1), // The spacer half of the first wide character
+		MaskRect(2, 8, 5, 5), // Clipped to the screen
+	))
+	want := `size 3x10
+cursor 2,3 block
+text
+|pid ░░░░  |
+|░░界abc   |
+|xyz     ░░|
+styles
+1:2-3 "界" fg=#cd3131
+`
+	if got != want {
+		t.Errorf("Render =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAssertScreenMismatch(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "screen.golden")
+	rec := &recorder{TB: t}
+	if AssertScreen(rec, newTerm(2, 8, "hello"), golden) {
+		t.Fatal("missing golden file matched")
+	}
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "-headlesstermtest.update") {
+		t.Fatalf("errors = %q", rec.errors)
+	}
+
+	*update = true
+	AssertScreen(rec, newTerm(2, 8, "hello"), golden)
+	*update = false
+
+	rec.errors = nil
+	if !AssertScreen(rec, newTerm(2, 8, "hello"), golden) || len(rec.errors) != 0 {
+		t.Fatalf("updated golden did not match: %q", rec.errors)
+	}
+
+	if AssertScreen(rec, newTerm(2, 8, "hEllo\x1b[1m!"), golden) {
+		t.Fatal("different screen matched")
+	}
+	report := rec.errors[len(rec.errors)-1]
+	for _, want := range []string{
+		"- cursor 0,5 block\n+ cursor 0,6 block\n",
+		"- |hello   |\n+>|hEllo!  |\n    ^   ^\n",
+		"+ 0:5-5 \"!\" bold\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestAssertScreenBinaryUpdateFlag(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "screen.golden")
+	rec := &recorder{TB: t}
+
+	*testUpdate = true
+	AssertScreen(rec, newTerm(2, 8, "hello"), golden)
+	*testUpdate = false
+
+	if !AssertScreen(rec, newTerm(2, 8, "hello"), golden) || len(rec.errors) != 0 {
+		t.Fatalf("golden written through -update did not match: %q", rec.errors)
+	}
+}
@@ -0,0 +1,153 @@
+package headlesstermtest
+
+import (
+	"fmt"
+	"strings"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// gridCell is one screen column. Wide characters occupy two cells; the
+// second one is a spacer with an empty char.
+type gridCell struct {
+	char  string
+	style string // Attributes that differ from the defaults, "" for none
+}
+
+// grid is the screen rebuilt cell by cell from a styled snapshot.
+type grid struct {
+	cols  int
+	cells [][]gridCell
+}
+
+var (
+	defaultFg = hex(headlessterm.DefaultForeground.R, headlessterm.DefaultForeground.G, headlessterm.DefaultForeground.B)
+	defaultBg = hex(headlessterm.DefaultBackground.R, headlessterm.DefaultBackground.G, headlessterm.DefaultBackground.B)
+)
+
+func hex(r, g, b uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+func newGrid(snap *headlessterm.Snapshot) *grid {
+	g := &grid{cols: snap.Size.Cols, cells: make([][]gridCell, snap.Size.Rows)}
+	for row := range g.cells {
+		line := make([]gridCell, g.cols)
+		for col := range line {
+			line[col].char = " "
+		}
+		if row < len(snap.Lines) {
+			col := 0
+			for _, seg := range snap.Lines[row].Segments {
+				style := segmentStyle(&seg)
+				for _, r := range seg.Text {
+					if col >= g.cols {
+						break
+					}
+					line[col] = gridCell{char: string(r), style: style}
+					if headlessterm.StringWidth(string(r)) == 2 && col+1 < g.cols {
+						col++
+						line[col] = gridCell{style: style}
+					}
+					col++
+				}
+			}
+		}
+		g.cells[row] = line
+	}
+	return g
+}
+
+// segmentStyle formats the attributes of seg that differ from the defaults.
+func segmentStyle(seg *headlessterm.SnapshotSegment) string {
+	var parts []string
+	if seg.Fg != "" && seg.Fg != defaultFg {
+		parts = append(parts, "fg="+seg.Fg)
+	}
+	if seg.Bg != "" && seg.Bg != defaultBg {
+		parts = append(parts, "bg="+seg.Bg)
+	}
+	a := seg.Attributes
+	for _, flag := range []struct {
+		on   bool
+		name string
+	}{
+		{a.Bold, "bold"},
+		{a.Dim, "dim"},
+		{a.Italic, "italic"},
+		{a.Reverse, "reverse"},
+		{a.Hidden, "hidden"},
+		{a.Strikethrough, "strike"},
+	} {
+		if flag.on {
+			parts = append(parts, flag.name)
+		}
+	}
+	if a.Underline != "" {
+		parts = append(parts, "underline="+a.Underline)
+	}
+	if seg.UnderlineColor != "" {
+		parts = append(parts, "ul="+seg.UnderlineColor)
+	}
+	if a.Blink != "" {
+		parts = append(parts, "blink="+a.Blink)
+	}
+	if seg.Hyperlink != nil {
+		parts = append(parts, "link="+seg.Hyperlink.URI)
+	}
+	return strings.Join(parts, " ")
+}
+
+// mask hides the cell at (row, col), including both halves of a wide character.
+func (g *grid) mask(row, col int) {
+	line := g.cells[row]
+	start := col
+	if line[col].char == "" && col > 0 {
+		start = col - 1
+	}
+	end := col
+	if end+1 < len(line) && line[end+1].char == "" {
+		end++
+	}
+	for c := start; c <= end; c++ {
+		line[c] = gridCell{char: string(MaskRune)}
+	}
+}
+
+// rowText returns the characters of row, padded to the screen width, and for
+// every byte of the text the column it belongs to.
+func (g *grid) rowText(row int) (string, []int) {
+	var b strings.Builder
+	var cols []int
+	for col, c := range g.cells[row] {
+		if c.char == "" {
+			continue
+		}
+		b.WriteString(c.char)
+		for range len(c.char) {
+			cols = append(cols, col)
+		}
+	}
+	return b.String(), cols
+}
+
+// writeStyles writes one line per run of equally styled cells.
+func (g *grid) writeStyles(b *strings.Builder) {
+	for row, line := range g.cells {
+		for col := 0; col < len(line); {
+			style := line[col].style
+			end := col
+			for end+1 < len(line) && line[end+1].style == style {
+				end++
+			}
+			if style != "" {
+				var text strings.Builder
+				for _, c := range line[col : end+1] {
+					text.WriteString(c.char)
+				}
+				fmt.Fprintf(b, "%d:%d-%d %q %s\n", row, col, end, text.String(), style)
+			}
+			col = end + 1
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package headlessterm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// HTMLOptions configures HTML export.
+type HTMLOptions struct {
+	// IncludeScrollback prepends the scrollback lines to the visible screen.
+	IncludeScrollback bool
+	// SelectionOnly exports only the active selection (the whole screen if there is none).
+	SelectionOnly bool
+	// UseClasses emits palette colors as CSS classes instead of inline styles.
+	UseClasses bool
+	// Fragment writes only the container element instead of a complete document.
+	Fragment bool
+	// FontFamily is the CSS font-family (default: monospace). Values with
+	// characters other than letters, digits, spaces, quotes, commas, dots,
+	// hyphens and underscores are ignored.
+	FontFamily string
+}
+
+// htmlLineHeight is the line height in em used to position images over the text grid.
+const htmlLineHeight = 1.2
+
+// htmlLinkSchemes are the URI schemes of OSC 8 hyperlinks exported as links.
+// Links with other schemes, such as javascript: or data:, could run code
+// where the HTML is opened or pasted, so their text is exported without a link.
+var htmlLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "file": true}
+
+// htmlLinkAllowed reports whether a hyperlink URI may be exported as a link.
+func htmlLinkAllowed(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && htmlLinkSchemes[strings.ToLower(u.Scheme)]
+}
+
+// cssFontFamily returns font if it is a plain font-family list, or
+// monospace, so options cannot inject CSS or markup into the style sheet.
+func cssFontFamily(font string) string {
+	for _, r := range font {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" ,'\"-_.", r) {
+			return "monospace"
+		}
+	}
+	if strings.TrimSpace(font) == "" {
+		return "monospace"
+	}
+	return font
+}
+
+// ExportHTML writes the screen as a self-contained HTML document.
+// Colors come from the terminal palette (including OSC 4/10/11 overrides), all cell
+// attributes are rendered with CSS, OSC 8 hyperlinks with http, https,
+// mailto and file URIs become links and image placements are embedded as
+// PNG data URIs.
+//
+// Example:
+//
+//	f, _ := os.Create("screen.html")
+//	term.ExportHTML(f, headlessterm.HTMLOptions{IncludeScrollback: true})
+func (t *Terminal) ExportHTML(w io.Writer, opts HTMLOptions) error {
+	t.mu.RLock()
+	e := &htmlExporter{t: t, opts: opts, classes: make(map[string]string)}
+	e.exportLocked()
+	title := t.title
+	t.mu.RUnlock()
+
+	var out bytes.Buffer
+	if !opts.Fragment {
+		out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+		fmt.Fprintf(&out, "<title>%s</title>\n", html.EscapeString(title))
+		out.WriteString("</head>\n<body>\n")
+	}
+	out.WriteString("<div class=\"term\">\n<style>\n")
+	out.WriteString(e.css())
+	out.WriteString("</style>\n")
+	out.Write(e.body.Bytes())
+	out.WriteString("</div>\n")
+	if !opts.Fragment {
+		out.WriteString("</body>\n</html>\n")
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// htmlExporter renders cells into HTML (caller must hold the terminal lock while exporting).
+type htmlExporter struct {
+	t       *Terminal
+	opts    HTMLOptions
+	body    bytes.Buffer
+	classes map[string]string // Class name -> CSS declarations
+	blink   bool
+
+	// Current run state
+	link     *Hyperlink
+	linkOpen bool
+	spanKey  string
+	spanOpen bool
+}
+
+// exportLocked renders the selected lines and images into e.body.
+func (e *htmlExporter) exportLocked() {
+	t := e.t
+	var lines [][]Cell
+	var scrollback int
+	if e.opts.IncludeScrollback && t.activeBuffer == t.primaryBuffer {
+		scrollback = t.primaryBuffer.ScrollbackLen()
+		for i := 0; i < scrollback; i++ {
+			lines = append(lines, t.primaryBuffer.ScrollbackLine(i))
+		}
+	}
+	for row := 0; row < t.rows; row++ {
+		lines = append(lines, t.activeBuffer.cells[row])
+	}
+
+	// Restrict to the selection; its rows are absolute
+	first, last := 0, len(lines)-1
+	startCol, endCol := 0, -1
+	if start, end, ok := t.selectionRangeLocked(); e.opts.SelectionOnly && ok {
+		offset := scrollback - t.primaryBuffer.ScrollbackLen()
+		first = clamp(offset+start.Row, 0, len(lines)-1)
+		last = clamp(offset+end.Row, first, len(lines)-1)
+		startCol, endCol = start.Col, end.Col
+	}
+
+	bg := t.resolveColorLocked(nil, false)
+	fg := t.resolveColorLocked(nil, true)
+	font := cssFontFamily(e.opts.FontFamily)
+	e.classes["term"] = fmt.Sprintf("position:relative;display:inline-block;z-index:0;background-color:%s;color:%s", cssColor(bg), cssColor(fg))
+	e.classes["term pre"] = fmt.Sprintf("margin:0;font-family:%s;line-height:%gem", font, htmlLineHeight)
+
+	e.body.WriteString("<pre>")
+	for i := first; i <= last; i++ {
+		line := lines[i]
+		from, to := 0, len(line)
+		if i == first && endCol >= 0 {
+			from = clamp(startCol, 0, len(line))
+		}
+		if i == last && endCol >= 0 {
+			to = clamp(endCol+1, from, len(line))
+		}
+		e.writeLine(line[from:to])
+		e.body.WriteString("\n")
+	}
+	e.body.WriteString("</pre>\n")
+
+	e.writeImages(first-scrollback, last-scrollback, first)
+}
+
+// writeLine renders one line of cells, trimming trailing blank cells.
+func (e *htmlExporter) writeLine(line []Cell) {
+	end := len(line)
+	for end > 0 && isBlankCell(&line[end-1]) && isDefaultBackground(line[end-1].Bg) && !line[end-1].HasFlag(CellFlagReverse) {
+		end--
+	}
+
+	for col := 0; col < end; col++ {
+		c := &line[col]
+		if c.IsWideSpacer() {
+			continue
+		}
+		if !sameHyperlink(c.Hyperlink, e.link) {
+			e.closeSpan()
+			e.closeLink()
+			e.link = c.Hyperlink
+			if c.Hyperlink != nil && htmlLinkAllowed(c.Hyperlink.URI) {
+				fmt.Fprintf(&e.body, "<a href=\"%s\">", html.EscapeString(c.Hyperlink.URI))
+				e.linkOpen = true
+			}
+		}
+
+		class, style := e.cellStyle(c)
+		if key := class + "|" + style; !e.spanOpen || key != e.spanKey {
+			e.closeSpan()
+			if key != "|" {
+				e.body.WriteString("<span")
+				if class != "" {
+					fmt.Fprintf(&e.body, " class=\"%s\"", class)
+				}
+				if style != "" {
+					fmt.Fprintf(&e.body, " style=\"%s\"", style)
+				}
+				e.body.WriteString(">")
+			}
+			e.spanKey, e.spanOpen = key, true
+		}
+
+		e.body.WriteString(html.EscapeString(string(printableRune(c))))
+	}
+	e.closeSpan()
+	e.closeLink()
+}
+
+// closeSpan ends the current styled run.
+func (e *htmlExporter) closeSpan() {
+	if e.spanOpen && e.spanKey != "|" {
+		e.body.WriteString("</span>")
+	}
+	e.spanOpen = false
+}
+
+// closeLink ends the current hyperlink run.
+func (e *htmlExporter) closeLink() {
+	if e.linkOpen {
+		e.body.WriteString("</a>")
+	}
+	e.link, e.linkOpen = nil, false
+}
+
+// cellStyle returns the CSS classes and inline declarations for a cell.
+func (e *htmlExporter) cellStyle(c *Cell) (string, string) {
+	t := e.t
+	colors := t.resolveCellColorsLocked(c)
+	var classes, decls []string
+
+	fgSrc, bgSrc := c.Fg, c.Bg
+	if c.HasFlag(CellFlagReverse) {
+		fgSrc, bgSrc = bgSrc, fgSrc
+	}
+
+	// Foreground
+	plainFg := !c.HasFlag(CellFlagDim) && !c.HasFlag(CellFlagHidden)
+	if idx, ok := paletteIndex(fgSrc); ok && e.opts.UseClasses && plainFg {
+		classes = append(classes, e.colorClass("fg", idx, colors.Fg))
+	} else if colors.Fg != t.resolveColorLocked(nil, true) || !plainFg {
+		decls = append(decls, "color:"+cssColor(colors.Fg))
+	}
+
+	// Background
+	if !colors.DefaultBg {
+		if idx, ok := paletteIndex(bgSrc); ok && e.opts.UseClasses {
+			classes = append(classes, e.colorClass("bg", idx, colors.Bg))
+		} else {
+			decls = append(decls, "background-color:"+cssColor(colors.Bg))
+		}
+	}
+
+	if c.HasFlag(CellFlagBold) {
+		decls = append(decls, "font-weight:bold")
+	}
+	if c.HasFlag(CellFlagItalic) {
+		decls = append(decls, "font-style:italic")
+	}
+
+	var lines []string
+	if c.Flags&cellUnderlineFlags != 0 {
+		lines = append(lines, "underline")
+	}
+	if c.HasFlag(CellFlagStrike) {
+		lines = append(lines, "line-through")
+	}
+	if len(lines) > 0 {
+		decls = append(decls, "text-decoration-line:"+strings.Join(lines, " "))
+		if style := cssUnderlineStyle(c.Flags); style != "" {
+			decls = append(decls, "text-decoration-style:"+style)
+		}
+		if c.UnderlineColor != nil && c.Flags&cellUnderlineFlags != 0 {
+			decls = append(decls, "text-decoration-color:"+cssColor(colors.Underline))
+		}
+	}
+
+	switch {
+	case c.HasFlag(CellFlagBlinkFast):
+		classes = append(classes, "blink-fast")
+		e.blink = true
+	case c.HasFlag(CellFlagBlinkSlow):
+		classes = append(classes, "blink-slow")
+		e.blink = true
+	}
+
+	return strings.Join(classes, " "), strings.Join(decls, ";")
+}
+
+// colorClass registers and returns the class for a palette color.
+func (e *htmlExporter) colorClass(prefix string, index int, c color.RGBA) string {
+	name := fmt.Sprintf("%s%d", prefix, index)
+	prop := "color"
+	if prefix == "bg" {
+		prop = "background-color"
+	}
+	e.classes[name] = prop + ":" + cssColor(c)
+	return name
+}
+
+// css returns the stylesheet for all classes in use.
+func (e *htmlExporter) css() string {
+	names := make([]string, 0, len(e.classes))
+	for name := range e.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, ".%s{%s}\n", name, e.classes[name])
+	}
+	if e.blink {
+		b.WriteString("@keyframes term-blink{50%{opacity:0}}\n")
+		b.WriteString(".blink-slow{animation:term-blink 1s step-end infinite}\n")
+		b.WriteString(".blink-fast{animation:term-blink 0.4s step-end infinite}\n")
+	}
+	return b.String()
+}
+
+// writeImages overlays the placements intersecting visible rows [first, last] as PNG data URIs.
+// top is the output line at which visible row first is rendered.
+func (e *htmlExporter) writeImages(first, last, top int) {
+	placements := e.t.images.Placements()
+	sort.Slice(placements, func(i, j int) bool {
+		if placements[i].ZIndex != placements[j].ZIndex {
+			return placements[i].ZIndex < placements[j].ZIndex
+		}
+		return placements[i].ID < placements[j].ID
+	})
+
+	for _, p := range placements {
+		if p.Row+p.Rows <= first || p.Row > last {
+			continue
+		}
+		uri := placementDataURI(e.t.images, p)
+		if uri == "" {
+			continue
+		}
+		fmt.Fprintf(&e.body,
+			"<img src=\"%s\" style=\"position:absolute;left:%dch;top:%gem;width:%dch;height:%gem;z-index:%d\">\n",
+			uri, p.Col, float64(top+p.Row-first)*htmlLineHeight, p.Cols, float64(p.Rows)*htmlLineHeight, p.ZIndex)
+	}
+}
+
+// placementDataURI encodes the source region of a placement as a PNG data URI.
+// Returns "" if the image no longer exists.
+func placementDataURI(m *ImageManager, p *ImagePlacement) string {
+	img := placementImage(m, p)
+	if img == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// placementImage returns the cropped source region of a placement, or nil if the image is gone.
+func placementImage(m *ImageManager, p *ImagePlacement) image.Image {
+	data := m.Image(p.ImageID)
+	if data == nil || len(data.Data) < int(data.Width*data.Height*4) {
+		return nil
+	}
+	img := &image.RGBA{
+		Pix:    data.Data,
+		Stride: int(data.Width) * 4,
+		Rect:   image.Rect(0, 0, int(data.Width), int(data.Height)),
+	}
+	if p.SrcW == 0 || p.SrcH == 0 {
+		return img
+	}
+	src := image.Rect(int(p.SrcX), int(p.SrcY), int(p.SrcX+p.SrcW), int(p.SrcY+p.SrcH))
+	return img.SubImage(src)
+}
+
+// cssUnderlineStyle maps underline flags to text-decoration-style ("" for solid).
+func cssUnderlineStyle(flags CellFlags) string {
+	switch {
+	case flags&CellFlagDoubleUnderline != 0:
+		return "double"
+	case flags&CellFlagCurlyUnderline != 0:
+		return "wavy"
+	case flags&CellFlagDottedUnderline != 0:
+		return "dotted"
+	case flags&CellFlagDashedUnderline != 0:
+		return "dashed"
+	}
+	return ""
+}
+
+// paletteIndex returns the palette index of an indexed or basic named color.
+func paletteIndex(c color.Color) (int, bool) {
+	switch v := c.(type) {
+	case *IndexedColor:
+		return v.Index, v.Index >= 0 && v.Index < 256
+	case *NamedColor:
+		return v.Name, v.Name >= 0 && v.Name < 16
+	}
+	return 0, false
+}
+
+// cssColor formats a color as #rrggbb.
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
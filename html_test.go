@@ -0,0 +1,115 @@
+package headlessterm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func exportHTML(t *testing.T, term *Terminal, opts HTMLOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := term.ExportHTML(&buf, opts); err != nil {
+		t.Fatalf("ExportHTML: %v", err)
+	}
+	return buf.String()
+}
+
+func TestExportHTML_Attributes(t *testing.T) {
+	term := New(WithSize(4, 40))
+	term.WriteString("\x1b]2;My <title>\x07")
+	term.WriteString("\x1b[1;3;31mbold\x1b[0m \x1b[4:3;58;2;255;0;0mcurly\x1b[0m \x1b[9;2mgone\x1b[0m\r\n")
+	term.WriteString("\x1b[7mrev\x1b[0m \x1b[5mblink\x1b[0m \x1b[8mhidden\x1b[0m <&>\r\n")
+	term.WriteString("\x1b]8;;https://example.com/?a=1&b=2\x1b\\link\x1b]8;;\x1b\\ 中文")
+
+	out := exportHTML(t, term, HTMLOptions{})
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>My &lt;title&gt;</title>",
+		"color:#cd3131;font-weight:bold;font-style:italic",
+		"text-decoration-line:underline;text-decoration-style:wavy;text-decoration-color:#ff0000",
+		"text-decoration-line:line-through",
+		"background-color:#e5e5e5",
+		"class=\"blink-slow\"",
+		"@keyframes term-blink",
+		"&lt;&amp;&gt;",
+		"<a href=\"https://example.com/?a=1&amp;b=2\">link</a>",
+		"中文\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportHTML_UnsafeInput(t *testing.T) {
+	term := New(WithSize(3, 40))
+	term.WriteString("\x1b]8;;javascript:alert(1)\x07js\x1b]8;;\x07 \x1b]8;;data:text/html,x\x07data\x1b]8;;\x07 \x1b]8;;mailto:a@b.io\x07mail\x1b]8;;\x07")
+
+	out := exportHTML(t, term, HTMLOptions{FontFamily: "x}</style><script>alert(1)</script>"})
+	for _, bad := range []string{"javascript:", "data:text", "<script>"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("output contains %q:\n%s", bad, out)
+		}
+	}
+	for _, want := range []string{"js data ", "<a href=\"mailto:a@b.io\">mail</a>", "font-family:monospace"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	term.SetSelection(Position{0, 0}, Position{0, 1})
+	if sel := term.GetSelectionAs(SelectionHTML); strings.Contains(sel, "<a") || !strings.Contains(sel, "js") {
+		t.Errorf("selection HTML = %q", sel)
+	}
+
+	out = exportHTML(t, term, HTMLOptions{FontFamily: "'Fira Code', monospace"})
+	if !strings.Contains(out, "font-family:'Fira Code', monospace") {
+		t.Errorf("plain font family dropped:\n%s", out)
+	}
+}
+
+func TestExportHTML_Classes(t *testing.T) {
+	term := New(WithSize(2, 20))
+	term.WriteString("\x1b]4;1;rgb:12/34/56\x07\x1b[31;42mx")
+
+	out := exportHTML(t, term, HTMLOptions{UseClasses: true, Fragment: true})
+	if strings.Contains(out, "<html>") {
+		t.Error("fragment should not contain a document wrapper")
+	}
+	for _, want := range []string{".fg1{color:#123456}", ".bg2{background-color:", "class=\"fg1 bg2\""} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportHTML_ScrollbackAndSelection(t *testing.T) {
+	term := New(WithSize(2, 10), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("first\r\nsecond\r\nthird")
+
+	out := exportHTML(t, term, HTMLOptions{})
+	if strings.Contains(out, "first") {
+		t.Error("scrollback should not be exported by default")
+	}
+	out = exportHTML(t, term, HTMLOptions{IncludeScrollback: true})
+	if !strings.Contains(out, "first\nsecond\nthird") {
+		t.Errorf("scrollback missing:\n%s", out)
+	}
+
+	term.SetSelection(Position{Row: 0, Col: 2}, Position{Row: 1, Col: 2})
+	out = exportHTML(t, term, HTMLOptions{SelectionOnly: true})
+	if !strings.Contains(out, "<pre>cond\nthi\n</pre>") {
+		t.Errorf("selection not exported:\n%s", out)
+	}
+}
+
+func TestExportHTML_Images(t *testing.T) {
+	term := New(WithSize(5, 10))
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;AAAA/wAAAP8AAAD/AAAA/w==\x1b\\")
+
+	out := exportHTML(t, term, HTMLOptions{})
+	if !strings.Contains(out, "<img src=\"data:image/png;base64,") {
+		t.Errorf("image not embedded:\n%s", out)
+	}
+}
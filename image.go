@@ -1,7 +1,9 @@
 package headlessterm
 
 import (
+	"cmp"
 	"crypto/sha256"
+	"slices"
 	"sync"
 	"time"
 )
@@ -38,6 +40,14 @@ type ImagePlacement struct {
 	// Position in terminal (cell coordinates, viewport-relative)
 	Row, Col int
 
+	// Stable ID of the line of the top row when placed (see
+	// Terminal.AbsoluteRowToLineID and Terminal.ImagePlacementRow).
+	// Only set if Anchored.
+	LineID uint64
+	// Anchored is true for placements made on the primary screen, whose
+	// lines move into the scrollback. The alternate screen has none.
+	Anchored bool
+
 	// Size in cells
 	Cols, Rows int
 
@@ -92,6 +102,23 @@ type ImageManager struct {
 	accumulatorWidth       uint32      // Width from first chunk
 	accumulatorHeight      uint32      // Height from first chunk
 	accumulatorCompression byte        // Compression from first chunk
+
+	// Change tracking
+	clock         *generationClock
+	changed       Generation            // Last placement change
+	placementGens map[uint32]Generation // Last change of each placement
+	removals      []placementRemoval    // Recently removed placements, oldest first
+	resetAt       Generation            // Last change not tracked per placement
+}
+
+// maxPlacementRemovals is how many removals are remembered for deltas.
+// Deltas from before the oldest one resend every placement.
+const maxPlacementRemovals = 256
+
+// placementRemoval records when a placement was removed.
+type placementRemoval struct {
+	id  uint32
+	gen Generation
 }
 
 // NewImageManager creates a new ImageManager with default settings.
@@ -101,6 +128,8 @@ func NewImageManager() *ImageManager {
 		placements: make(map[uint32]*ImagePlacement),
 		hashToID:   make(map[[32]byte]uint32),
 		maxMemory:  320 * 1024 * 1024, // 320MB default
+
+		placementGens: make(map[uint32]Generation),
 	}
 }
 
@@ -212,6 +241,7 @@ func (m *ImageManager) Place(p *ImagePlacement) uint32 {
 	m.nextPlacementID++
 	p.ID = m.nextPlacementID
 	m.placements[p.ID] = p
+	m.touchPlacementLocked(p.ID)
 
 	return p.ID
 }
@@ -239,7 +269,9 @@ func (m *ImageManager) Placements() []*ImagePlacement {
 func (m *ImageManager) RemovePlacement(id uint32) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.placements, id)
+	if _, ok := m.placements[id]; ok {
+		m.deletePlacementLocked(id)
+	}
 }
 
 // RemovePlacementsForImage removes all placements for a given image ID.
@@ -249,7 +281,7 @@ func (m *ImageManager) RemovePlacementsForImage(imageID uint32) {
 
 	for id, p := range m.placements {
 		if p.ImageID == imageID {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -268,7 +300,7 @@ func (m *ImageManager) DeleteImage(id uint32) {
 	// Remove associated placements
 	for pid, p := range m.placements {
 		if p.ImageID == id {
-			delete(m.placements, pid)
+			m.deletePlacementLocked(pid)
 		}
 	}
 }
@@ -279,7 +311,7 @@ func (m *ImageManager) Clear() {
 	defer m.mu.Unlock()
 
 	m.images = make(map[uint32]*ImageData)
-	m.placements = make(map[uint32]*ImagePlacement)
+	m.clearPlacementsLocked()
 	m.hashToID = make(map[[32]byte]uint32)
 	m.usedMemory = 0
 	m.accumulator = nil
@@ -289,7 +321,7 @@ func (m *ImageManager) Clear() {
 func (m *ImageManager) ClearPlacements() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.placements = make(map[uint32]*ImagePlacement)
+	m.clearPlacementsLocked()
 }
 
 // UsedMemory returns the current memory usage in bytes.
@@ -366,7 +398,7 @@ func (m *ImageManager) DeletePlacementsByPosition(row, col int) {
 	for id, p := range m.placements {
 		if row >= p.Row && row < p.Row+p.Rows &&
 			col >= p.Col && col < p.Col+p.Cols {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -378,7 +410,7 @@ func (m *ImageManager) DeletePlacementsByZIndex(z int32) {
 
 	for id, p := range m.placements {
 		if p.ZIndex == z {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -390,7 +422,7 @@ func (m *ImageManager) DeletePlacementsInRow(row int) {
 
 	for id, p := range m.placements {
 		if row >= p.Row && row < p.Row+p.Rows {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -402,7 +434,7 @@ func (m *ImageManager) DeletePlacementsInColumn(col int) {
 
 	for id, p := range m.placements {
 		if col >= p.Col && col < p.Col+p.Cols {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -416,7 +448,7 @@ func (m *ImageManager) DeletePlacementsInRowRange(startRow, endRow int) {
 		placementEnd := p.Row + p.Rows
 		// Check if placement overlaps with the row range
 		if p.Row < endRow && placementEnd > startRow {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -429,7 +461,7 @@ func (m *ImageManager) DeletePlacementsBelow(row int) {
 	for id, p := range m.placements {
 		// Placement intersects if any part is at row or below
 		if p.Row+p.Rows > row {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
 		}
 	}
 }
@@ -442,7 +474,89 @@ func (m *ImageManager) DeletePlacementsAbove(row int) {
 	for id, p := range m.placements {
 		// Placement intersects if any part is at row or above
 		if p.Row <= row {
-			delete(m.placements, id)
+			m.deletePlacementLocked(id)
+		}
+	}
+}
+
+// --- Change Tracking ---
+
+// setClock attaches the generation clock used for change tracking.
+func (m *ImageManager) setClock(c *generationClock) {
+	m.clock = c
+}
+
+// markChanged records that a placement was modified in place.
+func (m *ImageManager) markChanged(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.touchPlacementLocked(id)
+}
+
+// changesSince returns the placements added or changed and the IDs of the
+// placements removed after since, ordered by ID. reset is true if the
+// changes are not known, and every placement must be resent.
+func (m *ImageManager) changesSince(since Generation) (changed []*ImagePlacement, removed []uint32, reset bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if since < m.resetAt {
+		return nil, nil, true
+	}
+	for id, gen := range m.placementGens {
+		if p := m.placements[id]; p != nil && gen > since {
+			changed = append(changed, p)
 		}
 	}
+	slices.SortFunc(changed, func(a, b *ImagePlacement) int { return cmp.Compare(a.ID, b.ID) })
+	for _, r := range m.removals {
+		if r.gen > since {
+			removed = append(removed, r.id)
+		}
+	}
+	slices.Sort(removed)
+	return changed, removed, false
+}
+
+// changedAt returns the generation of the last placement change.
+func (m *ImageManager) changedAt() Generation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.changed
+}
+
+// touchLocked records a placement change. Must be called with lock held.
+func (m *ImageManager) touchLocked() {
+	if m.clock != nil {
+		m.changed = m.clock.next()
+	}
+}
+
+// touchPlacementLocked records a change of one placement. Must be called with lock held.
+func (m *ImageManager) touchPlacementLocked(id uint32) {
+	m.touchLocked()
+	m.placementGens[id] = m.changed
+}
+
+// deletePlacementLocked removes a placement and records the change. Must be called with lock held.
+func (m *ImageManager) deletePlacementLocked(id uint32) {
+	delete(m.placements, id)
+	delete(m.placementGens, id)
+	m.touchLocked()
+	m.removals = append(m.removals, placementRemoval{id: id, gen: m.changed})
+	if extra := len(m.removals) - maxPlacementRemovals; extra > 0 {
+		m.resetAt = max(m.resetAt, m.removals[extra-1].gen)
+		m.removals = slices.Delete(m.removals, 0, extra)
+	}
+}
+
+// clearPlacementsLocked removes all placements. Must be called with lock held.
+func (m *ImageManager) clearPlacementsLocked() {
+	if len(m.placements) > 0 {
+		m.touchLocked()
+		m.resetAt = m.changed
+	}
+	m.placements = make(map[uint32]*ImagePlacement)
+	m.placementGens = make(map[uint32]Generation)
+	m.removals = nil
 }
@@ -0,0 +1,322 @@
+package headlessterm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyModifiers is a bitmask of modifier keys held during a key or mouse event.
+type KeyModifiers uint8
+
+const (
+	ModShift KeyModifiers = 1 << iota
+	ModAlt
+	ModCtrl
+	ModMeta
+)
+
+// xtermParam returns the xterm modifier parameter (1 + bitmask).
+func (m KeyModifiers) xtermParam() int {
+	return 1 + int(m)
+}
+
+// keyModifierNames maps modifier spellings accepted by EncodeKey.
+var keyModifierNames = map[string]KeyModifiers{
+	"shift": ModShift, "s": ModShift,
+	"alt": ModAlt, "a": ModAlt, "m": ModAlt, "opt": ModAlt, "option": ModAlt,
+	"ctrl": ModCtrl, "control": ModCtrl, "c": ModCtrl,
+	"meta": ModMeta, "super": ModMeta, "cmd": ModMeta,
+}
+
+// csiKeys are keys sent as CSI sequences: final byte for cursor-style keys,
+// or a number followed by '~'.
+var csiKeys = map[string]string{
+	"up": "A", "down": "B", "right": "C", "left": "D",
+	"home": "H", "end": "F",
+	"insert": "2~", "delete": "3~", "pageup": "5~", "pagedown": "6~",
+	"f1": "P", "f2": "Q", "f3": "R", "f4": "S",
+	"f5": "15~", "f6": "17~", "f7": "18~", "f8": "19~",
+	"f9": "20~", "f10": "21~", "f11": "23~", "f12": "24~",
+}
+
+// keyAliases maps alternative key names to the canonical ones.
+var keyAliases = map[string]string{
+	"return": "enter", "esc": "escape", "bs": "backspace", "del": "delete",
+	"ins": "insert", "pgup": "pageup", "pgdn": "pagedown", "pgdown": "pagedown",
+	"arrowup": "up", "arrowdown": "down", "arrowleft": "left", "arrowright": "right",
+}
+
+// EncodeKey returns the bytes a terminal sends for a key press, honoring the
+// cursor key mode (DECCKM) and line feed/new line mode.
+//
+// spec is a key name with optional modifiers separated by '+' or '-', such
+// as "Enter", "ctrl+c", "C-c", "Alt+Left", "shift+tab", "F5" or a single
+// character like "a". Named keys: Enter, Tab, Backspace, Escape, Space, Up,
+// Down, Left, Right, Home, End, Insert, Delete, PageUp, PageDown and F1-F12.
+// Modified special keys use the xterm "CSI 1;m X" form; Alt prefixes ESC to
+// plain keys. The kitty keyboard protocol is not used.
+//
+// Example:
+//
+//	seq, _ := term.EncodeKey("ctrl+c") // "\x03"
+//	pty.Write(seq)
+func (t *Terminal) EncodeKey(spec string) ([]byte, error) {
+	name, mods, err := parseKeySpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.RLock()
+	cursorKeys := t.modes&ModeCursorKeys != 0
+	newLine := t.modes&ModeLineFeedNewLine != 0
+	t.mu.RUnlock()
+
+	var seq string
+	switch name {
+	case "enter":
+		seq = "\r"
+		if newLine {
+			seq = "\r\n"
+		}
+	case "tab":
+		if mods&ModShift != 0 {
+			return []byte("\x1b[Z"), nil
+		}
+		seq = "\t"
+	case "backspace":
+		seq = "\x7f"
+		if mods&ModCtrl != 0 {
+			seq = "\x08"
+		}
+	case "escape":
+		seq = "\x1b"
+	case "space":
+		seq = " "
+		if mods&ModCtrl != 0 {
+			seq = "\x00"
+		}
+	default:
+		if final, ok := csiKeys[name]; ok {
+			return []byte(encodeCSIKey(final, mods, cursorKeys)), nil
+		}
+		r, size := utf8.DecodeRuneInString(name)
+		if size != len(name) {
+			return nil, fmt.Errorf("unknown key %q", spec)
+		}
+		seq = encodeRuneKey(r, mods)
+	}
+
+	if mods&ModAlt != 0 {
+		seq = "\x1b" + seq
+	}
+	return []byte(seq), nil
+}
+
+// parseKeySpec splits a key spec into a lower-case key name and modifiers.
+func parseKeySpec(spec string) (string, KeyModifiers, error) {
+	if spec == "" {
+		return "", 0, fmt.Errorf("empty key")
+	}
+	// A trailing separator is the key itself, as in "ctrl+-"
+	var parts []string
+	rest := spec
+	for len(rest) > 1 {
+		i := strings.IndexAny(rest[:len(rest)-1], "+-")
+		if i <= 0 {
+			break
+		}
+		parts = append(parts, rest[:i])
+		rest = rest[i+1:]
+	}
+
+	var mods KeyModifiers
+	for _, p := range parts {
+		mod, ok := keyModifierNames[strings.ToLower(p)]
+		if !ok {
+			return "", 0, fmt.Errorf("unknown modifier %q in key %q", p, spec)
+		}
+		mods |= mod
+	}
+
+	name := rest
+	if utf8.RuneCountInString(name) > 1 {
+		name = strings.ToLower(name)
+		if alias, ok := keyAliases[name]; ok {
+			name = alias
+		}
+	}
+	return name, mods, nil
+}
+
+// encodeCSIKey encodes a cursor, editing or function key.
+func encodeCSIKey(final string, mods KeyModifiers, cursorKeys bool) string {
+	numbered := strings.HasSuffix(final, "~")
+	if mods != 0 {
+		if numbered {
+			return "\x1b[" + strings.TrimSuffix(final, "~") + ";" + strconv.Itoa(mods.xtermParam()) + "~"
+		}
+		return "\x1b[1;" + strconv.Itoa(mods.xtermParam()) + final
+	}
+	if numbered {
+		return "\x1b[" + final
+	}
+	// F1-F4 always use SS3; cursor keys only in application mode
+	if cursorKeys || strings.Contains("PQRS", final) {
+		return "\x1bO" + final
+	}
+	return "\x1b[" + final
+}
+
+// encodeRuneKey encodes a printable key with Shift and Ctrl applied.
+func encodeRuneKey(r rune, mods KeyModifiers) string {
+	if mods&ModShift != 0 {
+		r = unicode.ToUpper(r)
+	}
+	if mods&ModCtrl != 0 {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return string(r - 'a' + 1)
+		case r >= '@' && r <= '_':
+			return string(r - '@')
+		case r == '?':
+			return "\x7f"
+		case r == '2' || r == ' ':
+			return "\x00"
+		}
+	}
+	return string(r)
+}
+
+// EncodePaste returns the bytes a terminal sends when text is pasted.
+// Line endings become CR, as typed. With bracketed paste mode enabled the
+// text is wrapped in CSI 200~ / CSI 201~, and any end marker inside the text
+// is removed so the pasted content cannot terminate the paste early.
+func (t *Terminal) EncodePaste(text string) []byte {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+
+	if !t.HasMode(ModeBracketedPaste) {
+		return []byte(text)
+	}
+	text = strings.ReplaceAll(text, "\x1b[201~", "")
+	return []byte("\x1b[200~" + text + "\x1b[201~")
+}
+
+// MouseButton identifies the button of a mouse event.
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	// MouseNone is used for motion without a pressed button.
+	MouseNone
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+// MouseAction is the kind of a mouse event.
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+// MouseEvent is a mouse event at a 0-based cell position.
+type MouseEvent struct {
+	Row, Col int
+	Button   MouseButton
+	Action   MouseAction
+	Mods     KeyModifiers
+}
+
+// EncodeMouse returns the bytes a terminal sends for a mouse event given the
+// mouse modes the application enabled, or nil if the event is not reported.
+//
+// Clicks are reported with mode 1000, motion with a pressed button with mode
+// 1002, and all motion with mode 1003. Coordinates use SGR encoding (1006)
+// when enabled, else UTF-8 (1005) or the legacy X10 form, which cannot
+// represent positions beyond column or row 223. Without mouse reporting,
+// wheel events on the alternate screen become cursor keys when alternate
+// scroll mode (1007) is enabled.
+func (t *Terminal) EncodeMouse(ev MouseEvent) []byte {
+	t.mu.RLock()
+	modes := t.modes
+	alternate := t.activeBuffer == t.alternateBuffer
+	t.mu.RUnlock()
+
+	wheel := ev.Button >= MouseWheelUp
+	reporting := modes&(ModeReportMouseClicks|ModeReportCellMouseMotion|ModeReportAllMouseMotion) != 0
+
+	if !reporting {
+		if wheel && ev.Action == MousePress && alternate && modes&ModeAlternateScroll != 0 {
+			final := map[MouseButton]string{MouseWheelUp: "A", MouseWheelDown: "B", MouseWheelLeft: "D", MouseWheelRight: "C"}[ev.Button]
+			return []byte(encodeCSIKey(final, 0, modes&ModeCursorKeys != 0))
+		}
+		return nil
+	}
+
+	switch ev.Action {
+	case MouseMotion:
+		if modes&ModeReportAllMouseMotion == 0 && (modes&ModeReportCellMouseMotion == 0 || ev.Button == MouseNone) {
+			return nil
+		}
+	case MouseRelease:
+		if wheel {
+			return nil
+		}
+	}
+
+	var code int
+	switch ev.Button {
+	case MouseLeft, MouseMiddle, MouseRight:
+		code = int(ev.Button)
+	case MouseNone:
+		code = 3
+	default:
+		code = 64 + int(ev.Button-MouseWheelUp)
+	}
+	if ev.Action == MouseMotion {
+		code += 32
+	}
+	if ev.Mods&ModShift != 0 {
+		code += 4
+	}
+	if ev.Mods&(ModAlt|ModMeta) != 0 {
+		code += 8
+	}
+	if ev.Mods&ModCtrl != 0 {
+		code += 16
+	}
+
+	x, y := ev.Col+1, ev.Row+1
+	if modes&ModeSGRMouse != 0 {
+		final := 'M'
+		if ev.Action == MouseRelease {
+			final = 'm'
+		}
+		return []byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", code, x, y, final))
+	}
+
+	// Legacy encodings cannot tell which button was released
+	if ev.Action == MouseRelease {
+		code = code&^3 | 3
+	}
+	if modes&ModeUTF8Mouse != 0 {
+		if x > 2015 || y > 2015 {
+			return nil
+		}
+		return []byte("\x1b[M" + string(rune(32+code)) + string(rune(32+x)) + string(rune(32+y)))
+	}
+	if x > 223 || y > 223 {
+		return nil
+	}
+	return []byte{0x1b, '[', 'M', byte(32 + code), byte(32 + x), byte(32 + y)}
+}
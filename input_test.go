@@ -0,0 +1,133 @@
+package headlessterm
+
+import "testing"
+
+func TestEncodeKey(t *testing.T) {
+	term := New()
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"a", "a"},
+		{"Enter", "\r"},
+		{"return", "\r"},
+		{"ctrl+c", "\x03"},
+		{"C-c", "\x03"},
+		{"ctrl+shift+c", "\x03"},
+		{"ctrl+[", "\x1b"},
+		{"ctrl+space", "\x00"},
+		{"shift+a", "A"},
+		{"alt+x", "\x1bx"},
+		{"M-Enter", "\x1b\r"},
+		{"Tab", "\t"},
+		{"shift+tab", "\x1b[Z"},
+		{"Backspace", "\x7f"},
+		{"ctrl+backspace", "\x08"},
+		{"Escape", "\x1b"},
+		{"Up", "\x1b[A"},
+		{"ctrl+Left", "\x1b[1;5D"},
+		{"shift+alt+Right", "\x1b[1;4C"},
+		{"Home", "\x1b[H"},
+		{"PageDown", "\x1b[6~"},
+		{"ctrl+Delete", "\x1b[3;5~"},
+		{"F1", "\x1bOP"},
+		{"shift+F1", "\x1b[1;2P"},
+		{"F5", "\x1b[15~"},
+		{"F12", "\x1b[24~"},
+		{"ctrl+-", "-"},
+		{"-", "-"},
+		{"é", "é"},
+	}
+	for _, tt := range tests {
+		got, err := term.EncodeKey(tt.spec)
+		if err != nil {
+			t.Errorf("EncodeKey(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("EncodeKey(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "Foo", "hyper+a", "ctrl+Foo"} {
+		if _, err := term.EncodeKey(spec); err == nil {
+			t.Errorf("EncodeKey(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestEncodeKey_Modes(t *testing.T) {
+	term := New()
+	term.WriteString("\x1b[?1h\x1b[20h")
+
+	if got, _ := term.EncodeKey("Up"); string(got) != "\x1bOA" {
+		t.Errorf("Up in application cursor mode = %q", got)
+	}
+	if got, _ := term.EncodeKey("ctrl+Up"); string(got) != "\x1b[1;5A" {
+		t.Errorf("ctrl+Up in application cursor mode = %q", got)
+	}
+	if got, _ := term.EncodeKey("Enter"); string(got) != "\r\n" {
+		t.Errorf("Enter in new line mode = %q", got)
+	}
+}
+
+func TestEncodePaste(t *testing.T) {
+	term := New()
+	if got := term.EncodePaste("a\nb\r\nc"); string(got) != "a\rb\rc" {
+		t.Errorf("plain paste = %q", got)
+	}
+
+	term.WriteString("\x1b[?2004h")
+	if got := term.EncodePaste("x\x1b[201~y"); string(got) != "\x1b[200~xy\x1b[201~" {
+		t.Errorf("bracketed paste = %q", got)
+	}
+}
+
+func TestEncodeMouse(t *testing.T) {
+	term := New()
+	press := MouseEvent{Row: 4, Col: 9, Button: MouseLeft, Action: MousePress}
+	if got := term.EncodeMouse(press); got != nil {
+		t.Errorf("mouse without reporting = %q, want nil", got)
+	}
+
+	term.WriteString("\x1b[?1000h")
+	if got := term.EncodeMouse(press); string(got) != "\x1b[M *%" {
+		t.Errorf("X10 press = %q", got)
+	}
+	release := press
+	release.Action = MouseRelease
+	if got := term.EncodeMouse(release); string(got) != "\x1b[M#*%" {
+		t.Errorf("X10 release = %q", got)
+	}
+	motion := MouseEvent{Row: 0, Col: 0, Button: MouseLeft, Action: MouseMotion}
+	if got := term.EncodeMouse(motion); got != nil {
+		t.Errorf("motion with clicks only = %q, want nil", got)
+	}
+
+	term.WriteString("\x1b[?1002h\x1b[?1006h")
+	if got := term.EncodeMouse(motion); string(got) != "\x1b[<32;1;1M" {
+		t.Errorf("SGR drag = %q", got)
+	}
+	if got := term.EncodeMouse(MouseEvent{Button: MouseNone, Action: MouseMotion}); got != nil {
+		t.Errorf("hover with button-event tracking = %q, want nil", got)
+	}
+	if got := term.EncodeMouse(MouseEvent{Row: 1, Col: 2, Button: MouseRight, Action: MouseRelease, Mods: ModCtrl}); string(got) != "\x1b[<18;3;2m" {
+		t.Errorf("SGR release = %q", got)
+	}
+	if got := term.EncodeMouse(MouseEvent{Button: MouseWheelDown, Action: MousePress, Mods: ModShift}); string(got) != "\x1b[<69;1;1M" {
+		t.Errorf("SGR wheel = %q", got)
+	}
+
+	term.WriteString("\x1b[?1003h")
+	if got := term.EncodeMouse(MouseEvent{Row: 2, Col: 3, Button: MouseNone, Action: MouseMotion}); string(got) != "\x1b[<35;4;3M" {
+		t.Errorf("SGR hover = %q", got)
+	}
+}
+
+func TestEncodeMouse_AlternateScroll(t *testing.T) {
+	term := New()
+	term.WriteString("\x1b[?1049h\x1b[?1007h")
+	if got := term.EncodeMouse(MouseEvent{Button: MouseWheelUp, Action: MousePress}); string(got) != "\x1b[A" {
+		t.Errorf("wheel on alternate screen = %q", got)
+	}
+}
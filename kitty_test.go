@@ -365,6 +365,45 @@ func TestKittyImageDelete(t *testing.T) {
 	}
 }
 
+// TestKittyPlacementRow tests that placements follow their line into the
+// scrollback until it is evicted
+func TestKittyPlacementRow(t *testing.T) {
+	term := New(WithSize(4, 20), WithScrollback(NewMemoryScrollback(2)))
+	term.SetSizeProvider(&testSizeProvider{cellW: 10, cellH: 10})
+
+	payload := base64.StdEncoding.EncodeToString(make([]byte, 10*10*4))
+	term.WriteString("\x1b[2;1H\x1b_Ga=T,f=32,s=10,v=10;" + payload + "\x1b\\")
+	placements := term.ImagePlacements()
+	if len(placements) != 1 {
+		t.Fatalf("expected 1 placement, got %d", len(placements))
+	}
+	id := placements[0].ID
+	if row := term.ImagePlacementRow(id); row != 1 {
+		t.Errorf("expected row 1, got %d", row)
+	}
+
+	// One line pushed: the absolute row stays
+	term.WriteString("\r\n\r\n\r\n")
+	if row := term.ImagePlacementRow(id); row != 1 {
+		t.Errorf("expected row 1 after scrolling, got %d", row)
+	}
+
+	// One line evicted: the row moves up
+	term.WriteString("\r\n\r\n")
+	if row := term.ImagePlacementRow(id); row != 0 {
+		t.Errorf("expected row 0 after eviction, got %d", row)
+	}
+
+	// The placement's line evicted
+	term.WriteString("\r\n")
+	if row := term.ImagePlacementRow(id); row != -1 {
+		t.Errorf("expected row -1 once evicted, got %d", row)
+	}
+	if row := term.ImagePlacementRow(id + 1); row != -1 {
+		t.Errorf("expected row -1 for unknown placement, got %d", row)
+	}
+}
+
 // testSizeProvider is a test implementation of SizeProvider
 type testSizeProvider struct {
 	cellW, cellH int
@@ -386,3 +425,24 @@ func floatClose(a, b float32) bool {
 	}
 	return diff < 0.01
 }
+
+// TestKittyPlacementRowAlternateScreen tests that placements on the
+// alternate screen are not anchored to primary screen lines
+func TestKittyPlacementRowAlternateScreen(t *testing.T) {
+	term := New(WithSize(4, 20), WithScrollback(NewMemoryScrollback(10)))
+	term.SetSizeProvider(&testSizeProvider{cellW: 10, cellH: 10})
+	term.WriteString("one\r\ntwo\r\nthree\r\nfour\r\nfive")
+
+	payload := base64.StdEncoding.EncodeToString(make([]byte, 10*10*4))
+	term.WriteString("\x1b[?1049h\x1b[2;1H\x1b_Ga=T,f=32,s=10,v=10;" + payload + "\x1b\\")
+	placements := term.ImagePlacements()
+	if len(placements) != 1 {
+		t.Fatalf("expected 1 placement, got %d", len(placements))
+	}
+	if placements[0].Anchored {
+		t.Error("alternate screen placement should not be anchored")
+	}
+	if row := term.ImagePlacementRow(placements[0].ID); row != -1 {
+		t.Errorf("expected row -1 on the alternate screen, got %d", row)
+	}
+}
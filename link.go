@@ -0,0 +1,319 @@
+package headlessterm
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// LinkKind tells how a link was found.
+type LinkKind int
+
+const (
+	// LinkExplicit is an OSC 8 hyperlink.
+	LinkExplicit LinkKind = iota
+	// LinkURL is a URL in the text, such as https://example.com.
+	LinkURL
+	// LinkEmail is an email address in the text.
+	LinkEmail
+	// LinkFile is a file reference with a line and optional column, such as
+	// main.go:12:5.
+	LinkFile
+	// LinkCustom is a match of a LinkPattern.
+	LinkCustom
+)
+
+// String returns the kind name.
+func (k LinkKind) String() string {
+	switch k {
+	case LinkExplicit:
+		return "explicit"
+	case LinkURL:
+		return "url"
+	case LinkEmail:
+		return "email"
+	case LinkFile:
+		return "file"
+	case LinkCustom:
+		return "custom"
+	}
+	return "unknown"
+}
+
+// Link is a range of text that points somewhere.
+type Link struct {
+	Kind LinkKind
+	// Start and End are absolute positions (see ViewportRowToAbsolute). End
+	// is the last cell of the link, so links may span soft-wrapped rows.
+	Start Position
+	End   Position
+	// Text is the linked text.
+	Text string
+	// Target is the URI of explicit links and URLs, a mailto: URI for
+	// emails, the path of file references, and the pattern's target for
+	// custom links. Relative paths are as printed; resolve them against
+	// WorkingDirectoryPath.
+	Target string
+	// ID is the id parameter of explicit links.
+	ID string
+	// Line and Col are the 1-based position in the file of file references
+	// (Col is 0 if absent).
+	Line int
+	Col  int
+	// Pattern is the name of the LinkPattern of custom links.
+	Pattern string
+}
+
+// LinkPattern detects custom links, such as issue numbers.
+type LinkPattern struct {
+	// Name identifies the pattern in Link.Pattern.
+	Name   string
+	Regexp *regexp.Regexp
+	// Target returns the target of a match from its submatches, where
+	// submatches[0] is the whole match. Nil uses the matched text.
+	Target func(submatches []string) string
+}
+
+var (
+	urlRegexp   = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>"'` + "`" + `]+[^\s<>"'` + "`" + `.,;:!?)\]}]`)
+	emailRegexp = regexp.MustCompile(`[\w.+-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}`)
+	fileRegexp  = regexp.MustCompile(`((?:~|\.{1,2})?/?(?:[\w.@+-]+/)*[\w@+-][\w.@+-]*\.[a-zA-Z]\w*):(\d+)(?::(\d+))?`)
+)
+
+// linkCacheMax bounds the number of cached logical lines.
+const linkCacheMax = 4096
+
+// linkCache holds detected links per logical line. It has its own lock so
+// readers holding the terminal's read lock can fill it.
+type linkCache struct {
+	mu    sync.Mutex
+	lines map[linkLineKey]linkCacheEntry
+}
+
+// linkLineKey identifies the first row of a logical line: a scrollback line
+// ID, which survives pushes and evictions, or a screen row.
+type linkLineKey struct {
+	scrollback bool
+	id         uint64
+}
+
+// linkStamp records the state a cached line was detected in.
+type linkStamp struct {
+	screen Generation // Last resize, buffer switch or state restore
+	rows   Generation // Newest change of the line's screen rows
+	height int
+}
+
+type linkCacheEntry struct {
+	stamp linkStamp
+	links []Link // Rows relative to the first row of the line
+}
+
+// WithLinkPatterns adds custom link patterns, matched before the built-in
+// URL, email and file reference detectors.
+func WithLinkPatterns(patterns ...LinkPattern) Option {
+	return func(t *Terminal) {
+		t.linkPatterns = append(t.linkPatterns, patterns...)
+	}
+}
+
+// AddLinkPattern adds a custom link pattern, matched after the patterns
+// added before it and before the built-in detectors.
+func (t *Terminal) AddLinkPattern(p LinkPattern) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.linkPatterns = append(t.linkPatterns, p)
+
+	t.links.mu.Lock()
+	t.links.lines = nil
+	t.links.mu.Unlock()
+}
+
+// Links returns the links on the logical lines intersecting absolute rows
+// first through last, in reading order. Besides OSC 8 hyperlinks, lines are
+// scanned for custom patterns, URLs, email addresses and file references,
+// across soft wraps; a detected link never overlaps one found earlier in
+// that order. Results are cached per line until the line changes.
+func (t *Terminal) Links(first, last int) []Link {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var links []Link
+	for row := max(first, 0); row <= last; {
+		lineLinks, end, ok := t.lineLinksLocked(row)
+		if !ok {
+			break
+		}
+		for _, l := range lineLinks {
+			if l.End.Row >= first && l.Start.Row <= last {
+				links = append(links, l)
+			}
+		}
+		row = end + 1
+	}
+	return links
+}
+
+// LinkAt returns the link covering the cell at an absolute position, such
+// as under the mouse pointer. OSC 8 hyperlinks take precedence over
+// detected links.
+//
+// Example:
+//
+//	if link, ok := term.LinkAt(term.ViewportRowToAbsolute(row), col); ok {
+//	    open(link.Target)
+//	}
+func (t *Terminal) LinkAt(row, col int) (Link, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	links, _, _ := t.lineLinksLocked(row)
+	pos := Position{Row: row, Col: col}
+	for _, l := range links {
+		if !pos.Before(l.Start) && !l.End.Before(pos) {
+			return l, true
+		}
+	}
+	return Link{}, false
+}
+
+// lineLinksLocked returns the links of the logical line containing the
+// absolute row and the line's last row (caller must hold lock).
+func (t *Terminal) lineLinksLocked(abs int) ([]Link, int, bool) {
+	first, last, ok := t.logicalLineBoundsLocked(abs)
+	if !ok {
+		return nil, 0, false
+	}
+
+	sb := t.primaryBuffer.ScrollbackLen()
+	key := linkLineKey{id: uint64(first - sb)}
+	if first < sb {
+		key = linkLineKey{scrollback: true, id: t.lineIDLocked(first)}
+	}
+	stamp := linkStamp{screen: t.screenGen, height: last - first}
+	for row := max(first, sb); row <= last; row++ {
+		stamp.rows = max(stamp.rows, t.activeBuffer.lineGeneration(row-sb))
+	}
+
+	t.links.mu.Lock()
+	entry, ok := t.links.lines[key]
+	t.links.mu.Unlock()
+	if !ok || entry.stamp != stamp {
+		line, _ := t.logicalLineLocked(first)
+		entry = linkCacheEntry{stamp: stamp, links: t.detectLinksLocked(line)}
+
+		t.links.mu.Lock()
+		if t.links.lines == nil || len(t.links.lines) >= linkCacheMax {
+			t.links.lines = make(map[linkLineKey]linkCacheEntry)
+		}
+		t.links.lines[key] = entry
+		t.links.mu.Unlock()
+	}
+
+	links := slices.Clone(entry.links)
+	for i := range links {
+		links[i].Start.Row += first
+		links[i].End.Row += first
+	}
+	return links, last, true
+}
+
+// detectLinksLocked finds the links of a logical line, with rows relative
+// to its first row (caller must hold lock).
+func (t *Terminal) detectLinksLocked(line *logicalLine) []Link {
+	var links []Link
+	var taken [][2]int // Rune ranges of the links found so far
+	add := func(lo, hi int, l Link) {
+		for _, r := range taken {
+			if lo <= r[1] && hi >= r[0] {
+				return
+			}
+		}
+		taken = append(taken, [2]int{lo, hi})
+		l.Start, l.End = line.span(lo, hi)
+		l.Start.Row -= line.first
+		l.End.Row -= line.first
+		l.Text = line.text[line.offsets[lo]:line.byteEnd(hi)]
+		links = append(links, l)
+	}
+
+	// Explicit links: runs of cells with the same OSC 8 hyperlink
+	for lo := 0; lo < len(line.cells); {
+		link := t.cellHyperlinkLocked(line.cells[lo])
+		hi := lo
+		for hi+1 < len(line.cells) && sameHyperlink(t.cellHyperlinkLocked(line.cells[hi+1]), link) {
+			hi++
+		}
+		if link != nil {
+			add(lo, hi, Link{Kind: LinkExplicit, Target: link.URI, ID: link.ID})
+		}
+		lo = hi + 1
+	}
+
+	for _, p := range t.linkPatterns {
+		for _, loc := range p.Regexp.FindAllStringSubmatchIndex(line.text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			target := line.text[loc[0]:loc[1]]
+			if p.Target != nil {
+				target = p.Target(submatches(line.text, loc))
+			}
+			add(line.runeAt(loc[0]), line.runeAt(loc[1])-1, Link{Kind: LinkCustom, Target: target, Pattern: p.Name})
+		}
+	}
+	for _, loc := range urlRegexp.FindAllStringIndex(line.text, -1) {
+		add(line.runeAt(loc[0]), line.runeAt(loc[1])-1, Link{Kind: LinkURL, Target: line.text[loc[0]:loc[1]]})
+	}
+	for _, loc := range emailRegexp.FindAllStringIndex(line.text, -1) {
+		add(line.runeAt(loc[0]), line.runeAt(loc[1])-1, Link{Kind: LinkEmail, Target: "mailto:" + line.text[loc[0]:loc[1]]})
+	}
+	for _, loc := range fileRegexp.FindAllStringSubmatchIndex(line.text, -1) {
+		m := submatches(line.text, loc)
+		l := Link{Kind: LinkFile, Target: m[1]}
+		l.Line, _ = strconv.Atoi(m[2])
+		l.Col, _ = strconv.Atoi(m[3])
+		add(line.runeAt(loc[0]), line.runeAt(loc[1])-1, l)
+	}
+
+	slices.SortFunc(links, func(a, b Link) int {
+		if a.Start.Before(b.Start) {
+			return -1
+		}
+		if b.Start.Before(a.Start) {
+			return 1
+		}
+		return 0
+	})
+	return links
+}
+
+// cellHyperlinkLocked returns the OSC 8 hyperlink of the cell at an
+// absolute position (caller must hold lock).
+func (t *Terminal) cellHyperlinkLocked(pos Position) *Hyperlink {
+	cells, _, ok := t.searchRowLocked(pos.Row)
+	if !ok || pos.Col >= len(cells) {
+		return nil
+	}
+	return cells[pos.Col].Hyperlink
+}
+
+// byteEnd returns the byte offset just past rune i.
+func (l *logicalLine) byteEnd(i int) int {
+	if i+1 < len(l.offsets) {
+		return l.offsets[i+1]
+	}
+	return len(l.text)
+}
+
+// submatches returns the submatch strings of a match given its index pairs.
+func submatches(s string, loc []int) []string {
+	m := make([]string, len(loc)/2)
+	for i := range m {
+		if loc[2*i] >= 0 {
+			m[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return m
+}
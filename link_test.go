@@ -0,0 +1,101 @@
+package headlessterm
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLinks_Detection(t *testing.T) {
+	term := New(WithSize(4, 80))
+	term.WriteString("see https://example.com/docs. mail dev@example.org or edit ./cmd/main.go:12:5\r\n")
+	term.WriteString("\x1b]8;id=a;https://explicit.io\x07click https://inner.io\x1b]8;;\x07 and pkg/x.go:7")
+
+	links := term.Links(0, 3)
+	want := []Link{
+		{Kind: LinkURL, Start: Position{0, 4}, End: Position{0, 27}, Text: "https://example.com/docs", Target: "https://example.com/docs"},
+		{Kind: LinkEmail, Start: Position{0, 35}, End: Position{0, 49}, Text: "dev@example.org", Target: "mailto:dev@example.org"},
+		{Kind: LinkFile, Start: Position{0, 59}, End: Position{0, 76}, Text: "./cmd/main.go:12:5", Target: "./cmd/main.go", Line: 12, Col: 5},
+		{Kind: LinkExplicit, Start: Position{1, 0}, End: Position{1, 21}, Text: "click https://inner.io", Target: "https://explicit.io", ID: "a"},
+		{Kind: LinkFile, Start: Position{1, 27}, End: Position{1, 36}, Text: "pkg/x.go:7", Target: "pkg/x.go", Line: 7},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("links = %+v", links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("link %d = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+}
+
+func TestLinkAt(t *testing.T) {
+	term := New(WithSize(3, 10))
+	term.WriteString("go to https://a.io/xyz ok")
+
+	link, ok := term.LinkAt(1, 2)
+	if !ok || link.Target != "https://a.io/xyz" || link.Start != (Position{0, 6}) || link.End != (Position{2, 1}) {
+		t.Errorf("LinkAt across wrap = %+v, %v", link, ok)
+	}
+	if _, ok := term.LinkAt(0, 2); ok {
+		t.Error("LinkAt on plain text found a link")
+	}
+
+	// Explicit links win over detected ones
+	term.WriteString("\x1b[3;1H\x1b]8;;https://x.io\x07ftp://yy\x1b]8;;\x07")
+	if link, ok := term.LinkAt(2, 3); !ok || link.Kind != LinkExplicit || link.Target != "https://x.io" {
+		t.Errorf("LinkAt on explicit link = %+v, %v", link, ok)
+	}
+}
+
+func TestLinks_CustomPatterns(t *testing.T) {
+	term := New(WithSize(2, 40), WithLinkPatterns(LinkPattern{
+		Name:   "issue",
+		Regexp: regexp.MustCompile(`#(\d+)`),
+		Target: func(m []string) string { return "https://tracker.io/issues/" + m[1] },
+	}))
+	term.WriteString("fixes #42 and PROJ-7")
+
+	link, ok := term.LinkAt(0, 7)
+	if !ok || link.Kind != LinkCustom || link.Pattern != "issue" || link.Target != "https://tracker.io/issues/42" {
+		t.Errorf("issue link = %+v, %v", link, ok)
+	}
+	if _, ok := term.LinkAt(0, 16); ok {
+		t.Error("unregistered pattern matched")
+	}
+
+	term.AddLinkPattern(LinkPattern{Name: "jira", Regexp: regexp.MustCompile(`[A-Z]+-\d+`)})
+	if link, ok := term.LinkAt(0, 16); !ok || link.Target != "PROJ-7" || link.Pattern != "jira" {
+		t.Errorf("link after AddLinkPattern = %+v, %v", link, ok)
+	}
+}
+
+func TestLinks_ScrollbackAndCache(t *testing.T) {
+	term := New(WithSize(2, 30), WithScrollback(NewMemoryScrollback(2)))
+	term.WriteString("https://old.io\r\n")
+
+	term.WriteString("plain\r\n")
+	links := term.Links(0, 0)
+	if len(links) != 1 || links[0].Target != "https://old.io" {
+		t.Fatalf("scrollback links = %+v", links)
+	}
+
+	// Pushing and evicting lines keeps cached lines attached to their text
+	term.WriteString(strings.Repeat("x\r\n", 2) + "https://new.io")
+	for row := 0; row < term.ScrollbackLen()+2; row++ {
+		for _, l := range term.Links(row, row) {
+			if l.Target == "https://old.io" {
+				t.Errorf("evicted link still reported at row %d", row)
+			}
+		}
+	}
+	if link, ok := term.LinkAt(term.ViewportRowToAbsolute(1), 3); !ok || link.Target != "https://new.io" {
+		t.Errorf("screen link = %+v, %v", link, ok)
+	}
+
+	// Rewriting a row invalidates its cached links
+	term.WriteString("\x1b[2;1H\x1b[2Kno link")
+	if _, ok := term.LinkAt(term.ViewportRowToAbsolute(1), 3); ok {
+		t.Error("stale link after rewrite")
+	}
+}
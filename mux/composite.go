@@ -0,0 +1,237 @@
+package mux
+
+import (
+	"fmt"
+	"image/color"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// Screen composites the panes, borders and status line and returns the
+// composite terminal. Only panes that changed since the previous call are
+// redrawn. The cursor is placed at the focused pane's cursor.
+//
+// The returned terminal is owned by the Mux: read it with Cell, Snapshot,
+// String, DamageSince or the exporters, but do not write to it.
+func (m *Mux) Screen() *headlessterm.Terminal {
+	var status string
+	if m.opts.StatusLine {
+		statusFunc := m.opts.StatusFunc
+		if statusFunc == nil {
+			statusFunc = defaultStatus
+		}
+		status = statusFunc(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compositeLocked(status)
+	return m.screen
+}
+
+// Cell returns the cell at (row, col) of the composite screen.
+func (m *Mux) Cell(row, col int) *headlessterm.Cell {
+	return m.Screen().Cell(row, col)
+}
+
+// Snapshot returns a snapshot of the composite screen.
+func (m *Mux) Snapshot(detail headlessterm.SnapshotDetail) *headlessterm.Snapshot {
+	return m.Screen().Snapshot(detail)
+}
+
+// String returns the text of the composite screen.
+func (m *Mux) String() string {
+	return m.Screen().String()
+}
+
+// compositeLocked brings the composite screen up to date (caller must hold lock).
+// The new content is drawn onto a copy of the screen and only cells that
+// differ are written, so the screen's damage tracking reflects real changes.
+func (m *Mux) compositeLocked(status string) {
+	prev := m.screen.Frame()
+	full := m.layoutDirty || prev.Rows != m.rows || prev.Cols != m.cols
+
+	grid := make([][]headlessterm.Cell, m.rows)
+	for row := range grid {
+		if full {
+			grid[row] = make([]headlessterm.Cell, m.cols)
+			for col := range grid[row] {
+				grid[row][col] = headlessterm.NewCell()
+			}
+		} else {
+			grid[row] = append([]headlessterm.Cell(nil), prev.Lines[row]...)
+		}
+	}
+	if full {
+		m.drawBordersLocked(grid)
+	}
+
+	for _, p := range m.panes {
+		gen := p.term.Generation()
+		if full || gen != p.lastGen {
+			drawPane(grid, p)
+			p.lastGen = gen
+		}
+	}
+	if m.opts.StatusLine {
+		drawStatus(grid, status)
+	}
+	m.layoutDirty = false
+
+	for row := range grid {
+		for col, cell := range grid[row] {
+			if row < prev.Rows && col < prev.Cols && sameCell(&cell, &prev.Lines[row][col]) {
+				continue
+			}
+			m.screen.SetCell(row, col, cell)
+		}
+	}
+	m.placeCursorLocked()
+}
+
+// drawPane copies the visible screen of p into its rectangle.
+func drawPane(grid [][]headlessterm.Cell, p *Pane) {
+	r := p.node.rect
+	frame := p.term.Frame()
+	for row := 0; row < r.Rows; row++ {
+		for col := 0; col < r.Cols; col++ {
+			cell := headlessterm.NewCell()
+			if row < frame.Rows && col < frame.Cols {
+				cell = frame.Lines[row][col]
+				// Images live in the pane's image store, not the screen's
+				cell.Image = nil
+			}
+			grid[r.Row+row][r.Col+col] = cell
+		}
+	}
+}
+
+// drawBordersLocked draws the borders between panes, highlighting the ones
+// around the focused pane (caller must hold lock).
+func (m *Mux) drawBordersLocked(grid [][]headlessterm.Cell) {
+	mask := make([][]bool, m.rows)
+	for i := range mask {
+		mask[i] = make([]bool, m.cols)
+	}
+	isBorder := func(row, col int) bool {
+		return row >= 0 && row < m.rows && col >= 0 && col < m.cols && mask[row][col]
+	}
+
+	m.root.walk(func(n *node) {
+		if n.pane != nil {
+			return
+		}
+		b := n.border()
+		for row := b.Row; row < b.Row+b.Rows; row++ {
+			for col := b.Col; col < b.Col+b.Cols; col++ {
+				if row < m.rows && col < m.cols {
+					mask[row][col] = true
+				}
+			}
+		}
+	})
+
+	focus := m.focused.node.rect
+	active := Rect{Row: focus.Row - 1, Col: focus.Col - 1, Rows: focus.Rows + 2, Cols: focus.Cols + 2}
+
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			if !mask[row][col] {
+				continue
+			}
+			var bits int
+			if isBorder(row-1, col) {
+				bits |= 0b1000
+			}
+			if isBorder(row+1, col) {
+				bits |= 0b0100
+			}
+			if isBorder(row, col-1) {
+				bits |= 0b0010
+			}
+			if isBorder(row, col+1) {
+				bits |= 0b0001
+			}
+			cell := headlessterm.NewCell()
+			cell.Char = borderGlyphs[bits]
+			cell.Fg = m.opts.BorderColor
+			if len(m.panes) > 1 && active.Contains(row, col) {
+				cell.Fg = m.opts.ActiveBorderColor
+			}
+			grid[row][col] = cell
+		}
+	}
+}
+
+// drawStatus draws the status line on the bottom row.
+func drawStatus(grid [][]headlessterm.Cell, status string) {
+	line := grid[len(grid)-1]
+	text := []rune(status)
+	for col := range line {
+		cell := headlessterm.NewCell()
+		cell.Fg = &headlessterm.IndexedColor{Index: 0}
+		cell.Bg = &headlessterm.IndexedColor{Index: 2}
+		if col < len(text) {
+			cell.Char = text[col]
+		}
+		line[col] = cell
+	}
+}
+
+// sameCell reports whether two cells render identically, ignoring the dirty flag.
+func sameCell(a, b *headlessterm.Cell) bool {
+	const ignored = headlessterm.CellFlagDirty
+	if a.Char != b.Char || a.Flags&^ignored != b.Flags&^ignored {
+		return false
+	}
+	if !sameColor(a.Fg, b.Fg) || !sameColor(a.Bg, b.Bg) || !sameColor(a.UnderlineColor, b.UnderlineColor) {
+		return false
+	}
+	if (a.Hyperlink == nil) != (b.Hyperlink == nil) {
+		return false
+	}
+	return a.Hyperlink == nil || *a.Hyperlink == *b.Hyperlink
+}
+
+// sameColor compares colors by value; palette references compare by index or name.
+func sameColor(a, b color.Color) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case *headlessterm.NamedColor:
+		b, ok := b.(*headlessterm.NamedColor)
+		return ok && a.Name == b.Name
+	case *headlessterm.IndexedColor:
+		b, ok := b.(*headlessterm.IndexedColor)
+		return ok && a.Index == b.Index
+	default:
+		switch b.(type) {
+		case nil, *headlessterm.NamedColor, *headlessterm.IndexedColor:
+			return false
+		}
+		r1, g1, b1, a1 := a.RGBA()
+		r2, g2, b2, a2 := b.RGBA()
+		return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+	}
+}
+
+// placeCursorLocked moves the screen cursor to the focused pane's cursor (caller must hold lock).
+func (m *Mux) placeCursorLocked() {
+	r := m.focused.node.rect
+	frame := m.focused.term.Frame()
+	cur := frame.Cursor
+
+	visible := cur.Visible && r.Contains(r.Row+cur.Row, r.Col+cur.Col)
+	seq := fmt.Sprintf("\x1b[%d;%dH\x1b[%d q", r.Row+cur.Row+1, r.Col+cur.Col+1, cursorStyleParam(cur.Style))
+	if visible {
+		seq += "\x1b[?25h"
+	} else {
+		seq += "\x1b[?25l"
+	}
+	m.screen.WriteString(seq)
+}
+
+// cursorStyleParam returns the DECSCUSR parameter for style.
+func cursorStyleParam(style headlessterm.CursorStyle) int {
+	return int(style) + 1
+}
@@ -0,0 +1,112 @@
+package mux
+
+import "math"
+
+// Direction is the orientation of a split.
+type Direction int
+
+const (
+	// Horizontal places the two sides next to each other with a vertical border.
+	Horizontal Direction = iota
+	// Vertical stacks the two sides with a horizontal border.
+	Vertical
+)
+
+// Rect is a region of the composite screen in cells (0-based).
+type Rect struct {
+	Row, Col   int
+	Rows, Cols int
+}
+
+// Contains reports whether the cell (row, col) lies inside r.
+func (r Rect) Contains(row, col int) bool {
+	return row >= r.Row && row < r.Row+r.Rows && col >= r.Col && col < r.Col+r.Cols
+}
+
+// node is a layout tree node: either a leaf holding a pane or a split whose
+// first child gets ratio of the space (minus the border).
+type node struct {
+	parent   *node
+	pane     *Pane
+	dir      Direction
+	ratio    float64
+	children [2]*node
+	rect     Rect
+}
+
+// layout assigns r to n and divides it among the descendants.
+func (n *node) layout(r Rect) {
+	n.rect = r
+	if n.pane != nil {
+		return
+	}
+	a, b := splitRect(r, n.dir, n.ratio)
+	n.children[0].layout(a)
+	n.children[1].layout(b)
+}
+
+// border returns the cells of the border between the children of a split.
+func (n *node) border() Rect {
+	a := n.children[0].rect
+	if n.dir == Horizontal {
+		return Rect{Row: n.rect.Row, Col: a.Col + a.Cols, Rows: n.rect.Rows, Cols: 1}
+	}
+	return Rect{Row: a.Row + a.Rows, Col: n.rect.Col, Rows: 1, Cols: n.rect.Cols}
+}
+
+// walk calls fn for n and its descendants in depth-first order.
+func (n *node) walk(fn func(*node)) {
+	fn(n)
+	if n.pane == nil {
+		n.children[0].walk(fn)
+		n.children[1].walk(fn)
+	}
+}
+
+// sibling returns the other child of n's parent.
+func (n *node) sibling() *node {
+	if n.parent.children[0] == n {
+		return n.parent.children[1]
+	}
+	return n.parent.children[0]
+}
+
+// splitRect divides r into two sides separated by a one-cell border.
+// Each side keeps at least one cell while space allows.
+func splitRect(r Rect, dir Direction, ratio float64) (a, b Rect) {
+	size := r.Cols
+	if dir == Vertical {
+		size = r.Rows
+	}
+	avail := size - 1
+	first := int(math.Round(float64(avail) * ratio))
+	if first > avail-1 {
+		first = avail - 1
+	}
+	if first < 1 {
+		first = 1
+	}
+	second := avail - first
+	if second < 0 {
+		second = 0
+	}
+
+	if dir == Horizontal {
+		a = Rect{Row: r.Row, Col: r.Col, Rows: r.Rows, Cols: first}
+		b = Rect{Row: r.Row, Col: r.Col + first + 1, Rows: r.Rows, Cols: second}
+	} else {
+		a = Rect{Row: r.Row, Col: r.Col, Rows: first, Cols: r.Cols}
+		b = Rect{Row: r.Row + first + 1, Col: r.Col, Rows: second, Cols: r.Cols}
+	}
+	return a, b
+}
+
+// borderGlyphs maps the border neighbors of a border cell (up, down, left,
+// right bits) to its box-drawing character.
+var borderGlyphs = [16]rune{
+	0b0000: '─',
+	0b1000: '│', 0b0100: '│', 0b1100: '│',
+	0b0010: '─', 0b0001: '─', 0b0011: '─',
+	0b1101: '├', 0b1110: '┤', 0b0111: '┬', 0b1011: '┴', 0b1111: '┼',
+	0b0101: '┌', 0b0110: '┐', 0b1001: '└', 0b1010: '┘',
+}
@@ -0,0 +1,468 @@
+// Package mux manages several headlessterm.Terminal panes in a layout and
+// composites them into one virtual screen, like tmux.
+//
+// Panes are arranged in a tree of horizontal and vertical splits with ratios.
+// Layout changes resize the pane terminals (and the processes attached to
+// them). The composite screen, with borders and an optional status line, is
+// itself a *headlessterm.Terminal, so Cell, Snapshot, String and the exporters
+// work on it unchanged.
+//
+// Input written to the Mux goes to the focused pane. Focus changes are
+// reported to panes that enabled focus reporting (DECSET 1004).
+//
+// Example:
+//
+//	m := mux.New(24, 80, mux.Options{StatusLine: true})
+//	left := m.Focused()
+//	right, _ := m.Split(left, mux.Horizontal, 0.5)
+//	s, _ := pty.Spawn(exec.Command("top"), right.Terminal(), pty.Options{})
+//	right.Attach(s)
+//	fmt.Println(m.Screen().String())
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"sync"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+var (
+	// ErrUnknownPane is returned for panes that are closed or belong to another Mux.
+	ErrUnknownPane = errors.New("mux: unknown pane")
+	// ErrLastPane is returned when closing the only remaining pane.
+	ErrLastPane = errors.New("mux: cannot close the last pane")
+	// ErrRootPane is returned by SetRatio for a pane that is not part of a split.
+	ErrRootPane = errors.New("mux: pane is not split")
+)
+
+// Process is a program running in a pane, such as a *pty.Session.
+// It receives the pane's input and is resized with the pane; its Resize is
+// expected to resize the pane terminal as well.
+type Process interface {
+	io.Writer
+	Resize(rows, cols int) error
+}
+
+// Options configures New.
+type Options struct {
+	// TerminalOptions are applied to every pane terminal; the size is set by the layout.
+	TerminalOptions []headlessterm.Option
+	// StatusLine reserves the bottom row for a status line.
+	StatusLine bool
+	// StatusFunc returns the status line text (default: the panes as
+	// "id:title", the focused one marked with '*').
+	StatusFunc func(m *Mux) string
+	// BorderColor is the color of pane borders (default: foreground).
+	BorderColor color.Color
+	// ActiveBorderColor is the color of the borders around the focused pane (default: green).
+	ActiveBorderColor color.Color
+}
+
+// Mux is a set of panes composited into one screen.
+// All methods are safe for concurrent use.
+type Mux struct {
+	mu     sync.Mutex
+	opts   Options
+	rows   int
+	cols   int
+	root   *node
+	panes  []*Pane
+	nextID int
+
+	focused       *Pane
+	windowFocused bool
+
+	screen      *headlessterm.Terminal
+	layoutDirty bool
+}
+
+// Pane is one terminal in a Mux.
+type Pane struct {
+	mux     *Mux
+	id      int
+	term    *headlessterm.Terminal
+	node    *node
+	proc    Process
+	lastGen headlessterm.Generation
+}
+
+// New creates a Mux of the given size with a single focused pane.
+func New(rows, cols int, opts Options) *Mux {
+	if rows <= 0 {
+		rows = headlessterm.DEFAULT_ROWS
+	}
+	if cols <= 0 {
+		cols = headlessterm.DEFAULT_COLS
+	}
+	if opts.BorderColor == nil {
+		opts.BorderColor = &headlessterm.NamedColor{Name: headlessterm.NamedColorForeground}
+	}
+	if opts.ActiveBorderColor == nil {
+		opts.ActiveBorderColor = &headlessterm.IndexedColor{Index: 2}
+	}
+
+	m := &Mux{
+		opts:          opts,
+		rows:          rows,
+		cols:          cols,
+		windowFocused: true,
+		screen:        headlessterm.New(headlessterm.WithSize(rows, cols)),
+		layoutDirty:   true,
+	}
+	area := m.paneAreaLocked()
+	p := m.newPaneLocked(area.Rows, area.Cols)
+	m.root = p.node
+	m.focused = p
+	m.root.layout(area)
+	return m
+}
+
+// paneAreaLocked returns the screen region available to panes (caller must hold lock).
+func (m *Mux) paneAreaLocked() Rect {
+	rows := m.rows
+	if m.opts.StatusLine && rows > 1 {
+		rows--
+	}
+	return Rect{Rows: rows, Cols: m.cols}
+}
+
+// newPaneLocked creates a pane with its terminal (caller must hold lock).
+func (m *Mux) newPaneLocked(rows, cols int) *Pane {
+	if rows <= 0 {
+		rows = 1
+	}
+	if cols <= 0 {
+		cols = 1
+	}
+	opts := append([]headlessterm.Option{}, m.opts.TerminalOptions...)
+	opts = append(opts, headlessterm.WithSize(rows, cols))
+
+	p := &Pane{mux: m, id: m.nextID, term: headlessterm.New(opts...)}
+	p.node = &node{pane: p}
+	m.nextID++
+	m.panes = append(m.panes, p)
+	return p
+}
+
+// ownsLocked reports whether p is an open pane of m (caller must hold lock).
+func (m *Mux) ownsLocked(p *Pane) bool {
+	if p == nil || p.mux != m {
+		return false
+	}
+	for _, q := range m.panes {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// relayoutLocked recomputes pane rectangles and resizes pane terminals (caller must hold lock).
+func (m *Mux) relayoutLocked() {
+	m.root.layout(m.paneAreaLocked())
+	for _, p := range m.panes {
+		p.resizeLocked()
+	}
+	m.layoutDirty = true
+}
+
+// Rows returns the height of the composite screen.
+func (m *Mux) Rows() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rows
+}
+
+// Cols returns the width of the composite screen.
+func (m *Mux) Cols() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cols
+}
+
+// Panes returns the open panes in creation order.
+func (m *Mux) Panes() []*Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Pane(nil), m.panes...)
+}
+
+// Pane returns the open pane with the given ID, or nil.
+func (m *Mux) Pane(id int) *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.panes {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// PaneAt returns the pane covering the screen cell (row, col), or nil for
+// borders and the status line. Useful for routing mouse events.
+func (m *Mux) PaneAt(row, col int) *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.panes {
+		if p.node.rect.Contains(row, col) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Split divides p in direction dir and returns the new pane, placed right of
+// or below p. ratio is the share of the space p keeps (clamped to 0.05..0.95).
+func (m *Mux) Split(p *Pane, dir Direction, ratio float64) (*Pane, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ownsLocked(p) {
+		return nil, ErrUnknownPane
+	}
+
+	leaf := p.node
+	_, b := splitRect(leaf.rect, dir, clampRatio(ratio))
+	np := m.newPaneLocked(b.Rows, b.Cols)
+
+	split := &node{parent: leaf.parent, dir: dir, ratio: clampRatio(ratio)}
+	m.replaceLocked(leaf, split)
+	split.children = [2]*node{leaf, np.node}
+	leaf.parent = split
+	np.node.parent = split
+
+	m.relayoutLocked()
+	return np, nil
+}
+
+// replaceLocked puts n in old's place in the tree (caller must hold lock).
+func (m *Mux) replaceLocked(old, n *node) {
+	n.parent = old.parent
+	if old.parent == nil {
+		m.root = n
+		return
+	}
+	if old.parent.children[0] == old {
+		old.parent.children[0] = n
+	} else {
+		old.parent.children[1] = n
+	}
+}
+
+// ClosePane removes p from the layout; its sibling takes over the space.
+// If p was focused, focus moves to the first remaining pane. The pane's
+// terminal and process are left to the caller.
+func (m *Mux) ClosePane(p *Pane) error {
+	m.mu.Lock()
+	if !m.ownsLocked(p) {
+		m.mu.Unlock()
+		return ErrUnknownPane
+	}
+	if len(m.panes) == 1 {
+		m.mu.Unlock()
+		return ErrLastPane
+	}
+
+	m.replaceLocked(p.node.parent, p.node.sibling())
+	for i, q := range m.panes {
+		if q == p {
+			m.panes = append(m.panes[:i], m.panes[i+1:]...)
+			break
+		}
+	}
+
+	var focusIn *Pane
+	if m.focused == p {
+		m.focused = m.panes[0]
+		if m.windowFocused {
+			focusIn = m.focused
+		}
+	}
+	m.relayoutLocked()
+	m.mu.Unlock()
+
+	if focusIn != nil {
+		focusIn.reportFocus(true)
+	}
+	return nil
+}
+
+// SetRatio changes the ratio of the split directly containing p; ratio is the
+// share of the first (left or top) side.
+func (m *Mux) SetRatio(p *Pane, ratio float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ownsLocked(p) {
+		return ErrUnknownPane
+	}
+	if p.node.parent == nil {
+		return ErrRootPane
+	}
+	p.node.parent.ratio = clampRatio(ratio)
+	m.relayoutLocked()
+	return nil
+}
+
+// clampRatio keeps split ratios away from degenerate values.
+func clampRatio(r float64) float64 {
+	if r < 0.05 {
+		return 0.05
+	}
+	if r > 0.95 {
+		return 0.95
+	}
+	return r
+}
+
+// Resize changes the size of the composite screen and lays out the panes again.
+func (m *Mux) Resize(rows, cols int) {
+	if rows <= 0 || cols <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rows, m.cols = rows, cols
+	m.screen.Resize(rows, cols)
+	m.relayoutLocked()
+}
+
+// Focused returns the focused pane, which receives input.
+func (m *Mux) Focused() *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.focused
+}
+
+// Focus moves input focus to p. Panes with focus reporting enabled receive
+// CSI O when losing and CSI I when gaining focus, as long as the Mux itself
+// is focused (see SetWindowFocused).
+func (m *Mux) Focus(p *Pane) error {
+	m.mu.Lock()
+	if !m.ownsLocked(p) {
+		m.mu.Unlock()
+		return ErrUnknownPane
+	}
+	old := m.focused
+	if old == p {
+		m.mu.Unlock()
+		return nil
+	}
+	m.focused = p
+	m.layoutDirty = true
+	report := m.windowFocused
+	m.mu.Unlock()
+
+	if report {
+		old.reportFocus(false)
+		p.reportFocus(true)
+	}
+	return nil
+}
+
+// SetWindowFocused tells the Mux whether the window showing it has focus,
+// for example when the outer terminal reports CSI I / CSI O. The change is
+// forwarded to the focused pane. A new Mux starts focused.
+func (m *Mux) SetWindowFocused(focused bool) {
+	m.mu.Lock()
+	if m.windowFocused == focused {
+		m.mu.Unlock()
+		return
+	}
+	m.windowFocused = focused
+	p := m.focused
+	m.mu.Unlock()
+
+	p.reportFocus(focused)
+}
+
+// Write sends input to the focused pane.
+func (m *Mux) Write(data []byte) (int, error) {
+	return m.Focused().Write(data)
+}
+
+// ID returns the pane's identifier, unique within its Mux.
+func (p *Pane) ID() int {
+	return p.id
+}
+
+// Terminal returns the pane's terminal.
+func (p *Pane) Terminal() *headlessterm.Terminal {
+	return p.term
+}
+
+// Rect returns the pane's region of the composite screen.
+func (p *Pane) Rect() Rect {
+	p.mux.mu.Lock()
+	defer p.mux.mu.Unlock()
+	return p.node.rect
+}
+
+// Attach sets the process running in the pane: it receives the pane's input
+// and is resized with it. The process is resized to the pane immediately.
+func (p *Pane) Attach(proc Process) {
+	p.mux.mu.Lock()
+	defer p.mux.mu.Unlock()
+	p.proc = proc
+	p.resizeLocked()
+}
+
+// Write sends input to the pane's process, or to the terminal's PTYWriter if
+// no process is attached.
+func (p *Pane) Write(data []byte) (int, error) {
+	p.mux.mu.Lock()
+	proc := p.proc
+	p.mux.mu.Unlock()
+
+	if proc != nil {
+		return proc.Write(data)
+	}
+	return p.term.PTYWriter().Write(data)
+}
+
+// resizeLocked fits the pane terminal to its rectangle (caller must hold mux lock).
+func (p *Pane) resizeLocked() {
+	r := p.node.rect
+	if r.Rows <= 0 || r.Cols <= 0 {
+		return
+	}
+	if p.proc != nil {
+		p.proc.Resize(r.Rows, r.Cols)
+		return
+	}
+	if p.term.Rows() != r.Rows || p.term.Cols() != r.Cols {
+		p.term.Resize(r.Rows, r.Cols)
+	}
+}
+
+// reportFocus sends a focus event if the pane's application asked for them.
+func (p *Pane) reportFocus(focused bool) {
+	if !p.term.HasMode(headlessterm.ModeReportFocusInOut) {
+		return
+	}
+	if focused {
+		p.Write([]byte("\x1b[I"))
+	} else {
+		p.Write([]byte("\x1b[O"))
+	}
+}
+
+// defaultStatus lists the panes as "id:title", marking the focused one.
+func defaultStatus(m *Mux) string {
+	var sb strings.Builder
+	for _, p := range m.Panes() {
+		title := p.term.Title()
+		if title == "" {
+			title = "pane"
+		}
+		fmt.Fprintf(&sb, " %d:%s", p.id, title)
+		if p == m.Focused() {
+			sb.WriteByte('*')
+		}
+	}
+	return sb.String()
+}
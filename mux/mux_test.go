@@ -0,0 +1,238 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// fakeProcess records input and resizes, resizing the pane terminal like a pty.Session.
+type fakeProcess struct {
+	term   *headlessterm.Terminal
+	input  bytes.Buffer
+	resize [][2]int
+}
+
+func (f *fakeProcess) Write(p []byte) (int, error) {
+	return f.input.Write(p)
+}
+
+func (f *fakeProcess) Resize(rows, cols int) error {
+	f.resize = append(f.resize, [2]int{rows, cols})
+	f.term.Resize(rows, cols)
+	return nil
+}
+
+// screenLines returns the composite screen as one string per row.
+func screenLines(m *Mux) []string {
+	scr := m.Screen()
+	lines := make([]string, scr.Rows())
+	for i := range lines {
+		lines[i] = scr.LineContent(i)
+	}
+	return lines
+}
+
+func TestSplit_LayoutAndBorders(t *testing.T) {
+	m := New(5, 11, Options{})
+	left := m.Focused()
+	right, err := m.Split(left, Horizontal, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bottom, err := m.Split(right, Vertical, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := left.Rect(), (Rect{Row: 0, Col: 0, Rows: 5, Cols: 5}); got != want {
+		t.Errorf("left = %+v, want %+v", got, want)
+	}
+	if got, want := right.Rect(), (Rect{Row: 0, Col: 6, Rows: 2, Cols: 5}); got != want {
+		t.Errorf("right = %+v, want %+v", got, want)
+	}
+	if got, want := bottom.Rect(), (Rect{Row: 3, Col: 6, Rows: 2, Cols: 5}); got != want {
+		t.Errorf("bottom = %+v, want %+v", got, want)
+	}
+	if term := bottom.Terminal(); term.Rows() != 2 || term.Cols() != 5 {
+		t.Errorf("bottom terminal = %dx%d, want 2x5", term.Rows(), term.Cols())
+	}
+
+	left.Terminal().WriteString("left")
+	right.Terminal().WriteString("top")
+	bottom.Terminal().WriteString("bot")
+
+	want := []string{
+		"left │top",
+		"     │",
+		"     ├─────",
+		"     │bot",
+		"     │",
+	}
+	if got := screenLines(m); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("screen:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if p := m.PaneAt(3, 7); p != bottom {
+		t.Errorf("PaneAt(3, 7) = %v, want bottom pane", p)
+	}
+	if p := m.PaneAt(2, 7); p != nil {
+		t.Errorf("PaneAt on a border = %v, want nil", p)
+	}
+}
+
+func TestScreen_PreservesAttributesAndCursor(t *testing.T) {
+	m := New(4, 20, Options{})
+	right, _ := m.Split(m.Focused(), Horizontal, 0.5)
+	right.Terminal().WriteString("\x1b[1;31mred\x1b[0m\r\nx")
+	m.Focus(right)
+	r := right.Rect()
+
+	c := m.Cell(0, r.Col)
+	if c == nil || c.Char != 'r' || !c.HasFlag(headlessterm.CellFlagBold) {
+		t.Fatalf("cell = %+v, want bold 'r'", c)
+	}
+	if fg := headlessterm.ResolveDefaultColor(c.Fg, true); fg != headlessterm.DefaultPalette[1] {
+		t.Errorf("fg = %v, want red", fg)
+	}
+	if row, col := m.Screen().CursorPos(); row != 1 || col != r.Col+1 {
+		t.Errorf("cursor = (%d, %d), want (1, %d)", row, col, r.Col+1)
+	}
+
+	snap := m.Snapshot(headlessterm.SnapshotDetailText)
+	if snap.Size.Rows != 4 || snap.Size.Cols != 20 {
+		t.Errorf("snapshot size = %+v", snap.Size)
+	}
+}
+
+func TestScreen_StatusLine(t *testing.T) {
+	m := New(4, 20, Options{StatusLine: true})
+	if r := m.Focused().Rect(); r.Rows != 3 {
+		t.Errorf("pane rows = %d, want 3", r.Rows)
+	}
+	second, _ := m.Split(m.Focused(), Horizontal, 0.5)
+	second.Terminal().WriteString("\x1b]2;vim\x07")
+
+	lines := screenLines(m)
+	if got := lines[3]; got != " 0:pane* 1:vim" {
+		t.Errorf("status = %q", got)
+	}
+
+	m = New(3, 10, Options{StatusLine: true, StatusFunc: func(*Mux) string { return "custom" }})
+	if got := screenLines(m)[2]; got != "custom" {
+		t.Errorf("custom status = %q", got)
+	}
+}
+
+func TestScreen_RedrawsOnlyChangedPanes(t *testing.T) {
+	m := New(3, 21, Options{})
+	left := m.Focused()
+	right, _ := m.Split(left, Horizontal, 0.5)
+	scr := m.Screen()
+
+	gen := scr.Generation()
+	right.Terminal().WriteString("hi")
+	m.Screen()
+
+	// Only the changed row is written to the screen
+	d := scr.DamageSince(gen)
+	if d.Full || len(d.Spans) != 1 || d.Spans[0].Row != 0 {
+		t.Fatalf("damage = %+v, want row 0 only", d)
+	}
+	if got := scr.LineContent(0); !strings.HasSuffix(got, "│hi") {
+		t.Errorf("row 0 = %q", got)
+	}
+}
+
+func TestResizeAndSetRatio(t *testing.T) {
+	m := New(10, 41, Options{})
+	left := m.Focused()
+	right, _ := m.Split(left, Horizontal, 0.5)
+	proc := &fakeProcess{term: right.Terminal()}
+	right.Attach(proc)
+
+	if err := m.SetRatio(right, 0.25); err != nil {
+		t.Fatal(err)
+	}
+	if got := left.Rect().Cols; got != 10 {
+		t.Errorf("left cols = %d, want 10", got)
+	}
+	if got := right.Terminal().Cols(); got != 30 {
+		t.Errorf("right terminal cols = %d, want 30", got)
+	}
+
+	m.Resize(20, 81)
+	if m.Screen().Rows() != 20 || m.Screen().Cols() != 81 {
+		t.Errorf("screen = %dx%d", m.Screen().Rows(), m.Screen().Cols())
+	}
+	if got, want := proc.resize[len(proc.resize)-1], [2]int{20, 60}; got != want {
+		t.Errorf("last process resize = %v, want %v", got, want)
+	}
+
+	if err := m.SetRatio(m.Panes()[0], 0.5); err != nil {
+		t.Errorf("SetRatio = %v", err)
+	}
+	single := New(5, 5, Options{})
+	if err := single.SetRatio(single.Focused(), 0.5); !errors.Is(err, ErrRootPane) {
+		t.Errorf("SetRatio on root = %v, want ErrRootPane", err)
+	}
+}
+
+func TestFocusAndInputRouting(t *testing.T) {
+	m := New(5, 20, Options{})
+	left := m.Focused()
+	right, _ := m.Split(left, Horizontal, 0.5)
+
+	leftProc := &fakeProcess{term: left.Terminal()}
+	rightProc := &fakeProcess{term: right.Terminal()}
+	left.Attach(leftProc)
+	right.Attach(rightProc)
+
+	// Only the right pane asked for focus reports
+	right.Terminal().WriteString("\x1b[?1004h")
+
+	m.Write([]byte("a"))
+	if err := m.Focus(right); err != nil {
+		t.Fatal(err)
+	}
+	m.Write([]byte("b"))
+	m.SetWindowFocused(false)
+	m.SetWindowFocused(true)
+	m.Focus(left)
+
+	if got := leftProc.input.String(); got != "a" {
+		t.Errorf("left input = %q, want %q", got, "a")
+	}
+	if got, want := rightProc.input.String(), "\x1b[Ib\x1b[O\x1b[I\x1b[O"; got != want {
+		t.Errorf("right input = %q, want %q", got, want)
+	}
+}
+
+func TestClosePane(t *testing.T) {
+	m := New(5, 21, Options{})
+	left := m.Focused()
+	right, _ := m.Split(left, Horizontal, 0.5)
+	m.Focus(right)
+
+	if err := m.ClosePane(right); err != nil {
+		t.Fatal(err)
+	}
+	if m.Focused() != left {
+		t.Error("focus did not move to the remaining pane")
+	}
+	if got := left.Rect(); got.Cols != 21 || got.Rows != 5 {
+		t.Errorf("left = %+v, want the whole screen", got)
+	}
+	if strings.ContainsRune(m.String(), '│') {
+		t.Error("border still drawn")
+	}
+	if err := m.ClosePane(right); !errors.Is(err, ErrUnknownPane) {
+		t.Errorf("closing twice = %v, want ErrUnknownPane", err)
+	}
+	if err := m.ClosePane(left); !errors.Is(err, ErrLastPane) {
+		t.Errorf("closing last = %v, want ErrLastPane", err)
+	}
+}
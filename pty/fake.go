@@ -0,0 +1,437 @@
+package pty
+
+import (
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// Signal is a signal generated by a FakeTTY.
+type Signal int
+
+const (
+	// SignalInterrupt is generated by VINTR (SIGINT).
+	SignalInterrupt Signal = iota + 1
+	// SignalQuit is generated by VQUIT (SIGQUIT).
+	SignalQuit
+	// SignalSuspend is generated by VSUSP (SIGTSTP).
+	SignalSuspend
+	// SignalWinch is generated by Resize (SIGWINCH).
+	SignalWinch
+	// SignalHangup is generated when the master side is closed (SIGHUP).
+	SignalHangup
+)
+
+// Termios is the subset of terminal attributes emulated by FakeTTY.
+// A zero control character disables it.
+type Termios struct {
+	Canonical bool // ICANON: line editing, input is read line by line
+	Echo      bool // ECHO: echo input
+	EchoErase bool // ECHOE: erase echoed characters on VERASE/VKILL
+	EchoCtl   bool // ECHOCTL: echo control characters as ^X
+	Signals   bool // ISIG: VINTR, VQUIT and VSUSP generate signals
+	ICRNL     bool // Translate CR to NL on input
+	ONLCR     bool // Translate NL to CR-NL on output
+
+	VIntr, VQuit, VSusp, VEOF, VErase, VKill byte
+}
+
+// DefaultTermios returns the attributes of a freshly allocated Linux PTY.
+func DefaultTermios() Termios {
+	return Termios{
+		Canonical: true,
+		Echo:      true,
+		EchoErase: true,
+		EchoCtl:   true,
+		Signals:   true,
+		ICRNL:     true,
+		ONLCR:     true,
+		VIntr:     0x03, // ^C
+		VQuit:     0x1c, // ^\
+		VSusp:     0x1a, // ^Z
+		VEOF:      0x04, // ^D
+		VErase:    0x7f, // DEL
+		VKill:     0x15, // ^U
+	}
+}
+
+// Raw returns a copy with line editing, echo, signals and newline
+// translation disabled, like cfmakeraw.
+func (t Termios) Raw() Termios {
+	t.Canonical, t.Echo, t.Signals, t.ICRNL, t.ONLCR = false, false, false, false, false
+	return t
+}
+
+// FakeOptions configures NewFakeTTY.
+type FakeOptions struct {
+	// Rows and Cols set the initial window size (default: 24x80).
+	Rows, Cols int
+	// OnSignal is called for generated signals, outside of any lock.
+	OnSignal func(Signal)
+}
+
+// FakeTTY is an in-process pseudo-terminal for environments where PTYs cannot
+// be allocated. The program under test uses Slave as its terminal; Master is
+// the terminal side, usually connected to a Terminal with Attach.
+// Input written to Master passes through a line discipline (canonical or raw
+// mode, echo, CR/NL translation, VINTR/VEOF/VERASE handling) and output
+// written to Slave gets ONLCR translation, as with a kernel PTY.
+//
+// Output is buffered without limit, so a slave never blocks on writes.
+type FakeTTY struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	termios  Termios
+	rows     int
+	cols     int
+	onSignal func(Signal)
+	term     *headlessterm.Terminal
+
+	line   []byte   // Canonical mode line being edited
+	input  [][]byte // Data readable by the slave; an empty chunk is an EOF
+	output []byte   // Data readable by the master
+
+	masterClosed bool
+	slaveClosed  bool
+
+	master *fakeEnd
+	slave  *fakeEnd
+}
+
+// NewFakeTTY creates a fake TTY with DefaultTermios.
+func NewFakeTTY(opts FakeOptions) *FakeTTY {
+	if opts.Rows <= 0 {
+		opts.Rows = headlessterm.DEFAULT_ROWS
+	}
+	if opts.Cols <= 0 {
+		opts.Cols = headlessterm.DEFAULT_COLS
+	}
+	f := &FakeTTY{
+		termios:  DefaultTermios(),
+		rows:     opts.Rows,
+		cols:     opts.Cols,
+		onSignal: opts.OnSignal,
+	}
+	f.cond = sync.NewCond(&f.mu)
+	f.master = &fakeEnd{f: f, master: true}
+	f.slave = &fakeEnd{f: f}
+	return f
+}
+
+// Master returns the terminal side: writes are keyboard input, reads return
+// the program's processed output and echo.
+func (f *FakeTTY) Master() io.ReadWriteCloser {
+	return f.master
+}
+
+// Slave returns the program side: reads return input as the line discipline
+// releases it, writes are the program's output.
+func (f *FakeTTY) Slave() io.ReadWriteCloser {
+	return f.slave
+}
+
+// Termios returns the current attributes.
+func (f *FakeTTY) Termios() Termios {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.termios
+}
+
+// SetTermios changes the attributes. Leaving canonical mode releases the
+// line being edited to the reader.
+func (f *FakeTTY) SetTermios(t Termios) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.termios.Canonical && !t.Canonical && len(f.line) > 0 {
+		f.input = append(f.input, f.line)
+		f.line = nil
+		f.cond.Broadcast()
+	}
+	f.termios = t
+}
+
+// Winsize returns the window size, like TIOCGWINSZ.
+func (f *FakeTTY) Winsize() (rows, cols int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rows, f.cols
+}
+
+// Resize sets the window size, resizes the attached Terminal and generates
+// SignalWinch.
+func (f *FakeTTY) Resize(rows, cols int) {
+	if rows <= 0 || cols <= 0 {
+		return
+	}
+	f.mu.Lock()
+	f.rows, f.cols = rows, cols
+	term := f.term
+	f.mu.Unlock()
+
+	if term != nil {
+		term.Resize(rows, cols)
+	}
+	f.signal(SignalWinch)
+}
+
+// Attach connects the master side to term: output is written to the terminal
+// and terminal responses are routed back as input. The window size is taken
+// from term. The returned channel is closed once the slave is closed and all
+// output has been written to the terminal.
+func (f *FakeTTY) Attach(term *headlessterm.Terminal) <-chan struct{} {
+	f.mu.Lock()
+	f.term = term
+	f.rows, f.cols = term.Rows(), term.Cols()
+	f.mu.Unlock()
+	term.SetPTYWriter(f.master)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := f.master.Read(buf)
+			if n > 0 {
+				term.Write(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// signal delivers a signal to the handler.
+func (f *FakeTTY) signal(sig Signal) {
+	if f.onSignal != nil {
+		f.onSignal(sig)
+	}
+}
+
+// receiveLocked runs input through the line discipline and returns the
+// signals it generated (caller must hold lock).
+func (f *FakeTTY) receiveLocked(p []byte) []Signal {
+	var signals []Signal
+	t := f.termios
+	var raw []byte
+
+	for _, c := range p {
+		if t.Signals && c != 0 {
+			var sig Signal
+			switch c {
+			case t.VIntr:
+				sig = SignalInterrupt
+			case t.VQuit:
+				sig = SignalQuit
+			case t.VSusp:
+				sig = SignalSuspend
+			}
+			if sig != 0 {
+				// Pending input is flushed, as without NOFLSH
+				f.line, f.input, raw = nil, nil, nil
+				f.echoLocked(c)
+				signals = append(signals, sig)
+				continue
+			}
+		}
+
+		if c == '\r' && t.ICRNL {
+			c = '\n'
+		}
+
+		if !t.Canonical {
+			raw = append(raw, c)
+			f.echoLocked(c)
+			continue
+		}
+
+		switch {
+		case c == t.VErase && c != 0:
+			f.eraseLocked()
+		case c == t.VKill && c != 0:
+			for len(f.line) > 0 {
+				f.eraseLocked()
+			}
+		case c == t.VEOF && c != 0:
+			// Releases the line without a newline; on an empty line the reader gets EOF
+			f.input = append(f.input, f.line)
+			f.line = nil
+		case c == '\n':
+			f.line = append(f.line, c)
+			f.input = append(f.input, f.line)
+			f.line = nil
+			f.echoLocked(c)
+		default:
+			f.line = append(f.line, c)
+			f.echoLocked(c)
+		}
+	}
+
+	if len(raw) > 0 {
+		f.input = append(f.input, raw)
+	}
+	f.cond.Broadcast()
+	return signals
+}
+
+// eraseLocked removes the last character of the line being edited (caller must hold lock).
+func (f *FakeTTY) eraseLocked() {
+	if len(f.line) == 0 {
+		return
+	}
+	r, size := utf8.DecodeLastRune(f.line)
+	f.line = f.line[:len(f.line)-size]
+	if !f.termios.Echo || !f.termios.EchoErase {
+		return
+	}
+	width := 1
+	if size == 1 && isControl(byte(r)) && f.termios.EchoCtl {
+		width = 2
+	}
+	for i := 0; i < width; i++ {
+		f.output = append(f.output, '\b', ' ', '\b')
+	}
+}
+
+// echoLocked echoes an input byte (caller must hold lock).
+func (f *FakeTTY) echoLocked(c byte) {
+	if !f.termios.Echo {
+		return
+	}
+	if isControl(c) && f.termios.EchoCtl {
+		f.output = append(f.output, '^', c^0x40)
+		return
+	}
+	f.writeOutputLocked([]byte{c})
+}
+
+// isControl reports whether c is echoed as ^X with ECHOCTL.
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t' && c != '\n') || c == 0x7f
+}
+
+// writeOutputLocked appends output with ONLCR translation (caller must hold lock).
+func (f *FakeTTY) writeOutputLocked(p []byte) {
+	if !f.termios.ONLCR {
+		f.output = append(f.output, p...)
+		return
+	}
+	for _, c := range p {
+		if c == '\n' {
+			f.output = append(f.output, '\r')
+		}
+		f.output = append(f.output, c)
+	}
+}
+
+// fakeEnd is one side of a FakeTTY.
+type fakeEnd struct {
+	f      *FakeTTY
+	master bool
+}
+
+func (e *fakeEnd) Read(p []byte) (int, error) {
+	f := e.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e.master {
+		return f.readOutputLocked(p)
+	}
+	return f.readInputLocked(p)
+}
+
+func (e *fakeEnd) Write(p []byte) (int, error) {
+	f := e.f
+	f.mu.Lock()
+	if f.masterClosed || f.slaveClosed {
+		f.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	var signals []Signal
+	if e.master {
+		signals = f.receiveLocked(p)
+	} else {
+		f.writeOutputLocked(p)
+	}
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	for _, sig := range signals {
+		f.signal(sig)
+	}
+	return len(p), nil
+}
+
+func (e *fakeEnd) Close() error {
+	f := e.f
+	f.mu.Lock()
+	hangup := e.master && !f.masterClosed
+	if e.master {
+		f.masterClosed = true
+	} else {
+		f.slaveClosed = true
+	}
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	if hangup {
+		f.signal(SignalHangup)
+	}
+	return nil
+}
+
+// readOutputLocked reads program output for the master (caller must hold lock).
+// Returns io.EOF once the slave is closed and all output was read.
+func (f *FakeTTY) readOutputLocked(p []byte) (int, error) {
+	for len(f.output) == 0 && !f.slaveClosed && !f.masterClosed {
+		f.cond.Wait()
+	}
+	if f.masterClosed {
+		return 0, ErrClosed
+	}
+	if len(f.output) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, f.output)
+	f.output = f.output[n:]
+	return n, nil
+}
+
+// readInputLocked reads released input for the slave (caller must hold lock).
+// In canonical mode a read returns at most one line. An EOF chunk makes a
+// single read return io.EOF; a closed master (hangup) returns io.EOF.
+func (f *FakeTTY) readInputLocked(p []byte) (int, error) {
+	for len(f.input) == 0 && !f.masterClosed && !f.slaveClosed {
+		f.cond.Wait()
+	}
+	if f.slaveClosed {
+		return 0, ErrClosed
+	}
+	if len(f.input) == 0 {
+		return 0, io.EOF
+	}
+	if len(f.input[0]) == 0 {
+		f.input = f.input[1:]
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && len(f.input) > 0 && len(f.input[0]) > 0 {
+		chunk := f.input[0]
+		c := copy(p[n:], chunk)
+		n += c
+		if c < len(chunk) {
+			f.input[0] = chunk[c:]
+			break
+		}
+		f.input = f.input[1:]
+		if f.termios.Canonical {
+			break
+		}
+	}
+	return n, nil
+}
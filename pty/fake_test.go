@@ -0,0 +1,182 @@
+package pty
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// readOutput reads n bytes of master output.
+func readOutput(t *testing.T, r io.Reader, n int) string {
+	t.Helper()
+	buf := make([]byte, n)
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(r, buf)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out reading output")
+	}
+	return string(buf)
+}
+
+func TestFakeTTY_CanonicalEchoAndErase(t *testing.T) {
+	f := NewFakeTTY(FakeOptions{})
+	f.Master().Write([]byte("helo\x7flo\r"))
+
+	line, err := bufio.NewReader(f.Slave()).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello\n" {
+		t.Errorf("slave read %q, want %q", line, "hello\n")
+	}
+	want := "helo\b \blo\r\n"
+	if got := readOutput(t, f.Master(), len(want)); got != want {
+		t.Errorf("echo = %q, want %q", got, want)
+	}
+}
+
+func TestFakeTTY_ReadsOneLineAtATime(t *testing.T) {
+	f := NewFakeTTY(FakeOptions{})
+	f.Master().Write([]byte("one\ntwo\n"))
+
+	buf := make([]byte, 64)
+	n, _ := f.Slave().Read(buf)
+	if string(buf[:n]) != "one\n" {
+		t.Errorf("first read = %q", buf[:n])
+	}
+	n, _ = f.Slave().Read(buf)
+	if string(buf[:n]) != "two\n" {
+		t.Errorf("second read = %q", buf[:n])
+	}
+}
+
+func TestFakeTTY_KillAndEOF(t *testing.T) {
+	f := NewFakeTTY(FakeOptions{})
+	f.SetTermios(func() Termios { tt := DefaultTermios(); tt.Echo = false; return tt }())
+	f.Master().Write([]byte("junk\x15partial\x04\x04"))
+
+	buf := make([]byte, 64)
+	n, err := f.Slave().Read(buf)
+	if err != nil || string(buf[:n]) != "partial" {
+		t.Errorf("read = %q, %v; want %q", buf[:n], err, "partial")
+	}
+	if n, err = f.Slave().Read(buf); n != 0 || err != io.EOF {
+		t.Errorf("read on empty line after VEOF = %d, %v; want EOF", n, err)
+	}
+}
+
+func TestFakeTTY_RawMode(t *testing.T) {
+	f := NewFakeTTY(FakeOptions{})
+	f.SetTermios(DefaultTermios().Raw())
+	f.Master().Write([]byte("a\r\x03\x7f"))
+
+	buf := make([]byte, 64)
+	n, err := f.Slave().Read(buf)
+	if err != nil || string(buf[:n]) != "a\r\x03\x7f" {
+		t.Errorf("raw read = %q, %v", buf[:n], err)
+	}
+
+	// No ONLCR in raw mode
+	f.Slave().Write([]byte("x\n"))
+	if got := readOutput(t, f.Master(), 2); got != "x\n" {
+		t.Errorf("raw output = %q", got)
+	}
+}
+
+func TestFakeTTY_LeavingCanonicalReleasesLine(t *testing.T) {
+	f := NewFakeTTY(FakeOptions{})
+	f.Master().Write([]byte("ab"))
+	f.SetTermios(DefaultTermios().Raw())
+
+	buf := make([]byte, 8)
+	n, _ := f.Slave().Read(buf)
+	if string(buf[:n]) != "ab" {
+		t.Errorf("read = %q, want %q", buf[:n], "ab")
+	}
+}
+
+func TestFakeTTY_Signals(t *testing.T) {
+	var mu sync.Mutex
+	var got []Signal
+	f := NewFakeTTY(FakeOptions{OnSignal: func(s Signal) {
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+	}})
+
+	f.Master().Write([]byte("discard\x03"))
+	f.Resize(30, 100)
+	f.Master().Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Signal{SignalInterrupt, SignalWinch, SignalHangup}
+	if len(got) != len(want) {
+		t.Fatalf("signals = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("signal %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if rows, cols := f.Winsize(); rows != 30 || cols != 100 {
+		t.Errorf("Winsize() = %dx%d, want 30x100", rows, cols)
+	}
+
+	// VINTR flushed the pending line and the hangup ends input
+	if n, err := f.Slave().Read(make([]byte, 8)); n != 0 || err != io.EOF {
+		t.Errorf("read after hangup = %d, %v; want EOF", n, err)
+	}
+	if _, err := f.Slave().Write([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after hangup = %v, want ErrClosed", err)
+	}
+}
+
+func TestFakeTTY_Attach(t *testing.T) {
+	term := headlessterm.New(headlessterm.WithSize(5, 20))
+	f := NewFakeTTY(FakeOptions{})
+	done := f.Attach(term)
+
+	if rows, cols := f.Winsize(); rows != 5 || cols != 20 {
+		t.Errorf("Winsize() = %dx%d, want 5x20", rows, cols)
+	}
+
+	// A cursor position request is answered through the line discipline
+	f.SetTermios(DefaultTermios().Raw())
+	f.Slave().Write([]byte("one\r\n\x1b[6n"))
+	buf := make([]byte, 16)
+	n, err := f.Slave().Read(buf)
+	if err != nil || string(buf[:n]) != "\x1b[2;1R" {
+		t.Errorf("response = %q, %v", buf[:n], err)
+	}
+
+	f.SetTermios(DefaultTermios())
+	f.Slave().Write([]byte("two\nthree"))
+	f.Slave().Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("output not drained")
+	}
+	if got := term.String(); got != "one\ntwo\nthree" {
+		t.Errorf("screen = %q", got)
+	}
+
+	f.Resize(8, 30)
+	if term.Rows() != 8 || term.Cols() != 30 {
+		t.Errorf("terminal size = %dx%d, want 8x30", term.Rows(), term.Cols())
+	}
+}
@@ -0,0 +1,376 @@
+// Package pty runs a process on a pseudo-terminal and connects it to a
+// headlessterm.Terminal.
+//
+// Spawn opens a PTY, starts the command as a session leader with the PTY as
+// its controlling terminal, copies its output into Terminal.Write, routes
+// terminal responses (DSR, DA, ...) back to the process, and keeps the kernel
+// window size in sync with the terminal.
+//
+// Only Linux is supported; on other platforms Spawn returns ErrUnsupported.
+// FakeTTY is a portable in-process alternative for tests that cannot
+// allocate a PTY.
+//
+// Example:
+//
+//	term := headlessterm.New(headlessterm.WithSize(24, 80))
+//	s, err := pty.Spawn(exec.Command("bash"), term, pty.Options{})
+//	if err != nil {
+//	    return err
+//	}
+//	defer s.Close()
+//	s.WriteString("ls\r")
+package pty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// ErrUnsupported is returned by Spawn on platforms without PTY support.
+var ErrUnsupported = errors.New("pty: unsupported platform")
+
+// ErrClosed is returned when writing to or resizing a closed session.
+var ErrClosed = errors.New("pty: session closed")
+
+const (
+	// DefaultTerm is the TERM value set for spawned processes.
+	DefaultTerm = "xterm-256color"
+	// DefaultDrainTimeout is how long output is read after the process exits.
+	DefaultDrainTimeout = 500 * time.Millisecond
+	// DefaultKillTimeout is how long Close waits after SIGHUP before SIGKILL.
+	DefaultKillTimeout = 2 * time.Second
+
+	// maxPendingResponses bounds the terminal responses queued for a process
+	// that does not read its input. Responses beyond it are dropped.
+	maxPendingResponses = 64 * 1024
+)
+
+// errResponsesFull is returned to the terminal when a response is dropped.
+var errResponsesFull = errors.New("pty: too many pending terminal responses")
+
+// Options configures Spawn.
+type Options struct {
+	// Term is the TERM environment variable, set unless cmd.Env already has one
+	// (default: DefaultTerm).
+	Term string
+	// DrainTimeout bounds how long output is still read after the process
+	// exited, for example while background children keep the PTY open
+	// (default: DefaultDrainTimeout).
+	DrainTimeout time.Duration
+	// KillTimeout is how long Close waits for the process group to exit after
+	// SIGHUP before sending SIGKILL (default: DefaultKillTimeout).
+	KillTimeout time.Duration
+	// ReadBufferSize is the size of reads from the PTY (default: 32 KiB).
+	ReadBufferSize int
+	// KeepPTYWriter leaves the terminal's PTYWriter untouched instead of
+	// routing terminal responses to the process.
+	KeepPTYWriter bool
+}
+
+// Session is a process running on a PTY connected to a Terminal.
+// All methods are safe for concurrent use.
+type Session struct {
+	cmd    *exec.Cmd
+	term   *headlessterm.Terminal
+	master *os.File
+	opts   Options
+
+	writeMu sync.Mutex  // Serializes writes to the process
+	closed  atomic.Bool // Set before Close closes the PTY
+
+	responses *responseQueue
+
+	readDone chan struct{} // Output reader finished
+	done     chan struct{} // Process exited and output drained
+	waitErr  error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Spawn starts cmd on a new PTY sized like term and pipes its output into term.
+// cmd must not have been started; its Stdin, Stdout and Stderr are replaced by
+// the PTY. Terminal responses are routed to the process unless
+// Options.KeepPTYWriter is set.
+func Spawn(cmd *exec.Cmd, term *headlessterm.Terminal, opts Options) (*Session, error) {
+	if opts.Term == "" {
+		opts.Term = DefaultTerm
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	if opts.KillTimeout <= 0 {
+		opts.KillTimeout = DefaultKillTimeout
+	}
+	if opts.ReadBufferSize <= 0 {
+		opts.ReadBufferSize = 32 * 1024
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if err := setWinsize(master, term.Rows(), term.Cols()); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if !hasEnv(cmd.Env, "TERM") {
+		cmd.Env = append(cmd.Env, "TERM="+opts.Term)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	configureCommand(cmd)
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("pty: start: %w", err)
+	}
+	// The child holds its own copy; closing ours lets reads fail with EIO once
+	// every process using the PTY has exited
+	slave.Close()
+
+	s := &Session{
+		cmd:      cmd,
+		term:     term,
+		master:   master,
+		opts:     opts,
+		readDone: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if !opts.KeepPTYWriter {
+		s.responses = &responseQueue{ready: make(chan struct{}, 1)}
+		term.SetPTYWriter(s.responses)
+		go s.responseLoop()
+	}
+
+	go s.readLoop()
+	go s.waitLoop()
+	return s, nil
+}
+
+// hasEnv reports whether env sets key.
+func hasEnv(env []string, key string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// readLoop copies PTY output into the terminal until the PTY is closed.
+func (s *Session) readLoop() {
+	defer close(s.readDone)
+	buf := make([]byte, s.opts.ReadBufferSize)
+	for {
+		n, err := s.master.Read(buf)
+		if n > 0 {
+			s.term.Write(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// responseLoop writes queued terminal responses to the process. Responses
+// are produced while the read loop feeds output to the terminal, and writing
+// them there would stop reading output whenever the process stops reading input.
+func (s *Session) responseLoop() {
+	for {
+		select {
+		case <-s.responses.ready:
+			if _, err := s.Write(s.responses.take()); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// responseQueue is the terminal's PTYWriter. It holds responses until
+// responseLoop writes them, so the terminal never waits for the process.
+type responseQueue struct {
+	mu      sync.Mutex
+	pending []byte
+	ready   chan struct{} // Signalled when pending becomes non-empty
+}
+
+// Write queues p, or drops it if too much is pending already.
+func (q *responseQueue) Write(p []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending)+len(p) > maxPendingResponses {
+		return 0, errResponsesFull
+	}
+	q.pending = append(q.pending, p...)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// take returns and clears the pending responses.
+func (q *responseQueue) take() []byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p := q.pending
+	q.pending = nil
+	return p
+}
+
+// waitLoop reaps the process and then drains its remaining output.
+func (s *Session) waitLoop() {
+	s.waitErr = s.cmd.Wait()
+
+	select {
+	case <-s.readDone:
+	case <-time.After(s.opts.DrainTimeout):
+		// Background children still hold the PTY; stop reading
+		s.master.SetReadDeadline(time.Now())
+		<-s.readDone
+	}
+	close(s.done)
+}
+
+// Write sends input to the process, e.g. keystrokes or pasted text.
+// It blocks while the process does not read its input, until Close.
+func (s *Session) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+	select {
+	case <-s.done:
+		return 0, ErrClosed
+	default:
+	}
+	n, err := s.master.Write(p)
+	if errors.Is(err, os.ErrClosed) {
+		err = ErrClosed
+	}
+	return n, err
+}
+
+// WriteString sends a string to the process.
+func (s *Session) WriteString(str string) (int, error) {
+	return s.Write([]byte(str))
+}
+
+// Resize resizes the terminal and the PTY; the kernel sends SIGWINCH to the
+// foreground process group.
+func (s *Session) Resize(rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("pty: invalid size %dx%d", rows, cols)
+	}
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+	s.term.Resize(rows, cols)
+	return setWinsize(s.master, rows, cols)
+}
+
+// Pid returns the process ID of the spawned process, which also leads its
+// session and process group.
+func (s *Session) Pid() int {
+	return s.cmd.Process.Pid
+}
+
+// Signal sends sig to the process group of the spawned process.
+func (s *Session) Signal(sig syscall.Signal) error {
+	return signalGroup(s.cmd.Process.Pid, sig)
+}
+
+// SignalForeground sends sig to the PTY's foreground process group, which is
+// the job a shell is currently running (like pressing Ctrl+C on a real terminal).
+func (s *Session) SignalForeground(sig syscall.Signal) error {
+	pgrp, err := foregroundGroup(s.master)
+	if err != nil {
+		return err
+	}
+	return signalGroup(pgrp, sig)
+}
+
+// Done returns a channel closed when the process has exited and its output
+// has been drained into the terminal.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the process has exited and its output has been drained,
+// and returns the error from exec.Cmd.Wait (an *exec.ExitError for a
+// non-zero exit status or a signal).
+func (s *Session) Wait() error {
+	<-s.done
+	return s.waitErr
+}
+
+// ExitCode returns the process exit code, -1 if it was killed by a signal or
+// has not exited yet.
+func (s *Session) ExitCode() int {
+	select {
+	case <-s.done:
+		return s.cmd.ProcessState.ExitCode()
+	default:
+		return -1
+	}
+}
+
+// ProcessState returns the state of the exited process, or nil while running.
+func (s *Session) ProcessState() *os.ProcessState {
+	select {
+	case <-s.done:
+		return s.cmd.ProcessState
+	default:
+		return nil
+	}
+}
+
+// Close shuts the session down: it sends SIGHUP to the process group (and
+// SIGKILL after Options.KillTimeout), waits for the process to exit, drains
+// pending output into the terminal and closes the PTY. A Write blocked on
+// the process fails with ErrClosed.
+// The terminal's PTYWriter is reset if it still points to this session.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		select {
+		case <-s.done:
+		default:
+			s.Signal(hangupSignal)
+			select {
+			case <-s.done:
+			case <-time.After(s.opts.KillTimeout):
+				s.Signal(syscall.SIGKILL)
+				<-s.done
+			}
+		}
+
+		if w, ok := s.term.PTYWriter().(*responseQueue); ok && w == s.responses {
+			s.term.SetPTYWriter(headlessterm.NoopPTYWriter{})
+		}
+
+		// Closing the PTY wakes a blocked Write, so don't wait for writeMu
+		s.closed.Store(true)
+		s.closeErr = s.master.Close()
+	})
+	return s.closeErr
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package pty
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// hangupSignal is sent to the process group when a session is closed.
+const hangupSignal = syscall.SIGHUP
+
+// winsize mirrors struct winsize from <sys/ioctl.h>.
+type winsize struct {
+	Row, Col       uint16
+	Xpixel, Ypixel uint16
+}
+
+// ioctl runs an ioctl on f without switching it to blocking mode.
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openPTY opens a new PTY pair.
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pty: open /dev/ptmx: %w", err)
+	}
+
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("pty: unlock: %w", err)
+	}
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("pty: get number: %w", err)
+	}
+
+	name := "/dev/pts/" + strconv.Itoa(int(n))
+	slave, err = os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("pty: open %s: %w", name, err)
+	}
+	return master, slave, nil
+}
+
+// setWinsize sets the PTY window size in cells.
+func setWinsize(f *os.File, rows, cols int) error {
+	ws := winsize{Row: uint16(rows), Col: uint16(cols)}
+	if err := ioctl(f, syscall.TIOCSWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return fmt.Errorf("pty: set window size: %w", err)
+	}
+	return nil
+}
+
+// foregroundGroup returns the foreground process group of the PTY.
+func foregroundGroup(f *os.File) (int, error) {
+	var pgrp int32
+	if err := ioctl(f, syscall.TIOCGPGRP, unsafe.Pointer(&pgrp)); err != nil {
+		return 0, fmt.Errorf("pty: get foreground group: %w", err)
+	}
+	return int(pgrp), nil
+}
+
+// configureCommand makes the process a session leader with the PTY (its
+// stdin) as controlling terminal.
+func configureCommand(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// signalGroup sends sig to the process group pgid.
+func signalGroup(pgid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pgid, sig); err != nil {
+		return fmt.Errorf("pty: signal %v: %w", sig, err)
+	}
+	return nil
+}
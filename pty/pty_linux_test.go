@@ -0,0 +1,190 @@
+//go:build linux
+
+package pty
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	headlessterm "github.com/danielgatis/go-headless-term"
+)
+
+// spawn starts a shell command on a new terminal and closes it at the end of the test.
+func spawn(t *testing.T, script string, opts Options, termOpts ...headlessterm.Option) (*Session, *headlessterm.Terminal) {
+	t.Helper()
+	term := headlessterm.New(append([]headlessterm.Option{headlessterm.WithSize(10, 40)}, termOpts...)...)
+	s, err := Spawn(exec.Command("sh", "-c", script), term, opts)
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, term
+}
+
+// waitFor waits for a terminal condition with a timeout.
+func waitFor(t *testing.T, term *headlessterm.Terminal, cond headlessterm.WaitCondition) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := term.WaitFor(ctx, cond); err != nil {
+		t.Fatalf("%v\nscreen:\n%s", err, term.String())
+	}
+}
+
+func TestSpawn_OutputAndExitCode(t *testing.T) {
+	term := headlessterm.New(headlessterm.WithSize(10, 40))
+	cmd := exec.Command("sh", "-c", `printf 'hello\n'; echo "$TERM"; exit 3`)
+	cmd.Env = []string{"PATH=/usr/bin:/bin"}
+	s, err := Spawn(cmd, term, Options{})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	defer s.Close()
+
+	err = s.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Wait() = %v, want exit error", err)
+	}
+	if s.ExitCode() != 3 {
+		t.Errorf("ExitCode() = %d, want 3", s.ExitCode())
+	}
+	if got := term.String(); got != "hello\n"+DefaultTerm {
+		t.Errorf("screen = %q", got)
+	}
+}
+
+func TestSpawn_DrainsOutput(t *testing.T) {
+	s, term := spawn(t, `seq 1 5000`, Options{}, headlessterm.WithScrollback(headlessterm.NewMemoryScrollback(10000)))
+	if err := s.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(term.String(), "5000") {
+		t.Errorf("last line missing:\n%s", term.String())
+	}
+	if term.ScrollbackLen() < 4990 {
+		t.Errorf("scrollback has %d lines", term.ScrollbackLen())
+	}
+}
+
+func TestSpawn_InputAndResponses(t *testing.T) {
+	// The cursor position report is answered by the terminal through the PTY
+	s, term := spawn(t, `stty raw -echo; printf '\033[6n'; r=$(head -c 6 | od -An -c | tr -d ' '); stty sane; echo; echo "reply:$r"; read line; echo "got:$line"`, Options{})
+
+	waitFor(t, term, headlessterm.WaitText(`reply:033[1;1R`))
+	s.WriteString("input\r")
+	waitFor(t, term, headlessterm.WaitText("got:input"))
+	if err := s.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpawn_Resize(t *testing.T) {
+	s, term := spawn(t, `stty size; read x; stty size`, Options{})
+	waitFor(t, term, headlessterm.WaitText("10 40"))
+
+	if err := s.Resize(20, 100); err != nil {
+		t.Fatal(err)
+	}
+	if term.Rows() != 20 || term.Cols() != 100 {
+		t.Errorf("terminal size = %dx%d, want 20x100", term.Rows(), term.Cols())
+	}
+	s.WriteString("\r")
+	waitFor(t, term, headlessterm.WaitText("20 100"))
+}
+
+func TestSpawn_Signal(t *testing.T) {
+	s, term := spawn(t, `echo ready; sleep 10`, Options{})
+	waitFor(t, term, headlessterm.WaitText("ready"))
+
+	if err := s.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit")
+	}
+	if status, ok := s.ProcessState().Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGTERM {
+		t.Errorf("process state = %v, want killed by SIGTERM", s.ProcessState())
+	}
+	if _, err := s.Write([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after exit = %v, want ErrClosed", err)
+	}
+}
+
+func TestSpawn_SignalForeground(t *testing.T) {
+	s, term := spawn(t, `trap 'echo interrupted; exit 0' INT; echo ready; while :; do sleep 0.05; done`, Options{})
+	waitFor(t, term, headlessterm.WaitText("ready"))
+
+	if err := s.SignalForeground(syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, term, headlessterm.WaitRegexp(regexp.MustCompile(`interrupted`)))
+	s.Wait()
+}
+
+func TestSession_CloseEscalates(t *testing.T) {
+	s, term := spawn(t, `trap '' HUP; echo ready; sleep 10`, Options{KillTimeout: 100 * time.Millisecond})
+	waitFor(t, term, headlessterm.WaitText("ready"))
+
+	start := time.Now()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Close took %v", elapsed)
+	}
+	if s.ProcessState() == nil {
+		t.Error("process not reaped")
+	}
+	if _, ok := term.PTYWriter().(headlessterm.NoopPTYWriter); !ok {
+		t.Error("PTYWriter still points to the closed session")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+func TestSession_CloseWhileWriteBlocked(t *testing.T) {
+	s, term := spawn(t, `stty raw -echo; echo ready; sleep 10`, Options{KillTimeout: 100 * time.Millisecond})
+	waitFor(t, term, headlessterm.WaitText("ready"))
+
+	// The process never reads, so the write blocks once the PTY buffer is full
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.Write(make([]byte, 1<<20))
+		written <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on a pending Write")
+	}
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Error("blocked Write succeeded after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write still blocked after Close")
+	}
+}
+
+func TestSpawn_ResponsesToProcessNotReading(t *testing.T) {
+	// Every request produces a response, but the process never reads them
+	s, term := spawn(t, `stty raw -echo; awk 'BEGIN { for (i = 0; i < 200000; i++) printf "\033[6n" }'; echo done; sleep 10`, Options{})
+
+	waitFor(t, term, headlessterm.WaitText("done"))
+	s.Close()
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// hangupSignal stands in for SIGHUP, which not every platform defines.
+// Sessions cannot start without PTY support, so it is never sent.
+const hangupSignal = syscall.SIGKILL
+
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, ErrUnsupported
+}
+
+func setWinsize(f *os.File, rows, cols int) error {
+	return ErrUnsupported
+}
+
+func foregroundGroup(f *os.File) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func configureCommand(cmd *exec.Cmd) {}
+
+func signalGroup(pgid int, sig syscall.Signal) error {
+	return ErrUnsupported
+}
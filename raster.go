@@ -0,0 +1,323 @@
+package headlessterm
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// RenderOptions configures RenderImage.
+type RenderOptions struct {
+	// Font used for text (default: DefaultFont()).
+	Font *BitmapFont
+	// CellWidth and CellHeight override the cell size in pixels
+	// (default: SizeProvider.CellSizePixels, 10x20 without a provider).
+	CellWidth, CellHeight int
+	// HideCursor omits the cursor.
+	HideCursor bool
+	// SelectionColor is the background of selected cells (default: #264f78).
+	SelectionColor color.Color
+}
+
+// defaultSelectionColor is the background of selected cells.
+var defaultSelectionColor = color.RGBA{0x26, 0x4f, 0x78, 0xff}
+
+// RenderImage rasterizes the screen with a bitmap font, without fonts from the
+// system or a GPU. Box-drawing and block characters are drawn procedurally,
+// bold and italic are synthesized, and image placements are composited by
+// z-index using the UV coordinates stored in each cell.
+// The result can be written with image/png.
+//
+// Example:
+//
+//	img := term.RenderImage(headlessterm.RenderOptions{})
+//	f, _ := os.Create("screen.png")
+//	png.Encode(f, img)
+func (t *Terminal) RenderImage(opts RenderOptions) *image.RGBA {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if opts.Font == nil {
+		opts.Font = DefaultFont()
+	}
+	if opts.CellWidth <= 0 || opts.CellHeight <= 0 {
+		opts.CellWidth, opts.CellHeight = t.getCellSizePixels()
+	}
+	if opts.SelectionColor == nil {
+		opts.SelectionColor = defaultSelectionColor
+	}
+
+	r := &rasterizer{
+		t:    t,
+		opts: opts,
+		img:  image.NewRGBA(image.Rect(0, 0, t.cols*opts.CellWidth, t.rows*opts.CellHeight)),
+		cw:   opts.CellWidth,
+		ch:   opts.CellHeight,
+	}
+	r.render()
+	return r.img
+}
+
+// rasterizer draws the active buffer into an RGBA image (the terminal lock must be held).
+type rasterizer struct {
+	t      *Terminal
+	opts   RenderOptions
+	img    *image.RGBA
+	cw, ch int
+}
+
+// rasterCellImage is an image slice referenced by a cell, collected for z-ordered compositing.
+type rasterCellImage struct {
+	row, col int
+	ref      *CellImage
+}
+
+func (r *rasterizer) render() {
+	t := r.t
+	r.fill(r.img.Rect, t.resolveColorLocked(nil, false), 1)
+
+	var images []rasterCellImage
+	sb := t.primaryBuffer.ScrollbackLen()
+	for row := 0; row < t.rows; row++ {
+		for col := 0; col < t.cols; col++ {
+			c := t.activeBuffer.Cell(row, col)
+			if c == nil {
+				continue
+			}
+			colors := t.resolveCellColorsLocked(c)
+			bg := colors.Bg
+			if t.isSelectedLocked(Position{Row: sb + row, Col: col}) {
+				bg = toRGBA(r.opts.SelectionColor)
+			} else if colors.DefaultBg {
+				continue
+			}
+			r.fill(r.cellRect(row, col, 1), bg, 1)
+		}
+		for col := 0; col < t.cols; col++ {
+			if c := t.activeBuffer.Cell(row, col); c != nil && c.Image != nil {
+				images = append(images, rasterCellImage{row: row, col: col, ref: c.Image})
+			}
+		}
+	}
+
+	sort.SliceStable(images, func(i, j int) bool { return images[i].ref.ZIndex < images[j].ref.ZIndex })
+	split := sort.Search(len(images), func(i int) bool { return images[i].ref.ZIndex >= 0 })
+
+	// Images below the text, then text, then images above it
+	for _, ci := range images[:split] {
+		r.drawCellImage(ci)
+	}
+	for row := 0; row < t.rows; row++ {
+		for col := 0; col < t.cols; col++ {
+			c := t.activeBuffer.Cell(row, col)
+			if c == nil || c.IsWideSpacer() {
+				continue
+			}
+			colors := t.resolveCellColorsLocked(c)
+			r.drawCell(row, col, c, colors.Fg, colors.Underline)
+		}
+	}
+	for _, ci := range images[split:] {
+		r.drawCellImage(ci)
+	}
+
+	if !r.opts.HideCursor && t.cursor.Visible {
+		r.drawCursor()
+	}
+}
+
+// cellRect returns the pixel rectangle of span cells starting at (row, col).
+func (r *rasterizer) cellRect(row, col, span int) image.Rectangle {
+	return image.Rect(col*r.cw, row*r.ch, (col+span)*r.cw, (row+1)*r.ch)
+}
+
+// drawCell draws the glyph and decorations of a cell.
+func (r *rasterizer) drawCell(row, col int, c *Cell, fg, ul color.RGBA) {
+	if c.HasFlag(CellFlagHidden) {
+		return
+	}
+	span := 1
+	if c.IsWide() {
+		span = 2
+	}
+	rect := r.cellRect(row, col, span)
+
+	ch := printableRune(c)
+	switch {
+	case isProceduralGlyph(ch):
+		for _, g := range boxGlyphRects(ch, float64(r.cw), float64(r.ch)) {
+			gr := image.Rect(
+				rect.Min.X+int(math.Round(g.X)), rect.Min.Y+int(math.Round(g.Y)),
+				rect.Min.X+int(math.Round(g.X+g.W)), rect.Min.Y+int(math.Round(g.Y+g.H)),
+			)
+			r.fill(gr, fg, g.Alpha)
+		}
+	case ch != ' ':
+		r.drawGlyph(rect, ch, fg, c.HasFlag(CellFlagBold), c.HasFlag(CellFlagItalic))
+	}
+
+	r.drawDecorations(rect, c.Flags, fg, ul)
+}
+
+// drawGlyph draws a font glyph centered in rect, scaled by the largest integer
+// factor that fits. Bold is synthesized by overstriking, italic by shearing.
+func (r *rasterizer) drawGlyph(rect image.Rectangle, ch rune, fg color.RGBA, bold, italic bool) {
+	font := r.opts.Font
+	g := font.lookup(ch)
+	if g == nil {
+		return
+	}
+	scale := max(1, min(r.cw/font.Width, r.ch/font.Height))
+	x0 := rect.Min.X + (rect.Dx()-g.width*scale)/2
+	y0 := rect.Min.Y + (rect.Dy()-font.Height*scale)/2
+
+	for gy := 0; gy < font.Height; gy++ {
+		shift := 0
+		if italic {
+			shift = (font.Height - 1 - gy) / 4 * scale
+		}
+		for gx := 0; gx < g.width; gx++ {
+			if !g.set(gx, gy) {
+				continue
+			}
+			px := image.Rect(x0+gx*scale+shift, y0+gy*scale, x0+(gx+1)*scale+shift, y0+(gy+1)*scale)
+			if bold {
+				px.Max.X += max(1, scale/2)
+			}
+			r.fill(px.Intersect(rect), fg, 1)
+		}
+	}
+}
+
+// drawDecorations draws underline styles and strikethrough.
+func (r *rasterizer) drawDecorations(rect image.Rectangle, flags CellFlags, fg, ul color.RGBA) {
+	thick := max(1, r.ch/16)
+	base := rect.Max.Y - thick*2
+	line := func(y int, c color.RGBA) {
+		r.fill(image.Rect(rect.Min.X, y, rect.Max.X, y+thick), c, 1)
+	}
+
+	switch {
+	case flags&CellFlagDoubleUnderline != 0:
+		line(base-thick, ul)
+		line(base+thick, ul)
+	case flags&CellFlagCurlyUnderline != 0:
+		amp := float64(thick)
+		period := float64(r.cw)
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			y := base + int(math.Round(amp*math.Sin(2*math.Pi*float64(x)/period)))
+			r.fill(image.Rect(x, y, x+1, y+thick), ul, 1)
+		}
+	case flags&CellFlagDottedUnderline != 0:
+		for x := rect.Min.X; x < rect.Max.X; x += thick * 2 {
+			r.fill(image.Rect(x, base, x+thick, base+thick), ul, 1)
+		}
+	case flags&CellFlagDashedUnderline != 0:
+		dash := max(2, r.cw/3)
+		for x := rect.Min.X; x < rect.Max.X; x += dash * 2 {
+			r.fill(image.Rect(x, base, min(x+dash, rect.Max.X), base+thick), ul, 1)
+		}
+	case flags&CellFlagUnderline != 0:
+		line(base, ul)
+	}
+
+	if flags&CellFlagStrike != 0 {
+		line(rect.Min.Y+rect.Dy()/2, fg)
+	}
+}
+
+// drawCursor draws the cursor according to its style.
+func (r *rasterizer) drawCursor() {
+	t := r.t
+	row, col := t.cursor.Row, min(t.cursor.Col, t.cols-1)
+	c := t.activeBuffer.Cell(row, col)
+	span := 1
+	if c != nil && c.IsWide() {
+		span = 2
+	}
+	rect := r.cellRect(row, col, span)
+	cursor := t.resolveColorLocked(&NamedColor{Name: NamedColorCursor}, true)
+	thick := max(1, r.cw/6)
+
+	switch t.cursor.Style {
+	case CursorStyleBlinkingUnderline, CursorStyleSteadyUnderline:
+		r.fill(image.Rect(rect.Min.X, rect.Max.Y-thick, rect.Max.X, rect.Max.Y), cursor, 1)
+	case CursorStyleBlinkingBar, CursorStyleSteadyBar:
+		r.fill(image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+thick, rect.Max.Y), cursor, 1)
+	default:
+		r.fill(rect, cursor, 1)
+		if c != nil {
+			// Redraw the cell inverted on top of the block
+			bg := t.resolveCellColorsLocked(c).Bg
+			r.drawCell(row, col, c, bg, bg)
+		}
+	}
+}
+
+// drawCellImage composites the image slice referenced by a cell, sampling the
+// placement's source region at the cell's UV coordinates.
+func (r *rasterizer) drawCellImage(ci rasterCellImage) {
+	m := r.t.images
+	p := m.Placement(ci.ref.PlacementID)
+	data := m.Image(ci.ref.ImageID)
+	if p == nil || data == nil || len(data.Data) < int(data.Width*data.Height*4) {
+		return
+	}
+
+	srcX, srcY, srcW, srcH := float64(p.SrcX), float64(p.SrcY), float64(p.SrcW), float64(p.SrcH)
+	if p.SrcW == 0 || p.SrcH == 0 {
+		srcX, srcY, srcW, srcH = 0, 0, float64(data.Width), float64(data.Height)
+	}
+
+	rect := r.cellRect(ci.row, ci.col, 1)
+	ref := ci.ref
+	for py := 0; py < rect.Dy(); py++ {
+		v := float64(ref.V0) + (float64(py)+0.5)/float64(rect.Dy())*float64(ref.V1-ref.V0)
+		sy := int(srcY + v*srcH)
+		if sy < 0 || sy >= int(data.Height) {
+			continue
+		}
+		for px := 0; px < rect.Dx(); px++ {
+			u := float64(ref.U0) + (float64(px)+0.5)/float64(rect.Dx())*float64(ref.U1-ref.U0)
+			sx := int(srcX + u*srcW)
+			if sx < 0 || sx >= int(data.Width) {
+				continue
+			}
+			i := (sy*int(data.Width) + sx) * 4
+			src := color.RGBA{data.Data[i], data.Data[i+1], data.Data[i+2], 255}
+			r.blend(rect.Min.X+px, rect.Min.Y+py, src, float64(data.Data[i+3])/255)
+		}
+	}
+}
+
+// fill blends a rectangle with color c at the given coverage.
+func (r *rasterizer) fill(rect image.Rectangle, c color.RGBA, alpha float64) {
+	rect = rect.Intersect(r.img.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r.blend(x, y, c, alpha)
+		}
+	}
+}
+
+// blend mixes c into the pixel at (x, y) with the given coverage.
+func (r *rasterizer) blend(x, y int, c color.RGBA, alpha float64) {
+	if alpha >= 1 {
+		r.img.SetRGBA(x, y, color.RGBA{c.R, c.G, c.B, 255})
+		return
+	}
+	if alpha <= 0 {
+		return
+	}
+	dst := r.img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-alpha) + float64(b)*alpha))
+	}
+	r.img.SetRGBA(x, y, color.RGBA{mix(dst.R, c.R), mix(dst.G, c.G), mix(dst.B, c.B), 255})
+}
+
+// toRGBA converts any color to opaque RGBA.
+func toRGBA(c color.Color) color.RGBA {
+	return resolveDefaultColor(c, false)
+}
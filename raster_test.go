@@ -0,0 +1,191 @@
+package headlessterm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// countColor counts the pixels of rect that have color c.
+func countColor(img *image.RGBA, rect image.Rectangle, c color.RGBA) int {
+	n := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if img.RGBAAt(x, y) == c {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRenderImage_Text(t *testing.T) {
+	term := New(WithSize(2, 4))
+	term.WriteString("A\x1b[41m \x1b[0m")
+
+	img := term.RenderImage(RenderOptions{CellWidth: 10, CellHeight: 20, HideCursor: true})
+	if got := img.Bounds(); got != image.Rect(0, 0, 40, 40) {
+		t.Fatalf("bounds = %v, want 40x40", got)
+	}
+
+	fg := resolveDefaultColor(nil, true)
+	bg := resolveDefaultColor(nil, false)
+	if n := countColor(img, image.Rect(0, 0, 10, 20), fg); n == 0 {
+		t.Error("glyph 'A' not drawn")
+	}
+	if n := countColor(img, image.Rect(0, 20, 40, 40), bg); n != 40*20 {
+		t.Errorf("empty row has %d background pixels, want %d", n, 40*20)
+	}
+	red := resolveDefaultColor(&IndexedColor{Index: 1}, false)
+	if n := countColor(img, image.Rect(10, 0, 20, 20), red); n != 10*20 {
+		t.Errorf("red cell has %d red pixels, want %d", n, 10*20)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+}
+
+func TestRenderImage_BoxDrawingAndCursor(t *testing.T) {
+	term := New(WithSize(1, 3))
+	term.WriteString("─█")
+
+	img := term.RenderImage(RenderOptions{CellWidth: 8, CellHeight: 16})
+	fg := resolveDefaultColor(nil, true)
+
+	// Horizontal line spans the full cell width at the vertical center
+	for x := 0; x < 8; x++ {
+		if img.RGBAAt(x, 8) != fg {
+			t.Fatalf("box line missing at x=%d", x)
+		}
+	}
+	if n := countColor(img, image.Rect(8, 0, 16, 16), fg); n != 8*16 {
+		t.Errorf("full block has %d fg pixels, want %d", n, 8*16)
+	}
+
+	cursor := resolveDefaultColor(&NamedColor{Name: NamedColorCursor}, true)
+	if n := countColor(img, image.Rect(16, 0, 24, 16), cursor); n != 8*16 {
+		t.Errorf("block cursor has %d pixels, want %d", n, 8*16)
+	}
+
+	term.WriteString("\x1b[6 q")
+	img = term.RenderImage(RenderOptions{CellWidth: 8, CellHeight: 16})
+	if n := countColor(img, image.Rect(16, 0, 24, 16), cursor); n != 16 {
+		t.Errorf("bar cursor has %d pixels, want 16", n)
+	}
+}
+
+func TestRenderImage_Selection(t *testing.T) {
+	term := New(WithSize(1, 4))
+	term.WriteString("ab")
+	term.SetSelection(Position{Row: 0, Col: 0}, Position{Row: 0, Col: 1})
+
+	sel := color.RGBA{1, 2, 3, 255}
+	img := term.RenderImage(RenderOptions{CellWidth: 8, CellHeight: 16, HideCursor: true, SelectionColor: sel})
+	if n := countColor(img, image.Rect(0, 0, 16, 16), sel); n == 0 {
+		t.Error("selection background not drawn")
+	}
+	if n := countColor(img, image.Rect(16, 0, 32, 16), sel); n != 0 {
+		t.Errorf("unselected cells have %d selection pixels", n)
+	}
+}
+
+func TestRenderImage_Image(t *testing.T) {
+	term := New(WithSize(4, 10))
+	red := bytes.Repeat([]byte{0xff, 0, 0, 0xff}, 4)
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;" + base64.StdEncoding.EncodeToString(red) + "\x1b\\")
+
+	img := term.RenderImage(RenderOptions{CellWidth: 10, CellHeight: 20, HideCursor: true})
+	if got := img.RGBAAt(2, 2); got != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("image pixel = %v, want red", got)
+	}
+}
+
+func TestLoadPSF(t *testing.T) {
+	f := DefaultFont()
+	if f.Width != 7 || f.Height != 13 {
+		t.Errorf("default font = %dx%d, want 7x13", f.Width, f.Height)
+	}
+	if !f.HasGlyph('A') || !f.HasGlyph('é') {
+		t.Error("default font missing common glyphs")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(defaultFontData)
+	zw.Close()
+	gz, err := LoadPSF(&buf)
+	if err != nil {
+		t.Fatalf("LoadPSF(gzip): %v", err)
+	}
+	if gz.Width != 7 || !gz.HasGlyph('A') {
+		t.Error("gzip font not loaded")
+	}
+
+	if _, err := LoadPSF(strings.NewReader("not a font")); err == nil {
+		t.Error("expected error for invalid data")
+	}
+}
+
+const testBDF = `STARTFONT 2.1
+FONT test
+SIZE 8 75 75
+FONTBOUNDINGBOX 4 4 0 0
+STARTPROPERTIES 1
+FONT_ASCENT 4
+ENDPROPERTIES
+CHARS 2
+STARTCHAR bar
+ENCODING 124
+DWIDTH 4 0
+BBX 1 4 1 0
+BITMAP
+80
+80
+80
+80
+ENDCHAR
+STARTCHAR wide
+ENCODING 20013
+DWIDTH 8 0
+BBX 8 1 0 0
+BITMAP
+FF
+ENDCHAR
+ENDFONT
+`
+
+func TestLoadBDF(t *testing.T) {
+	f, err := LoadBDF(strings.NewReader(testBDF))
+	if err != nil {
+		t.Fatalf("LoadBDF: %v", err)
+	}
+	if f.Width != 4 || f.Height != 4 {
+		t.Fatalf("size = %dx%d, want 4x4", f.Width, f.Height)
+	}
+
+	bar := f.lookup('|')
+	for y := 0; y < 4; y++ {
+		if !bar.set(1, y) || bar.set(0, y) {
+			t.Errorf("bar row %d misplaced", y)
+		}
+	}
+
+	wide := f.lookup('中')
+	if wide.width != 8 {
+		t.Errorf("wide glyph width = %d, want 8", wide.width)
+	}
+	if !wide.set(7, 3) || wide.set(7, 2) {
+		t.Error("wide glyph should occupy the bottom row")
+	}
+
+	if _, err := LoadBDF(strings.NewReader("STARTFONT 2.1\nENDFONT\n")); err == nil {
+		t.Error("expected error without FONTBOUNDINGBOX")
+	}
+}
@@ -0,0 +1,418 @@
+package headlessterm
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"unicode"
+)
+
+// IndexedScrollback wraps a ScrollbackProvider with a trigram index so
+// SearchScrollback and SearchRegexp only scan lines that can match.
+// The index is updated incrementally on Push and Pop and drops evicted lines
+// in step with the wrapped provider's MaxLines. Text is indexed case-folded,
+// so case-insensitive searches use it too. Trigrams spanning a soft wrap are
+// attributed to the continuation line, so matches across wraps are found.
+//
+// Like other providers it is driven by the terminal under its lock; do not
+// call Push or Pop while the terminal is in use.
+//
+// Example:
+//
+//	storage := headlessterm.NewIndexedScrollback(headlessterm.NewMemoryScrollback(1_000_000))
+//	term := headlessterm.New(headlessterm.WithScrollback(storage))
+type IndexedScrollback struct {
+	storage ScrollbackProvider
+
+	postings map[trigram][]uint64 // Ascending line sequence numbers per trigram
+	next     uint64               // Sequence number of the next pushed line
+	first    uint64               // Sequence number of the oldest stored line
+	stale    uint64               // Postings below this sequence number were compacted away
+	wrapped  []bool               // Wrap flags aligned with the newest stored lines
+}
+
+// trigram packs three runes into one key.
+type trigram uint64
+
+// indexCompactMin is the minimum number of evicted lines before postings
+// are compacted.
+const indexCompactMin = 1024
+
+// NewIndexedScrollback indexes lines stored in storage. Lines already in
+// storage are indexed immediately.
+func NewIndexedScrollback(storage ScrollbackProvider) *IndexedScrollback {
+	s := &IndexedScrollback{storage: storage, postings: make(map[trigram][]uint64)}
+	n := storage.Len()
+	for i := 0; i < n; i++ {
+		s.add(i, false)
+	}
+	return s
+}
+
+// Storage returns the wrapped provider.
+func (s *IndexedScrollback) Storage() ScrollbackProvider {
+	return s.storage
+}
+
+// Push appends a line that does not continue a soft wrap.
+func (s *IndexedScrollback) Push(line []Cell) {
+	s.pushWrapped(line, false)
+}
+
+// pushWrapped appends a line and records whether it wraps into the next one.
+// Buffers call it instead of Push.
+func (s *IndexedScrollback) pushWrapped(line []Cell, wrapped bool) {
+	s.storage.Push(line)
+	if n := s.storage.Len(); n > 0 {
+		s.add(n-1, wrapped)
+	}
+	s.evict()
+}
+
+// add indexes the stored line at index as the next sequence number.
+func (s *IndexedScrollback) add(index int, wrapped bool) {
+	seq := s.next
+	s.next++
+	for _, key := range s.lineTrigrams(index) {
+		s.postings[key] = append(s.postings[key], seq)
+	}
+	s.wrapped = append(s.wrapped, wrapped)
+}
+
+// Pop removes and returns the newest line, removing it from the index.
+func (s *IndexedScrollback) Pop() []Cell {
+	n := s.storage.Len()
+	if n == 0 {
+		return nil
+	}
+	// Compute the trigrams while the previous line is still stored
+	if len(s.wrapped) > 0 {
+		s.wrapped = s.wrapped[:len(s.wrapped)-1]
+	}
+	keys := s.lineTrigrams(n - 1)
+
+	line := s.storage.Pop()
+	s.next--
+	seq := s.next
+	for _, key := range keys {
+		list := s.postings[key]
+		if len(list) > 0 && list[len(list)-1] == seq {
+			list = list[:len(list)-1]
+		}
+		if len(list) == 0 {
+			delete(s.postings, key)
+		} else {
+			s.postings[key] = list
+		}
+	}
+	s.first = s.next - uint64(s.storage.Len())
+	return line
+}
+
+// lineTrigrams returns the distinct trigrams of the stored line at index,
+// including those spanning the wrap from the line before it.
+// s.wrapped must end with the flag of the line before it.
+func (s *IndexedScrollback) lineTrigrams(index int) []trigram {
+	text := indexText(s.storage.Line(index))
+	keys := trigramsOf(text)
+	if index > 0 && len(s.wrapped) > 0 && s.wrapped[len(s.wrapped)-1] {
+		prev := indexText(s.storage.Line(index - 1))
+		head := text[:min(2, len(text))]
+		keys = append(keys, trigramsOf(append(tail(prev, 2), head...))...)
+		if len(prev) > 0 && prev[len(prev)-1] == ' ' {
+			// Search drops a blank left by a wide character that wrapped
+			keys = append(keys, trigramsOf(append(tail(prev[:len(prev)-1], 2), head...))...)
+		}
+	}
+	return dedupeTrigrams(keys)
+}
+
+// Len returns the number of stored lines.
+func (s *IndexedScrollback) Len() int {
+	return s.storage.Len()
+}
+
+// Line returns the line at index, where 0 is the oldest line.
+func (s *IndexedScrollback) Line(index int) []Cell {
+	return s.storage.Line(index)
+}
+
+// Clear removes all lines and empties the index.
+func (s *IndexedScrollback) Clear() {
+	s.storage.Clear()
+	s.postings = make(map[trigram][]uint64)
+	s.wrapped = nil
+	s.first, s.stale = s.next, s.next
+}
+
+// SetMaxLines sets the capacity of the wrapped provider and drops evicted
+// lines from the index.
+func (s *IndexedScrollback) SetMaxLines(max int) {
+	s.storage.SetMaxLines(max)
+	s.evict()
+}
+
+// MaxLines returns the capacity of the wrapped provider.
+func (s *IndexedScrollback) MaxLines() int {
+	return s.storage.MaxLines()
+}
+
+// evict forgets lines the provider dropped. Postings are compacted once
+// enough lines were evicted, so the cost is amortized over pushes.
+func (s *IndexedScrollback) evict() {
+	n := s.storage.Len()
+	s.first = s.next - uint64(n)
+	if extra := len(s.wrapped) - n; extra > 0 {
+		s.wrapped = s.wrapped[extra:]
+	}
+	if s.first-s.stale < max(indexCompactMin, uint64(n)/2) {
+		return
+	}
+	for key, list := range s.postings {
+		i, _ := slices.BinarySearch(list, s.first)
+		if i == len(list) {
+			delete(s.postings, key)
+		} else if i > 0 {
+			s.postings[key] = slices.Clone(list[i:])
+		}
+	}
+	s.stale = s.first
+}
+
+// candidateLines returns the indexes (0 = oldest) of lines that contain all
+// of keys. Lines matching across a wrap are reported by their last row.
+func (s *IndexedScrollback) candidateLines(keys []trigram) []int {
+	if len(keys) == 0 {
+		return nil
+	}
+	lists := make([][]uint64, len(keys))
+	for i, key := range keys {
+		lists[i] = s.postings[key]
+		if len(lists[i]) == 0 {
+			return nil
+		}
+	}
+	slices.SortFunc(lists, func(a, b []uint64) int { return len(a) - len(b) })
+
+	var lines []int
+	for _, seq := range lists[0] {
+		if seq < s.first {
+			continue
+		}
+		found := true
+		for _, other := range lists[1:] {
+			if _, ok := slices.BinarySearch(other, seq); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			lines = append(lines, int(seq-s.first))
+		}
+	}
+	return lines
+}
+
+// rarest returns the required trigram with the fewest postings, or false if
+// keys is empty.
+func (s *IndexedScrollback) rarest(keys []trigram) (trigram, bool) {
+	if len(keys) == 0 {
+		return 0, false
+	}
+	best := keys[0]
+	for _, key := range keys[1:] {
+		if len(s.postings[key]) < len(s.postings[best]) {
+			best = key
+		}
+	}
+	return best, true
+}
+
+// nextCandidate returns the index of the first line at or after index that
+// contains key, or -1.
+func (s *IndexedScrollback) nextCandidate(key trigram, index int) int {
+	list := s.postings[key]
+	i, _ := slices.BinarySearch(list, s.first+uint64(max(index, 0)))
+	if i == len(list) {
+		return -1
+	}
+	return int(list[i] - s.first)
+}
+
+// prevCandidate returns the index of the last line at or before index that
+// contains key, or -1.
+func (s *IndexedScrollback) prevCandidate(key trigram, index int) int {
+	if index < 0 {
+		return -1
+	}
+	list := s.postings[key]
+	i, found := slices.BinarySearch(list, s.first+uint64(index))
+	if !found {
+		i--
+	}
+	if i < 0 || list[i] < s.first {
+		return -1
+	}
+	return int(list[i] - s.first)
+}
+
+// scrollbackCandidatesLocked returns the scrollback lines that may contain
+// all of keys, or false if the scrollback is not indexed or keys is empty
+// (caller must hold lock).
+func (t *Terminal) scrollbackCandidatesLocked(keys []trigram) ([]int, bool) {
+	ix, ok := t.primaryBuffer.ScrollbackProvider().(*IndexedScrollback)
+	if !ok || len(keys) == 0 {
+		return nil, false
+	}
+	return ix.candidateLines(keys), true
+}
+
+// nextSearchRowLocked returns the absolute row after a logical line that
+// ended at row (or before one that started at row, going backward), skipping
+// indexed scrollback lines without key (caller must hold lock).
+func (t *Terminal) nextSearchRowLocked(row int, backward bool, key trigram, indexed bool) int {
+	sb := t.primaryBuffer.ScrollbackLen()
+	ix, ok := t.primaryBuffer.ScrollbackProvider().(*IndexedScrollback)
+	if backward {
+		row--
+		if indexed && ok && row < sb {
+			row = ix.prevCandidate(key, row)
+		}
+		return row
+	}
+	row++
+	if indexed && ok && row < sb {
+		if row = ix.nextCandidate(key, row); row < 0 {
+			row = sb
+		}
+	}
+	return row
+}
+
+// indexText returns the case-folded runes of a line as search sees them.
+func indexText(line []Cell) []rune {
+	text := make([]rune, 0, len(line))
+	for i := range line {
+		if line[i].IsWideSpacer() {
+			continue
+		}
+		ch := line[i].Char
+		if ch == 0 {
+			ch = ' '
+		}
+		text = append(text, foldRune(ch))
+	}
+	return text
+}
+
+// foldRune maps all runes that are equal under simple case folding to the
+// same rune, as regexp does for case-insensitive matching.
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folded = min(folded, f)
+	}
+	return folded
+}
+
+func tail(text []rune, n int) []rune {
+	return slices.Clone(text[max(len(text)-n, 0):])
+}
+
+func trigramsOf(text []rune) []trigram {
+	if len(text) < 3 {
+		return nil
+	}
+	keys := make([]trigram, 0, len(text)-2)
+	for i := 0; i+3 <= len(text); i++ {
+		keys = append(keys, trigram(text[i])<<42|trigram(text[i+1])<<21|trigram(text[i+2]))
+	}
+	return keys
+}
+
+func dedupeTrigrams(keys []trigram) []trigram {
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// literalTrigrams returns trigrams that every line containing s has.
+func literalTrigrams(s string) []trigram {
+	text := []rune(s)
+	for i, r := range text {
+		text[i] = foldRune(r)
+	}
+	return dedupeTrigrams(trigramsOf(text))
+}
+
+// regexpTrigrams returns trigrams that every match of re contains, derived
+// from the literal strings the pattern requires. It returns nil when the
+// pattern requires no literal of three runes or more.
+func regexpTrigrams(re *regexp.Regexp) []trigram {
+	tree, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	var keys []trigram
+	for _, lit := range requiredLiterals(tree.Simplify()) {
+		keys = append(keys, literalTrigrams(lit)...)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return dedupeTrigrams(keys)
+}
+
+// requiredLiterals returns literal strings that every match of re contains.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literal parts form longer required runs
+		var lits []string
+		var run []rune
+		for _, sub := range re.Sub {
+			if r, ok := exactLiteral(sub); ok {
+				run = append(run, r...)
+				continue
+			}
+			if len(run) > 0 {
+				lits = append(lits, string(run))
+				run = nil
+			}
+			lits = append(lits, requiredLiterals(sub)...)
+		}
+		if len(run) > 0 {
+			lits = append(lits, string(run))
+		}
+		return lits
+	}
+	return nil
+}
+
+// exactLiteral returns the runes re matches if it matches exactly one string.
+func exactLiteral(re *syntax.Regexp) ([]rune, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return re.Rune, true
+	case syntax.OpCapture:
+		return exactLiteral(re.Sub[0])
+	case syntax.OpConcat:
+		var out []rune
+		for _, sub := range re.Sub {
+			r, ok := exactLiteral(sub)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, r...)
+		}
+		return out, true
+	}
+	return nil, false
+}
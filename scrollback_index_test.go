@@ -0,0 +1,182 @@
+package headlessterm
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func cellsOf(s string) []Cell {
+	line := make([]Cell, 0, len(s))
+	for _, r := range s {
+		c := NewCell()
+		c.Char = r
+		line = append(line, c)
+	}
+	return line
+}
+
+func stringOf(line []Cell) string {
+	var b strings.Builder
+	for _, c := range line {
+		b.WriteRune(c.Char)
+	}
+	return b.String()
+}
+
+func TestIndexedScrollback_Candidates(t *testing.T) {
+	ix := NewIndexedScrollback(NewMemoryScrollback(100))
+	for _, s := range []string{"alpha beta", "gamma", "BETA delta", "beta"} {
+		ix.Push(cellsOf(s))
+	}
+
+	if got := ix.candidateLines(literalTrigrams("beta")); !slices.Equal(got, []int{0, 2, 3}) {
+		t.Errorf("beta candidates = %v", got)
+	}
+	if got := ix.candidateLines(literalTrigrams("delta")); !slices.Equal(got, []int{2}) {
+		t.Errorf("delta candidates = %v", got)
+	}
+	if got := ix.candidateLines(literalTrigrams("omega")); len(got) != 0 {
+		t.Errorf("omega candidates = %v", got)
+	}
+
+	if line := ix.Pop(); stringOf(line) != "beta" {
+		t.Errorf("Pop = %q", stringOf(line))
+	}
+	if got := ix.candidateLines(literalTrigrams("beta")); !slices.Equal(got, []int{0, 2}) {
+		t.Errorf("beta candidates after Pop = %v", got)
+	}
+}
+
+func TestIndexedScrollback_Eviction(t *testing.T) {
+	ix := NewIndexedScrollback(NewMemoryScrollback(10))
+	n := indexCompactMin * 3
+	for i := 0; i < n; i++ {
+		ix.Push(cellsOf(fmt.Sprintf("line %05d", i)))
+	}
+
+	if got := ix.candidateLines(literalTrigrams("line")); len(got) != 10 || got[0] != 0 || got[9] != 9 {
+		t.Errorf("candidates = %v", got)
+	}
+	if got := ix.candidateLines(literalTrigrams(fmt.Sprintf("%05d", n-10))); !slices.Equal(got, []int{0}) {
+		t.Errorf("oldest line candidates = %v", got)
+	}
+	if got := ix.candidateLines(literalTrigrams("00000")); len(got) != 0 {
+		t.Errorf("evicted line candidates = %v", got)
+	}
+	// Compaction keeps postings proportional to the stored lines
+	if list := ix.postings[literalTrigrams("lin")[0]]; len(list) > indexCompactMin+10 {
+		t.Errorf("postings not compacted: %d entries", len(list))
+	}
+
+	ix.SetMaxLines(2)
+	if got := ix.candidateLines(literalTrigrams("line")); !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("candidates after SetMaxLines = %v", got)
+	}
+	ix.Clear()
+	if ix.Len() != 0 || len(ix.candidateLines(literalTrigrams("line"))) != 0 {
+		t.Errorf("index not cleared")
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`error: \w+`, []string{"error: "}},
+		{`(foo)bar\d+baz`, []string{"foobar", "baz"}},
+		{`a|b`, nil},
+		{`(abc)+x?`, []string{"abc"}},
+		{`(abc)*`, nil},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := requiredLiterals(re.Simplify())
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("requiredLiterals(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	if keys := regexpTrigrams(regexp.MustCompile(`ab.`)); keys != nil {
+		t.Errorf("short literal keys = %v", keys)
+	}
+}
+
+// newSearchTerms returns a terminal with an indexed scrollback and one
+// without, fed the same output.
+func newSearchTerms(rows, cols int, output string) (indexed, plain *Terminal) {
+	indexed = New(WithSize(rows, cols), WithScrollback(NewIndexedScrollback(NewMemoryScrollback(1000))))
+	plain = New(WithSize(rows, cols), WithScrollback(NewMemoryScrollback(1000)))
+	indexed.WriteString(output)
+	plain.WriteString(output)
+	return indexed, plain
+}
+
+func TestIndexedScrollback_SearchMatchesUnindexed(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 300; i++ {
+		switch {
+		case i%50 == 7:
+			fmt.Fprintf(&b, "ERROR: disk %d is full, retrying the operation now\r\n", i)
+		case i%30 == 0:
+			fmt.Fprintf(&b, "warning %d\r\n", i)
+		default:
+			fmt.Fprintf(&b, "ok %d\r\n", i)
+		}
+	}
+	indexed, plain := newSearchTerms(5, 20, b.String())
+
+	for _, pattern := range []string{`disk \d+ is full`, `retrying the operation`, `warning`, `ok 2\d\d`, `.`} {
+		for _, opts := range []SearchOptions{
+			{Origin: &Position{}},
+			{Origin: &Position{}, CaseInsensitive: true},
+			{Backward: true},
+		} {
+			got := collectMatches(indexed, pattern, opts)
+			want := collectMatches(plain, pattern, opts)
+			if !slices.Equal(got, want) {
+				t.Errorf("%q %+v: indexed %d matches, unindexed %d", pattern, opts, len(got), len(want))
+			}
+		}
+	}
+	if n := len(collectMatches(indexed, `error: disk`, SearchOptions{Origin: &Position{}, CaseInsensitive: true})); n != 6 {
+		t.Errorf("case-insensitive matches = %d, want 6", n)
+	}
+
+	for _, pattern := range []string{"warning", "full, ret", "ok 1"} {
+		if got, want := indexed.SearchScrollback(pattern), plain.SearchScrollback(pattern); !slices.Equal(got, want) {
+			t.Errorf("SearchScrollback(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestIndexedScrollback_AcrossWrapsAndResize(t *testing.T) {
+	indexed, plain := newSearchTerms(2, 6, "xxxx日本語text\r\nabcdefghij\r\ny\r\nz")
+
+	for _, pattern := range []string{`efgh`, `本語te`, `x日本`} {
+		got := collectMatches(indexed, pattern, SearchOptions{Origin: &Position{}})
+		want := collectMatches(plain, pattern, SearchOptions{Origin: &Position{}})
+		if len(want) != 1 || !slices.Equal(got, want) {
+			t.Errorf("%q: indexed %+v, unindexed %+v", pattern, got, want)
+		}
+	}
+
+	// Growing the screen pops lines back out of the index
+	indexed.Resize(6, 6)
+	plain.Resize(6, 6)
+	got := collectMatches(indexed, `efgh`, SearchOptions{Origin: &Position{}})
+	want := collectMatches(plain, `efgh`, SearchOptions{Origin: &Position{}})
+	if len(want) != 1 || !slices.Equal(got, want) {
+		t.Errorf("after resize: indexed %+v, unindexed %+v", got, want)
+	}
+	ix := indexed.ScrollbackProvider().(*IndexedScrollback)
+	if got := ix.candidateLines(literalTrigrams("efgh")); len(got) != 0 || ix.Len() != indexed.ScrollbackLen() {
+		t.Errorf("index after resize: candidates %v, %d lines", got, ix.Len())
+	}
+}
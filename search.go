@@ -0,0 +1,259 @@
+package headlessterm
+
+import (
+	"iter"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions configures SearchRegexp.
+type SearchOptions struct {
+	// CaseInsensitive matches letters regardless of case.
+	CaseInsensitive bool
+	// WholeWord only reports matches not preceded or followed by a letter,
+	// digit or underscore.
+	WholeWord bool
+	// Backward iterates from the origin towards the oldest scrollback line
+	// instead of towards the bottom of the screen.
+	Backward bool
+	// Origin is the absolute position the search starts from (default: the
+	// cursor). Forward searches report matches starting at or after Origin,
+	// backward searches report matches starting before it.
+	Origin *Position
+}
+
+// SearchMatch is a match range in absolute rows (see ViewportRowToAbsolute).
+// End is the last cell of the match, including the second cell of a wide
+// character, so a match may span several rows of a soft-wrapped line.
+type SearchMatch struct {
+	Start Position
+	End   Position
+	// Text is the matched text.
+	Text string
+}
+
+// SearchRegexp iterates over matches of re in the scrollback and the screen.
+// Rows that were soft-wrapped are joined into logical lines, so matches can
+// span row boundaries. Matches are produced lazily, one logical line at a
+// time, so callers can stop early without scanning all the scrollback.
+// Writes during iteration may shift rows; iterate again to resynchronize.
+// With an IndexedScrollback, scrollback lines lacking a literal the pattern
+// requires are skipped without being read.
+//
+// Example:
+//
+//	re := regexp.MustCompile(`error: \w+`)
+//	for m := range term.SearchRegexp(re, headlessterm.SearchOptions{Backward: true}) {
+//	    fmt.Println(m.Start.Row, m.Text) // the most recent error before the cursor
+//	    break
+//	}
+func (t *Terminal) SearchRegexp(re *regexp.Regexp, opts SearchOptions) iter.Seq[SearchMatch] {
+	if opts.CaseInsensitive {
+		re = regexp.MustCompile("(?i)" + re.String())
+	}
+
+	return func(yield func(SearchMatch) bool) {
+		t.mu.RLock()
+		origin := Position{Row: t.primaryBuffer.ScrollbackLen() + t.cursor.Row, Col: t.cursor.Col}
+		if opts.Origin != nil {
+			origin = *opts.Origin
+		}
+		// Origins outside the rows start at the nearest row
+		row := min(max(origin.Row, 0), t.primaryBuffer.ScrollbackLen()+t.rows-1)
+		line, ok := t.logicalLineLocked(row)
+		// Matching logical lines contain the rarest required trigram in some row
+		var key trigram
+		indexed := false
+		if ix, isIndexed := t.primaryBuffer.ScrollbackProvider().(*IndexedScrollback); isIndexed {
+			key, indexed = ix.rarest(regexpTrigrams(re))
+		}
+		t.mu.RUnlock()
+
+		for ok {
+			matches := line.matches(re, opts.WholeWord)
+			if opts.Backward {
+				for i := len(matches) - 1; i >= 0; i-- {
+					if matches[i].Start.Before(origin) && !yield(matches[i]) {
+						return
+					}
+				}
+			} else {
+				for _, m := range matches {
+					if !m.Start.Before(origin) && !yield(m) {
+						return
+					}
+				}
+			}
+
+			t.mu.RLock()
+			if opts.Backward {
+				line, ok = t.logicalLineLocked(t.nextSearchRowLocked(line.first, true, key, indexed))
+			} else {
+				line, ok = t.logicalLineLocked(t.nextSearchRowLocked(line.last, false, key, indexed))
+			}
+			t.mu.RUnlock()
+		}
+	}
+}
+
+// logicalLine is a run of soft-wrapped rows flattened into text.
+type logicalLine struct {
+	first, last int // Absolute rows
+	text        string
+	// For each rune of text: its byte offset, cell position and width
+	offsets []int
+	cells   []Position
+	widths  []int
+}
+
+// searchRowLocked returns the cells of an absolute row and whether it wraps
+// into the next row (caller must hold lock). Scrollback rows come from the
+// primary buffer; the newest one only continues into the screen when the
+// primary buffer is active.
+func (t *Terminal) searchRowLocked(abs int) ([]Cell, bool, bool) {
+	sb := t.primaryBuffer.ScrollbackLen()
+	switch {
+	case abs < 0 || abs >= sb+t.rows:
+		return nil, false, false
+	case abs < sb:
+		wrapped := t.primaryBuffer.ScrollbackWrapped(abs)
+		if abs == sb-1 && t.activeBuffer != t.primaryBuffer {
+			wrapped = false
+		}
+		return t.primaryBuffer.ScrollbackLine(abs), wrapped, true
+	default:
+		row := abs - sb
+		return t.activeBuffer.cells[row], t.activeBuffer.IsWrapped(row), true
+	}
+}
+
+// logicalLineLocked returns the logical line containing the absolute row
+// (caller must hold lock).
+func (t *Terminal) logicalLineLocked(abs int) (*logicalLine, bool) {
+	first, _, ok := t.logicalLineBoundsLocked(abs)
+	if !ok {
+		return nil, false
+	}
+
+	line := &logicalLine{first: first}
+	var text []byte
+	for row := first; ; row++ {
+		cells, wrapped, ok := t.searchRowLocked(row)
+		if !ok {
+			break
+		}
+		line.last = row
+
+		end := len(cells)
+		if wrapped {
+			// A wide character that did not fit leaves a blank last cell
+			if next, _, ok := t.searchRowLocked(row + 1); ok && len(next) > 0 && next[0].IsWide() &&
+				end > 0 && (cells[end-1].Char == ' ' || cells[end-1].Char == 0) {
+				end--
+			}
+		} else {
+			for end > 0 && (cells[end-1].Char == ' ' || cells[end-1].Char == 0) && !cells[end-1].IsWideSpacer() {
+				end--
+			}
+		}
+		for col := 0; col < end; col++ {
+			c := &cells[col]
+			if c.IsWideSpacer() {
+				continue
+			}
+			ch := c.Char
+			if ch == 0 {
+				ch = ' '
+			}
+			width := 1
+			if c.IsWide() {
+				width = 2
+			}
+			line.offsets = append(line.offsets, len(text))
+			line.cells = append(line.cells, Position{Row: row, Col: col})
+			line.widths = append(line.widths, width)
+			text = utf8.AppendRune(text, ch)
+		}
+		if !wrapped {
+			break
+		}
+	}
+	line.text = string(text)
+	return line, true
+}
+
+// logicalLineBoundsLocked returns the first and last absolute rows of the
+// logical line containing abs (caller must hold lock).
+func (t *Terminal) logicalLineBoundsLocked(abs int) (first, last int, ok bool) {
+	if _, _, ok := t.searchRowLocked(abs); !ok {
+		return 0, 0, false
+	}
+	first = abs
+	for {
+		_, wrapped, ok := t.searchRowLocked(first - 1)
+		if !ok || !wrapped {
+			break
+		}
+		first--
+	}
+	last = abs
+	for {
+		_, wrapped, ok := t.searchRowLocked(last)
+		if !ok || !wrapped {
+			break
+		}
+		if _, _, ok := t.searchRowLocked(last + 1); !ok {
+			break
+		}
+		last++
+	}
+	return first, last, true
+}
+
+// runeAt returns the index of the rune starting at byte offset off.
+func (l *logicalLine) runeAt(off int) int {
+	lo, hi := 0, len(l.offsets)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if l.offsets[mid] < off {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// matches returns the non-empty matches of re in reading order.
+func (l *logicalLine) matches(re *regexp.Regexp, wholeWord bool) []SearchMatch {
+	var matches []SearchMatch
+	for _, loc := range re.FindAllStringIndex(l.text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if wholeWord && !l.wordBoundary(loc[0], loc[1]) {
+			continue
+		}
+		first, last := l.runeAt(loc[0]), l.runeAt(loc[1])-1
+		end := l.cells[last]
+		end.Col += l.widths[last] - 1
+		matches = append(matches, SearchMatch{Start: l.cells[first], End: end, Text: l.text[loc[0]:loc[1]]})
+	}
+	return matches
+}
+
+// wordBoundary reports whether text[start:end] is delimited by non-word runes.
+func (l *logicalLine) wordBoundary(start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(l.text[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(l.text[end:]); end < len(l.text) && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
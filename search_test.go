@@ -0,0 +1,121 @@
+package headlessterm
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func collectMatches(t *Terminal, pattern string, opts SearchOptions) []SearchMatch {
+	return slices.Collect(t.SearchRegexp(regexp.MustCompile(pattern), opts))
+}
+
+func TestSearchRegexp_AcrossWraps(t *testing.T) {
+	term := New(WithSize(3, 10))
+	term.WriteString("hello world wide\r\nnext")
+
+	matches := collectMatches(term, `wor\w+`, SearchOptions{Origin: &Position{}})
+	want := []SearchMatch{{Start: Position{Row: 0, Col: 6}, End: Position{Row: 1, Col: 0}, Text: "world"}}
+	if !slices.Equal(matches, want) {
+		t.Errorf("matches = %+v, want %+v", matches, want)
+	}
+
+	// The unwrapped second line does not continue into the third
+	if matches := collectMatches(term, `wide\s*next`, SearchOptions{Origin: &Position{}}); len(matches) != 0 {
+		t.Errorf("matched across a hard newline: %+v", matches)
+	}
+}
+
+func TestSearchRegexp_ScrollbackAbsoluteRows(t *testing.T) {
+	term := New(WithSize(3, 20), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("line0\r\nline1\r\nline2\r\nline3\r\nline4")
+
+	matches := collectMatches(term, `line\d`, SearchOptions{Origin: &Position{}})
+	if len(matches) != 5 {
+		t.Fatalf("matches = %+v", matches)
+	}
+	for i, m := range matches {
+		if m.Start.Row != i || m.Text != "line"+string(rune('0'+i)) {
+			t.Errorf("match %d = %+v", i, m)
+		}
+	}
+	if row := term.ViewportRowToAbsolute(2); row != matches[4].Start.Row {
+		t.Errorf("screen row 2 is absolute %d, match reports %d", row, matches[4].Start.Row)
+	}
+}
+
+func TestSearchRegexp_FromCursor(t *testing.T) {
+	term := New(WithSize(4, 20), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("a1 a2\r\na3 a4\r\na5\x1b[2;3H")
+
+	forward := collectMatches(term, `a\d`, SearchOptions{})
+	if len(forward) != 2 || forward[0].Text != "a4" || forward[1].Text != "a5" {
+		t.Errorf("forward = %+v", forward)
+	}
+
+	var backward []string
+	for m := range term.SearchRegexp(regexp.MustCompile(`a\d`), SearchOptions{Backward: true}) {
+		backward = append(backward, m.Text)
+		if len(backward) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(backward, []string{"a3", "a2"}) {
+		t.Errorf("backward = %v", backward)
+	}
+}
+
+func TestSearchRegexp_CaseAndWholeWord(t *testing.T) {
+	term := New(WithSize(2, 30))
+	term.WriteString("Foo foobar FOO_x FOO")
+
+	origin := &Position{}
+	if n := len(collectMatches(term, `foo`, SearchOptions{Origin: origin})); n != 1 {
+		t.Errorf("case-sensitive matches = %d, want 1", n)
+	}
+	if n := len(collectMatches(term, `foo`, SearchOptions{Origin: origin, CaseInsensitive: true})); n != 4 {
+		t.Errorf("case-insensitive matches = %d, want 4", n)
+	}
+	matches := collectMatches(term, `foo`, SearchOptions{Origin: origin, CaseInsensitive: true, WholeWord: true})
+	if len(matches) != 2 || matches[0].Start.Col != 0 || matches[1].Start.Col != 17 {
+		t.Errorf("whole-word matches = %+v", matches)
+	}
+}
+
+func TestSearchRegexp_WideCharacters(t *testing.T) {
+	term := New(WithSize(3, 5))
+	// The third wide character does not fit in the first row and wraps
+	term.WriteString("日本語 text")
+
+	matches := collectMatches(term, `本語 t`, SearchOptions{Origin: &Position{}})
+	want := []SearchMatch{{Start: Position{Row: 0, Col: 2}, End: Position{Row: 1, Col: 3}, Text: "本語 t"}}
+	if !slices.Equal(matches, want) {
+		t.Errorf("matches = %+v, want %+v", matches, want)
+	}
+
+	matches = collectMatches(term, `語`, SearchOptions{Origin: &Position{}})
+	if len(matches) != 1 || matches[0].Start != (Position{Row: 1, Col: 0}) || matches[0].End != (Position{Row: 1, Col: 1}) {
+		t.Errorf("wide match = %+v", matches)
+	}
+}
+
+func TestSearchRegexp_WrapsIntoScrollback(t *testing.T) {
+	term := New(WithSize(2, 6), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("abcdefghij\r\nx\r\ny")
+
+	matches := collectMatches(term, `efgh`, SearchOptions{Origin: &Position{}})
+	want := []SearchMatch{{Start: Position{Row: 0, Col: 4}, End: Position{Row: 1, Col: 1}, Text: "efgh"}}
+	if !slices.Equal(matches, want) {
+		t.Errorf("matches = %+v, want %+v", matches, want)
+	}
+
+	// Growing the screen pulls the rows back with their wrap flags
+	term.Resize(4, 6)
+	if !term.IsWrapped(0) || term.IsWrapped(1) {
+		t.Errorf("wrap flags after resize: %v %v", term.IsWrapped(0), term.IsWrapped(1))
+	}
+	matches = collectMatches(term, `efgh`, SearchOptions{Origin: &Position{}})
+	if !slices.Equal(matches, want) {
+		t.Errorf("matches after resize = %+v, want %+v", matches, want)
+	}
+}
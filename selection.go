@@ -0,0 +1,536 @@
+package headlessterm
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// DefaultWordSeparators are the runes besides whitespace that end a word in
+// SelectWord mode.
+const DefaultWordSeparators = ",│`|:\"'()[]{}<>"
+
+// SelectionMode controls how the ends of a selection expand.
+type SelectionMode int
+
+const (
+	// SelectChar selects the cells between the two ends in reading order.
+	SelectChar SelectionMode = iota
+	// SelectWord expands both ends to whole words (see WithWordSeparators).
+	SelectWord
+	// SelectLine expands both ends to whole logical lines, following soft wraps.
+	SelectLine
+	// SelectBlock selects the rectangle spanned by the two ends.
+	SelectBlock
+	// SelectSemantic expands both ends to the URL, path or quoted string
+	// under them, else to the OSC 133 command output they are in, else to
+	// the word under them.
+	SelectSemantic
+)
+
+// String returns the mode name.
+func (m SelectionMode) String() string {
+	switch m {
+	case SelectChar:
+		return "char"
+	case SelectWord:
+		return "word"
+	case SelectLine:
+		return "line"
+	case SelectBlock:
+		return "block"
+	case SelectSemantic:
+		return "semantic"
+	}
+	return "unknown"
+}
+
+// Selection describes the active text selection.
+// Start and End are normalized so Start is always before or equal to End,
+// and End is inclusive. Rows are relative to the live screen, so rows in
+// the scrollback are negative, whether or not the viewport is scrolled
+// (see ViewportSelection).
+type Selection struct {
+	Start  Position
+	End    Position
+	Active bool
+	// Mode is the selection mode. For SelectBlock, Start and End are
+	// opposite corners of the rectangle.
+	Mode SelectionMode
+}
+
+// selectionState is a selection anchored to line IDs, so it follows its
+// text when lines scroll into the scrollback, are evicted from it, or the
+// screen is resized.
+type selectionState struct {
+	active bool
+	mode   SelectionMode
+	// Expanded ranges of the anchor (where the selection started) and the
+	// head (where it was extended to)
+	anchorStart, anchorEnd linePos
+	headStart, headEnd     linePos
+}
+
+// WithWordSeparators sets the runes besides whitespace that end a word when
+// selecting in SelectWord mode (default: DefaultWordSeparators).
+func WithWordSeparators(separators string) Option {
+	return func(t *Terminal) {
+		t.wordSeparators = separators
+	}
+}
+
+// SetWordSeparators sets the runes besides whitespace that end a word.
+func (t *Terminal) SetWordSeparators(separators string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.wordSeparators = separators
+}
+
+// WordSeparators returns the runes besides whitespace that end a word.
+func (t *Terminal) WordSeparators() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.wordSeparators
+}
+
+// SetSelection selects the cells from start to end in reading order.
+// Positions are relative to the live screen, ignoring the display offset;
+// negative rows address the scrollback. Start and end are automatically
+// normalized. Use SetViewportSelection for rows of the scrolled viewport.
+func (t *Terminal) SetSelection(start, end Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.selectRowsLocked(start, end, t.primaryBuffer.ScrollbackLen())
+}
+
+// SetViewportSelection is like SetSelection, with rows relative to the top
+// of the viewport (see ScrollViewport).
+func (t *Terminal) SetViewportSelection(start, end Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.selectRowsLocked(start, end, t.viewportTopLocked())
+}
+
+// selectRowsLocked selects from start to end, with rows relative to the
+// absolute row top (caller must hold lock).
+func (t *Terminal) selectRowsLocked(start, end Position, top int) {
+	start.Row += top
+	end.Row += top
+	t.startSelectionLocked(start, SelectChar)
+	t.extendSelectionLocked(end)
+}
+
+// StartSelection starts a selection at an absolute position (see
+// ViewportRowToAbsolute), like pressing the mouse button. Word, line and
+// semantic modes select the unit under pos immediately, like a double or
+// triple click.
+//
+// Example:
+//
+//	// Alt-drag from (2, 4) to (5, 10) on the screen
+//	term.StartSelection(headlessterm.Position{Row: term.ViewportRowToAbsolute(2), Col: 4}, headlessterm.SelectBlock)
+//	term.ExtendSelection(headlessterm.Position{Row: term.ViewportRowToAbsolute(5), Col: 10})
+//	text := term.GetSelectedText()
+func (t *Terminal) StartSelection(pos Position, mode SelectionMode) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startSelectionLocked(pos, mode)
+}
+
+// ExtendSelection moves the end of the selection that is not the anchor to
+// an absolute position, like dragging the mouse. It starts a character
+// selection if none is active.
+func (t *Terminal) ExtendSelection(pos Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.selection.active {
+		t.startSelectionLocked(pos, SelectChar)
+		return
+	}
+	t.extendSelectionLocked(pos)
+}
+
+// startSelectionLocked starts a selection at pos (caller must hold lock).
+func (t *Terminal) startSelectionLocked(pos Position, mode SelectionMode) {
+	start, end := t.expandSelectionLocked(pos, mode)
+	t.selection = selectionState{
+		active:      true,
+		mode:        mode,
+		anchorStart: t.linePosLocked(start),
+		anchorEnd:   t.linePosLocked(end),
+		headStart:   t.linePosLocked(start),
+		headEnd:     t.linePosLocked(end),
+	}
+}
+
+// extendSelectionLocked moves the head of the selection to pos (caller must hold lock).
+func (t *Terminal) extendSelectionLocked(pos Position) {
+	start, end := t.expandSelectionLocked(pos, t.selection.mode)
+	t.selection.headStart, t.selection.headEnd = t.linePosLocked(start), t.linePosLocked(end)
+}
+
+// ClearSelection deactivates the current selection.
+func (t *Terminal) ClearSelection() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.selection.active = false
+}
+
+// GetSelection returns the current selection with rows relative to the
+// live screen, ignoring the display offset.
+func (t *Terminal) GetSelection() Selection {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.getSelectionLocked(t.primaryBuffer.ScrollbackLen())
+}
+
+// ViewportSelection returns the current selection with rows relative to
+// the top of the viewport. Rows above the viewport are negative.
+func (t *Terminal) ViewportSelection() Selection {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.getSelectionLocked(t.viewportTopLocked())
+}
+
+// getSelectionLocked returns the current selection with rows relative to
+// the absolute row top (caller must hold lock).
+func (t *Terminal) getSelectionLocked(top int) Selection {
+	start, end, ok := t.selectionRangeLocked()
+	if !ok {
+		return Selection{Mode: t.selection.mode}
+	}
+	start.Row -= top
+	end.Row -= top
+	return Selection{Start: start, End: end, Active: true, Mode: t.selection.mode}
+}
+
+// SelectionRange returns the selected range in absolute rows, or false if
+// there is no selection. For SelectBlock, start and end are the top-left
+// and bottom-right corners.
+func (t *Terminal) SelectionRange() (start, end Position, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.selectionRangeLocked()
+}
+
+// selectionRangeLocked returns the selected range in absolute rows (caller
+// must hold lock). Evicted lines are cut from the selection; there is no
+// selection once all of its lines are evicted.
+func (t *Terminal) selectionRangeLocked() (start, end Position, ok bool) {
+	s := &t.selection
+	if !s.active {
+		return Position{}, Position{}, false
+	}
+	anchorStart, _ := t.absolutePosLocked(s.anchorStart)
+	anchorEnd, anchorOK := t.absolutePosLocked(s.anchorEnd)
+	headStart, _ := t.absolutePosLocked(s.headStart)
+	headEnd, headOK := t.absolutePosLocked(s.headEnd)
+	if !anchorOK && !headOK {
+		return Position{}, Position{}, false
+	}
+	if s.mode == SelectBlock {
+		start = Position{Row: min(anchorStart.Row, headStart.Row), Col: min(s.anchorStart.col, s.headStart.col)}
+		end = Position{Row: max(anchorEnd.Row, headEnd.Row), Col: max(s.anchorEnd.col, s.headEnd.col)}
+		return start, end, true
+	}
+	start, end = anchorStart, anchorEnd
+	if headStart.Before(start) {
+		start = headStart
+	}
+	if end.Before(headEnd) {
+		end = headEnd
+	}
+	return start, end, true
+}
+
+// setSelectionLocked restores a selection with rows relative to the
+// live screen without expanding it (caller must hold lock).
+func (t *Terminal) setSelectionLocked(sel Selection) {
+	sb := t.primaryBuffer.ScrollbackLen()
+	sel.Start.Row += sb
+	sel.End.Row += sb
+	t.selection = selectionState{
+		active:      sel.Active,
+		mode:        sel.Mode,
+		anchorStart: t.linePosLocked(sel.Start),
+		anchorEnd:   t.linePosLocked(sel.Start),
+		headStart:   t.linePosLocked(sel.End),
+		headEnd:     t.linePosLocked(sel.End),
+	}
+}
+
+// HasSelection returns true if a selection is currently active.
+func (t *Terminal) HasSelection() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, _, ok := t.selectionRangeLocked()
+	return ok
+}
+
+// IsSelected returns true if the cell at (row, col) is within the active selection.
+// Row is relative to the live screen, ignoring the display offset.
+func (t *Terminal) IsSelected(row, col int) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.isSelectedLocked(Position{Row: t.primaryBuffer.ScrollbackLen() + row, Col: col})
+}
+
+// IsSelectedInViewport is like IsSelected, with row relative to the top of
+// the viewport, as in ViewportCell.
+func (t *Terminal) IsSelectedInViewport(row, col int) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.isSelectedLocked(Position{Row: t.viewportTopLocked() + row, Col: col})
+}
+
+// isSelectedLocked reports whether an absolute position is within the
+// active selection (caller must hold lock).
+func (t *Terminal) isSelectedLocked(pos Position) bool {
+	start, end, ok := t.selectionRangeLocked()
+	if !ok {
+		return false
+	}
+
+	if t.selection.mode == SelectBlock {
+		return pos.Row >= start.Row && pos.Row <= end.Row && pos.Col >= start.Col && pos.Col <= end.Col
+	}
+	return !pos.Before(start) && !end.Before(pos)
+}
+
+// GetSelectedText returns the text of the active selection, including rows
+// in the scrollback. Soft-wrapped rows are joined, other rows end with a
+// newline, and blanks past the end of a line's text are trimmed. Empty cells
+// within a line are converted to spaces.
+func (t *Terminal) GetSelectedText() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	lines, ok := t.selectedLinesLocked()
+	if !ok {
+		return ""
+	}
+	return selectionText(lines)
+}
+
+// selectedLinesLocked returns copies of the selected cells, one slice per
+// line, joining soft-wrapped rows and trimming blanks past the end of each
+// line's text (caller must hold lock). In SelectBlock mode each row is a line.
+func (t *Terminal) selectedLinesLocked() ([][]Cell, bool) {
+	start, end, ok := t.selectionRangeLocked()
+	if !ok {
+		return nil, false
+	}
+	return t.rangeLinesLocked(start, end, t.selection.mode == SelectBlock), true
+}
+
+// rangeLinesLocked returns the cells between two absolute positions as
+// logical lines, joining soft wraps and trimming blanks past the end of the
+// text (caller must hold lock). A block range takes the same columns of
+// every row.
+func (t *Terminal) rangeLinesLocked(start, end Position, block bool) [][]Cell {
+	var lines [][]Cell
+	var line []Cell
+	for row := start.Row; row <= end.Row; row++ {
+		cells, wrapped, ok := t.searchRowLocked(row)
+		if !ok {
+			continue
+		}
+		from, to := 0, len(cells)-1
+		if block || row == start.Row {
+			from = max(start.Col, 0)
+		}
+		if block || row == end.Row {
+			to = min(end.Col, len(cells)-1)
+		}
+		if from <= to {
+			part := cells[from : to+1]
+			if (block || !wrapped) && blankFrom(cells, to+1) {
+				// The selection reaches past the end of the line's text
+				for len(part) > 0 && isBlankChar(&part[len(part)-1]) {
+					part = part[:len(part)-1]
+				}
+			}
+			line = append(line, part...)
+		}
+		if block || !wrapped || row == end.Row {
+			lines = append(lines, line)
+			line = nil
+		}
+	}
+	return lines
+}
+
+// cellsText returns the text of cells[from:to+1], converting empty cells to
+// spaces and skipping the second cells of wide characters.
+func cellsText(cells []Cell, from, to int) string {
+	var b strings.Builder
+	for col := max(from, 0); col <= to && col < len(cells); col++ {
+		c := &cells[col]
+		if c.IsWideSpacer() {
+			continue
+		}
+		if c.Char == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(c.Char)
+		}
+	}
+	return b.String()
+}
+
+// blankFrom reports whether cells from col to the end are all blank.
+func blankFrom(cells []Cell, col int) bool {
+	for col = max(col, 0); col < len(cells); col++ {
+		if !isBlankChar(&cells[col]) && !cells[col].IsWideSpacer() {
+			return false
+		}
+	}
+	return true
+}
+
+// isBlankChar reports whether a cell holds no character.
+func isBlankChar(c *Cell) bool {
+	return (c.Char == ' ' || c.Char == 0) && !c.IsWideSpacer()
+}
+
+// expandSelectionLocked returns the absolute range mode selects at pos
+// (caller must hold lock). Wide characters are always selected whole.
+func (t *Terminal) expandSelectionLocked(pos Position, mode SelectionMode) (Position, Position) {
+	switch mode {
+	case SelectWord, SelectLine, SelectSemantic:
+		line, ok := t.logicalLineLocked(pos.Row)
+		if !ok {
+			break
+		}
+		if mode == SelectLine {
+			return Position{Row: line.first}, Position{Row: line.last, Col: t.cols - 1}
+		}
+		i := line.runeIndexAt(pos)
+		if mode == SelectSemantic {
+			if start, end, ok := line.semanticToken(i); ok {
+				return start, end
+			}
+			if start, end, ok := t.commandOutputLocked(pos.Row); ok {
+				return start, end
+			}
+		}
+		if i < 0 {
+			break
+		}
+		lo, hi := line.word(i, t.wordSeparators)
+		return line.span(lo, hi)
+	}
+
+	start, end := pos, pos
+	if cells, _, ok := t.searchRowLocked(pos.Row); ok && pos.Col >= 0 && pos.Col < len(cells) {
+		if cells[pos.Col].IsWideSpacer() && pos.Col > 0 {
+			start.Col--
+		} else if cells[pos.Col].IsWide() {
+			end.Col++
+		}
+	}
+	if mode == SelectBlock {
+		// Block corners are single cells
+		return start, start
+	}
+	return start, end
+}
+
+// commandOutputLocked returns the rows of the OSC 133 command output
+// containing the absolute row (caller must hold lock). The output starts at
+// a CommandExecuted mark and ends before the next mark, or at the cursor
+// while the command runs.
+func (t *Terminal) commandOutputLocked(row int) (Position, Position, bool) {
+	marks := t.livePromptMarksLocked()
+	for i := len(marks) - 1; i >= 0; i-- {
+		mark := marks[i]
+		if mark.Type != ansicode.CommandExecuted || mark.Row > row {
+			continue
+		}
+		last := t.primaryBuffer.ScrollbackLen() + t.cursor.Row
+		if i+1 < len(marks) {
+			last = marks[i+1].Row - 1
+		}
+		if row > last {
+			return Position{}, Position{}, false
+		}
+		return Position{Row: mark.Row}, Position{Row: last, Col: t.cols - 1}, true
+	}
+	return Position{}, Position{}, false
+}
+
+// runeIndexAt returns the index of the rune covering pos, or -1 if pos is
+// past the end of the text.
+func (l *logicalLine) runeIndexAt(pos Position) int {
+	for i, cell := range l.cells {
+		if cell.Row == pos.Row && pos.Col >= cell.Col && pos.Col < cell.Col+l.widths[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// span returns the cell range of runes lo through hi.
+func (l *logicalLine) span(lo, hi int) (Position, Position) {
+	end := l.cells[hi]
+	end.Col += l.widths[hi] - 1
+	return l.cells[lo], end
+}
+
+// word returns the rune range of the word containing rune i. A separator
+// is a word by itself, and so is a run of whitespace.
+func (l *logicalLine) word(i int, separators string) (int, int) {
+	runes := []rune(l.text)
+	isSeparator := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(separators, r)
+	}
+	if isSeparator(runes[i]) && !unicode.IsSpace(runes[i]) {
+		return i, i
+	}
+	same := func(r rune) bool {
+		if unicode.IsSpace(runes[i]) {
+			return unicode.IsSpace(r)
+		}
+		return !isSeparator(r)
+	}
+	lo, hi := i, i
+	for lo > 0 && same(runes[lo-1]) {
+		lo--
+	}
+	for hi+1 < len(runes) && same(runes[hi+1]) {
+		hi++
+	}
+	return lo, hi
+}
+
+var (
+	semanticQuoteRegexp  = regexp.MustCompile(`"[^"]*"|'[^']*'|` + "`[^`]*`")
+	semanticPathRegexp   = regexp.MustCompile(`(?:~|\.{1,2})?(?:/[\w.@+%-]+)+/?|[\w.@+%-]+(?:/[\w.@+%-]+)+/?`)
+	semanticTokenRegexps = []*regexp.Regexp{urlRegexp, semanticQuoteRegexp, semanticPathRegexp}
+)
+
+// semanticToken returns the range of the URL, quoted string or path
+// containing rune i. Inside quotes only the quoted text is selected; on a
+// quote, the quotes are included.
+func (l *logicalLine) semanticToken(i int) (Position, Position, bool) {
+	if i < 0 {
+		return Position{}, Position{}, false
+	}
+	off := l.offsets[i]
+	for _, re := range semanticTokenRegexps {
+		for _, loc := range re.FindAllStringIndex(l.text, -1) {
+			if off < loc[0] || off >= loc[1] {
+				continue
+			}
+			lo, hi := l.runeAt(loc[0]), l.runeAt(loc[1])-1
+			if re == semanticQuoteRegexp && i > lo && i < hi {
+				lo, hi = lo+1, hi-1
+			}
+			start, end := l.span(lo, hi)
+			return start, end, true
+		}
+	}
+	return Position{}, Position{}, false
+}
@@ -0,0 +1,137 @@
+package headlessterm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectionFormat selects how GetSelectionAs renders the selection.
+type SelectionFormat int
+
+const (
+	// SelectionText is plain text, as returned by GetSelectedText.
+	SelectionText SelectionFormat = iota
+	// SelectionANSI is text with SGR attributes and OSC 8 hyperlinks. It
+	// starts from default attributes and ends with a reset.
+	SelectionANSI
+	// SelectionHTML is a <pre> element with inline styles and links.
+	SelectionHTML
+	// SelectionMarkdown is a fenced code block of the plain text.
+	SelectionMarkdown
+)
+
+// String returns the format name.
+func (f SelectionFormat) String() string {
+	switch f {
+	case SelectionText:
+		return "text"
+	case SelectionANSI:
+		return "ansi"
+	case SelectionHTML:
+		return "html"
+	case SelectionMarkdown:
+		return "markdown"
+	}
+	return "unknown"
+}
+
+// GetSelectionAs returns the active selection rendered in format, or "" if
+// there is no selection. Like GetSelectedText, it includes scrollback rows,
+// joins soft-wrapped rows and trims blanks past the end of each line.
+//
+// Example:
+//
+//	clipboard.Write("text/html", term.GetSelectionAs(headlessterm.SelectionHTML))
+//	clipboard.Write("text/plain", term.GetSelectedText())
+func (t *Terminal) GetSelectionAs(format SelectionFormat) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	lines, ok := t.selectedLinesLocked()
+	if !ok {
+		return ""
+	}
+	return t.renderLinesLocked(lines, format)
+}
+
+// renderLinesLocked renders lines in format (caller must hold lock).
+func (t *Terminal) renderLinesLocked(lines [][]Cell, format SelectionFormat) string {
+	switch format {
+	case SelectionANSI:
+		return selectionANSI(lines)
+	case SelectionHTML:
+		return t.selectionHTMLLocked(lines)
+	case SelectionMarkdown:
+		return selectionMarkdown(lines)
+	}
+	return selectionText(lines)
+}
+
+// selectionText joins lines as plain text.
+func selectionText(lines [][]Cell) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(cellsText(line, 0, len(line)-1))
+	}
+	return b.String()
+}
+
+// selectionANSI encodes lines with SGR attributes and OSC 8 hyperlinks.
+// Attributes carry over line breaks; hyperlinks are closed before them.
+func selectionANSI(lines [][]Cell) string {
+	e := newANSIEncoder(ColorDepthTrueColor)
+	e.resetPen()
+	for i, line := range lines {
+		if i > 0 {
+			e.setHyperlink(nil)
+			e.WriteString("\n")
+		}
+		for col := range line {
+			if !line[col].IsWideSpacer() {
+				e.writeCell(&line[col])
+			}
+		}
+	}
+	e.setHyperlink(nil)
+	if e.pen != (cellPen{}) {
+		e.WriteString("\x1b[0m")
+	}
+	return e.buf.String()
+}
+
+// selectionHTMLLocked renders lines as a <pre> element with inline styles
+// (caller must hold lock).
+func (t *Terminal) selectionHTMLLocked(lines [][]Cell) string {
+	e := &htmlExporter{t: t, classes: make(map[string]string)}
+	bg := t.resolveColorLocked(nil, false)
+	fg := t.resolveColorLocked(nil, true)
+	fmt.Fprintf(&e.body, "<pre style=\"font-family:monospace;background-color:%s;color:%s\">", cssColor(bg), cssColor(fg))
+	for i, line := range lines {
+		if i > 0 {
+			e.body.WriteString("\n")
+		}
+		e.writeLine(line)
+	}
+	e.body.WriteString("</pre>")
+	return e.body.String()
+}
+
+// selectionMarkdown wraps the plain text in a code fence longer than any
+// run of backticks it contains.
+func selectionMarkdown(lines [][]Cell) string {
+	text := selectionText(lines)
+	fence, run := 3, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		fence = max(fence, run+1)
+	}
+	ticks := strings.Repeat("`", fence)
+	return ticks + "\n" + text + "\n" + ticks + "\n"
+}
@@ -0,0 +1,54 @@
+package headlessterm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetSelectionAs(t *testing.T) {
+	term := New(WithSize(4, 8))
+	term.WriteString("\x1b[1;31mred\x1b[0m \x1b]8;;https://x.io\x07link\x1b]8;;\x07 and more\r\nend   ")
+	term.SetSelection(Position{Row: 0, Col: 0}, Position{Row: 3, Col: 7})
+
+	if got, want := term.GetSelectionAs(SelectionText), "red link and more\nend"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if got := term.GetSelectionAs(SelectionText); got != term.GetSelectedText() {
+		t.Errorf("text format differs from GetSelectedText: %q", got)
+	}
+
+	want := "\x1b[1;31mred\x1b[0m \x1b]8;;https://x.io\x1b\\link\x1b]8;;\x1b\\ and more\nend"
+	if got := term.GetSelectionAs(SelectionANSI); got != want {
+		t.Errorf("ansi = %q, want %q", got, want)
+	}
+
+	html := term.GetSelectionAs(SelectionHTML)
+	for _, part := range []string{
+		`<pre style="font-family:monospace;background-color:#000000;color:#e5e5e5">`,
+		`<span style="color:#cd3131;font-weight:bold">red</span>`,
+		`<a href="https://x.io">link</a> and more` + "\nend</pre>",
+	} {
+		if !strings.Contains(html, part) {
+			t.Errorf("html lacks %q:\n%s", part, html)
+		}
+	}
+
+	if got, want := term.GetSelectionAs(SelectionMarkdown), "```\nred link and more\nend\n```\n"; got != want {
+		t.Errorf("markdown = %q, want %q", got, want)
+	}
+
+	term.ClearSelection()
+	if got := term.GetSelectionAs(SelectionHTML); got != "" {
+		t.Errorf("no selection = %q", got)
+	}
+}
+
+func TestGetSelectionAs_MarkdownFence(t *testing.T) {
+	term := New(WithSize(2, 20))
+	term.WriteString("use ```go``` fences")
+	term.SetSelection(Position{Row: 0, Col: 0}, Position{Row: 0, Col: 19})
+
+	if got, want := term.GetSelectionAs(SelectionMarkdown), "````\nuse ```go``` fences\n````\n"; got != want {
+		t.Errorf("markdown = %q, want %q", got, want)
+	}
+}
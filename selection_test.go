@@ -0,0 +1,205 @@
+package headlessterm
+
+import "testing"
+
+func selectAt(term *Terminal, row, col int, mode SelectionMode) string {
+	term.StartSelection(Position{Row: term.ViewportRowToAbsolute(row), Col: col}, mode)
+	return term.GetSelectedText()
+}
+
+func TestSelection_Word(t *testing.T) {
+	term := New(WithSize(3, 40))
+	term.WriteString("foo bar-baz (qux)   end")
+
+	tests := []struct {
+		col  int
+		want string
+	}{
+		{1, "foo"},
+		{5, "bar-baz"},
+		{12, "("},
+		{13, "qux"},
+		{18, "   "},
+	}
+	for _, tt := range tests {
+		if got := selectAt(term, 0, tt.col, SelectWord); got != tt.want {
+			t.Errorf("word at col %d = %q, want %q", tt.col, got, tt.want)
+		}
+	}
+
+	term.SetWordSeparators("-")
+	if got := selectAt(term, 0, 5, SelectWord); got != "bar" {
+		t.Errorf("word with custom separators = %q, want %q", got, "bar")
+	}
+
+	// Dragging extends by whole words
+	term.SetWordSeparators(DefaultWordSeparators)
+	term.StartSelection(Position{Row: 0, Col: 5}, SelectWord)
+	term.ExtendSelection(Position{Row: 0, Col: 22})
+	if got := term.GetSelectedText(); got != "bar-baz (qux)   end" {
+		t.Errorf("extended word selection = %q", got)
+	}
+}
+
+func TestSelection_WordAcrossWrap(t *testing.T) {
+	term := New(WithSize(3, 8), WithWordSeparators(" "))
+	term.WriteString("aa superlongword")
+
+	if got := selectAt(term, 1, 2, SelectWord); got != "superlongword" {
+		t.Errorf("word = %q", got)
+	}
+	sel := term.GetSelection()
+	if sel.Start != (Position{Row: 0, Col: 3}) || sel.End != (Position{Row: 1, Col: 7}) || sel.Mode != SelectWord {
+		t.Errorf("selection = %+v", sel)
+	}
+}
+
+func TestSelection_Line(t *testing.T) {
+	term := New(WithSize(4, 6))
+	term.WriteString("first\r\nwrapped line\r\nlast")
+
+	if got := selectAt(term, 2, 0, SelectLine); got != "wrapped line" {
+		t.Errorf("line = %q", got)
+	}
+	term.ExtendSelection(Position{Row: 3, Col: 0})
+	if got := term.GetSelectedText(); got != "wrapped line\nlast" {
+		t.Errorf("extended lines = %q", got)
+	}
+}
+
+func TestSelection_Block(t *testing.T) {
+	term := New(WithSize(4, 10))
+	term.WriteString("abcdef\r\nghijkl\r\nmn\r\nstuvwx")
+
+	term.StartSelection(Position{Row: 3, Col: 4}, SelectBlock)
+	term.ExtendSelection(Position{Row: 0, Col: 1})
+	if got := term.GetSelectedText(); got != "bcde\nhijk\nn\ntuvw" {
+		t.Errorf("block = %q", got)
+	}
+	if !term.IsSelected(2, 3) || term.IsSelected(2, 0) || term.IsSelected(1, 5) {
+		t.Error("IsSelected does not follow the block")
+	}
+}
+
+func TestSelection_Semantic(t *testing.T) {
+	term := New(WithSize(4, 80))
+	term.WriteString(`see https://example.com/a?b=1. or ./src/main.go and "quoted text" here`)
+
+	tests := []struct {
+		col  int
+		want string
+	}{
+		{10, "https://example.com/a?b=1"},
+		{38, "./src/main.go"},
+		{55, "quoted text"},
+		{52, `"quoted text"`},
+		{1, "see"},
+	}
+	for _, tt := range tests {
+		if got := selectAt(term, 0, tt.col, SelectSemantic); got != tt.want {
+			t.Errorf("semantic at col %d = %q, want %q", tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestSelection_SemanticCommandOutput(t *testing.T) {
+	term := New(WithSize(8, 20))
+	term.WriteString("\x1b]133;A\x07$ ls\r\n\x1b]133;C\x07one two\r\nthree\r\n\x1b]133;D;0\x07\x1b]133;A\x07$ ")
+
+	if got := selectAt(term, 1, 5, SelectSemantic); got != "one two\nthree" {
+		t.Errorf("command output = %q", got)
+	}
+	// The prompt is not part of any output
+	if got := selectAt(term, 3, 0, SelectSemantic); got != "$" {
+		t.Errorf("prompt = %q", got)
+	}
+}
+
+func TestSelection_ScrollbackAndResize(t *testing.T) {
+	term := New(WithSize(3, 10), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("keep me\r\n")
+	term.StartSelection(Position{Row: term.ViewportRowToAbsolute(0), Col: 0}, SelectLine)
+
+	term.WriteString("a\r\nb\r\nc\r\n")
+	if got := term.GetSelectedText(); got != "keep me" {
+		t.Errorf("after scrolling = %q", got)
+	}
+	if sel := term.GetSelection(); sel.Start.Row != -2 || term.IsSelected(0, 0) {
+		t.Errorf("selection after scrolling = %+v", sel)
+	}
+
+	term.Resize(6, 10)
+	if got := term.GetSelectedText(); got != "keep me" {
+		t.Errorf("after resize = %q", got)
+	}
+	if sel := term.GetSelection(); sel.Start.Row < 0 || !term.IsSelected(sel.Start.Row, 3) {
+		t.Errorf("selection after resize = %+v", sel)
+	}
+}
+
+func TestSelection_ScrolledViewport(t *testing.T) {
+	term := New(WithSize(3, 10), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("one\r\ntwo\r\nthree\r\nfour\r\nfive")
+	term.ScrollViewport(2)
+
+	// The top of the viewport shows "one", two rows above the live screen
+	term.SetViewportSelection(Position{Row: 0, Col: 0}, Position{Row: 0, Col: 2})
+	if got := term.GetSelectedText(); got != "one" {
+		t.Errorf("selected text = %q", got)
+	}
+	if sel := term.ViewportSelection(); sel.Start.Row != 0 || sel.End != (Position{Row: 0, Col: 2}) {
+		t.Errorf("viewport selection = %+v", sel)
+	}
+	if sel := term.GetSelection(); sel.Start.Row != -2 {
+		t.Errorf("screen selection = %+v", sel)
+	}
+	if !term.IsSelectedInViewport(0, 1) || term.IsSelected(0, 1) || !term.IsSelected(-2, 1) {
+		t.Error("IsSelected and IsSelectedInViewport disagree on the selected row")
+	}
+
+	// SetSelection ignores the display offset
+	term.SetSelection(Position{Row: 0, Col: 0}, Position{Row: 0, Col: 4})
+	if got := term.GetSelectedText(); got != "three" {
+		t.Errorf("screen selection text = %q", got)
+	}
+	if sel := term.ViewportSelection(); sel.Start.Row != 2 {
+		t.Errorf("viewport selection = %+v", sel)
+	}
+}
+
+func TestSelection_Eviction(t *testing.T) {
+	term := New(WithSize(2, 10), WithScrollback(NewMemoryScrollback(2)))
+	term.WriteString("one\r\ntwo\r\nthree")
+	term.StartSelection(Position{Row: 0, Col: 0}, SelectChar)
+	term.ExtendSelection(Position{Row: 1, Col: 2})
+
+	// Evicting "one" cuts it from the selection
+	term.WriteString("\r\nfour\r\nfive")
+	if got := term.GetSelectedText(); got != "two" {
+		t.Errorf("after evicting the first line = %q", got)
+	}
+	if start, end, ok := term.SelectionRange(); !ok || start != (Position{0, 0}) || end != (Position{0, 2}) {
+		t.Errorf("range after eviction = %v %v %v", start, end, ok)
+	}
+
+	// Evicting every selected line ends the selection
+	term.WriteString("\r\nsix")
+	if term.HasSelection() || term.GetSelectedText() != "" {
+		t.Errorf("selection of evicted lines = %q", term.GetSelectedText())
+	}
+}
+
+func TestSelection_TextAcrossWrapsAndWide(t *testing.T) {
+	term := New(WithSize(4, 5))
+	term.WriteString("hello world\r\n日本")
+
+	term.SetSelection(Position{Row: 0, Col: 3}, Position{Row: 3, Col: 0})
+	if got := term.GetSelectedText(); got != "lo world\n日" {
+		t.Errorf("text = %q", got)
+	}
+	// Selecting half of a wide character selects all of it
+	term.SetSelection(Position{Row: 3, Col: 3}, Position{Row: 3, Col: 3})
+	if sel := term.GetSelection(); sel.Start.Col != 2 || sel.End.Col != 3 || term.GetSelectedText() != "本" {
+		t.Errorf("wide selection = %+v %q", sel, term.GetSelectedText())
+	}
+}
@@ -9,9 +9,11 @@ import (
 type PromptMark struct {
 	// Type is the mark type (PromptStart, CommandStart, CommandExecuted, CommandFinished).
 	Type ansicode.ShellIntegrationMark
-	// Row is the absolute row position (including scrollback offset).
-	// Negative values indicate scrollback lines (-1 is most recent scrollback line).
+	// Row is the absolute row position (including scrollback offset) when
+	// the mark was returned. It changes as the scrollback evicts lines.
 	Row int
+	// LineID is the stable ID of the mark's line (see AbsoluteRowToLineID).
+	LineID uint64
 	// ExitCode is the command exit code (only valid for CommandFinished marks, -1 otherwise).
 	ExitCode int
 }
@@ -49,12 +51,16 @@ func (t *Terminal) semanticPromptMarkInternal(mark ansicode.ShellIntegrationMark
 	scrollbackLen := t.primaryBuffer.ScrollbackLen()
 	absoluteRow := t.cursor.Row + scrollbackLen
 
-	// Store the mark
+	// Store the mark, dropping marks whose lines were evicted
+	t.dropEvictedPromptMarksLocked()
 	t.promptMarks = append(t.promptMarks, PromptMark{
 		Type:     mark,
 		Row:      absoluteRow,
+		LineID:   t.lineIDLocked(absoluteRow),
 		ExitCode: exitCode,
 	})
+	t.queueEventLocked(PromptMarkEvent{Mark: t.promptMarks[len(t.promptMarks)-1]})
+	t.recordCommandMarkLocked(mark, exitCode, Position{Row: absoluteRow, Col: t.cursor.Col})
 
 	// Notify handler if set
 	if t.semanticPromptHandler != nil {
@@ -62,28 +68,57 @@ func (t *Terminal) semanticPromptMarkInternal(mark ansicode.ShellIntegrationMark
 	}
 }
 
-// PromptMarks returns all recorded prompt marks.
+// PromptMarks returns all recorded prompt marks. Marks on lines evicted
+// from the scrollback are left out.
 func (t *Terminal) PromptMarks() []PromptMark {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
+	return t.livePromptMarksLocked()
+}
 
-	// Return a copy to prevent external modification
-	marks := make([]PromptMark, len(t.promptMarks))
-	copy(marks, t.promptMarks)
+// livePromptMarksLocked returns copies of the marks whose lines are still
+// stored, with Row updated (caller must hold lock).
+func (t *Terminal) livePromptMarksLocked() []PromptMark {
+	marks := make([]PromptMark, 0, len(t.promptMarks))
+	for _, mark := range t.promptMarks {
+		if mark.Row = t.lineRowLocked(mark.LineID); mark.Row >= 0 {
+			marks = append(marks, mark)
+		}
+	}
 	return marks
 }
 
-// PromptMarkCount returns the number of recorded prompt marks.
+// dropEvictedPromptMarksLocked removes the marks whose lines were evicted
+// from the scrollback (caller must hold lock).
+func (t *Terminal) dropEvictedPromptMarksLocked() {
+	n := 0
+	for n < len(t.promptMarks) && t.lineRowLocked(t.promptMarks[n].LineID) < 0 {
+		n++
+	}
+	t.promptMarks = t.promptMarks[n:]
+	t.promptMarksDropped += n
+}
+
+// PromptMarkCount returns the number of recorded prompt marks, not counting
+// marks on lines evicted from the scrollback.
 func (t *Terminal) PromptMarkCount() int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return len(t.promptMarks)
+
+	count := 0
+	for _, mark := range t.promptMarks {
+		if t.lineRowLocked(mark.LineID) >= 0 {
+			count++
+		}
+	}
+	return count
 }
 
 // ClearPromptMarks removes all recorded prompt marks.
 func (t *Terminal) ClearPromptMarks() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.promptMarksDropped += len(t.promptMarks)
 	t.promptMarks = nil
 }
 
@@ -93,11 +128,15 @@ func (t *Terminal) ClearPromptMarks() {
 func (t *Terminal) NextPromptRow(currentAbsRow int, markType ansicode.ShellIntegrationMark) int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
+	return t.nextPromptRowLocked(currentAbsRow, markType)
+}
 
+// nextPromptRowLocked implements NextPromptRow (caller must hold lock).
+func (t *Terminal) nextPromptRowLocked(currentAbsRow int, markType ansicode.ShellIntegrationMark) int {
 	for _, mark := range t.promptMarks {
-		if mark.Row > currentAbsRow {
+		if row := t.lineRowLocked(mark.LineID); row > currentAbsRow {
 			if markType == -1 || mark.Type == markType {
-				return mark.Row
+				return row
 			}
 		}
 	}
@@ -110,13 +149,17 @@ func (t *Terminal) NextPromptRow(currentAbsRow int, markType ansicode.ShellInteg
 func (t *Terminal) PrevPromptRow(currentAbsRow int, markType ansicode.ShellIntegrationMark) int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
+	return t.prevPromptRowLocked(currentAbsRow, markType)
+}
 
+// prevPromptRowLocked implements PrevPromptRow (caller must hold lock).
+func (t *Terminal) prevPromptRowLocked(currentAbsRow int, markType ansicode.ShellIntegrationMark) int {
 	// Search backwards
 	for i := len(t.promptMarks) - 1; i >= 0; i-- {
 		mark := t.promptMarks[i]
-		if mark.Row < currentAbsRow {
+		if row := t.lineRowLocked(mark.LineID); row >= 0 && row < currentAbsRow {
 			if markType == -1 || mark.Type == markType {
-				return mark.Row
+				return row
 			}
 		}
 	}
@@ -128,9 +171,14 @@ func (t *Terminal) GetPromptMarkAt(absRow int) *PromptMark {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
+	if absRow < 0 {
+		return nil
+	}
+	id := t.lineIDLocked(absRow)
 	for i := range t.promptMarks {
-		if t.promptMarks[i].Row == absRow {
+		if t.promptMarks[i].LineID == id {
 			mark := t.promptMarks[i]
+			mark.Row = absRow
 			return &mark
 		}
 	}
@@ -158,14 +206,15 @@ func (t *Terminal) GetLastCommandOutput() string {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	if len(t.promptMarks) == 0 {
+	marks := t.livePromptMarksLocked()
+	if len(marks) == 0 {
 		return ""
 	}
 
 	// Find the last CommandExecuted and CommandFinished marks
 	var lastExecuted, lastFinished *PromptMark
-	for i := len(t.promptMarks) - 1; i >= 0; i-- {
-		mark := &t.promptMarks[i]
+	for i := len(marks) - 1; i >= 0; i-- {
+		mark := &marks[i]
 		if lastFinished == nil && mark.Type == ansicode.CommandFinished {
 			lastFinished = mark
 		}
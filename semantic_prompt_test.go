@@ -681,3 +681,84 @@ func TestSemanticPromptMark_GetMarkAtWithScrollback(t *testing.T) {
 		t.Errorf("expected nil at absolute row 5, got %v", mark)
 	}
 }
+
+func TestSemanticPromptMark_Eviction(t *testing.T) {
+	term := New(WithSize(3, 20), WithScrollback(NewMemoryScrollback(4)))
+
+	// Lines 0 to 4: the first prompt, three output lines and the second prompt
+	term.WriteString("\x1b]133;A\x07$ first\r\nout0\r\nout1\r\nout2\r\n\x1b]133;A\x07$ second")
+	if marks := term.PromptMarks(); len(marks) != 2 || marks[1].Row != 4 || marks[1].LineID != 4 {
+		t.Fatalf("marks before eviction = %+v", marks)
+	}
+
+	// Four more lines evict lines 0 and 1
+	term.WriteString("\r\nx\r\nx\r\nx\r\nx")
+	if term.EvictedLines() != 2 {
+		t.Fatalf("expected 2 evicted lines, got %d", term.EvictedLines())
+	}
+	marks := term.PromptMarks()
+	if len(marks) != 1 || marks[0].Row != 2 || marks[0].LineID != 4 {
+		t.Fatalf("marks after eviction = %+v", marks)
+	}
+	if got := term.cellsToString(term.ScrollbackLine(marks[0].Row)); got != "$ second" {
+		t.Errorf("mark row holds %q", got)
+	}
+	if term.LineIDToAbsoluteRow(4) != 2 || term.AbsoluteRowToLineID(2) != 4 || term.LineIDToAbsoluteRow(1) != -1 {
+		t.Error("line ID conversions disagree with the eviction offset")
+	}
+	if term.PromptMarkCount() != 1 {
+		t.Errorf("expected 1 mark, got %d", term.PromptMarkCount())
+	}
+	if row := term.NextPromptRow(-1, ansicode.PromptStart); row != 2 {
+		t.Errorf("expected next prompt at row 2, got %d", row)
+	}
+	if row := term.PrevPromptRow(2, ansicode.PromptStart); row != -1 {
+		t.Errorf("expected no prompt above the evicted lines, got %d", row)
+	}
+	if mark := term.GetPromptMarkAt(2); mark == nil || mark.LineID != 4 {
+		t.Errorf("expected the second prompt at row 2, got %v", mark)
+	}
+
+	// Recording a mark drops the evicted ones
+	term.WriteString("\x1b]133;A\x07")
+	if len(term.promptMarks) != 2 {
+		t.Errorf("expected evicted marks to be dropped, got %+v", term.promptMarks)
+	}
+}
+
+func TestSemanticPromptMark_LastCommandOutputAfterEviction(t *testing.T) {
+	term := New(WithSize(3, 20), WithScrollback(NewMemoryScrollback(2)))
+	term.WriteString("\x1b]133;C\x07o1\r\no2\r\no3\r\n\x1b]133;D;0\x07")
+	term.WriteString("\x1b]133;C\x07p1\r\np2\r\n\x1b]133;D;0\x07")
+
+	if term.EvictedLines() == 0 {
+		t.Fatal("expected the first output to be evicted")
+	}
+	if got := term.GetLastCommandOutput(); got != "p1\np2" {
+		t.Errorf("expected %q, got %q", "p1\np2", got)
+	}
+}
+
+func TestSemanticPromptMark_EvictionWithoutScrollback(t *testing.T) {
+	term := New(WithSize(4, 20))
+	term.WriteString("\x1b]133;A\x07$ ls\r\n1\r\n2\r\n3")
+	term.StartSelection(Position{Row: 1, Col: 0}, SelectLine)
+
+	// "$ ls" scrolls off the screen and is lost
+	term.WriteString("\r\n")
+	if marks := term.PromptMarks(); len(marks) != 0 {
+		t.Errorf("marks after the prompt scrolled off = %+v", marks)
+	}
+	if got := term.GetSelectedText(); got != "1" {
+		t.Errorf("selection after scrolling = %q", got)
+	}
+	if term.LineIDToAbsoluteRow(1) != 0 || term.EvictedLines() != 1 {
+		t.Errorf("line 1 at row %d with %d evicted", term.LineIDToAbsoluteRow(1), term.EvictedLines())
+	}
+
+	// The selected line scrolls off too
+	term.WriteString("\r\n")
+	if term.HasSelection() {
+		t.Errorf("selection of a lost line = %q", term.GetSelectedText())
+	}
+}
@@ -1,9 +1,11 @@
 package headlessterm
 
 import (
+	"cmp"
 	"encoding/base64"
 	"fmt"
 	"image/color"
+	"slices"
 )
 
 // SnapshotDetail specifies the level of detail in a snapshot.
@@ -20,10 +22,13 @@ const (
 
 // Snapshot represents a complete terminal screen capture.
 type Snapshot struct {
-	Size   SnapshotSize    `json:"size"`
-	Cursor SnapshotCursor  `json:"cursor"`
-	Lines  []SnapshotLine  `json:"lines"`
-	Images []SnapshotImage `json:"images,omitempty"`
+	Size       SnapshotSize    `json:"size"`
+	Cursor     SnapshotCursor  `json:"cursor"`
+	Lines      []SnapshotLine  `json:"lines"`
+	Images     []SnapshotImage `json:"images,omitempty"`
+	Generation Generation      `json:"generation,omitempty"` // Change counter at capture time
+	// DisplayOffset is how far SnapshotViewport was scrolled into the scrollback
+	DisplayOffset int `json:"display_offset,omitempty"`
 }
 
 // SnapshotSize holds terminal dimensions.
@@ -152,13 +157,9 @@ func (t *Terminal) Snapshot(detail SnapshotDetail) *Snapshot {
 			Rows: t.rows,
 			Cols: t.cols,
 		},
-		Cursor: SnapshotCursor{
-			Row:     t.cursor.Row,
-			Col:     t.cursor.Col,
-			Visible: t.cursor.Visible,
-			Style:   cursorStyleToString(t.cursor.Style),
-		},
-		Lines: make([]SnapshotLine, t.rows),
+		Cursor:     t.snapshotCursor(),
+		Lines:      make([]SnapshotLine, t.rows),
+		Generation: t.clock.now(),
 	}
 
 	for row := 0; row < t.rows; row++ {
@@ -171,9 +172,26 @@ func (t *Terminal) Snapshot(detail SnapshotDetail) *Snapshot {
 	return snap
 }
 
-// snapshotImages returns all image placements with metadata.
+// snapshotCursor returns the cursor state.
+func (t *Terminal) snapshotCursor() SnapshotCursor {
+	return SnapshotCursor{
+		Row:     t.cursor.Row,
+		Col:     t.cursor.Col,
+		Visible: t.cursor.Visible,
+		Style:   cursorStyleToString(t.cursor.Style),
+	}
+}
+
+// snapshotImages returns all image placements with metadata, ordered by placement ID.
 func (t *Terminal) snapshotImages() []SnapshotImage {
 	placements := t.images.Placements()
+	slices.SortFunc(placements, func(a, b *ImagePlacement) int { return cmp.Compare(a.ID, b.ID) })
+	return t.snapshotPlacements(placements)
+}
+
+// snapshotPlacements converts placements to their snapshot form, skipping
+// placements whose image is gone.
+func (t *Terminal) snapshotPlacements(placements []*ImagePlacement) []SnapshotImage {
 	if len(placements) == 0 {
 		return nil
 	}
@@ -203,8 +221,13 @@ func (t *Terminal) snapshotImages() []SnapshotImage {
 
 // snapshotLine creates a snapshot of a single line.
 func (t *Terminal) snapshotLine(row int, detail SnapshotDetail) SnapshotLine {
+	return t.snapshotCells(t.activeBuffer.cells[row], detail)
+}
+
+// snapshotCells creates a snapshot of a line of cells, such as a scrollback line.
+func (t *Terminal) snapshotCells(cells []Cell, detail SnapshotDetail) SnapshotLine {
 	line := SnapshotLine{
-		Text: t.activeBuffer.LineContent(row),
+		Text: t.cellsToString(cells[:min(len(cells), t.cols)]),
 	}
 
 	switch detail {
@@ -212,26 +235,23 @@ func (t *Terminal) snapshotLine(row int, detail SnapshotDetail) SnapshotLine {
 		// Just text, already set
 
 	case SnapshotDetailStyled:
-		line.Segments = t.lineToSegments(row)
+		line.Segments = t.lineToSegments(cells)
 
 	case SnapshotDetailFull:
-		line.Cells = t.lineToCells(row)
+		line.Cells = t.lineToCells(cells)
 	}
 
 	return line
 }
 
 // lineToSegments converts a line to styled segments (runs of same style).
-func (t *Terminal) lineToSegments(row int) []SnapshotSegment {
+func (t *Terminal) lineToSegments(cells []Cell) []SnapshotSegment {
 	var segments []SnapshotSegment
 	var current *SnapshotSegment
 	var currentChars []rune
 
-	for col := 0; col < t.cols; col++ {
-		cell := t.activeBuffer.Cell(row, col)
-		if cell == nil {
-			continue
-		}
+	for col := 0; col < t.cols && col < len(cells); col++ {
+		cell := &cells[col]
 		if cell.IsWideSpacer() {
 			continue
 		}
@@ -277,13 +297,12 @@ func (t *Terminal) lineToSegments(row int) []SnapshotSegment {
 	return segments
 }
 
-// lineToCells converts a line to full cell data.
-func (t *Terminal) lineToCells(row int) []SnapshotCell {
+// lineToCells converts a line to full cell data, padded to the terminal width.
+func (t *Terminal) lineToCells(line []Cell) []SnapshotCell {
 	cells := make([]SnapshotCell, 0, t.cols)
 
 	for col := 0; col < t.cols; col++ {
-		cell := t.activeBuffer.Cell(row, col)
-		if cell == nil {
+		if col >= len(line) {
 			cells = append(cells, SnapshotCell{
 				Char: " ",
 				Fg:   colorToHex(nil),
@@ -291,6 +310,7 @@ func (t *Terminal) lineToCells(row int) []SnapshotCell {
 			})
 			continue
 		}
+		cell := &line[col]
 
 		ch := cell.Char
 		if ch == 0 {
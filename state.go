@@ -0,0 +1,751 @@
+package headlessterm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// StateVersion is the version of the serialized terminal state format.
+// LoadState rejects states written with a newer version.
+const StateVersion = 1
+
+// terminalState is the serialized form of a Terminal.
+// Providers, middleware and the partially parsed escape sequence are not included.
+type terminalState struct {
+	Version int `json:"version"`
+
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+
+	Primary   bufferState `json:"primary"`
+	Alternate bufferState `json:"alternate"`
+	// AlternateActive is true if the alternate buffer was active.
+	AlternateActive bool `json:"alternate_active,omitempty"`
+
+	Cursor      cursorState       `json:"cursor"`
+	SavedCursor *savedCursorState `json:"saved_cursor,omitempty"`
+	Template    cellState         `json:"template"`
+
+	Charsets      [4]Charset `json:"charsets"`
+	ActiveCharset int        `json:"active_charset"`
+
+	ScrollTop    int          `json:"scroll_top"`
+	ScrollBottom int          `json:"scroll_bottom"`
+	Modes        TerminalMode `json:"modes"`
+
+	Title      string   `json:"title,omitempty"`
+	TitleStack []string `json:"title_stack,omitempty"`
+
+	Colors map[int]string `json:"colors,omitempty"`
+
+	// Hyperlinks is the table of hyperlinks referenced by cells (1-based index, 0 means none).
+	Hyperlinks       []Hyperlink `json:"hyperlinks,omitempty"`
+	CurrentHyperlink int         `json:"current_hyperlink,omitempty"`
+
+	KeyboardModes   []ansicode.KeyboardMode  `json:"keyboard_modes,omitempty"`
+	ModifyOtherKeys ansicode.ModifyOtherKeys `json:"modify_other_keys,omitempty"`
+
+	Selection  Selection `json:"selection"`
+	AutoResize bool      `json:"auto_resize,omitempty"`
+
+	PromptMarks []PromptMark      `json:"prompt_marks,omitempty"`
+	Commands    []Command         `json:"commands,omitempty"`
+	WorkingDir  string            `json:"working_dir,omitempty"`
+	UserVars    map[string]string `json:"user_vars,omitempty"`
+
+	SixelEnabled bool `json:"sixel_enabled"`
+	KittyEnabled bool `json:"kitty_enabled"`
+
+	Images imageManagerState `json:"images"`
+}
+
+// bufferState is the serialized form of a Buffer.
+type bufferState struct {
+	Rows       int           `json:"rows"`
+	Cols       int           `json:"cols"`
+	Lines      [][]cellState `json:"lines"`
+	Wrapped    []int         `json:"wrapped,omitempty"` // Rows that are soft-wrapped
+	TabStops   []int         `json:"tab_stops"`         // Columns with a tab stop
+	Scrollback [][]cellState `json:"scrollback,omitempty"`
+	// ScrollbackWrapped lists the scrollback lines that are soft-wrapped
+	ScrollbackWrapped []int  `json:"scrollback_wrapped,omitempty"`
+	MaxScrollback     int    `json:"max_scrollback,omitempty"`
+	Evicted           uint64 `json:"evicted,omitempty"` // Lines evicted before the scrollback, for line IDs
+}
+
+// cellState is the serialized form of a Cell.
+// Colors use the encoding produced by encodeStateColor.
+type cellState struct {
+	Char           rune       `json:"c,omitempty"`
+	Fg             string     `json:"f,omitempty"`
+	Bg             string     `json:"b,omitempty"`
+	UnderlineColor string     `json:"u,omitempty"`
+	Flags          CellFlags  `json:"a,omitempty"`
+	Hyperlink      int        `json:"h,omitempty"`
+	Image          *CellImage `json:"i,omitempty"`
+}
+
+// cursorState is the serialized form of a Cursor.
+type cursorState struct {
+	Row     int         `json:"row"`
+	Col     int         `json:"col"`
+	Style   CursorStyle `json:"style"`
+	Visible bool        `json:"visible"`
+}
+
+// savedCursorState is the serialized form of a SavedCursor.
+type savedCursorState struct {
+	Row          int        `json:"row"`
+	Col          int        `json:"col"`
+	Attrs        cellState  `json:"attrs"`
+	OriginMode   bool       `json:"origin_mode,omitempty"`
+	CharsetIndex int        `json:"charset_index"`
+	Charsets     [4]Charset `json:"charsets"`
+}
+
+// imageManagerState is the serialized form of an ImageManager.
+type imageManagerState struct {
+	Images          []imageState     `json:"images,omitempty"`
+	Placements      []ImagePlacement `json:"placements,omitempty"`
+	NextImageID     uint32           `json:"next_image_id"`
+	NextPlacementID uint32           `json:"next_placement_id"`
+	MaxMemory       int64            `json:"max_memory"`
+}
+
+// imageState is the serialized form of an ImageData.
+type imageState struct {
+	ID        uint32    `json:"id"`
+	Width     uint32    `json:"width"`
+	Height    uint32    `json:"height"`
+	Data      []byte    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MarshalState serializes the complete terminal state (both buffers, scrollback,
+// cursor, modes, charsets, tab stops, palette, hyperlinks, prompt marks, user
+// variables and images) into a versioned JSON document.
+// Providers and middleware are not part of the state.
+func (t *Terminal) MarshalState() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.SaveState(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalState restores a state produced by MarshalState.
+func (t *Terminal) UnmarshalState(data []byte) error {
+	return t.LoadState(bytes.NewReader(data))
+}
+
+// SaveState writes the complete terminal state to w.
+// See MarshalState for what is included.
+func (t *Terminal) SaveState(w io.Writer) error {
+	t.mu.RLock()
+	st := t.captureStateLocked()
+	t.mu.RUnlock()
+
+	return json.NewEncoder(w).Encode(st)
+}
+
+// LoadState replaces the terminal state with one read from r.
+// Configured providers and middleware are kept. Scrollback lines are pushed
+// into the current ScrollbackProvider after clearing it. If the state is
+// invalid, an error is returned and the terminal is left unchanged.
+func (t *Terminal) LoadState(r io.Reader) error {
+	var st terminalState
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		return fmt.Errorf("failed to decode state: %w", err)
+	}
+	if st.Version < 1 || st.Version > StateVersion {
+		return fmt.Errorf("unsupported state version: %d", st.Version)
+	}
+	if st.Rows <= 0 || st.Cols <= 0 {
+		return fmt.Errorf("invalid state size: %dx%d", st.Rows, st.Cols)
+	}
+
+	defer t.written.broadcast()
+
+	// The decoder is replaced, so wait for a Write in progress
+	t.decodeMu.Lock()
+	defer t.decodeMu.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.restoreStateLocked(&st)
+}
+
+// captureStateLocked builds the serialized state (caller must hold lock).
+func (t *Terminal) captureStateLocked() *terminalState {
+	links := newHyperlinkTable()
+
+	st := &terminalState{
+		Version:         StateVersion,
+		Rows:            t.rows,
+		Cols:            t.cols,
+		Primary:         captureBufferState(t.primaryBuffer, links),
+		Alternate:       captureBufferState(t.alternateBuffer, links),
+		AlternateActive: t.activeBuffer == t.alternateBuffer,
+		Cursor: cursorState{
+			Row:     t.cursor.Row,
+			Col:     t.cursor.Col,
+			Style:   t.cursor.Style,
+			Visible: t.cursor.Visible,
+		},
+		Template:         captureCellState(&t.template.Cell, links),
+		Charsets:         t.charsets,
+		ActiveCharset:    t.activeCharset,
+		ScrollTop:        t.scrollTop,
+		ScrollBottom:     t.scrollBottom,
+		Modes:            t.modes,
+		Title:            t.title,
+		TitleStack:       append([]string(nil), t.titleStack...),
+		CurrentHyperlink: links.index(t.currentHyperlink),
+		KeyboardModes:    append([]ansicode.KeyboardMode(nil), t.keyboardModes...),
+		ModifyOtherKeys:  t.modifyOtherKeys,
+		Selection:        t.getSelectionLocked(t.primaryBuffer.ScrollbackLen()),
+		AutoResize:       t.autoResize,
+		PromptMarks:      t.livePromptMarksLocked(),
+		Commands:         t.commandsLocked(),
+		WorkingDir:       t.workingDir,
+		SixelEnabled:     t.sixelEnabled,
+		KittyEnabled:     t.kittyEnabled,
+		Images:           t.images.captureState(),
+	}
+
+	if t.savedCursor != nil {
+		st.SavedCursor = &savedCursorState{
+			Row:          t.savedCursor.Row,
+			Col:          t.savedCursor.Col,
+			Attrs:        captureCellState(&t.savedCursor.Attrs.Cell, links),
+			OriginMode:   t.savedCursor.OriginMode,
+			CharsetIndex: t.savedCursor.CharsetIndex,
+			Charsets:     t.savedCursor.Charsets,
+		}
+	}
+
+	if len(t.colors) > 0 {
+		st.Colors = make(map[int]string, len(t.colors))
+		for i, c := range t.colors {
+			st.Colors[i] = encodeStateColor(c)
+		}
+	}
+
+	if len(t.userVars) > 0 {
+		st.UserVars = make(map[string]string, len(t.userVars))
+		for k, v := range t.userVars {
+			st.UserVars[k] = v
+		}
+	}
+
+	st.Hyperlinks = links.links
+	return st
+}
+
+// restoreStateLocked applies a decoded state (caller must hold lock and decodeMu).
+func (t *Terminal) restoreStateLocked(st *terminalState) error {
+	links := make([]*Hyperlink, len(st.Hyperlinks))
+	for i := range st.Hyperlinks {
+		link := st.Hyperlinks[i]
+		links[i] = &link
+	}
+
+	// Only the active buffer grows in auto-resize mode, so the other one may
+	// be smaller than the terminal
+	active, inactive := &st.Primary, &st.Alternate
+	if st.AlternateActive {
+		active, inactive = inactive, active
+	}
+	if active.Rows != st.Rows || active.Cols != st.Cols {
+		return fmt.Errorf("active buffer size %dx%d does not match terminal size %dx%d", active.Rows, active.Cols, st.Rows, st.Cols)
+	}
+	if inactive.Rows > st.Rows || inactive.Cols > st.Cols ||
+		!st.AutoResize && (inactive.Rows != st.Rows || inactive.Cols != st.Cols) {
+		return fmt.Errorf("inactive buffer size %dx%d does not match terminal size %dx%d", inactive.Rows, inactive.Cols, st.Rows, st.Cols)
+	}
+
+	primary, scrollback, err := restoreBufferState(&st.Primary, t.scrollbackStorage, links)
+	if err != nil {
+		return fmt.Errorf("primary buffer: %w", err)
+	}
+	alternate, _, err := restoreBufferState(&st.Alternate, NoopScrollback{}, links)
+	if err != nil {
+		return fmt.Errorf("alternate buffer: %w", err)
+	}
+
+	if !validCharsetIndex(st.ActiveCharset) {
+		return fmt.Errorf("invalid active charset: %d", st.ActiveCharset)
+	}
+
+	template, err := restoreCellState(&st.Template, links)
+	if err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+
+	var saved *SavedCursor
+	if st.SavedCursor != nil {
+		attrs, err := restoreCellState(&st.SavedCursor.Attrs, links)
+		if err != nil {
+			return fmt.Errorf("saved cursor: %w", err)
+		}
+		if !validCharsetIndex(st.SavedCursor.CharsetIndex) {
+			return fmt.Errorf("saved cursor: invalid charset: %d", st.SavedCursor.CharsetIndex)
+		}
+		saved = &SavedCursor{
+			Row:          clamp(st.SavedCursor.Row, 0, st.Rows-1),
+			Col:          clamp(st.SavedCursor.Col, 0, st.Cols-1),
+			Attrs:        CellTemplate{Cell: attrs},
+			OriginMode:   st.SavedCursor.OriginMode,
+			CharsetIndex: st.SavedCursor.CharsetIndex,
+			Charsets:     st.SavedCursor.Charsets,
+		}
+	}
+
+	colors := make(map[int]color.Color, len(st.Colors))
+	for i, s := range st.Colors {
+		c, err := decodeStateColor(s)
+		if err != nil {
+			return fmt.Errorf("palette color %d: %w", i, err)
+		}
+		colors[i] = c
+	}
+
+	currentHyperlink, err := lookupHyperlink(links, st.CurrentHyperlink)
+	if err != nil {
+		return err
+	}
+
+	// Everything is validated; only now replace the scrollback contents
+	restoreScrollback(primary, &st.Primary, scrollback)
+
+	t.rows = st.Rows
+	t.cols = st.Cols
+	t.primaryBuffer = primary
+	t.alternateBuffer = alternate
+	t.activeBuffer = primary
+	if st.AlternateActive {
+		t.activeBuffer = alternate
+	}
+	primary.setClock(t.clock)
+	alternate.setClock(t.clock)
+	t.markScreenChangedLocked()
+
+	t.cursor = &Cursor{
+		Row:     clamp(st.Cursor.Row, 0, t.rows-1),
+		Col:     clamp(st.Cursor.Col, 0, t.cols-1),
+		Style:   st.Cursor.Style,
+		Visible: st.Cursor.Visible,
+	}
+	t.savedCursor = saved
+	t.template = CellTemplate{Cell: template}
+
+	t.charsets = st.Charsets
+	t.activeCharset = st.ActiveCharset
+	t.scrollTop = clamp(st.ScrollTop, 0, t.rows-1)
+	t.scrollBottom = clamp(st.ScrollBottom, t.scrollTop+1, t.rows)
+	t.modes = st.Modes
+
+	t.title = st.Title
+	t.titleStack = st.TitleStack
+	t.colors = colors
+	t.currentHyperlink = currentHyperlink
+
+	t.keyboardModes = st.KeyboardModes
+	if t.keyboardModes == nil {
+		t.keyboardModes = make([]ansicode.KeyboardMode, 0)
+	}
+	t.modifyOtherKeys = st.ModifyOtherKeys
+
+	t.setSelectionLocked(st.Selection)
+	t.autoResize = st.AutoResize
+	// Rows were saved relative to the saved scrollback
+	t.promptMarks = st.PromptMarks
+	for i := range t.promptMarks {
+		t.promptMarks[i].LineID = st.Primary.Evicted + uint64(max(t.promptMarks[i].Row, 0))
+	}
+	t.restoreCommandsLocked(st.Commands, st.Primary.Evicted)
+	t.workingDir = st.WorkingDir
+	t.userVars = st.UserVars
+	if t.userVars == nil {
+		t.userVars = make(map[string]string)
+	}
+
+	t.sixelEnabled = st.SixelEnabled
+	t.kittyEnabled = st.KittyEnabled
+
+	t.images.restoreState(&st.Images)
+
+	// Discard any partially parsed sequence from before the restore
+	t.decoder = newDecoder(t)
+
+	return nil
+}
+
+// hyperlinkTable deduplicates hyperlinks while capturing state.
+type hyperlinkTable struct {
+	links []Hyperlink
+	ids   map[*Hyperlink]int
+	keys  map[Hyperlink]int
+}
+
+func newHyperlinkTable() *hyperlinkTable {
+	return &hyperlinkTable{
+		ids:  make(map[*Hyperlink]int),
+		keys: make(map[Hyperlink]int),
+	}
+}
+
+// index returns the 1-based table index for link, adding it if needed. Returns 0 for nil.
+func (h *hyperlinkTable) index(link *Hyperlink) int {
+	if link == nil {
+		return 0
+	}
+	if i, ok := h.ids[link]; ok {
+		return i
+	}
+	if i, ok := h.keys[*link]; ok {
+		h.ids[link] = i
+		return i
+	}
+	h.links = append(h.links, *link)
+	i := len(h.links)
+	h.ids[link] = i
+	h.keys[*link] = i
+	return i
+}
+
+// lookupHyperlink resolves a 1-based hyperlink table index.
+func lookupHyperlink(links []*Hyperlink, i int) (*Hyperlink, error) {
+	if i == 0 {
+		return nil, nil
+	}
+	if i < 0 || i > len(links) {
+		return nil, fmt.Errorf("invalid hyperlink reference: %d", i)
+	}
+	return links[i-1], nil
+}
+
+// captureBufferState serializes a buffer, including its scrollback.
+func captureBufferState(b *Buffer, links *hyperlinkTable) bufferState {
+	st := bufferState{
+		Rows:  b.rows,
+		Cols:  b.cols,
+		Lines: make([][]cellState, b.rows),
+	}
+
+	for row := 0; row < b.rows; row++ {
+		st.Lines[row] = captureLineState(b.cells[row], links)
+		if b.wrapped[row] {
+			st.Wrapped = append(st.Wrapped, row)
+		}
+	}
+
+	for col, set := range b.tabStop {
+		if set {
+			st.TabStops = append(st.TabStops, col)
+		}
+	}
+
+	st.Evicted = b.evicted
+	if b.scrollback != nil {
+		st.MaxScrollback = b.scrollback.MaxLines()
+		n := b.scrollback.Len()
+		if n > 0 {
+			st.Scrollback = make([][]cellState, 0, n)
+			for i := 0; i < n; i++ {
+				st.Scrollback = append(st.Scrollback, captureLineState(b.scrollback.Line(i), links))
+				if b.ScrollbackWrapped(i) {
+					st.ScrollbackWrapped = append(st.ScrollbackWrapped, i)
+				}
+			}
+		}
+	}
+
+	return st
+}
+
+// captureLineState serializes a row of cells.
+func captureLineState(line []Cell, links *hyperlinkTable) []cellState {
+	cells := make([]cellState, len(line))
+	for i := range line {
+		cells[i] = captureCellState(&line[i], links)
+	}
+	return cells
+}
+
+// captureCellState serializes a single cell. The dirty flag is not preserved.
+func captureCellState(c *Cell, links *hyperlinkTable) cellState {
+	st := cellState{
+		Char:           c.Char,
+		Fg:             encodeStateColor(c.Fg),
+		Bg:             encodeStateColor(c.Bg),
+		UnderlineColor: encodeStateColor(c.UnderlineColor),
+		Flags:          c.Flags &^ CellFlagDirty,
+		Hyperlink:      links.index(c.Hyperlink),
+	}
+	if c.Image != nil {
+		img := *c.Image
+		st.Image = &img
+	}
+	return st
+}
+
+// restoreBufferState rebuilds a buffer from its serialized form, along with its decoded scrollback lines. The storage is
+// attached but left untouched; see restoreScrollback.
+// All cells are marked dirty so renderers repaint the restored screen.
+func restoreBufferState(st *bufferState, storage ScrollbackProvider, links []*Hyperlink) (*Buffer, [][]Cell, error) {
+	if st.Rows <= 0 || st.Cols <= 0 || len(st.Lines) != st.Rows {
+		return nil, nil, fmt.Errorf("invalid buffer size: %dx%d with %d lines", st.Rows, st.Cols, len(st.Lines))
+	}
+
+	b := NewBufferWithStorage(st.Rows, st.Cols, storage)
+	for row, line := range st.Lines {
+		cells, err := restoreLineState(line, links)
+		if err != nil {
+			return nil, nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		// Rows may be wider than the buffer in auto-resize mode
+		for len(cells) < st.Cols {
+			cells = append(cells, NewCell())
+		}
+		for col := range cells {
+			cells[col].MarkDirty()
+		}
+		b.cells[row] = cells
+	}
+	b.hasDirty = true
+
+	for _, row := range st.Wrapped {
+		b.SetWrapped(row, true)
+	}
+
+	b.ClearAllTabStops()
+	for _, col := range st.TabStops {
+		b.SetTabStop(col)
+	}
+
+	scrollback := make([][]Cell, len(st.Scrollback))
+	for i, line := range st.Scrollback {
+		cells, err := restoreLineState(line, links)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scrollback line %d: %w", i, err)
+		}
+		scrollback[i] = cells
+	}
+
+	return b, scrollback, nil
+}
+
+// restoreScrollback replaces the contents of the buffer's storage with the
+// restored scrollback lines. It runs once the whole state has been
+// validated, so a failing restore leaves the storage as it was.
+func restoreScrollback(b *Buffer, st *bufferState, scrollback [][]Cell) {
+	storage := b.scrollback
+	if storage == nil {
+		return
+	}
+	storage.Clear()
+	if st.MaxScrollback > 0 {
+		storage.SetMaxLines(st.MaxScrollback)
+	}
+	wrapped := make([]bool, len(scrollback))
+	for _, i := range st.ScrollbackWrapped {
+		if i >= 0 && i < len(wrapped) {
+			wrapped[i] = true
+		}
+	}
+	indexed, _ := storage.(*IndexedScrollback)
+	for i, cells := range scrollback {
+		if indexed != nil {
+			indexed.pushWrapped(cells, wrapped[i])
+		} else {
+			storage.Push(cells)
+		}
+	}
+	// Keep line IDs when the storage holds fewer lines
+	b.evicted = st.Evicted + uint64(max(len(scrollback)-storage.Len(), 0))
+	b.scrollbackWrapped = wrapped[max(len(wrapped)-storage.Len(), 0):]
+}
+
+// validCharsetIndex reports whether i selects one of the G0-G3 charsets.
+func validCharsetIndex(i int) bool {
+	return i >= 0 && i < 4
+}
+
+// restoreLineState rebuilds a row of cells.
+func restoreLineState(line []cellState, links []*Hyperlink) ([]Cell, error) {
+	cells := make([]Cell, len(line))
+	for i := range line {
+		c, err := restoreCellState(&line[i], links)
+		if err != nil {
+			return nil, fmt.Errorf("col %d: %w", i, err)
+		}
+		cells[i] = c
+	}
+	return cells, nil
+}
+
+// restoreCellState rebuilds a single cell.
+func restoreCellState(st *cellState, links []*Hyperlink) (Cell, error) {
+	fg, err := decodeStateColor(st.Fg)
+	if err != nil {
+		return Cell{}, err
+	}
+	bg, err := decodeStateColor(st.Bg)
+	if err != nil {
+		return Cell{}, err
+	}
+	ul, err := decodeStateColor(st.UnderlineColor)
+	if err != nil {
+		return Cell{}, err
+	}
+	link, err := lookupHyperlink(links, st.Hyperlink)
+	if err != nil {
+		return Cell{}, err
+	}
+
+	c := Cell{
+		Char:           st.Char,
+		Fg:             fg,
+		Bg:             bg,
+		UnderlineColor: ul,
+		Flags:          st.Flags,
+		Hyperlink:      link,
+	}
+	if st.Image != nil {
+		img := *st.Image
+		c.Image = &img
+	}
+	return c, nil
+}
+
+// encodeStateColor encodes a color without losing its kind:
+// "" for nil, "i:N" for IndexedColor, "n:N" for NamedColor and "#rrggbbaa" otherwise.
+func encodeStateColor(c color.Color) string {
+	switch v := c.(type) {
+	case nil:
+		return ""
+	case *IndexedColor:
+		return "i:" + strconv.Itoa(v.Index)
+	case *NamedColor:
+		return "n:" + strconv.Itoa(v.Name)
+	case color.RGBA:
+		return fmt.Sprintf("#%02x%02x%02x%02x", v.R, v.G, v.B, v.A)
+	default:
+		r, g, b, a := c.RGBA()
+		return fmt.Sprintf("#%02x%02x%02x%02x", r>>8, g>>8, b>>8, a>>8)
+	}
+}
+
+// decodeStateColor is the inverse of encodeStateColor.
+func decodeStateColor(s string) (color.Color, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case strings.HasPrefix(s, "i:"):
+		n, err := strconv.Atoi(s[2:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid indexed color %q", s)
+		}
+		return &IndexedColor{Index: n}, nil
+	case strings.HasPrefix(s, "n:"):
+		n, err := strconv.Atoi(s[2:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid named color %q", s)
+		}
+		return &NamedColor{Name: n}, nil
+	case strings.HasPrefix(s, "#") && len(s) == 9:
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rgba color %q", s)
+		}
+		return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+	default:
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+}
+
+// captureState serializes stored images and placements.
+// In-flight chunked Kitty transfers are not included.
+func (m *ImageManager) captureState() imageManagerState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	st := imageManagerState{
+		NextImageID:     m.nextImageID,
+		NextPlacementID: m.nextPlacementID,
+		MaxMemory:       m.maxMemory,
+	}
+
+	for _, img := range m.images {
+		st.Images = append(st.Images, imageState{
+			ID:        img.ID,
+			Width:     img.Width,
+			Height:    img.Height,
+			Data:      img.Data,
+			CreatedAt: img.CreatedAt,
+		})
+	}
+	sort.Slice(st.Images, func(i, j int) bool { return st.Images[i].ID < st.Images[j].ID })
+
+	for _, p := range m.placements {
+		st.Placements = append(st.Placements, *p)
+	}
+	sort.Slice(st.Placements, func(i, j int) bool { return st.Placements[i].ID < st.Placements[j].ID })
+
+	return st
+}
+
+// restoreState replaces all images and placements.
+func (m *ImageManager) restoreState(st *imageManagerState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.images = make(map[uint32]*ImageData, len(st.Images))
+	m.placements = make(map[uint32]*ImagePlacement, len(st.Placements))
+	m.hashToID = make(map[[32]byte]uint32, len(st.Images))
+	m.usedMemory = 0
+	m.accumulator = nil
+	m.accumulatorMore = false
+
+	now := time.Now()
+	for _, s := range st.Images {
+		img := &ImageData{
+			ID:         s.ID,
+			Width:      s.Width,
+			Height:     s.Height,
+			Data:       s.Data,
+			Hash:       sha256.Sum256(s.Data),
+			CreatedAt:  s.CreatedAt,
+			AccessedAt: now,
+		}
+		m.images[img.ID] = img
+		m.hashToID[img.Hash] = img.ID
+		m.usedMemory += int64(len(img.Data))
+	}
+
+	for i := range st.Placements {
+		p := st.Placements[i]
+		m.placements[p.ID] = &p
+	}
+
+	m.nextImageID = st.NextImageID
+	m.nextPlacementID = st.NextPlacementID
+	if st.MaxMemory > 0 {
+		m.maxMemory = st.MaxMemory
+	}
+	m.touchLocked()
+	m.placementGens = make(map[uint32]Generation)
+	m.removals = nil
+	m.resetAt = m.changed
+}
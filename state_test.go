@@ -0,0 +1,333 @@
+package headlessterm
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestState_RoundTrip(t *testing.T) {
+	storage := NewMemoryScrollback(100)
+	term := New(WithSize(5, 20), WithScrollback(storage))
+
+	term.WriteString("\x1b]2;first\x07\x1b[22;0t\x1b]2;second\x07")
+	term.WriteString("\x1b]4;1;rgb:ff/00/00\x07")
+	for i := 0; i < 8; i++ {
+		term.WriteString("line\r\n")
+	}
+	term.WriteString("\x1b]8;id=x;https://example.com\x1b\\link\x1b]8;;\x1b\\ ")
+	term.WriteString("\x1b[1;38;5;196;48;2;1;2;3mstyled\x1b[0m")
+	term.WriteString("\x1b(0q\x1b(B")
+	term.WriteString("\x1b]7;file://host/tmp\x07")
+	term.WriteString("\x1b]1337;SetUserVar=foo=YmFy\x07")
+	term.WriteString("\x1b[2;4r\x1b[3g\x1b[5;5H\x1bH")
+	term.WriteString("\x1b[?2004h\x1b[4 q\x1b7")
+	term.SetSelection(Position{Row: 0, Col: 1}, Position{Row: 1, Col: 2})
+	term.ShellIntegrationMark(0, -1)
+
+	data, err := term.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState: %v", err)
+	}
+
+	restored := New(WithScrollback(NewMemoryScrollback(10)))
+	if err := restored.UnmarshalState(data); err != nil {
+		t.Fatalf("UnmarshalState: %v", err)
+	}
+
+	if restored.Rows() != 5 || restored.Cols() != 20 {
+		t.Errorf("size = %dx%d, want 5x20", restored.Rows(), restored.Cols())
+	}
+	if restored.String() != term.String() {
+		t.Errorf("screen = %q, want %q", restored.String(), term.String())
+	}
+	if restored.ScrollbackLen() != term.ScrollbackLen() {
+		t.Errorf("ScrollbackLen = %d, want %d", restored.ScrollbackLen(), term.ScrollbackLen())
+	}
+	if restored.MaxScrollback() != 100 {
+		t.Errorf("MaxScrollback = %d, want 100", restored.MaxScrollback())
+	}
+	if restored.Title() != "second" {
+		t.Errorf("Title = %q, want %q", restored.Title(), "second")
+	}
+	restored.WriteString("\x1b[23;0t")
+	if restored.Title() != "first" {
+		t.Errorf("Title after pop = %q, want %q", restored.Title(), "first")
+	}
+
+	r1, c1 := term.CursorPos()
+	r2, c2 := restored.CursorPos()
+	if r1 != r2 || c1 != c2 {
+		t.Errorf("cursor = (%d,%d), want (%d,%d)", r2, c2, r1, c1)
+	}
+	if restored.CursorStyle() != term.CursorStyle() {
+		t.Errorf("CursorStyle = %v, want %v", restored.CursorStyle(), term.CursorStyle())
+	}
+	if !restored.HasMode(ModeBracketedPaste) {
+		t.Error("bracketed paste mode not restored")
+	}
+	top, bottom := restored.ScrollRegion()
+	if top != 1 || bottom != 4 {
+		t.Errorf("ScrollRegion = (%d,%d), want (1,4)", top, bottom)
+	}
+	if restored.WorkingDirectoryPath() != "/tmp" {
+		t.Errorf("WorkingDirectoryPath = %q, want /tmp", restored.WorkingDirectoryPath())
+	}
+	if restored.GetUserVar("foo") != "bar" {
+		t.Errorf("user var foo = %q, want bar", restored.GetUserVar("foo"))
+	}
+	if restored.PromptMarkCount() != 1 {
+		t.Errorf("PromptMarkCount = %d, want 1", restored.PromptMarkCount())
+	}
+	if restored.GetSelectedText() != term.GetSelectedText() {
+		t.Errorf("selection = %q, want %q", restored.GetSelectedText(), term.GetSelectedText())
+	}
+}
+
+func TestState_CellAttributes(t *testing.T) {
+	term := New(WithSize(3, 20))
+	term.WriteString("\x1b]8;id=x;https://example.com\x1b\\ab\x1b]8;;\x1b\\")
+	term.WriteString("\x1b[1;4:3;38;5;196;48;2;1;2;3;58;2;9;8;7mZ\x1b[0m")
+	term.WriteString("中")
+
+	data, err := term.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.UnmarshalState(data); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := restored.Cell(0, 0), restored.Cell(0, 1)
+	if a.Hyperlink == nil || a.Hyperlink.URI != "https://example.com" || a.Hyperlink.ID != "x" {
+		t.Fatalf("hyperlink = %+v", a.Hyperlink)
+	}
+	if a.Hyperlink != b.Hyperlink {
+		t.Error("cells sharing a hyperlink should share the restored pointer")
+	}
+
+	z := restored.Cell(0, 2)
+	if !z.HasFlag(CellFlagBold) || !z.HasFlag(CellFlagCurlyUnderline) {
+		t.Errorf("flags = %b", z.Flags)
+	}
+	if ic, ok := z.Fg.(*IndexedColor); !ok || ic.Index != 196 {
+		t.Errorf("Fg = %#v, want IndexedColor 196", z.Fg)
+	}
+	if z.Bg != (color.RGBA{1, 2, 3, 255}) {
+		t.Errorf("Bg = %#v", z.Bg)
+	}
+	if z.UnderlineColor != (color.RGBA{9, 8, 7, 255}) {
+		t.Errorf("UnderlineColor = %#v", z.UnderlineColor)
+	}
+	if nc, ok := restored.Cell(0, 5).Fg.(*NamedColor); !ok || nc.Name != NamedColorForeground {
+		t.Errorf("default Fg = %#v", restored.Cell(0, 5).Fg)
+	}
+	if !restored.Cell(0, 3).IsWide() || !restored.Cell(0, 4).IsWideSpacer() {
+		t.Error("wide character flags not restored")
+	}
+}
+
+func TestState_AlternateScreen(t *testing.T) {
+	term := New(WithSize(3, 10))
+	term.WriteString("primary\x1b[?1049h\x1b[Halt")
+
+	data, err := term.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.UnmarshalState(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.IsAlternateScreen() {
+		t.Fatal("alternate screen not restored")
+	}
+	if restored.LineContent(0) != "alt" {
+		t.Errorf("alternate line = %q, want alt", restored.LineContent(0))
+	}
+
+	restored.WriteString("\x1b[?1049l")
+	if restored.LineContent(0) != "primary" {
+		t.Errorf("primary line = %q, want primary", restored.LineContent(0))
+	}
+	row, col := restored.CursorPos()
+	if row != 0 || col != 7 {
+		t.Errorf("restored cursor = (%d,%d), want (0,7)", row, col)
+	}
+}
+
+func TestState_Images(t *testing.T) {
+	term := New(WithSize(10, 20))
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;" + "AAAA/wAAAP8AAAD/AAAA/w==" + "\x1b\\")
+	if term.ImageCount() != 1 || term.ImagePlacementCount() != 1 {
+		t.Fatalf("setup: images=%d placements=%d", term.ImageCount(), term.ImagePlacementCount())
+	}
+
+	var buf bytes.Buffer
+	if err := term.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.ImageCount() != 1 || restored.ImagePlacementCount() != 1 {
+		t.Fatalf("images=%d placements=%d", restored.ImageCount(), restored.ImagePlacementCount())
+	}
+	p := restored.ImagePlacements()[0]
+	img := restored.Image(p.ImageID)
+	if img == nil || img.Width != 2 || len(img.Data) != 16 {
+		t.Fatalf("image = %+v", img)
+	}
+	if restored.Cell(0, 0).Image == nil {
+		t.Error("cell image reference not restored")
+	}
+}
+
+func TestState_LineIDs(t *testing.T) {
+	term := New(WithSize(2, 20), WithScrollback(NewMemoryScrollback(2)))
+	term.WriteString("a\r\nb\r\n\x1b]133;A\x07$ \r\nc\r\nd")
+	if term.EvictedLines() != 1 {
+		t.Fatalf("setup: evicted=%d", term.EvictedLines())
+	}
+
+	var buf bytes.Buffer
+	if err := term.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(WithScrollback(NewMemoryScrollback(100)))
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.EvictedLines() != 1 {
+		t.Errorf("evicted=%d", restored.EvictedLines())
+	}
+	marks := restored.PromptMarks()
+	if len(marks) != 1 || marks[0].LineID != 2 || marks[0].Row != 1 {
+		t.Errorf("marks = %+v", marks)
+	}
+}
+
+func TestState_Errors(t *testing.T) {
+	term := New()
+
+	if err := term.UnmarshalState([]byte("not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if err := term.UnmarshalState([]byte(`{"version":99,"rows":1,"cols":1}`)); err == nil ||
+		!strings.Contains(err.Error(), "version") {
+		t.Errorf("expected version error, got %v", err)
+	}
+	if err := term.UnmarshalState([]byte(`{"version":1,"rows":0,"cols":1}`)); err == nil {
+		t.Error("expected error for invalid size")
+	}
+}
+
+func TestState_InvalidStateKeepsTerminal(t *testing.T) {
+	src := New(WithSize(3, 10), WithScrollback(NewMemoryScrollback(10)))
+	src.WriteString("saved1\r\nsaved2\r\nsaved3\r\nsaved4")
+	data, err := src.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(edit func(st map[string]any)) []byte {
+		var st map[string]any
+		if err := json.Unmarshal(data, &st); err != nil {
+			t.Fatal(err)
+		}
+		edit(st)
+		out, err := json.Marshal(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	tests := map[string][]byte{
+		"active charset": corrupt(func(st map[string]any) { st["active_charset"] = 4 }),
+		"saved cursor charset": corrupt(func(st map[string]any) {
+			st["saved_cursor"] = map[string]any{"attrs": st["template"], "charset_index": -1}
+		}),
+		"buffer size": corrupt(func(st map[string]any) { st["rows"] = 2 }),
+		"alternate size": corrupt(func(st map[string]any) {
+			st["alternate"].(map[string]any)["cols"] = 5
+		}),
+		"palette color": corrupt(func(st map[string]any) {
+			st["colors"] = map[string]any{"1": "bogus"}
+		}),
+	}
+
+	for name, bad := range tests {
+		term := New(WithSize(3, 10), WithScrollback(NewMemoryScrollback(10)))
+		term.WriteString("live1\r\nlive2\r\nlive3\r\nlive4")
+		if err := term.UnmarshalState(bad); err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if line := term.ScrollbackLine(0); term.ScrollbackLen() != 1 || len(line) == 0 || line[0].Char != 'l' {
+			t.Errorf("%s: scrollback replaced by failed restore", name)
+		}
+		if got := term.LineContent(2); !strings.HasPrefix(got, "live4") {
+			t.Errorf("%s: screen = %q", name, got)
+		}
+	}
+}
+
+func TestState_ScrollbackWrapped(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		storage := func() ScrollbackProvider {
+			if indexed {
+				return NewIndexedScrollback(NewMemoryScrollback(100))
+			}
+			return NewMemoryScrollback(100)
+		}
+		term := New(WithSize(2, 6), WithScrollback(storage()))
+		term.WriteString("abcdefghij\r\nx\r\ny")
+
+		var buf bytes.Buffer
+		if err := term.SaveState(&buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := New(WithScrollback(storage()))
+		if err := restored.LoadState(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		matches := collectMatches(restored, `efgh`, SearchOptions{Origin: &Position{}})
+		want := []SearchMatch{{Start: Position{Row: 0, Col: 4}, End: Position{Row: 1, Col: 1}, Text: "efgh"}}
+		if !slices.Equal(matches, want) {
+			t.Errorf("indexed=%v: matches = %+v, want %+v", indexed, matches, want)
+		}
+	}
+}
+
+func TestState_LoadWhileWriting(t *testing.T) {
+	src := New(WithSize(5, 20))
+	src.WriteString("saved")
+	data, err := src.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	term := New(WithSize(5, 20))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			term.WriteString("\x1b[31mout\x1b[0m\r\n")
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := term.UnmarshalState(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,435 @@
+package headlessterm
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SVGOptions configures SVG rendering.
+type SVGOptions struct {
+	// FontSize is the font size in pixels (default: 14).
+	FontSize float64
+	// FontFamily is the font-family attribute (default: monospace).
+	FontFamily string
+	// CellWidth and CellHeight override the cell size in pixels
+	// (default: 0.6 and 1.2 times the font size).
+	CellWidth, CellHeight float64
+	// Padding around the grid in pixels (default: one cell width).
+	Padding float64
+	// WindowChrome draws a window frame with a title bar.
+	WindowChrome bool
+	// Title is shown in the title bar (default: the terminal title).
+	Title string
+	// HideCursor omits the cursor.
+	HideCursor bool
+}
+
+// SVGFrame is one frame of an animated SVG.
+type SVGFrame struct {
+	Frame    *Frame
+	Duration time.Duration
+}
+
+// svgTitleBarHeight is the height of the window chrome title bar in pixels.
+const svgTitleBarHeight = 30
+
+// ExportSVG writes the current screen as an SVG image.
+// Colors are resolved via the terminal palette, box-drawing and block characters
+// are drawn as geometry so they join seamlessly, and image placements are
+// embedded as PNGs.
+//
+// Example:
+//
+//	f, _ := os.Create("screenshot.svg")
+//	term.ExportSVG(f, headlessterm.SVGOptions{WindowChrome: true})
+func (t *Terminal) ExportSVG(w io.Writer, opts SVGOptions) error {
+	t.mu.RLock()
+	r := newSVGRenderer(t, opts, t.rows, t.cols)
+	r.writeHeader()
+	r.writeImages(true)
+	r.writeGrid(t.activeBuffer.cells, *t.cursor)
+	r.writeImages(false)
+	r.writeFooter()
+	t.mu.RUnlock()
+
+	_, err := w.Write(r.buf.Bytes())
+	return err
+}
+
+// ExportAnimatedSVG writes an SVG that plays the frames in order and loops.
+// Frames are typically captured with Frame() after each chunk of output.
+// Colors and the title come from the terminal; image placements are not animated.
+func (t *Terminal) ExportAnimatedSVG(w io.Writer, frames []SVGFrame, opts SVGOptions) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames")
+	}
+
+	rows, cols := 0, 0
+	var total time.Duration
+	for i, f := range frames {
+		if f.Frame == nil {
+			return fmt.Errorf("frame %d: nil frame", i)
+		}
+		rows, cols = max(rows, f.Frame.Rows), max(cols, f.Frame.Cols)
+		total += max(f.Duration, time.Millisecond)
+	}
+
+	t.mu.RLock()
+	r := newSVGRenderer(t, opts, rows, cols)
+	r.writeHeader()
+
+	var elapsed time.Duration
+	for i, f := range frames {
+		start := 100 * float64(elapsed) / float64(total)
+		elapsed += max(f.Duration, time.Millisecond)
+		end := 100 * float64(elapsed) / float64(total)
+
+		// Each frame is only visible during its share of the loop
+		if i == 0 {
+			r.css = append(r.css, fmt.Sprintf("@keyframes f%d{0%%{opacity:1}%s%%{opacity:0}}", i, svgNum(end)))
+		} else {
+			r.css = append(r.css, fmt.Sprintf("@keyframes f%d{0%%{opacity:0}%s%%{opacity:1}%s%%{opacity:0}}", i, svgNum(start), svgNum(end)))
+		}
+		fmt.Fprintf(&r.buf, "<g style=\"opacity:0;animation:f%d %ss step-end infinite\">\n", i, svgNum(total.Seconds()))
+		r.writeGrid(f.Frame.Lines, f.Frame.Cursor)
+		r.buf.WriteString("</g>\n")
+	}
+	r.writeFooter()
+	t.mu.RUnlock()
+
+	_, err := w.Write(r.buf.Bytes())
+	return err
+}
+
+// svgRenderer draws terminal cells as SVG (the terminal lock must be held while rendering).
+type svgRenderer struct {
+	t    *Terminal
+	opts SVGOptions
+	buf  bytes.Buffer // Content; wrapped into the document by writeFooter
+	css  []string
+
+	rows, cols    int
+	cw, ch        float64 // Cell size
+	originX       float64 // Top-left of the grid
+	originY       float64
+	width, height float64 // Image size
+	blinkUsed     bool
+}
+
+func newSVGRenderer(t *Terminal, opts SVGOptions, rows, cols int) *svgRenderer {
+	if opts.FontSize <= 0 {
+		opts.FontSize = 14
+	}
+	if opts.FontFamily == "" {
+		opts.FontFamily = "monospace"
+	}
+	if opts.CellWidth <= 0 {
+		opts.CellWidth = opts.FontSize * 0.6
+	}
+	if opts.CellHeight <= 0 {
+		opts.CellHeight = opts.FontSize * 1.2
+	}
+	if opts.Padding <= 0 {
+		opts.Padding = opts.CellWidth
+	}
+	if opts.Title == "" {
+		opts.Title = t.title
+	}
+
+	r := &svgRenderer{
+		t:       t,
+		opts:    opts,
+		rows:    rows,
+		cols:    cols,
+		cw:      opts.CellWidth,
+		ch:      opts.CellHeight,
+		originX: opts.Padding,
+		originY: opts.Padding,
+	}
+	if opts.WindowChrome {
+		r.originY += svgTitleBarHeight
+	}
+	r.width = r.originX*2 + float64(cols)*r.cw
+	r.height = r.originY + opts.Padding + float64(rows)*r.ch
+	return r
+}
+
+// writeHeader draws the background and window chrome.
+func (r *svgRenderer) writeHeader() {
+	bg := r.t.resolveColorLocked(nil, false)
+	fmt.Fprintf(&r.buf, "<rect width=\"%s\" height=\"%s\"%s fill=\"%s\"/>\n",
+		svgNum(r.width), svgNum(r.height), r.chromeRadius(), cssColor(bg))
+
+	if r.opts.WindowChrome {
+		for i, c := range []string{"#ff5f56", "#ffbd2e", "#27c93f"} {
+			fmt.Fprintf(&r.buf, "<circle cx=\"%d\" cy=\"15\" r=\"6\" fill=\"%s\"/>\n", 18+i*20, c)
+		}
+		if r.opts.Title != "" {
+			fmt.Fprintf(&r.buf, "<text x=\"%s\" y=\"20\" text-anchor=\"middle\" fill=\"%s\" font-size=\"13\">%s</text>\n",
+				svgNum(r.width/2), cssColor(r.t.resolveColorLocked(nil, true)), html.EscapeString(r.opts.Title))
+		}
+	}
+}
+
+func (r *svgRenderer) chromeRadius() string {
+	if r.opts.WindowChrome {
+		return " rx=\"8\""
+	}
+	return ""
+}
+
+// writeFooter wraps the content into the final document with the collected stylesheet.
+func (r *svgRenderer) writeFooter() {
+	content := r.buf.Bytes()
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\" font-family=\"%s\" font-size=\"%s\" xml:space=\"preserve\">\n",
+		svgNum(r.width), svgNum(r.height), svgNum(r.width), svgNum(r.height),
+		html.EscapeString(r.opts.FontFamily), svgNum(r.opts.FontSize))
+
+	css := r.css
+	if r.blinkUsed {
+		css = append(css, "@keyframes blink{50%{opacity:0}}", ".blink{animation:blink 1s step-end infinite}")
+	}
+	if len(css) > 0 {
+		out.WriteString("<style>\n" + strings.Join(css, "\n") + "\n</style>\n")
+	}
+	out.Write(content)
+	out.WriteString("</svg>\n")
+	r.buf = out
+}
+
+// writeGrid draws backgrounds, text, decorations and the cursor for the given lines.
+func (r *svgRenderer) writeGrid(lines [][]Cell, cursor Cursor) {
+	for row := 0; row < len(lines) && row < r.rows; row++ {
+		r.writeBackgrounds(row, lines[row])
+	}
+	for row := 0; row < len(lines) && row < r.rows; row++ {
+		r.writeForeground(row, lines[row])
+	}
+	if !r.opts.HideCursor && cursor.Visible && cursor.Row < len(lines) {
+		r.writeCursor(lines[cursor.Row], cursor)
+	}
+}
+
+// writeBackgrounds fills runs of cells with a non-default background.
+func (r *svgRenderer) writeBackgrounds(row int, line []Cell) {
+	y := r.originY + float64(row)*r.ch
+	for col := 0; col < len(line) && col < r.cols; {
+		colors := r.t.resolveCellColorsLocked(&line[col])
+		start := col
+		col++
+		for col < len(line) && col < r.cols {
+			next := r.t.resolveCellColorsLocked(&line[col])
+			if next.DefaultBg != colors.DefaultBg || next.Bg != colors.Bg {
+				break
+			}
+			col++
+		}
+		if colors.DefaultBg {
+			continue
+		}
+		fmt.Fprintf(&r.buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			svgNum(r.originX+float64(start)*r.cw), svgNum(y), svgNum(float64(col-start)*r.cw), svgNum(r.ch), cssColor(colors.Bg))
+	}
+}
+
+// svgTextStyle is the part of a cell's appearance that splits text runs.
+type svgTextStyle struct {
+	fg, ul             string
+	flags              CellFlags
+	underlineColorSet  bool
+	hidden, procedural bool
+}
+
+func (r *svgRenderer) textStyle(c *Cell) svgTextStyle {
+	colors := r.t.resolveCellColorsLocked(c)
+	const visible = CellFlagBold | CellFlagItalic | cellUnderlineFlags | CellFlagStrike | CellFlagBlinkSlow | CellFlagBlinkFast
+	return svgTextStyle{
+		fg:                cssColor(colors.Fg),
+		ul:                cssColor(colors.Underline),
+		flags:             c.Flags & visible,
+		underlineColorSet: c.UnderlineColor != nil,
+		hidden:            c.HasFlag(CellFlagHidden),
+		procedural:        isProceduralGlyph(c.Char),
+	}
+}
+
+// writeForeground draws text runs, box-drawing geometry and decorations for a line.
+func (r *svgRenderer) writeForeground(row int, line []Cell) {
+	top := r.originY + float64(row)*r.ch
+	n := min(len(line), r.cols)
+
+	for col := 0; col < n; {
+		if line[col].IsWideSpacer() {
+			col++
+			continue
+		}
+		style := r.textStyle(&line[col])
+		start := col
+		var text strings.Builder
+		for col < n {
+			c := &line[col]
+			if !c.IsWideSpacer() {
+				if r.textStyle(c) != style || (style.procedural && col > start) {
+					break
+				}
+				text.WriteRune(printableRune(c))
+			}
+			col++
+		}
+		x := r.originX + float64(start)*r.cw
+		width := float64(col-start) * r.cw
+
+		blink := style.flags&(CellFlagBlinkSlow|CellFlagBlinkFast) != 0
+		if blink {
+			r.buf.WriteString("<g class=\"blink\">\n")
+			r.blinkUsed = true
+		}
+
+		switch {
+		case style.hidden:
+		case style.procedural:
+			for _, g := range boxGlyphRects(line[start].Char, r.cw, r.ch) {
+				opacity := ""
+				if g.Alpha < 1 {
+					opacity = " fill-opacity=\"" + svgNum(g.Alpha) + "\""
+				}
+				fmt.Fprintf(&r.buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"%s/>\n",
+					svgNum(x+g.X), svgNum(top+g.Y), svgNum(g.W), svgNum(g.H), style.fg, opacity)
+			}
+		case strings.TrimRight(text.String(), " ") != "":
+			attrs := ""
+			if style.flags&CellFlagBold != 0 {
+				attrs += " font-weight=\"bold\""
+			}
+			if style.flags&CellFlagItalic != 0 {
+				attrs += " font-style=\"italic\""
+			}
+			fmt.Fprintf(&r.buf, "<text x=\"%s\" y=\"%s\" fill=\"%s\" textLength=\"%s\"%s>%s</text>\n",
+				svgNum(x), svgNum(top+r.ch*0.78), style.fg, svgNum(width), attrs, html.EscapeString(text.String()))
+		}
+
+		r.writeDecorations(style, x, top, width)
+		if blink {
+			r.buf.WriteString("</g>\n")
+		}
+	}
+}
+
+// writeDecorations draws underlines and strikethrough as geometry.
+func (r *svgRenderer) writeDecorations(style svgTextStyle, x, top, width float64) {
+	thick := max(1, r.opts.FontSize/14)
+	y := top + r.ch*0.9
+	line := func(y float64, color, extra string) {
+		fmt.Fprintf(&r.buf, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"%s\" stroke-width=\"%s\"%s/>\n",
+			svgNum(x), svgNum(y), svgNum(x+width), svgNum(y), color, svgNum(thick), extra)
+	}
+
+	switch {
+	case style.flags&CellFlagDoubleUnderline != 0:
+		line(y-thick, style.ul, "")
+		line(y+thick, style.ul, "")
+	case style.flags&CellFlagCurlyUnderline != 0:
+		var d strings.Builder
+		fmt.Fprintf(&d, "M%s %s", svgNum(x), svgNum(y))
+		half := r.cw / 2
+		for i, px := 0, x; px < x+width-0.01; i, px = i+1, px+half {
+			dy := -thick * 1.5
+			if i%2 == 1 {
+				dy = -dy
+			}
+			fmt.Fprintf(&d, " q%s %s %s 0", svgNum(half/2), svgNum(dy*2), svgNum(half))
+		}
+		fmt.Fprintf(&r.buf, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%s\"/>\n", d.String(), style.ul, svgNum(thick))
+	case style.flags&CellFlagDottedUnderline != 0:
+		line(y, style.ul, " stroke-dasharray=\""+svgNum(thick)+" "+svgNum(thick*2)+"\"")
+	case style.flags&CellFlagDashedUnderline != 0:
+		line(y, style.ul, " stroke-dasharray=\""+svgNum(thick*4)+" "+svgNum(thick*2)+"\"")
+	case style.flags&CellFlagUnderline != 0:
+		line(y, style.ul, "")
+	}
+
+	if style.flags&CellFlagStrike != 0 {
+		line(top+r.ch*0.5, style.fg, "")
+	}
+}
+
+// writeCursor draws the cursor according to its style.
+func (r *svgRenderer) writeCursor(line []Cell, cursor Cursor) {
+	col := min(cursor.Col, r.cols-1)
+	x := r.originX + float64(col)*r.cw
+	y := r.originY + float64(cursor.Row)*r.ch
+	w := r.cw
+	if col < len(line) && line[col].IsWide() {
+		w *= 2
+	}
+	color := cssColor(r.t.resolveColorLocked(&NamedColor{Name: NamedColorCursor}, true))
+	thick := max(1, r.cw/6)
+
+	class := ""
+	switch cursor.Style {
+	case CursorStyleBlinkingBlock, CursorStyleBlinkingUnderline, CursorStyleBlinkingBar:
+		class = " class=\"blink\""
+		r.blinkUsed = true
+	}
+
+	switch cursor.Style {
+	case CursorStyleBlinkingUnderline, CursorStyleSteadyUnderline:
+		fmt.Fprintf(&r.buf, "<rect%s x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			class, svgNum(x), svgNum(y+r.ch-thick), svgNum(w), svgNum(thick), color)
+	case CursorStyleBlinkingBar, CursorStyleSteadyBar:
+		fmt.Fprintf(&r.buf, "<rect%s x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			class, svgNum(x), svgNum(y), svgNum(thick), svgNum(r.ch), color)
+	default:
+		fmt.Fprintf(&r.buf, "<g%s>\n<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			class, svgNum(x), svgNum(y), svgNum(w), svgNum(r.ch), color)
+		// Redraw the character under the block in the background color
+		if col < len(line) {
+			c := &line[col]
+			if ch := printableRune(c); ch != ' ' && !isProceduralGlyph(ch) {
+				bg := cssColor(r.t.resolveCellColorsLocked(c).Bg)
+				fmt.Fprintf(&r.buf, "<text x=\"%s\" y=\"%s\" fill=\"%s\">%s</text>\n",
+					svgNum(x), svgNum(y+r.ch*0.78), bg, html.EscapeString(string(ch)))
+			}
+		}
+		r.buf.WriteString("</g>\n")
+	}
+}
+
+// writeImages embeds image placements either behind (ZIndex < 0) or in front of the text.
+func (r *svgRenderer) writeImages(behind bool) {
+	placements := r.t.images.Placements()
+	sort.Slice(placements, func(i, j int) bool {
+		if placements[i].ZIndex != placements[j].ZIndex {
+			return placements[i].ZIndex < placements[j].ZIndex
+		}
+		return placements[i].ID < placements[j].ID
+	})
+
+	for _, p := range placements {
+		if (p.ZIndex < 0) != behind || p.Row+p.Rows <= 0 || p.Row >= r.rows {
+			continue
+		}
+		uri := placementDataURI(r.t.images, p)
+		if uri == "" {
+			continue
+		}
+		fmt.Fprintf(&r.buf, "<image href=\"%s\" x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" preserveAspectRatio=\"none\"/>\n",
+			uri,
+			svgNum(r.originX+float64(p.Col)*r.cw+float64(p.OffsetX)),
+			svgNum(r.originY+float64(p.Row)*r.ch+float64(p.OffsetY)),
+			svgNum(float64(p.Cols)*r.cw), svgNum(float64(p.Rows)*r.ch))
+	}
+}
+
+// svgNum formats a coordinate with at most two decimals.
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,117 @@
+package headlessterm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// assertWellFormedXML fails if the document cannot be parsed.
+func assertWellFormedXML(t *testing.T, doc string) {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, doc)
+		}
+	}
+}
+
+func TestExportSVG_Content(t *testing.T) {
+	term := New(WithSize(4, 20))
+	term.WriteString("\x1b]2;demo & test\x07")
+	term.WriteString("\x1b[1;32mok\x1b[0m <tag> \x1b[4:3mwave\x1b[0m \x1b[9mx\x1b[0m\r\n")
+	term.WriteString("┌──┐\r\n╚══╝ █▌░\r\n")
+	term.WriteString("\x1b[44m  \x1b[0m")
+
+	var buf bytes.Buffer
+	if err := term.ExportSVG(&buf, SVGOptions{WindowChrome: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertWellFormedXML(t, out)
+
+	for _, want := range []string{
+		"demo &amp; test",
+		"font-weight=\"bold\">ok</text>",
+		"&lt;tag&gt;",
+		"<path d=\"M",
+		"fill-opacity=\"0.25\"",
+		"<circle",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.ContainsAny(out, "┌─┐╚═╝█▌░") {
+		t.Error("box-drawing characters should be drawn as geometry")
+	}
+}
+
+func TestExportSVG_Cursor(t *testing.T) {
+	term := New(WithSize(2, 10))
+	term.WriteString("ab\x1b[1 q")
+
+	var buf bytes.Buffer
+	term.ExportSVG(&buf, SVGOptions{FontSize: 10, CellWidth: 6, CellHeight: 12, Padding: 2})
+	if !strings.Contains(buf.String(), "<g class=\"blink\">\n<rect x=\"14\" y=\"2\" width=\"6\" height=\"12\"") {
+		t.Errorf("blinking block cursor missing:\n%s", buf.String())
+	}
+
+	term.WriteString("\x1b[6 q")
+	buf.Reset()
+	term.ExportSVG(&buf, SVGOptions{FontSize: 10, CellWidth: 6, CellHeight: 12, Padding: 2})
+	if !strings.Contains(buf.String(), "<rect x=\"14\" y=\"2\" width=\"1\" height=\"12\"") {
+		t.Errorf("steady bar cursor missing:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	term.ExportSVG(&buf, SVGOptions{HideCursor: true})
+	if strings.Contains(buf.String(), "height=\"16.8\" fill=\"#e5e5e5\"") {
+		t.Error("cursor should be hidden")
+	}
+}
+
+func TestExportSVG_Image(t *testing.T) {
+	term := New(WithSize(5, 10))
+	term.WriteString("\x1b_Gf=32,s=2,v=2,a=T;AAAA/wAAAP8AAAD/AAAA/w==\x1b\\")
+
+	var buf bytes.Buffer
+	term.ExportSVG(&buf, SVGOptions{})
+	assertWellFormedXML(t, buf.String())
+	if !strings.Contains(buf.String(), "<image href=\"data:image/png;base64,") {
+		t.Errorf("image not embedded:\n%s", buf.String())
+	}
+}
+
+func TestExportAnimatedSVG(t *testing.T) {
+	term := New(WithSize(2, 10))
+	var frames []SVGFrame
+	for _, s := range []string{"$ ", "l", "s\r\n"} {
+		term.WriteString(s)
+		frames = append(frames, SVGFrame{Frame: term.Frame(), Duration: 500 * time.Millisecond})
+	}
+
+	var buf bytes.Buffer
+	if err := term.ExportAnimatedSVG(&buf, frames, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertWellFormedXML(t, out)
+	for _, want := range []string{"@keyframes f0{0%{opacity:1}33.33%{opacity:0}}", "@keyframes f2{0%{opacity:0}66.67%{opacity:1}100%{opacity:0}}", "animation:f1 1.5s step-end infinite"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := term.ExportAnimatedSVG(&buf, nil, SVGOptions{}); err == nil {
+		t.Error("expected error for no frames")
+	}
+}
@@ -76,14 +76,6 @@ const (
 	DEFAULT_COLS = 80
 )
 
-// Selection defines a rectangular text region in the terminal.
-// Start and End are normalized so Start is always before or equal to End.
-type Selection struct {
-	Start  Position
-	End    Position
-	Active bool
-}
-
 // Terminal emulates a VT220-compatible terminal without a display.
 // It maintains two buffers: primary (with scrollback) and alternate (no scrollback).
 // The active buffer switches when entering/exiting alternate screen mode.
@@ -134,11 +126,21 @@ type Terminal struct {
 	keyboardModes   []ansicode.KeyboardMode
 	modifyOtherKeys ansicode.ModifyOtherKeys
 
-	// Internal ANSI decoder
-	decoder *ansicode.Decoder
+	// Internal ANSI decoder. decodeMu guards the decoder's parser state,
+	// which Write advances without holding mu.
+	decoder  *decoder
+	decodeMu sync.Mutex
 
 	// Selection
-	selection Selection
+	selection      selectionState
+	wordSeparators string
+
+	// Viewport display offset
+	viewport viewportState
+
+	// Implicit link detection
+	linkPatterns []LinkPattern
+	links        linkCache
 
 	// Scrollback provider
 	scrollbackStorage ScrollbackProvider
@@ -164,6 +166,9 @@ type Terminal struct {
 	// Semantic prompt handler (OSC 133)
 	semanticPromptHandler SemanticPromptHandler
 	promptMarks           []PromptMark
+	promptMarksDropped    int // Marks removed from the front of promptMarks
+	commands              []Command
+	pendingCommand        *Command // Command whose prompt is shown, until its C mark
 
 	// Working directory (OSC 7)
 	workingDir string
@@ -174,6 +179,17 @@ type Terminal struct {
 	// Image manager for Sixel and Kitty graphics
 	images *ImageManager
 
+	// Change tracking
+	clock     *generationClock
+	screenGen Generation // Last resize, buffer switch or state restore
+	sizeGen   Generation // Last change of dimensions
+
+	// Wakes WaitFor callers after each Write
+	written writeSignal
+
+	// Change notification subscribers
+	events eventHub
+
 	// Image protocol flags
 	sixelEnabled bool
 	kittyEnabled bool
@@ -357,6 +373,7 @@ func New(opts ...Option) *Terminal {
 		rows:                 DEFAULT_ROWS,
 		cols:                 DEFAULT_COLS,
 		colors:               make(map[int]color.Color),
+		wordSeparators:       DefaultWordSeparators,
 		keyboardModes:        make([]ansicode.KeyboardMode, 0),
 		bellProvider:         NoopBell{},
 		titleProvider:        NoopTitle{},
@@ -382,6 +399,10 @@ func New(opts ...Option) *Terminal {
 	t.primaryBuffer = NewBufferWithStorage(t.rows, t.cols, t.scrollbackStorage)
 	t.alternateBuffer = NewBuffer(t.rows, t.cols) // Alternate buffer has no scrollback
 	t.activeBuffer = t.primaryBuffer
+	t.clock = &generationClock{}
+	t.primaryBuffer.setClock(t.clock)
+	t.alternateBuffer.setClock(t.clock)
+	t.markScreenChangedLocked() // Generation 0 always means "nothing seen yet"
 
 	t.cursor = NewCursor()
 	t.template = NewCellTemplate()
@@ -392,10 +413,11 @@ func New(opts ...Option) *Terminal {
 	t.modes = ModeLineWrap | ModeShowCursor
 
 	// Create internal decoder
-	t.decoder = ansicode.NewDecoder(t)
+	t.decoder = newDecoder(t)
 
 	// Create image manager
 	t.images = NewImageManager()
+	t.images.setClock(t.clock)
 
 	return t
 }
@@ -423,6 +445,19 @@ func (t *Terminal) Cell(row, col int) *Cell {
 	return t.activeBuffer.Cell(row, col)
 }
 
+// SetCell replaces the cell at (row, col) in the active buffer, bypassing the
+// parser. It is meant for compositing: drawing content produced elsewhere
+// into a terminal that serves as a virtual screen.
+// Does nothing if coordinates are out of bounds.
+func (t *Terminal) SetCell(row, col int, cell Cell) {
+	defer t.written.broadcast()
+	defer t.flushEvents()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.activeBuffer.SetCell(row, col, cell)
+}
+
 // CursorPos returns the current cursor position (0-based).
 func (t *Terminal) CursorPos() (row, col int) {
 	t.mu.RLock()
@@ -466,6 +501,8 @@ func (t *Terminal) Resize(rows, cols int) {
 	if rows <= 0 || cols <= 0 {
 		return
 	}
+	defer t.written.broadcast()
+	defer t.flushEvents()
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -492,6 +529,7 @@ func (t *Terminal) Resize(rows, cols int) {
 	t.cols = cols
 	t.primaryBuffer.Resize(rows, cols)
 	t.alternateBuffer.Resize(rows, cols)
+	t.markScreenChangedLocked()
 
 	// When growing rows on primary buffer, pull lines from scrollback
 	// to restore previously scrolled content
@@ -507,14 +545,17 @@ func (t *Terminal) Resize(rows, cols int) {
 			// Pop lines from scrollback (most recent first) and collect them
 			// We need to reverse because Pop returns newest first
 			lines := make([][]Cell, linesToPull)
+			wraps := make([]bool, linesToPull)
 			for i := linesToPull - 1; i >= 0; i-- {
 				line := scrollback.Pop()
 				if line == nil {
 					linesToPull = linesToPull - 1 - i
 					lines = lines[linesToPull-1-i:]
+					wraps = wraps[linesToPull-1-i:]
 					break
 				}
 				lines[i] = line
+				wraps[i] = t.primaryBuffer.popScrollbackWrapped()
 			}
 
 			if len(lines) > 0 {
@@ -529,6 +570,7 @@ func (t *Terminal) Resize(rows, cols int) {
 							t.primaryBuffer.SetCell(i, col, cell)
 						}
 					}
+					t.primaryBuffer.SetWrapped(i, wraps[i])
 				}
 
 				// Adjust cursor position to account for the shift
@@ -560,7 +602,12 @@ func (t *Terminal) Resize(rows, cols int) {
 // Implements io.Writer.
 func (t *Terminal) Write(data []byte) (int, error) {
 	t.recordingProvider.Record(data)
-	return t.decoder.Write(data)
+	t.decodeMu.Lock()
+	n, err := t.decoder.Write(data)
+	t.decodeMu.Unlock()
+	t.flushEvents()
+	t.written.broadcast()
+	return n, err
 }
 
 // WriteString is a convenience method that converts the string to bytes and calls Write.
@@ -597,6 +644,7 @@ func (t *Terminal) scrollIfNeeded() {
 			t.activeBuffer.GrowRows(rowsToAdd)
 			t.rows = t.activeBuffer.Rows()
 			t.scrollBottom = t.rows
+			t.markSizeChangedLocked()
 		} else {
 			linesToScroll := t.cursor.Row - t.scrollBottom + 1
 			t.activeBuffer.ScrollUp(t.scrollTop, t.scrollBottom, linesToScroll)
@@ -772,27 +820,92 @@ func (t *Terminal) ScrollbackLine(index int) []Cell {
 // ViewportRowToAbsolute converts a viewport row (0 to Rows()-1) to an absolute row.
 // Absolute rows include the scrollback offset and are used by shell integration
 // functions like NextPromptRow, PrevPromptRow, and GetPromptMarkAt.
+// The display offset is taken into account (see ScrollViewport).
 func (t *Terminal) ViewportRowToAbsolute(viewportRow int) int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return viewportRow + t.primaryBuffer.ScrollbackLen()
+	return viewportRow + t.viewportTopLocked()
 }
 
 // AbsoluteRowToViewport converts an absolute row to a viewport row.
-// Returns -1 if the row is in scrollback or beyond the visible viewport.
+// Returns -1 if the row is above or below the viewport at the current display offset.
 // Use this to convert shell integration row values (like PromptMark.Row)
-// to viewport coordinates for functions like Cell() or LineContent().
+// to viewport coordinates for functions like ViewportCell() or ViewportLine().
 func (t *Terminal) AbsoluteRowToViewport(absoluteRow int) int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	scrollbackLen := t.primaryBuffer.ScrollbackLen()
-	viewportRow := absoluteRow - scrollbackLen
+	viewportRow := absoluteRow - t.viewportTopLocked()
 	if viewportRow < 0 || viewportRow >= t.rows {
 		return -1
 	}
 	return viewportRow
 }
 
+// AbsoluteRowToLineID returns the stable ID of the line at an absolute row.
+// Line IDs count every line since the terminal was created: unlike absolute
+// rows, they do not change when the scrollback evicts its oldest lines.
+// Prompt marks, commands, selections and image placements are anchored to
+// line IDs.
+func (t *Terminal) AbsoluteRowToLineID(absoluteRow int) uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lineIDLocked(absoluteRow)
+}
+
+// LineIDToAbsoluteRow converts a line ID to the current absolute row of the
+// line. Returns -1 if the line has been evicted from the scrollback.
+func (t *Terminal) LineIDToAbsoluteRow(id uint64) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lineRowLocked(id)
+}
+
+// EvictedLines returns the number of lines dropped from the front of the
+// scrollback, or scrolled off the top of the screen when there is no
+// scrollback. It is also the line ID of the oldest stored line.
+func (t *Terminal) EvictedLines() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.primaryBuffer.evicted
+}
+
+// lineIDLocked returns the line ID of an absolute row (caller must hold lock).
+func (t *Terminal) lineIDLocked(absoluteRow int) uint64 {
+	return t.primaryBuffer.scrollbackLineID(max(absoluteRow, 0))
+}
+
+// lineRowLocked returns the absolute row of a line ID, or -1 if the line
+// was evicted (caller must hold lock).
+func (t *Terminal) lineRowLocked(id uint64) int {
+	if id < t.primaryBuffer.evicted {
+		return -1
+	}
+	return int(id - t.primaryBuffer.evicted)
+}
+
+// linePos is a position anchored to a line ID, so it stays on its line when
+// the scrollback evicts lines.
+type linePos struct {
+	line uint64
+	col  int
+}
+
+// linePosLocked anchors an absolute position to its line (caller must hold lock).
+func (t *Terminal) linePosLocked(pos Position) linePos {
+	return linePos{line: t.lineIDLocked(pos.Row), col: pos.Col}
+}
+
+// absolutePosLocked returns the absolute position of an anchored position.
+// A position on an evicted line is moved to the start of the oldest line
+// and reported with false (caller must hold lock).
+func (t *Terminal) absolutePosLocked(p linePos) (Position, bool) {
+	row := t.lineRowLocked(p.line)
+	if row < 0 {
+		return Position{}, false
+	}
+	return Position{Row: row, Col: p.col}, true
+}
+
 // ClearScrollback removes all stored scrollback lines.
 func (t *Terminal) ClearScrollback() {
 	t.mu.Lock()
@@ -840,6 +953,8 @@ func (t *Terminal) HasDirty() bool {
 }
 
 // DirtyCells returns positions of all cells modified since the last ClearDirty call.
+// Scrolling marks every moved cell dirty; DamageSince reports row spans and
+// scrolls instead, without shared state to clear.
 func (t *Terminal) DirtyCells() []Position {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -853,116 +968,6 @@ func (t *Terminal) ClearDirty() {
 	t.activeBuffer.ClearAllDirty()
 }
 
-// --- Selection Methods ---
-
-// SetSelection sets the active text selection region.
-// Start and end are automatically normalized so start is before or equal to end.
-func (t *Terminal) SetSelection(start, end Position) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	// Normalize: ensure start is before end
-	if end.Before(start) {
-		start, end = end, start
-	}
-
-	t.selection = Selection{
-		Start:  start,
-		End:    end,
-		Active: true,
-	}
-}
-
-// ClearSelection deactivates the current selection.
-func (t *Terminal) ClearSelection() {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	t.selection.Active = false
-}
-
-// GetSelection returns the current selection state.
-func (t *Terminal) GetSelection() Selection {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	return t.selection
-}
-
-// HasSelection returns true if a selection is currently active.
-func (t *Terminal) HasSelection() bool {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	return t.selection.Active
-}
-
-// IsSelected returns true if the cell at (row, col) is within the active selection.
-func (t *Terminal) IsSelected(row, col int) bool {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-
-	if !t.selection.Active {
-		return false
-	}
-
-	pos := Position{Row: row, Col: col}
-	start := t.selection.Start
-	end := t.selection.End
-
-	// Check if position is within selection range
-	if pos.Before(start) {
-		return false
-	}
-	if end.Before(pos) {
-		return false
-	}
-	return true
-}
-
-// GetSelectedText extracts and returns the text content within the active selection.
-// Empty cells are converted to spaces, and newlines separate rows.
-func (t *Terminal) GetSelectedText() string {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-
-	if !t.selection.Active {
-		return ""
-	}
-
-	start := t.selection.Start
-	end := t.selection.End
-
-	var result []rune
-
-	for row := start.Row; row <= end.Row && row < t.rows; row++ {
-		startCol := 0
-		endCol := t.cols
-
-		if row == start.Row {
-			startCol = start.Col
-		}
-		if row == end.Row {
-			endCol = end.Col + 1
-		}
-
-		for col := startCol; col < endCol && col < t.cols; col++ {
-			cell := t.activeBuffer.Cell(row, col)
-			if cell != nil && !cell.IsWideSpacer() {
-				if cell.Char == 0 {
-					result = append(result, ' ')
-				} else {
-					result = append(result, cell.Char)
-				}
-			}
-		}
-
-		// Add newline between rows (but not after last row)
-		if row < end.Row {
-			result = append(result, '\n')
-		}
-	}
-
-	return string(result)
-}
-
 // --- Convenience Methods ---
 
 // LineContent returns the text content of a line, trimming trailing spaces.
@@ -1042,6 +1047,7 @@ func (t *Terminal) Search(pattern string) []Position {
 
 // SearchScrollback finds all occurrences of pattern in scrollback lines.
 // Returned row values are negative, where -1 is the most recent scrollback line.
+// With an IndexedScrollback only lines containing the pattern's trigrams are scanned.
 func (t *Terminal) SearchScrollback(pattern string) []Position {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -1053,8 +1059,16 @@ func (t *Terminal) SearchScrollback(pattern string) []Position {
 	var matches []Position
 	patternRunes := []rune(pattern)
 	scrollbackLen := t.primaryBuffer.ScrollbackLen()
+	candidates, indexed := t.scrollbackCandidatesLocked(literalTrigrams(pattern))
 
 	for i := 0; i < scrollbackLen; i++ {
+		if indexed {
+			// Skip to the next line containing all trigrams of the pattern
+			if len(candidates) == 0 {
+				break
+			}
+			i, candidates = candidates[0], candidates[1:]
+		}
 		line := t.primaryBuffer.ScrollbackLine(i)
 		if line == nil {
 			continue
@@ -1173,6 +1187,20 @@ func (t *Terminal) ImagePlacements() []*ImagePlacement {
 	return t.images.Placements()
 }
 
+// ImagePlacementRow returns the current absolute row of the top of an image
+// placement, which follows its line into the scrollback. Returns -1 if the
+// placement does not exist, was made on the alternate screen, or its line
+// was evicted from the scrollback.
+func (t *Terminal) ImagePlacementRow(placementID uint32) int {
+	p := t.images.Placement(placementID)
+	if p == nil || !p.Anchored {
+		return -1
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lineRowLocked(p.LineID)
+}
+
 // ImageCount returns the number of stored images.
 func (t *Terminal) ImageCount() int {
 	return t.images.ImageCount()
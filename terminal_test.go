@@ -93,6 +93,48 @@ func TestTerminalScrollback(t *testing.T) {
 	}
 }
 
+func TestTerminalScrollingRegion(t *testing.T) {
+	tests := []struct {
+		seq         string
+		top, bottom int
+	}{
+		{"\x1b[2;4r", 1, 4},
+		{"\x1b[3r", 2, 5},
+		{"\x1b[3;r", 2, 5},
+		{"\x1b[;4r", 0, 4},
+		{"\x1b[2;9r", 1, 5},
+		{"\x1b[r", 0, 5},
+		{"\x1b[0;0r", 0, 5},
+		// Invalid regions leave the previous one
+		{"\x1b[;1r", 1, 3},
+		{"\x1b[1;1r", 1, 3},
+		{"\x1b[3;3r", 1, 3},
+		{"\x1b[4;2r", 1, 3},
+		// Not DECSTBM
+		{"\x1b[?1r", 1, 3},
+	}
+	for _, tt := range tests {
+		term := New(WithSize(5, 10))
+		term.WriteString("\x1b[2;3r" + tt.seq)
+		if top, bottom := term.ScrollRegion(); top != tt.top || bottom != tt.bottom {
+			t.Errorf("%q: ScrollRegion = (%d,%d), want (%d,%d)", tt.seq, top, bottom, tt.top, tt.bottom)
+		}
+	}
+
+	// Middleware sees missing parameters as 0
+	var got [2]int
+	term := New(WithSize(5, 10), WithMiddleware(&Middleware{
+		SetScrollingRegion: func(top, bottom int, next func(int, int)) {
+			got = [2]int{top, bottom}
+			next(top, bottom)
+		},
+	}))
+	term.WriteString("\x1b[3r")
+	if got != [2]int{3, 0} {
+		t.Errorf("middleware got %v, want [3 0]", got)
+	}
+}
+
 func TestTerminalSelection(t *testing.T) {
 	term := New(WithSize(24, 80))
 
@@ -145,6 +187,24 @@ func TestTerminalString(t *testing.T) {
 	}
 }
 
+func TestTerminalSetCell(t *testing.T) {
+	term := New(WithSize(3, 10))
+	gen := term.Generation()
+
+	cell := NewCell()
+	cell.Char = 'X'
+	cell.SetFlag(CellFlagBold)
+	term.SetCell(1, 2, cell)
+	term.SetCell(5, 5, cell) // out of bounds, ignored
+
+	if got := term.Cell(1, 2); got.Char != 'X' || !got.HasFlag(CellFlagBold) {
+		t.Errorf("cell = %+v, want bold X", got)
+	}
+	if d := term.DamageSince(gen); len(d.Spans) != 1 || d.Spans[0] != (DamageSpan{Row: 1, StartCol: 2, EndCol: 3}) {
+		t.Errorf("damage = %+v", d.Spans)
+	}
+}
+
 func TestTerminalDirtyTracking(t *testing.T) {
 	term := New(WithSize(24, 80))
 
@@ -528,7 +588,7 @@ func TestResponseWriter(t *testing.T) {
 
 	term := New(
 		WithSize(24, 80),
-		WithResponse(writer),
+		WithPTYWriter(writer),
 	)
 
 	// Device status request (should trigger a response)
@@ -0,0 +1,204 @@
+package headlessterm
+
+import "github.com/danielgatis/go-ansicode"
+
+// viewportState is the part of the scrollback and screen on display.
+type viewportState struct {
+	scrolled bool       // The viewport is scrolled into the scrollback
+	top      uint64     // Line ID of the top row while scrolled (see AbsoluteRowToLineID)
+	gen      Generation // Generation when the viewport was last scrolled
+	stayPut  bool       // Keep showing the same lines while output arrives
+}
+
+// WithViewportStayPut keeps a viewport scrolled into the scrollback on the
+// same lines while new output arrives. By default, new output returns the
+// viewport to the bottom.
+func WithViewportStayPut() Option {
+	return func(t *Terminal) {
+		t.viewport.stayPut = true
+	}
+}
+
+// SetViewportStayPut sets whether a scrolled viewport stays on the same
+// lines while new output arrives.
+func (t *Terminal) SetViewportStayPut(stayPut bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Apply pending output under the old behaviour
+	offset := t.displayOffsetLocked()
+	t.viewport.stayPut = stayPut
+	t.setDisplayOffsetLocked(offset)
+}
+
+// DisplayOffset returns how many lines the viewport is scrolled up into the
+// scrollback (0 shows the live screen).
+func (t *Terminal) DisplayOffset() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.displayOffsetLocked()
+}
+
+// displayOffsetLocked returns the display offset (caller must hold lock).
+// The alternate screen has no scrollback, so it is always shown live.
+func (t *Terminal) displayOffsetLocked() int {
+	v := &t.viewport
+	if !v.scrolled || t.activeBuffer != t.primaryBuffer {
+		return 0
+	}
+	if !v.stayPut && t.clock.now() != v.gen {
+		return 0
+	}
+	sb := t.primaryBuffer.ScrollbackLen()
+	return sb - clamp(t.lineRowLocked(v.top), 0, sb)
+}
+
+// setDisplayOffsetLocked scrolls the viewport to offset, clamped to the
+// scrollback (caller must hold lock).
+func (t *Terminal) setDisplayOffsetLocked(offset int) int {
+	sb := t.primaryBuffer.ScrollbackLen()
+	if t.activeBuffer != t.primaryBuffer {
+		offset = 0
+	}
+	offset = clamp(offset, 0, sb)
+	t.viewport.scrolled = offset > 0
+	t.viewport.top = t.lineIDLocked(sb - offset)
+	t.viewport.gen = t.clock.now()
+	return offset
+}
+
+// ScrollViewport scrolls the viewport by delta lines, up into the
+// scrollback for positive delta and down towards the live screen for
+// negative delta. It returns the new display offset.
+//
+// Example:
+//
+//	term.ScrollViewport(term.Rows()) // Page up
+//	snap := term.SnapshotViewport(headlessterm.SnapshotDetailStyled)
+func (t *Terminal) ScrollViewport(delta int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.setDisplayOffsetLocked(t.displayOffsetLocked() + delta)
+}
+
+// ScrollToTop scrolls the viewport to the oldest scrollback line.
+func (t *Terminal) ScrollToTop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.setDisplayOffsetLocked(t.primaryBuffer.ScrollbackLen())
+}
+
+// ScrollToBottom returns the viewport to the live screen.
+func (t *Terminal) ScrollToBottom() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.setDisplayOffsetLocked(0)
+}
+
+// ScrollToPrevPrompt scrolls the viewport so the previous OSC 133 prompt
+// above its top row is at the top. It returns false if there is none.
+func (t *Terminal) ScrollToPrevPrompt() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scrollToPromptLocked(t.prevPromptRowLocked(t.viewportTopLocked(), ansicode.PromptStart))
+}
+
+// ScrollToNextPrompt scrolls the viewport so the next OSC 133 prompt below
+// its top row is at the top, or as close as the live screen allows. It
+// returns false if there is none.
+func (t *Terminal) ScrollToNextPrompt() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scrollToPromptLocked(t.nextPromptRowLocked(t.viewportTopLocked(), ansicode.PromptStart))
+}
+
+// scrollToPromptLocked puts an absolute row at the top of the viewport (caller must hold lock).
+func (t *Terminal) scrollToPromptLocked(row int) bool {
+	if row < 0 {
+		return false
+	}
+	t.setDisplayOffsetLocked(t.primaryBuffer.ScrollbackLen() - row)
+	return true
+}
+
+// viewportTopLocked returns the absolute row at the top of the viewport (caller must hold lock).
+func (t *Terminal) viewportTopLocked() int {
+	return t.primaryBuffer.ScrollbackLen() - t.displayOffsetLocked()
+}
+
+// viewportRowLocked returns the cells shown in a viewport row (caller must hold lock).
+func (t *Terminal) viewportRowLocked(row int) ([]Cell, bool) {
+	if row < 0 || row >= t.rows {
+		return nil, false
+	}
+	cells, _, ok := t.searchRowLocked(t.viewportTopLocked() + row)
+	return cells, ok
+}
+
+// ViewportCell returns a copy of the cell shown at (row, col) of the
+// viewport, read from the scrollback or the screen depending on the
+// display offset. Returns nil if out of bounds.
+func (t *Terminal) ViewportCell(row, col int) *Cell {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	cells, ok := t.viewportRowLocked(row)
+	if !ok || col < 0 || col >= len(cells) || col >= t.cols {
+		return nil
+	}
+	c := cells[col]
+	return &c
+}
+
+// ViewportLine returns a copy of the cells shown in a viewport row, read
+// from the scrollback or the screen depending on the display offset.
+// Scrollback lines written at another width are padded or cut to Cols().
+func (t *Terminal) ViewportLine(row int) []Cell {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	cells, ok := t.viewportRowLocked(row)
+	if !ok {
+		return nil
+	}
+	line := make([]Cell, t.cols)
+	n := copy(line, cells)
+	for col := n; col < t.cols; col++ {
+		line[col] = NewCell()
+	}
+	return line
+}
+
+// SnapshotViewport is like Snapshot but captures the viewport at the
+// current display offset. The cursor row is where the cursor appears in
+// the viewport; the cursor is reported hidden when scrolled out of view.
+// Image placement rows are shifted the same way.
+func (t *Terminal) SnapshotViewport(detail SnapshotDetail) *Snapshot {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	offset := t.displayOffsetLocked()
+	snap := &Snapshot{
+		Size: SnapshotSize{
+			Rows: t.rows,
+			Cols: t.cols,
+		},
+		Cursor:        t.snapshotCursor(),
+		Lines:         make([]SnapshotLine, t.rows),
+		Generation:    t.clock.now(),
+		DisplayOffset: offset,
+	}
+	for row := 0; row < t.rows; row++ {
+		cells, _ := t.viewportRowLocked(row)
+		snap.Lines[row] = t.snapshotCells(cells, detail)
+	}
+
+	snap.Cursor.Row += offset
+	if snap.Cursor.Row >= t.rows {
+		snap.Cursor.Visible = false
+	}
+	snap.Images = t.snapshotImages()
+	for i := range snap.Images {
+		snap.Images[i].Row += offset
+	}
+	return snap
+}
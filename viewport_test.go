@@ -0,0 +1,134 @@
+package headlessterm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newScrolledTerm returns a 3-row terminal with lines "line0" to "line9".
+func newScrolledTerm(opts ...Option) *Terminal {
+	term := New(append([]Option{WithSize(3, 10), WithScrollback(NewMemoryScrollback(100))}, opts...)...)
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			term.WriteString("\r\n")
+		}
+		fmt.Fprintf(term, "line%d", i)
+	}
+	return term
+}
+
+func viewportText(term *Terminal) string {
+	var lines []string
+	for row := 0; row < term.Rows(); row++ {
+		lines = append(lines, term.cellsToString(term.ViewportLine(row)))
+	}
+	return strings.Join(lines, "|")
+}
+
+func TestScrollViewport(t *testing.T) {
+	term := newScrolledTerm()
+
+	if got := term.ScrollViewport(2); got != 2 || term.DisplayOffset() != 2 {
+		t.Fatalf("offset = %d", got)
+	}
+	if got := viewportText(term); got != "line5|line6|line7" {
+		t.Errorf("viewport = %q", got)
+	}
+	if c := term.ViewportCell(0, 4); c == nil || c.Char != '5' {
+		t.Errorf("ViewportCell = %+v", c)
+	}
+	if row := term.ViewportRowToAbsolute(0); row != 5 || term.AbsoluteRowToViewport(7) != 2 || term.AbsoluteRowToViewport(9) != -1 {
+		t.Errorf("conversions at offset 2: top %d", row)
+	}
+
+	if got := term.ScrollViewport(100); got != 7 {
+		t.Errorf("offset clamped to %d, want 7", got)
+	}
+	term.ScrollToBottom()
+	if got := viewportText(term); got != "line7|line8|line9" || term.DisplayOffset() != 0 {
+		t.Errorf("bottom viewport = %q", got)
+	}
+	term.ScrollToTop()
+	if got := viewportText(term); got != "line0|line1|line2" {
+		t.Errorf("top viewport = %q", got)
+	}
+	if got := term.ScrollViewport(-3); got != 4 {
+		t.Errorf("offset after scrolling down = %d", got)
+	}
+}
+
+func TestScrollViewport_Output(t *testing.T) {
+	term := newScrolledTerm()
+	term.ScrollViewport(4)
+	term.WriteString("\r\nmore")
+	if term.DisplayOffset() != 0 {
+		t.Errorf("output did not return to the bottom: offset %d", term.DisplayOffset())
+	}
+
+	term = newScrolledTerm(WithViewportStayPut())
+	term.ScrollViewport(4)
+	term.WriteString("\r\nmore\r\nand more")
+	if got := viewportText(term); got != "line3|line4|line5" || term.DisplayOffset() != 6 {
+		t.Errorf("stay-put viewport = %q at offset %d", got, term.DisplayOffset())
+	}
+
+	// Evicting the top line clamps the viewport to the oldest line
+	term = newScrolledTerm(WithViewportStayPut())
+	term.SetMaxScrollback(7)
+	term.ScrollToTop()
+	term.WriteString("\r\nmore")
+	if got := viewportText(term); got != "line1|line2|line3" {
+		t.Errorf("viewport after eviction = %q", got)
+	}
+
+	// The alternate screen is always live
+	term.WriteString("\x1b[?1049h")
+	if term.ScrollViewport(2) != 0 || term.DisplayOffset() != 0 {
+		t.Error("alternate screen scrolled")
+	}
+}
+
+func TestScrollToPrompt(t *testing.T) {
+	term := New(WithSize(3, 20), WithScrollback(NewMemoryScrollback(100)))
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(term, "\x1b]133;A\x07$ cmd%d\r\nout%d\r\nout%d\r\n", i, i, i)
+	}
+	term.WriteString("\x1b]133;A\x07$ ")
+
+	if !term.ScrollToPrevPrompt() || viewportText(term) != "$ cmd2|out2|out2" {
+		t.Errorf("previous prompt: %q", viewportText(term))
+	}
+	if !term.ScrollToPrevPrompt() || viewportText(term) != "$ cmd1|out1|out1" {
+		t.Errorf("second previous prompt: %q", viewportText(term))
+	}
+	term.ScrollToPrevPrompt()
+	if term.ScrollToPrevPrompt() {
+		t.Error("found a prompt above the first one")
+	}
+	if !term.ScrollToNextPrompt() || viewportText(term) != "$ cmd1|out1|out1" {
+		t.Errorf("next prompt: %q", viewportText(term))
+	}
+}
+
+func TestSnapshotViewport(t *testing.T) {
+	term := newScrolledTerm()
+	term.ScrollViewport(1)
+
+	snap := term.SnapshotViewport(SnapshotDetailStyled)
+	if snap.DisplayOffset != 1 || snap.Lines[0].Text != "line6" || snap.Lines[2].Text != "line8" {
+		t.Errorf("snapshot = %+v", snap.Lines)
+	}
+	if snap.Cursor.Row != 3 || snap.Cursor.Visible {
+		t.Errorf("cursor = %+v", snap.Cursor)
+	}
+	if len(snap.Lines[0].Segments) != 1 || snap.Lines[0].Segments[0].Text != "line6     " {
+		t.Errorf("segments = %+v", snap.Lines[0].Segments)
+	}
+
+	term.ScrollToBottom()
+	live := term.Snapshot(SnapshotDetailFull)
+	if got := term.SnapshotViewport(SnapshotDetailFull); got.Lines[2].Text != live.Lines[2].Text || got.Cursor != live.Cursor {
+		t.Errorf("snapshot at the bottom differs from Snapshot")
+	}
+}
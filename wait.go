@@ -0,0 +1,355 @@
+package headlessterm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// WaitCondition is a condition awaited by WaitFor.
+// Check is called once when WaitFor starts and again after every Write,
+// SetCell, Resize and LoadState.
+// A positive recheck asks WaitFor to check again after that delay even if no
+// output arrives (used by time-based conditions such as WaitStable).
+//
+// Built-in conditions keep state from their first Check, so create a new
+// condition for every WaitFor call.
+type WaitCondition interface {
+	Check(t *Terminal) (met bool, recheck time.Duration)
+}
+
+// WaitFunc adapts a predicate to a WaitCondition.
+type WaitFunc func(t *Terminal) bool
+
+// Check implements WaitCondition.
+func (f WaitFunc) Check(t *Terminal) (bool, time.Duration) {
+	return f(t), 0
+}
+
+// String describes the condition in WaitFor errors.
+func (f WaitFunc) String() string { return "custom condition" }
+
+// writeSignal wakes goroutines waiting for the next Write.
+type writeSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait returns a channel that is closed by the next broadcast.
+func (s *writeSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+// broadcast wakes all current waiters.
+func (s *writeSignal) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// WaitFor blocks until cond is met or ctx is done.
+// Waiters are woken by changes to the terminal instead of polling the screen.
+// Returns an error wrapping ctx.Err() if the context ends first.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	if err := term.WaitFor(ctx, headlessterm.WaitText("$ ")); err != nil {
+//	    t.Fatal(err)
+//	}
+func (t *Terminal) WaitFor(ctx context.Context, cond WaitCondition) error {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		// Subscribe before checking so a Write in between is not missed
+		written := t.written.wait()
+		met, recheck := cond.Check(t)
+		if met {
+			return nil
+		}
+
+		var timeout <-chan time.Time
+		if recheck > 0 {
+			if timer == nil {
+				timer = time.NewTimer(recheck)
+			} else {
+				timer.Reset(recheck)
+			}
+			timeout = timer.C
+		}
+
+		select {
+		case <-written:
+		case <-timeout:
+		case <-ctx.Done():
+			return fmt.Errorf("wait for %v: %w", cond, ctx.Err())
+		}
+		if timer != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// WaitAny is met when any of the conditions is met.
+func WaitAny(conds ...WaitCondition) WaitCondition {
+	return &waitCombined{conds: conds, any: true}
+}
+
+// WaitAll is met when all of the conditions are met at the same time.
+func WaitAll(conds ...WaitCondition) WaitCondition {
+	return &waitCombined{conds: conds}
+}
+
+type waitCombined struct {
+	conds []WaitCondition
+	any   bool
+}
+
+func (w *waitCombined) Check(t *Terminal) (bool, time.Duration) {
+	all := true
+	var recheck time.Duration
+	for _, c := range w.conds {
+		met, r := c.Check(t)
+		if met && w.any {
+			return true, 0
+		}
+		all = all && met
+		if r > 0 && (recheck == 0 || r < recheck) {
+			recheck = r
+		}
+	}
+	return all && !w.any, recheck
+}
+
+func (w *waitCombined) String() string {
+	parts := make([]string, len(w.conds))
+	for i, c := range w.conds {
+		parts[i] = fmt.Sprint(c)
+	}
+	if w.any {
+		return "any of (" + strings.Join(parts, ", ") + ")"
+	}
+	return "all of (" + strings.Join(parts, ", ") + ")"
+}
+
+// WaitText is met when s appears on the screen.
+// Screen lines are joined with newlines.
+func WaitText(s string) WaitCondition {
+	return &waitText{match: func(text string) bool { return strings.Contains(text, s) }, desc: fmt.Sprintf("text %q", s)}
+}
+
+// WaitRegexp is met when re matches the screen.
+// Screen lines are joined with newlines.
+func WaitRegexp(re *regexp.Regexp) WaitCondition {
+	return &waitText{match: re.MatchString, desc: fmt.Sprintf("regexp %q", re)}
+}
+
+// WaitScrollbackText is met when s appears on the screen or in scrollback.
+func WaitScrollbackText(s string) WaitCondition {
+	return &waitText{match: func(text string) bool { return strings.Contains(text, s) }, desc: fmt.Sprintf("text %q in scrollback", s), scrollback: true}
+}
+
+// WaitScrollbackRegexp is met when re matches the screen or scrollback.
+func WaitScrollbackRegexp(re *regexp.Regexp) WaitCondition {
+	return &waitText{match: re.MatchString, desc: fmt.Sprintf("regexp %q in scrollback", re), scrollback: true}
+}
+
+type waitText struct {
+	match      func(string) bool
+	desc       string
+	scrollback bool
+}
+
+func (w *waitText) Check(t *Terminal) (bool, time.Duration) {
+	if !w.scrollback {
+		return w.match(t.String()), 0
+	}
+
+	t.mu.RLock()
+	var sb strings.Builder
+	for i := 0; i < t.primaryBuffer.ScrollbackLen(); i++ {
+		sb.WriteString(t.cellsToString(t.primaryBuffer.ScrollbackLine(i)))
+		sb.WriteByte('\n')
+	}
+	t.mu.RUnlock()
+	sb.WriteString(t.String())
+	return w.match(sb.String()), 0
+}
+
+func (w *waitText) String() string { return w.desc }
+
+// WaitStable is met when neither the screen nor the cursor has changed for d.
+func WaitStable(d time.Duration) WaitCondition {
+	return &waitStable{d: d}
+}
+
+type waitStable struct {
+	d         time.Duration
+	gen       Generation
+	row, col  int
+	since     time.Time
+	initiated bool
+}
+
+func (w *waitStable) Check(t *Terminal) (bool, time.Duration) {
+	gen := t.Generation()
+	row, col := t.CursorPos()
+	now := time.Now()
+	if !w.initiated || gen != w.gen || row != w.row || col != w.col {
+		w.gen, w.row, w.col, w.since, w.initiated = gen, row, col, now, true
+	}
+	if elapsed := now.Sub(w.since); elapsed < w.d {
+		return false, w.d - elapsed
+	}
+	return true, 0
+}
+
+func (w *waitStable) String() string { return fmt.Sprintf("screen stable for %v", w.d) }
+
+// WaitCursorAt is met when the cursor is at (row, col), 0-based.
+func WaitCursorAt(row, col int) WaitCondition {
+	return &waitFunc{desc: fmt.Sprintf("cursor at %d,%d", row, col), f: func(t *Terminal) bool {
+		r, c := t.CursorPos()
+		return r == row && c == col
+	}}
+}
+
+// WaitAltScreen is met when the alternate screen is active (entered true)
+// or the primary screen is active (entered false).
+func WaitAltScreen(entered bool) WaitCondition {
+	desc := "alternate screen left"
+	if entered {
+		desc = "alternate screen entered"
+	}
+	return &waitFunc{desc: desc, f: func(t *Terminal) bool {
+		return t.IsAlternateScreen() == entered
+	}}
+}
+
+// WaitTitle is met when the window title matches re.
+func WaitTitle(re *regexp.Regexp) WaitCondition {
+	return &waitFunc{desc: fmt.Sprintf("title %q", re), f: func(t *Terminal) bool {
+		return re.MatchString(t.Title())
+	}}
+}
+
+type waitFunc struct {
+	desc string
+	f    func(t *Terminal) bool
+}
+
+func (w *waitFunc) Check(t *Terminal) (bool, time.Duration) { return w.f(t), 0 }
+
+func (w *waitFunc) String() string { return w.desc }
+
+// WaitPromptMark is met when a prompt mark (OSC 133) of the given type is
+// recorded after WaitFor starts.
+func WaitPromptMark(mark ansicode.ShellIntegrationMark) WaitCondition {
+	return &waitPromptMark{mark: mark, start: -1}
+}
+
+type waitPromptMark struct {
+	mark  ansicode.ShellIntegrationMark
+	start int // Number of marks ever recorded when waiting started
+}
+
+func (w *waitPromptMark) Check(t *Terminal) (bool, time.Duration) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	// Restoring state may shrink the count below the starting point
+	recorded := t.promptMarksDropped + len(t.promptMarks)
+	if w.start < 0 || w.start > recorded {
+		w.start = recorded
+		return false, 0
+	}
+	for _, m := range t.promptMarks[max(w.start-t.promptMarksDropped, 0):] {
+		if m.Type == w.mark {
+			return true, 0
+		}
+	}
+	return false, 0
+}
+
+func (w *waitPromptMark) String() string { return fmt.Sprintf("prompt mark %d", w.mark) }
+
+// WaitCommandFinished is met when a CommandFinished mark (OSC 133 D) that
+// ends a command (OSC 133 C) is recorded after waiting started. The command
+// may have started before; a command that had already finished does not count.
+func WaitCommandFinished() WaitCondition {
+	return &waitCommand{exitCode: -1, start: -1}
+}
+
+// WaitCommandExit is like WaitCommandFinished but also requires the reported
+// exit code to equal code.
+func WaitCommandExit(code int) WaitCondition {
+	return &waitCommand{exitCode: code, checkCode: true, start: -1}
+}
+
+type waitCommand struct {
+	exitCode  int
+	checkCode bool
+	start     int // Number of marks ever recorded when waiting started
+}
+
+func (w *waitCommand) Check(t *Terminal) (bool, time.Duration) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	// Restoring state may shrink the count below the starting point
+	recorded := t.promptMarksDropped + len(t.promptMarks)
+	if w.start < 0 || w.start > recorded {
+		w.start = recorded
+	}
+	from := max(w.start-t.promptMarksDropped, 0)
+
+	// A command may already be running when waiting starts
+	running := false
+	for i := from - 1; i >= 0; i-- {
+		if m := t.promptMarks[i].Type; m == ansicode.CommandExecuted || m == ansicode.CommandFinished {
+			running = m == ansicode.CommandExecuted
+			break
+		}
+	}
+	for _, m := range t.promptMarks[from:] {
+		switch m.Type {
+		case ansicode.CommandExecuted:
+			running = true
+		case ansicode.CommandFinished:
+			if running && (!w.checkCode || m.ExitCode == w.exitCode) {
+				return true, 0
+			}
+			running = false
+		}
+	}
+	return false, 0
+}
+
+func (w *waitCommand) String() string {
+	if w.checkCode {
+		return fmt.Sprintf("command exit %d", w.exitCode)
+	}
+	return "command finished"
+}
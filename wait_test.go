@@ -0,0 +1,202 @@
+package headlessterm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/danielgatis/go-ansicode"
+)
+
+// waitAsync runs WaitFor in a goroutine and returns its result channel.
+func waitAsync(term *Terminal, cond WaitCondition, timeout time.Duration) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done <- term.WaitFor(ctx, cond)
+	}()
+	return done
+}
+
+func TestWaitFor_WokenByWrite(t *testing.T) {
+	term := New(WithSize(5, 20))
+	done := waitAsync(term, WaitText("ready"), 5*time.Second)
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned before output: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	term.WriteString("server ready\r\n")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitFor_WokenByResizeAndLoadState(t *testing.T) {
+	term := New(WithSize(5, 20))
+	term.WriteString("saved")
+	var state bytes.Buffer
+	if err := term.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	term.WriteString("\x1b[2J")
+
+	resized := WaitFunc(func(t *Terminal) bool { return t.Cols() == 30 })
+	done := waitAsync(term, resized, 5*time.Second)
+	time.Sleep(20 * time.Millisecond)
+	term.Resize(5, 30)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	done = waitAsync(term, WaitText("saved"), 5*time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if err := term.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitFor_AlreadyMet(t *testing.T) {
+	term := New(WithSize(5, 20))
+	term.WriteString("\x1b]2;build: ok\x07hello")
+
+	ctx := context.Background()
+	for _, cond := range []WaitCondition{
+		WaitText("hello"),
+		WaitRegexp(regexp.MustCompile(`h.llo`)),
+		WaitCursorAt(0, 5),
+		WaitAltScreen(false),
+		WaitTitle(regexp.MustCompile(`ok$`)),
+		WaitAll(WaitText("hello"), WaitCursorAt(0, 5)),
+	} {
+		if err := term.WaitFor(ctx, cond); err != nil {
+			t.Errorf("%v: %v", cond, err)
+		}
+	}
+}
+
+func TestWaitFor_ContextCancel(t *testing.T) {
+	term := New(WithSize(5, 20))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := term.WaitFor(ctx, WaitText("never"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if err.Error() != `wait for text "never": context deadline exceeded` {
+		t.Errorf("err = %q", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = term.WaitFor(ctx, WaitFunc(func(*Terminal) bool { return false }))
+	if err.Error() != `wait for custom condition: context deadline exceeded` {
+		t.Errorf("err = %q", err)
+	}
+}
+
+func TestWaitFor_Scrollback(t *testing.T) {
+	term := New(WithSize(2, 20), WithScrollback(NewMemoryScrollback(100)))
+	term.WriteString("marker\r\nline\r\nline\r\nline")
+
+	if err := term.WaitFor(context.Background(), WaitScrollbackText("marker")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := term.WaitFor(ctx, WaitText("marker")); err == nil {
+		t.Error("WaitText should only search the screen")
+	}
+	if err := term.WaitFor(context.Background(), WaitScrollbackRegexp(regexp.MustCompile(`^mark`))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitFor_Stable(t *testing.T) {
+	term := New(WithSize(5, 20))
+	start := time.Now()
+	done := waitAsync(term, WaitStable(50*time.Millisecond), 5*time.Second)
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(20 * time.Millisecond)
+		term.WriteString("x")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 110*time.Millisecond {
+		t.Errorf("returned after %v, before output settled", elapsed)
+	}
+}
+
+func TestWaitFor_AltScreen(t *testing.T) {
+	term := New(WithSize(5, 20))
+	done := waitAsync(term, WaitAltScreen(true), 5*time.Second)
+	term.WriteString("\x1b[?1049h")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitFor_PromptMarks(t *testing.T) {
+	term := New(WithSize(5, 20))
+	term.WriteString("\x1b]133;A\x07$ ")
+
+	// Marks recorded before WaitFor starts do not count
+	done := waitAsync(term, WaitPromptMark(ansicode.PromptStart), 5*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	term.WriteString("\x1b]133;B\x07ls\r\n\x1b]133;C\x07out\r\n")
+	select {
+	case err := <-done:
+		t.Fatalf("returned on an earlier mark: %v", err)
+	default:
+	}
+	exit2 := waitAsync(term, WaitCommandExit(2), 5*time.Second)
+	exit0 := waitAsync(term, WaitCommandExit(0), 50*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	term.WriteString("\x1b]133;D;2\x07\x1b]133;A\x07$ ")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-exit2; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-exit0; err == nil {
+		t.Error("exit code 0 should not match")
+	}
+
+	// A command that already finished does not count
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := term.WaitFor(ctx, WaitCommandFinished()); err == nil {
+		t.Error("an already finished command should not match")
+	}
+
+	// A command that is running when waiting starts counts once it finishes
+	term.WriteString("\x1b]133;C\x07")
+	done = waitAsync(term, WaitCommandFinished(), 5*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	term.WriteString("\x1b]133;D;0\x07")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitFor_Any(t *testing.T) {
+	term := New(WithSize(5, 20))
+	done := waitAsync(term, WaitAny(WaitText("error"), WaitText("done")), 5*time.Second)
+	term.WriteString("done")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}